@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -14,12 +16,17 @@ import (
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/kernel"
+	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/mixin/rpc"
 	"github.com/MixinNetwork/mixin/storage"
 	"github.com/urfave/cli/v2"
 )
 
 func createAddressCmd(c *cli.Context) error {
+	if path := c.String("path"); len(path) > 0 {
+		return deriveAddressCmd(c, path)
+	}
 	for {
 		seed := make([]byte, 64)
 		crypto.ReadRand(seed)
@@ -56,6 +63,276 @@ func createAddressCmd(c *cli.Context) error {
 	}
 }
 
+// deriveAddressCmd derives the address from the master seed, or from the
+// extended public key and the private view key for a watch-only wallet
+func deriveAddressCmd(c *cli.Context, path string) error {
+	var master *crypto.ExtendedKey
+	var view crypto.Key
+	if xpub := c.String("xpub"); len(xpub) > 0 {
+		key, err := crypto.ExtendedKeyFromString(xpub)
+		if err != nil {
+			return err
+		}
+		master = key
+	} else {
+		seed := make([]byte, 64)
+		if s := c.String("seed"); len(s) > 0 {
+			b, err := hex.DecodeString(s)
+			if err != nil {
+				return err
+			}
+			if len(b) != len(seed) {
+				return fmt.Errorf("invalid seed size %d", len(b))
+			}
+			copy(seed, b)
+		} else {
+			crypto.ReadRand(seed)
+			fmt.Printf("seed:\t\t%s\n", hex.EncodeToString(seed))
+		}
+		master = crypto.NewExtendedKeyFromSeed(seed)
+		view = common.NewAddressFromSeed(seed).PrivateViewKey
+	}
+	if v := c.String("view"); len(v) > 0 {
+		key, err := crypto.KeyFromString(v)
+		if err != nil {
+			return err
+		}
+		view = key
+	} else if !master.Private && !c.Bool("public") {
+		return fmt.Errorf("the private view key required for the extended public key")
+	}
+
+	addr, err := common.DeriveAddress(master, view, path)
+	if err != nil {
+		return err
+	}
+	if c.Bool("public") {
+		addr.PrivateViewKey = addr.PublicSpendKey.DeterministicHashDerive()
+		addr.PublicViewKey = addr.PrivateViewKey.Public()
+	}
+	indexes, _ := crypto.ParseDerivationPath(path)
+	child, _ := master.Derive(indexes)
+	fmt.Printf("address:\t%s\n", addr.String())
+	fmt.Printf("view key:\t%s\n", addr.PrivateViewKey.String())
+	if addr.PrivateSpendKey.HasValue() {
+		fmt.Printf("spend key:\t%s\n", addr.PrivateSpendKey.String())
+	}
+	fmt.Printf("extended public key:\t%s\n", child.Public().String())
+	return nil
+}
+
+func createMnemonicCmd(c *cli.Context) error {
+	if k := c.String("key"); len(k) > 0 {
+		key, err := crypto.KeyFromString(k)
+		if err != nil {
+			return err
+		}
+		m := key.Mnemonic()
+		_, err = crypto.KeyFromMnemonic(m)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("mnemonic:\t%s\n", m)
+		fmt.Printf("public key:\t%s\n", key.Public())
+		return nil
+	}
+	words := c.Int("words")
+	entropy := make([]byte, words*4/3)
+	crypto.ReadRand(entropy)
+	m, err := crypto.NewMnemonic(entropy)
+	if err != nil {
+		return fmt.Errorf("invalid mnemonic words count %d", words)
+	}
+	seed, err := crypto.MnemonicToSeed(m, c.String("passphrase"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("mnemonic:\t%s\n", m)
+	return printMnemonicAddress(c, seed)
+}
+
+func verifyMnemonicCmd(c *cli.Context) error {
+	m, err := readMnemonic(c)
+	if err != nil {
+		return err
+	}
+	entropy, err := crypto.MnemonicToEntropy(m)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("words:\t\t%d\n", len(entropy)*3/4)
+	if c.Bool("key") {
+		key, err := crypto.KeyFromMnemonic(m)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("public key:\t%s\n", key.Public())
+	}
+	return nil
+}
+
+func restoreMnemonicCmd(c *cli.Context) error {
+	m, err := readMnemonic(c)
+	if err != nil {
+		return err
+	}
+	if c.Bool("key") {
+		key, err := crypto.KeyFromMnemonic(m)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("key:\t\t%s\n", key)
+		fmt.Printf("public key:\t%s\n", key.Public())
+		return nil
+	}
+	seed, err := crypto.MnemonicToSeed(m, c.String("passphrase"))
+	if err != nil {
+		return err
+	}
+	return printMnemonicAddress(c, seed)
+}
+
+// readMnemonic reads the words from the file if provided, to keep them out
+// of the shell history
+func readMnemonic(c *cli.Context) (string, error) {
+	if f := c.String("file"); len(f) > 0 {
+		b, err := os.ReadFile(f)
+		return string(b), err
+	}
+	if m := c.String("mnemonic"); len(m) > 0 {
+		return m, nil
+	}
+	return "", fmt.Errorf("mnemonic or file required")
+}
+
+func printMnemonicAddress(c *cli.Context, seed []byte) error {
+	addr := common.NewAddressFromSeed(seed)
+	path := c.String("path")
+	if len(path) > 0 {
+		a, err := common.NewAddressFromSeedWithPath(seed, path)
+		if err != nil {
+			return err
+		}
+		addr = a
+	}
+	if c.Bool("public") {
+		addr.PrivateViewKey = addr.PublicSpendKey.DeterministicHashDerive()
+		addr.PublicViewKey = addr.PrivateViewKey.Public()
+	}
+	fmt.Printf("address:\t%s\n", addr.String())
+	fmt.Printf("view key:\t%s\n", addr.PrivateViewKey.String())
+	fmt.Printf("spend key:\t%s\n", addr.PrivateSpendKey.String())
+	if len(path) > 0 {
+		indexes, _ := crypto.ParseDerivationPath(path)
+		child, _ := crypto.NewExtendedKeyFromSeed(seed).Derive(indexes)
+		fmt.Printf("extended public key:\t%s\n", child.Public().String())
+	}
+	return nil
+}
+
+func importKeystoreCmd(c *cli.Context) error {
+	var key crypto.Key
+	if mf := c.String("mnemonic-file"); len(mf) > 0 {
+		words, err := os.ReadFile(mf)
+		if err != nil {
+			return err
+		}
+		key, err = crypto.KeyFromMnemonic(string(words))
+		if err != nil {
+			return err
+		}
+	} else {
+		k, err := crypto.KeyFromString(c.String("key"))
+		if err != nil {
+			return err
+		}
+		key = k
+	}
+	output := c.String("output")
+	if output == "" {
+		return fmt.Errorf("keystore output file required")
+	}
+	passphrase, err := config.ReadPassphrase(c.String("passphrase-file"), config.SignerPassphraseEnv)
+	if err != nil {
+		return err
+	}
+	ks, err := crypto.NewKeystore(key, passphrase)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(ks.JSON())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("public key:\t%s\n", ks.Public)
+	return nil
+}
+
+func exportKeystoreCmd(c *cli.Context) error {
+	ks, key, err := unlockKeystore(c.String("keystore"), c.String("passphrase-file"))
+	if err != nil {
+		return err
+	}
+	if c.Bool("mnemonic") {
+		fmt.Printf("mnemonic:\t%s\n", key.Mnemonic())
+	} else {
+		fmt.Printf("key:\t\t%s\n", key)
+	}
+	fmt.Printf("public key:\t%s\n", ks.Public)
+	return nil
+}
+
+// rotateKeystoreCmd encrypts the key with the new passphrase and a new salt,
+// and replaces the keystore file atomically
+func rotateKeystoreCmd(c *cli.Context) error {
+	file := c.String("keystore")
+	_, key, err := unlockKeystore(file, c.String("passphrase-file"))
+	if err != nil {
+		return err
+	}
+	passphrase, err := config.ReadPassphrase(c.String("new-passphrase-file"), "MIXIN_SIGNER_NEW_PASSPHRASE")
+	if err != nil {
+		return err
+	}
+	ks, err := crypto.NewKeystore(key, passphrase)
+	if err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	err = os.WriteFile(tmp, ks.JSON(), 0600)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, file)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("public key:\t%s\n", ks.Public)
+	return nil
+}
+
+func unlockKeystore(file, passphraseFile string) (*crypto.Keystore, crypto.Key, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, crypto.Key{}, err
+	}
+	ks, err := crypto.KeystoreFromJSON(b)
+	if err != nil {
+		return nil, crypto.Key{}, err
+	}
+	passphrase, err := config.ReadPassphrase(passphraseFile, config.SignerPassphraseEnv)
+	if err != nil {
+		return nil, crypto.Key{}, err
+	}
+	key, err := ks.Decrypt(passphrase)
+	return ks, key, err
+}
+
 func decodeAddressCmd(c *cli.Context) error {
 	addr, err := common.NewAddressFromString(c.String("address"))
 	if err != nil {
@@ -186,6 +463,78 @@ func validateGraphEntries(c *cli.Context) error {
 	return nil
 }
 
+func exportStateCmd(c *cli.Context) error {
+	custom, err := config.Initialize(c.String("dir") + "/config.toml")
+	if err != nil {
+		return err
+	}
+	gns, err := common.ReadGenesis(c.String("dir") + "/genesis.json")
+	if err != nil {
+		return err
+	}
+	store, err := storage.NewBadgerStore(custom, c.String("dir"))
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	header, entries, err := store.ExportState(c.String("file"), gns.NetworkId(), c.Uint64("rounds"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("exported %d entries at topology %d snapshot %s\n", entries, header.Topology, header.Snapshot)
+	return nil
+}
+
+func importStateCmd(c *cli.Context) error {
+	custom, err := config.Initialize(c.String("dir") + "/config.toml")
+	if err != nil {
+		return err
+	}
+	gns, err := common.ReadGenesis(c.String("dir") + "/genesis.json")
+	if err != nil {
+		return err
+	}
+	cache, err := newCache(custom)
+	if err != nil {
+		return err
+	}
+	store, err := storage.NewBadgerStore(custom, c.String("dir"))
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	anchor, err := crypto.HashFromString(c.String("snapshot"))
+	if err != nil {
+		return fmt.Errorf("invalid trusted anchor snapshot %s: %v", c.String("snapshot"), err)
+	}
+
+	var total int
+	staging := c.String("dir") + "/staging"
+	header, entries, err := store.ImportState(c.String("file"), gns.NetworkId(), staging, func(stage *storage.BadgerStore, header *storage.StateHeader) error {
+		err := kernel.VerifyImportedState(custom, stage, cache, gns, header, anchor)
+		if err != nil {
+			return fmt.Errorf("state verification failed: %v", err)
+		}
+		all, invalid, err := stage.ValidateGraphEntries(gns.NetworkId(), header.Rounds)
+		if err != nil {
+			return err
+		}
+		if invalid > 0 {
+			return fmt.Errorf("state verification failed with %d/%d invalid entries", invalid, all)
+		}
+		total = all
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("imported %d entries at topology %d snapshot %s\n", entries, header.Topology, header.Snapshot)
+	fmt.Printf("verified %d snapshots and utxos %s\n", total, header.UTXOs)
+	return nil
+}
+
 func decodeTransactionCmd(c *cli.Context) error {
 	raw, err := hex.DecodeString(c.String("raw"))
 	if err != nil {
@@ -280,7 +629,7 @@ func buildRawTransactionCmd(c *cli.Context) error {
 	}
 
 	var raw signerInput
-	raw.Node = c.String("node")
+	raw.Node = rpcClient(c)
 	isb, _ := json.Marshal(map[string]any{"inputs": inputs})
 	_ = json.Unmarshal(isb, &raw)
 
@@ -305,19 +654,40 @@ func buildRawTransactionCmd(c *cli.Context) error {
 }
 
 func signTransactionCmd(c *cli.Context) error {
+	tx, raw, err := buildSignerTransaction(c)
+	if err != nil {
+		return err
+	}
+	accounts, err := parseSignerKeys(c.StringSlice("key"))
+	if err != nil {
+		return err
+	}
+
+	signed := tx.AsVersioned()
+	for i := range signed.Inputs {
+		err := signed.SignInput(raw, i, accounts)
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Println(hex.EncodeToString(signed.Marshal()))
+	return nil
+}
+
+func buildSignerTransaction(c *cli.Context) (*common.Transaction, signerInput, error) {
 	var raw signerInput
 	err := json.Unmarshal([]byte(c.String("raw")), &raw)
 	if err != nil {
-		return err
+		return nil, raw, err
 	}
-	if raw.Version != common.TxVersionHashSignature {
-		return fmt.Errorf("invalid version number %d", raw.Version)
+	if raw.Version != common.TxVersionHashSignature && raw.Version != common.TxVersionExtendedScript {
+		return nil, raw, fmt.Errorf("invalid version number %d", raw.Version)
 	}
-	raw.Node = c.String("node")
+	raw.Node = rpcClient(c)
 
 	seed, err := hex.DecodeString(c.String("seed"))
 	if err != nil {
-		return err
+		return nil, raw, err
 	}
 	if len(seed) != 64 {
 		seed = make([]byte, 64)
@@ -325,6 +695,7 @@ func signTransactionCmd(c *cli.Context) error {
 	}
 
 	tx := common.NewTransactionV5(raw.Asset)
+	tx.Version = raw.Version
 	for _, in := range raw.Inputs {
 		if d := in.Deposit; d != nil {
 			tx.AddDepositInput(&common.DepositData{
@@ -337,6 +708,14 @@ func signTransactionCmd(c *cli.Context) error {
 		} else {
 			tx.AddInput(in.Hash, in.Index)
 		}
+		data, err := hex.DecodeString(in.Data)
+		if err != nil {
+			return nil, raw, err
+		}
+		if len(data) > 0 {
+			tx.Inputs[len(tx.Inputs)-1].Data = data
+		}
+		tx.Inputs[len(tx.Inputs)-1].Branch = in.Branch
 	}
 
 	for _, out := range raw.Outputs {
@@ -357,45 +736,166 @@ func signTransactionCmd(c *cli.Context) error {
 
 	extra, err := hex.DecodeString(raw.Extra)
 	if err != nil {
-		return err
+		return nil, raw, err
 	}
 	tx.Extra = extra
+	return tx, raw, nil
+}
 
-	keys := c.StringSlice("key")
+func parseSignerKeys(keys []string) ([]*common.Address, error) {
 	var accounts []*common.Address
 	for _, s := range keys {
 		key, err := hex.DecodeString(s)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if len(key) != 64 {
-			return fmt.Errorf("invalid key length %d", len(key))
+			return nil, fmt.Errorf("invalid key length %d", len(key))
 		}
 		var account common.Address
 		copy(account.PrivateViewKey[:], key[:32])
 		copy(account.PrivateSpendKey[:], key[32:])
 		accounts = append(accounts, &account)
 	}
+	return accounts, nil
+}
 
-	signed := tx.AsVersioned()
-	for i := range signed.Inputs {
-		err := signed.SignInput(raw, i, accounts)
+func createPSTCmd(c *cli.Context) error {
+	tx, raw, err := buildSignerTransaction(c)
+	if err != nil {
+		return err
+	}
+	pst, err := common.NewPartiallySignedTransaction(tx, raw)
+	if err != nil {
+		return err
+	}
+	return writePSTFile(c.String("file"), pst)
+}
+
+func decodePSTCmd(c *cli.Context) error {
+	pst, err := readPSTFile(c.String("file"))
+	if err != nil {
+		return err
+	}
+	inputs := make([]map[string]any, len(pst.Inputs))
+	for i, in := range pst.Inputs {
+		var signers []uint16
+		for k := range in.Signatures {
+			signers = append(signers, k)
+		}
+		slices.Sort(signers)
+		missing, err := pst.Missing(i)
 		if err != nil {
 			return err
 		}
+		input := map[string]any{
+			"signers": signers,
+			"missing": missing,
+		}
+		if u := in.UTXO; u != nil {
+			input["keys"] = u.Keys
+			input["mask"] = u.Mask
+			input["script"] = u.Script
+		}
+		inputs[i] = input
+	}
+	return printJSON(map[string]any{
+		"version":     pst.Version,
+		"hash":        pst.Transaction.PayloadHash(),
+		"transaction": transactionToMap(pst.Transaction),
+		"inputs":      inputs,
+		"aggregated":  pst.AggregatedSignature != nil,
+	})
+}
+
+func signPSTCmd(c *cli.Context) error {
+	pst, err := readPSTFile(c.String("file"))
+	if err != nil {
+		return err
 	}
-	fmt.Println(hex.EncodeToString(signed.Marshal()))
+	accounts, err := parseSignerKeys(c.StringSlice("key"))
+	if err != nil {
+		return err
+	}
+	if c.Bool("aggregate") {
+		seed := make([]byte, 64)
+		crypto.ReadRand(seed)
+		err = pst.AggregateSign(accounts, seed)
+	} else {
+		err = pst.Sign(accounts)
+	}
+	if err != nil {
+		return err
+	}
+	return writePSTFile(c.String("file"), pst)
+}
+
+func combinePSTCmd(c *cli.Context) error {
+	var pst *common.PartiallySignedTransaction
+	for _, path := range c.StringSlice("input") {
+		other, err := readPSTFile(path)
+		if err != nil {
+			return err
+		}
+		if pst == nil {
+			pst = other
+			continue
+		}
+		err = pst.Combine(other)
+		if err != nil {
+			return fmt.Errorf("combine %s: %v", path, err)
+		}
+	}
+	if pst == nil {
+		return fmt.Errorf("no partially signed transaction to combine")
+	}
+	return writePSTFile(c.String("file"), pst)
+}
+
+func finalizePSTCmd(c *cli.Context) error {
+	pst, err := readPSTFile(c.String("file"))
+	if err != nil {
+		return err
+	}
+	ver, err := pst.Finalize()
+	if err != nil {
+		return err
+	}
+	fmt.Println(hex.EncodeToString(ver.Marshal()))
 	return nil
 }
 
+func readPSTFile(path string) (*common.PartiallySignedTransaction, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	b, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, err
+	}
+	return common.UnmarshalPartiallySignedTransaction(b)
+}
+
+func writePSTFile(path string, pst *common.PartiallySignedTransaction) error {
+	data := hex.EncodeToString(pst.Marshal()) + "\n"
+	return os.WriteFile(path, []byte(data), 0600)
+}
+
 func sendTransactionCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "sendrawtransaction", []any{
-		c.String("raw"),
-	}, c.Bool("time"))
-	if err == nil {
-		fmt.Println(string(data))
+	hash, err := rpcClient(c).SendRawTransaction(context.Background(), c.String("raw"))
+	if err != nil {
+		return err
 	}
-	return err
+	return printJSON(map[string]any{"hash": hash})
+}
+
+func simulateTransactionCmd(c *cli.Context) error {
+	sim, err := rpcClient(c).SimulateTransaction(context.Background(), c.String("raw"))
+	if err != nil {
+		return err
+	}
+	return printJSON(sim)
 }
 
 func custodianDepositCmd(c *cli.Context) error {
@@ -488,12 +988,20 @@ func pledgeNodeCmd(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
-	raw.Node = c.String("node")
-	info, err := rpc.GetInfo(raw.Node)
+	raw.Node = rpcClient(c)
+	ctx := context.Background()
+	info, err := raw.Node.GetInfo(ctx)
 	if err != nil {
 		return err
 	}
-	snap, err := rpc.GetSnapshot(raw.Node, info.Consensus.String())
+	cs, err := raw.Node.GetSnapshot(ctx, info.Consensus)
+	if err != nil {
+		return err
+	}
+	if cs == nil {
+		return fmt.Errorf("consensus snapshot %s not found", info.Consensus)
+	}
+	snap, err := cs.Decode()
 	if err != nil {
 		return err
 	}
@@ -666,204 +1174,342 @@ func encodeCustodianExtraCmd(c *cli.Context) error {
 }
 
 func getRoundLinkCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "getroundlink", []any{
-		c.String("from"),
-		c.String("to"),
-	}, c.Bool("time"))
-	if err == nil {
-		fmt.Println(string(data))
+	from, err := crypto.HashFromString(c.String("from"))
+	if err != nil {
+		return err
 	}
-	return err
+	to, err := crypto.HashFromString(c.String("to"))
+	if err != nil {
+		return err
+	}
+	link, err := rpcClient(c).GetRoundLink(context.Background(), from, to)
+	if err != nil {
+		return err
+	}
+	return printJSON(map[string]any{"link": link})
 }
 
 func getRoundByNumberCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "getroundbynumber", []any{
-		c.String("id"),
-		c.Uint64("number"),
-	}, c.Bool("time"))
-	if err == nil {
-		fmt.Println(string(data))
+	id, err := crypto.HashFromString(c.String("id"))
+	if err != nil {
+		return err
 	}
-	return err
+	round, err := rpcClient(c).GetRoundByNumber(context.Background(), id, c.Uint64("number"))
+	if err != nil {
+		return err
+	}
+	return printJSON(round)
 }
 
 func getRoundByHashCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "getroundbyhash", []any{
-		c.String("hash"),
-	}, c.Bool("time"))
-	if err == nil {
-		fmt.Println(string(data))
+	hash, err := crypto.HashFromString(c.String("hash"))
+	if err != nil {
+		return err
 	}
-	return err
+	round, err := rpcClient(c).GetRoundByHash(context.Background(), hash)
+	if err != nil {
+		return err
+	}
+	return printJSON(round)
 }
 
 func listSnapshotsCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "listsnapshots", []any{
-		c.Uint64("since"),
-		c.Uint64("count"),
-		c.Bool("sig"),
-		c.Bool("tx"),
-	}, c.Bool("time"))
-	if err == nil {
-		fmt.Println(string(data))
+	snapshots, err := rpcClient(c).ListSnapshots(context.Background(),
+		c.Uint64("since"), c.Uint64("count"), c.Bool("sig"), c.Bool("tx"))
+	if err != nil {
+		return err
 	}
-	return err
+	return printJSON(snapshots)
 }
 
 func getSnapshotCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "getsnapshot", []any{
-		c.String("hash"),
-	}, c.Bool("time"))
-	if err == nil {
-		fmt.Println(string(data))
+	hash, err := crypto.HashFromString(c.String("hash"))
+	if err != nil {
+		return err
 	}
-	return err
+	snap, err := rpcClient(c).GetSnapshot(context.Background(), hash)
+	if err != nil {
+		return err
+	}
+	return printJSON(snap)
 }
 
 func getTransactionCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "gettransaction", []any{
-		c.String("hash"),
-	}, c.Bool("time"))
-	if err == nil {
-		fmt.Println(string(data))
+	hash, err := crypto.HashFromString(c.String("hash"))
+	if err != nil {
+		return err
 	}
-	return err
+	tx, snap, err := rpcClient(c).GetTransaction(context.Background(), hash)
+	if err != nil {
+		return err
+	}
+	return printTransaction(tx, snap)
 }
 
 func getCacheTransactionCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "getcachetransaction", []any{
-		c.String("hash"),
-	}, c.Bool("time"))
-	if err == nil {
-		fmt.Println(string(data))
+	hash, err := crypto.HashFromString(c.String("hash"))
+	if err != nil {
+		return err
 	}
-	return err
+	tx, err := rpcClient(c).GetCacheTransaction(context.Background(), hash)
+	if err != nil {
+		return err
+	}
+	return printTransaction(tx, "")
+}
+
+func listCacheTransactionsCmd(c *cli.Context) error {
+	var offset, asset crypto.Hash
+	if o := c.String("offset"); o != "" {
+		h, err := crypto.HashFromString(o)
+		if err != nil {
+			return err
+		}
+		offset = h
+	}
+	if a := c.String("asset"); a != "" {
+		h, err := crypto.HashFromString(a)
+		if err != nil {
+			return err
+		}
+		asset = h
+	}
+	txs, err := rpcClient(c).ListCacheTransactions(context.Background(),
+		offset, c.Uint64("count"), asset, c.Int("type"), c.Uint64("age"))
+	if err != nil {
+		return err
+	}
+	return printJSON(txs)
+}
+
+func getCacheStatsCmd(c *cli.Context) error {
+	stats, err := rpcClient(c).GetCacheStats(context.Background())
+	if err != nil {
+		return err
+	}
+	return printJSON(stats)
+}
+
+func diagnoseCacheTransactionCmd(c *cli.Context) error {
+	hash, err := crypto.HashFromString(c.String("hash"))
+	if err != nil {
+		return err
+	}
+	tx, err := rpcClient(c).DiagnoseCacheTransaction(context.Background(), hash)
+	if err != nil {
+		return err
+	}
+	return printJSON(tx)
+}
+
+func evictCacheTransactionCmd(c *cli.Context) error {
+	hash, err := crypto.HashFromString(c.String("hash"))
+	if err != nil {
+		return err
+	}
+	evicted, err := rpcClient(c).EvictCacheTransaction(context.Background(), hash)
+	if err != nil {
+		return err
+	}
+	return printJSON(map[string]any{"hash": hash, "evicted": evicted})
 }
 
 func getDepositTransactionCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "getdeposittransaction", []any{
-		c.String("chain"),
-		c.String("hash"),
-		c.Int("index"),
-	}, c.Bool("time"))
-	if err == nil {
-		fmt.Println(string(data))
+	chain, err := crypto.HashFromString(c.String("chain"))
+	if err != nil {
+		return err
 	}
-	return err
+	tx, snap, err := rpcClient(c).GetDepositTransaction(context.Background(),
+		chain, c.String("hash"), c.Uint64("index"))
+	if err != nil {
+		return err
+	}
+	return printTransaction(tx, snap)
 }
 
 func getWithdrawalClaimCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "getwithdrawalclaim", []any{
-		c.String("hash"),
-	}, c.Bool("time"))
-	if err == nil {
-		fmt.Println(string(data))
+	hash, err := crypto.HashFromString(c.String("hash"))
+	if err != nil {
+		return err
 	}
-	return err
+	tx, snap, err := rpcClient(c).GetWithdrawalClaim(context.Background(), hash)
+	if err != nil {
+		return err
+	}
+	return printTransaction(tx, snap)
 }
 
 func getUTXOCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "getutxo", []any{
-		c.String("hash"),
-		c.Uint64("index"),
-	}, c.Bool("time"))
-	if err == nil {
-		fmt.Println(string(data))
+	hash, err := crypto.HashFromString(c.String("hash"))
+	if err != nil {
+		return err
 	}
-	return err
+	utxo, err := rpcClient(c).GetUTXO(context.Background(), hash, uint(c.Uint64("index")))
+	if err != nil || utxo == nil {
+		return err
+	}
+	output := map[string]any{
+		"type":   utxo.Type,
+		"hash":   utxo.Hash,
+		"index":  utxo.Index,
+		"amount": utxo.Amount,
+	}
+	if len(utxo.Keys) > 0 {
+		output["keys"] = utxo.Keys
+	}
+	if len(utxo.Script) > 0 {
+		output["script"] = utxo.Script
+	}
+	if utxo.Mask.HasValue() {
+		output["mask"] = utxo.Mask
+	}
+	if utxo.LockHash.HasValue() {
+		output["lock"] = utxo.LockHash
+	}
+	return printJSON(output)
 }
 
 func getKeyCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "getkey", []any{
-		c.String("key"),
-	}, c.Bool("time"))
-	if err == nil {
-		fmt.Println(string(data))
+	key, err := crypto.KeyFromString(c.String("key"))
+	if err != nil {
+		return err
 	}
-	return err
+	tx, err := rpcClient(c).GetKey(context.Background(), key)
+	if err != nil {
+		return err
+	}
+	return printJSON(map[string]any{"transaction": tx})
 }
 
 func getAssetCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "getasset", []any{
-		c.String("id"),
-	}, c.Bool("time"))
-	if err == nil {
-		fmt.Println(string(data))
+	id, err := crypto.HashFromString(c.String("id"))
+	if err != nil {
+		return err
 	}
-	return err
+	asset, err := rpcClient(c).GetAsset(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	return printJSON(asset)
+}
+
+func listOutputsCmd(c *cli.Context) error {
+	addr, err := common.NewAddressFromString(c.String("address"))
+	if err != nil {
+		return err
+	}
+	outputs, err := rpcClient(c).ListOutputs(context.Background(),
+		addr, c.Uint64("since"), c.Uint64("count"))
+	if err != nil {
+		return err
+	}
+	return printJSON(outputs)
+}
+
+func getBalanceCmd(c *cli.Context) error {
+	addr, err := common.NewAddressFromString(c.String("address"))
+	if err != nil {
+		return err
+	}
+	asset, err := crypto.HashFromString(c.String("asset"))
+	if err != nil {
+		return err
+	}
+	balance, err := rpcClient(c).GetBalance(context.Background(), addr, asset)
+	if err != nil {
+		return err
+	}
+	return printJSON(balance)
 }
 
 func listCustodianUpdatesCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "listcustodianupdates", []any{}, c.Bool("time"))
-	if err == nil {
-		fmt.Println(string(data))
+	updates, err := rpcClient(c).ListCustodianUpdates(context.Background())
+	if err != nil {
+		return err
 	}
-	return err
+	return printJSON(updates)
 }
 
 func listMintWorksCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "listmintworks", []any{
-		c.Uint64("since"),
-	}, c.Bool("time"))
-	if err == nil {
-		fmt.Println(string(data))
+	works, err := rpcClient(c).ListMintWorks(context.Background(), c.Uint64("since"))
+	if err != nil {
+		return err
 	}
-	return err
+	return printJSON(works)
 }
 
 func listMintDistributionsCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "listmintdistributions", []any{
-		c.Uint64("since"),
-		c.Uint64("count"),
-		c.Bool("tx"),
-	}, c.Bool("time"))
-	if err == nil {
-		fmt.Println(string(data))
+	mds, err := rpcClient(c).ListMintDistributions(context.Background(),
+		c.Uint64("since"), c.Uint64("count"), c.Bool("tx"))
+	if err != nil {
+		return err
 	}
-	return err
+	return printJSON(mds)
 }
 
 func listAllNodesCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "listallnodes", []any{
-		c.Uint64("threshold"),
-		c.Bool("state"),
-	}, c.Bool("time"))
-	if err == nil {
-		fmt.Println(string(data))
+	nodes, err := rpcClient(c).ListAllNodes(context.Background(),
+		c.Uint64("threshold"), c.Bool("state"))
+	if err != nil {
+		return err
 	}
-	return err
+	return printJSON(nodes)
 }
 
 func getInfoCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "getinfo", []any{}, c.Bool("time"))
-	if err == nil {
-		fmt.Println(string(data))
+	info, err := rpcClient(c).GetInfo(context.Background())
+	if err != nil {
+		return err
 	}
-	return err
+	return printJSON(info)
 }
 
 func listPeersCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "listpeers", []any{}, c.Bool("time"))
-	if err == nil {
-		fmt.Println(string(data))
+	peers, err := rpcClient(c).ListPeers(context.Background())
+	if err != nil {
+		return err
 	}
-	return err
+	return printJSON(peers)
 }
 
 func listRelayersCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "listrelayers", []any{c.String("id")}, c.Bool("time"))
-	if err == nil {
-		fmt.Println(string(data))
+	id, err := crypto.HashFromString(c.String("id"))
+	if err != nil {
+		return err
 	}
-	return err
+	peers, err := rpcClient(c).ListRelayers(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	return printJSON(peers)
+}
+
+func getLogLevelsCmd(c *cli.Context) error {
+	levels, err := rpcClient(c).GetLogLevels(context.Background())
+	if err != nil {
+		return err
+	}
+	return printJSON(levels)
+}
+
+func setLogLevelCmd(c *cli.Context) error {
+	level, err := logger.ParseLevel(c.String("level"))
+	if err != nil {
+		return err
+	}
+	levels, err := rpcClient(c).SetLogLevel(context.Background(), c.String("subsystem"), level)
+	if err != nil {
+		return err
+	}
+	return printJSON(levels)
 }
 
 func dumpGraphHeadCmd(c *cli.Context) error {
-	data, err := callRPC(c.String("node"), "dumpgraphhead", []any{}, c.Bool("time"))
-	if err == nil {
-		fmt.Println(string(data))
+	points, err := rpcClient(c).DumpGraphHead(context.Background())
+	if err != nil {
+		return err
 	}
-	return err
+	return printJSON(points)
 }
 
 func setupTestNetCmd(c *cli.Context) error {
@@ -958,8 +1604,36 @@ object-server = true
 	return nil
 }
 
-func callRPC(node, method string, params []any, _ bool) ([]byte, error) {
-	return rpc.CallMixinRPC(node, method, params)
+func rpcClient(c *cli.Context) *rpc.Client {
+	var endpoints []string
+	for _, node := range strings.Split(c.String("node"), ",") {
+		node = strings.TrimSpace(node)
+		if node != "" {
+			endpoints = append(endpoints, node)
+		}
+	}
+	return rpc.NewClient(endpoints...)
+}
+
+func printJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func printTransaction(tx *common.VersionedTransaction, snap string) error {
+	if tx == nil {
+		return printJSON(nil)
+	}
+	data := transactionToMap(tx)
+	data["hex"] = hex.EncodeToString(tx.Marshal())
+	if snap != "" {
+		data["snapshot"] = snap
+	}
+	return printJSON(data)
 }
 
 type signerInput struct {
@@ -974,8 +1648,11 @@ type signerInput struct {
 			OutputIndex     uint64         `json:"index"`
 			Amount          common.Integer `json:"amount"`
 		} `json:"deposit,omitempty"`
-		Keys []*crypto.Key `json:"keys"`
-		Mask crypto.Key    `json:"mask"`
+		Branch uint8         `json:"branch,omitempty"`
+		Data   string        `json:"data,omitempty"`
+		Keys   []*crypto.Key `json:"keys"`
+		Mask   crypto.Key    `json:"mask"`
+		Script common.Script `json:"script,omitempty"`
 	} `json:"inputs"`
 	Outputs []struct {
 		Type     uint8             `json:"type"`
@@ -987,7 +1664,7 @@ type signerInput struct {
 	}
 	Asset crypto.Hash `json:"asset"`
 	Extra string      `json:"extra"`
-	Node  string      `json:"-"`
+	Node  *rpc.Client `json:"-"`
 }
 
 func (raw signerInput) ReadUTXOKeys(hash crypto.Hash, index uint) (*common.UTXOKeys, error) {
@@ -997,24 +1674,21 @@ func (raw signerInput) ReadUTXOKeys(hash crypto.Hash, index uint) (*common.UTXOK
 		if in.Hash == hash && in.Index == index && len(in.Keys) > 0 {
 			utxo.Keys = in.Keys
 			utxo.Mask = in.Mask
+			utxo.Script = in.Script
 			return utxo, nil
 		}
 	}
 
-	data, err := callRPC(raw.Node, "getutxo", []any{hash.String(), index}, false)
+	out, err := raw.Node.GetUTXO(context.Background(), hash, index)
 	if err != nil {
 		return nil, err
 	}
-	var out common.UTXOWithLock
-	err = json.Unmarshal(data, &out)
-	if err != nil {
-		return nil, err
-	}
-	if out.Amount.Sign() == 0 {
+	if out == nil || out.Amount.Sign() == 0 {
 		return nil, fmt.Errorf("invalid input %s#%d", hash.String(), index)
 	}
 	utxo.Keys = out.Keys
 	utxo.Mask = out.Mask
+	utxo.Script = out.Script
 	return utxo, nil
 }
 
@@ -1026,10 +1700,17 @@ func transactionToMap(tx *common.VersionedTransaction) map[string]any {
 	var inputs []map[string]any
 	for _, in := range tx.Inputs {
 		if in.Hash.HasValue() {
-			inputs = append(inputs, map[string]any{
+			input := map[string]any{
 				"hash":  in.Hash,
 				"index": in.Index,
-			})
+			}
+			if in.Branch > 0 {
+				input["branch"] = in.Branch
+			}
+			if len(in.Data) > 0 {
+				input["data"] = hex.EncodeToString(in.Data)
+			}
+			inputs = append(inputs, input)
 		} else if len(in.Genesis) > 0 {
 			inputs = append(inputs, map[string]any{
 				"genesis": hex.EncodeToString(in.Genesis),
@@ -34,6 +34,36 @@ func NewAddressFromSeed(seed []byte) Address {
 	}
 }
 
+// DeriveAddress derives the spend key of the path from the master key, and all
+// the derived addresses share the view key, so one private view key scans
+// them all. The private spend key is only derived from a private master key.
+func DeriveAddress(master *crypto.ExtendedKey, view crypto.Key, path string) (Address, error) {
+	var a Address
+	indexes, err := crypto.ParseDerivationPath(path)
+	if err != nil {
+		return a, err
+	}
+	child, err := master.Derive(indexes)
+	if err != nil {
+		return a, err
+	}
+	a.PrivateViewKey = view
+	a.PublicViewKey = view.Public()
+	a.PublicSpendKey = child.PublicKey()
+	if child.Private {
+		a.PrivateSpendKey = child.Key
+	}
+	return a, nil
+}
+
+// NewAddressFromSeedWithPath derives the address of the path from the master
+// key of the seed, with the view key of the address from the same seed
+func NewAddressFromSeedWithPath(seed []byte, path string) (Address, error) {
+	master := crypto.NewExtendedKeyFromSeed(seed)
+	view := NewAddressFromSeed(seed).PrivateViewKey
+	return DeriveAddress(master, view, path)
+}
+
 func NewAddressFromString(s string) (Address, error) {
 	var a Address
 	if !strings.HasPrefix(s, MainAddressPrefix) {
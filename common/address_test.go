@@ -3,6 +3,7 @@ package common
 import (
 	"testing"
 
+	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/stretchr/testify/require"
 )
 
@@ -51,3 +52,33 @@ func TestAddress(t *testing.T) {
 	err = a.UnmarshalJSON([]byte("\"\""))
 	require.NotNil(err)
 }
+
+func TestDeriveAddress(t *testing.T) {
+	require := require.New(t)
+
+	seed := make([]byte, 64)
+	for i := 0; i < len(seed); i++ {
+		seed[i] = byte(i + 1)
+	}
+	a, err := NewAddressFromSeedWithPath(seed, "m/44'/2365'/0'/0/7")
+	require.Nil(err)
+	require.Equal("XINBPGMsG9gnTa9r3sLEgLvDGiH4w2QtEKfPFTAwhyy8nUKFsnACQUaMsHbz2ZJa6NZct4bEt4ZdZvuRT5LxtqvRh4G1Ao3p", a.String())
+	require.Equal("73a3a769fbd066cb89fd6b0b35f9775c2df179d5180765cbbee932d1b60c3800", a.PrivateSpendKey.String())
+	require.Equal("4f5ec293046df6913397531a00710a59f26eb1890cc7715e26d7e199809e620b", a.PublicSpendKey.String())
+	require.Equal(NewAddressFromSeed(seed).PrivateViewKey, a.PrivateViewKey)
+
+	master := crypto.NewExtendedKeyFromSeed(seed)
+	path, err := crypto.ParseDerivationPath("m/44'/2365'/0'")
+	require.Nil(err)
+	account, err := master.Derive(path)
+	require.Nil(err)
+	w, err := DeriveAddress(account.Public(), a.PrivateViewKey, "m/0/7")
+	require.Nil(err)
+	require.Equal(a.String(), w.String())
+	require.False(w.PrivateSpendKey.HasValue())
+
+	_, err = DeriveAddress(account.Public(), a.PrivateViewKey, "m/0'/7")
+	require.ErrorContains(err, "hardened child 0 from public key")
+	_, err = NewAddressFromSeedWithPath(seed, "44'/0")
+	require.ErrorContains(err, "invalid derivation path")
+}
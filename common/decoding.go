@@ -127,6 +127,9 @@ func (dec *Decoder) DecodeTransaction() (*SignedTransaction, error) {
 		if err != nil {
 			return nil, err
 		}
+		if version < TxVersionExtendedScript && (in.Branch > 0 || len(in.Data) > 0) {
+			return nil, fmt.Errorf("invalid input data for version %d", version)
+		}
 		tx.Inputs = append(tx.Inputs, in)
 	}
 
@@ -259,6 +262,18 @@ func (dec *Decoder) ReadInput() (*Input, error) {
 		in.Deposit = d
 	}
 
+	branch, err := dec.ReadInputBranch()
+	if err != nil {
+		return nil, err
+	}
+	in.Branch = branch
+
+	data, err := dec.ReadInputData()
+	if err != nil {
+		return nil, err
+	}
+	in.Data = data
+
 	hm, err := dec.ReadMagic()
 	if err != nil {
 		return nil, err
@@ -475,6 +490,49 @@ func (dec *Decoder) ReadMagic() (bool, error) {
 	return false, fmt.Errorf("malformed %v", b)
 }
 
+func (dec *Decoder) ReadInputBranch() (uint8, error) {
+	found, err := dec.readInputMarker(inputBranch)
+	if err != nil || !found {
+		return 0, err
+	}
+	b, err := dec.ReadUint16()
+	if err != nil {
+		return 0, err
+	}
+	if b == 0 || b >= ScriptBranchMax {
+		return 0, fmt.Errorf("invalid input branch %d", b)
+	}
+	return uint8(b), nil
+}
+
+func (dec *Decoder) ReadInputData() ([]byte, error) {
+	found, err := dec.readInputMarker(inputData)
+	if err != nil || !found {
+		return nil, err
+	}
+	data, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 || len(data) > InputDataSizeLimit {
+		return nil, fmt.Errorf("invalid input data size %d", len(data))
+	}
+	return data, nil
+}
+
+func (dec *Decoder) readInputMarker(marker []byte) (bool, error) {
+	var b [2]byte
+	err := dec.Read(b[:])
+	if err != nil {
+		return false, err
+	}
+	if bytes.Equal(marker, b[:]) {
+		return true, nil
+	}
+	_, err = dec.buf.Seek(-2, io.SeekCurrent)
+	return false, err
+}
+
 func (dec *Decoder) ReadRoundReferences() (*RoundLink, error) {
 	rc, err := dec.ReadInt()
 	if err != nil || rc == 0 {
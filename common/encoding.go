@@ -20,6 +20,12 @@ const (
 var (
 	magic = []byte{0x77, 0x77}
 	null  = []byte{0x00, 0x00}
+
+	// the input branch and data are prefixed with these before the mint
+	// flag, so the encoding of the inputs without them is not changed, and
+	// they are only allowed since TxVersionExtendedScript
+	inputBranch = []byte{0x77, 0x62}
+	inputData   = []byte{0x77, 0x64}
 )
 
 type Encoder struct {
@@ -94,6 +100,9 @@ func (enc *Encoder) EncodeTransaction(signed *SignedTransaction) []byte {
 	il := len(signed.Inputs)
 	enc.WriteInt(il)
 	for _, in := range signed.Inputs {
+		if signed.Version < TxVersionExtendedScript && (in.Branch > 0 || len(in.Data) > 0) {
+			panic(signed.Version)
+		}
 		enc.EncodeInput(in)
 	}
 
@@ -158,6 +167,23 @@ func (enc *Encoder) EncodeInput(in *Input) {
 		enc.WriteInteger(d.Amount)
 	}
 
+	if in.Branch > 0 {
+		if in.Branch >= ScriptBranchMax {
+			panic(in.Branch)
+		}
+		enc.Write(inputBranch)
+		enc.WriteUint16(uint16(in.Branch))
+	}
+
+	if len(in.Data) > 0 {
+		if len(in.Data) > InputDataSizeLimit {
+			panic(len(in.Data))
+		}
+		enc.Write(inputData)
+		enc.WriteInt(len(in.Data))
+		enc.Write(in.Data)
+	}
+
 	if m := in.Mint; m == nil {
 		enc.Write(null)
 	} else {
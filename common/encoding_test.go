@@ -1,6 +1,7 @@
 package common
 
 import (
+	"bytes"
 	"encoding/hex"
 	"testing"
 
@@ -113,6 +114,54 @@ func TestAggregatedSignatureEncoding(t *testing.T) {
 	}
 }
 
+func TestInputDataEncoding(t *testing.T) {
+	require := require.New(t)
+
+	tx := NewTransactionV6(XINAssetId)
+	tx.AddInput(crypto.Blake3Hash([]byte("input")), 1)
+	tx.Outputs = append(tx.Outputs, &Output{Type: OutputTypeScript, Amount: NewInteger(1), Script: NewThresholdScript(1)})
+	plain := tx.AsVersioned().PayloadMarshal()
+
+	tx.Inputs[0].Data = []byte("preimage")
+	ver := tx.AsVersioned()
+	raw := ver.PayloadMarshal()
+	require.Equal(len(plain)+12, len(raw))
+	require.Contains(hex.EncodeToString(raw), "00010000000077640008707265696d6167650000")
+	res, err := NewDecoder(raw).DecodeTransaction()
+	require.Nil(err)
+	require.Equal([]byte("preimage"), res.Inputs[0].Data)
+	require.Equal(ver.PayloadHash(), res.AsVersioned().PayloadHash())
+
+	res, err = NewDecoder(plain).DecodeTransaction()
+	require.Nil(err)
+	require.Nil(res.Inputs[0].Data)
+
+	legacy := append([]byte{}, raw...)
+	legacy[3] = TxVersionHashSignature
+	_, err = NewDecoder(legacy).DecodeTransaction()
+	require.ErrorContains(err, "invalid input data for version 5")
+	tx.Version = TxVersionHashSignature
+	require.Panics(func() { tx.AsVersioned().PayloadMarshal() })
+	tx.Version = TxVersionExtendedScript
+
+	tx.Inputs[0].Branch = 3
+	ver = tx.AsVersioned()
+	raw = ver.PayloadMarshal()
+	require.Contains(hex.EncodeToString(raw), "0001000000007762000377640008707265696d6167650000")
+	res, err = NewDecoder(raw).DecodeTransaction()
+	require.Nil(err)
+	require.Equal(uint8(3), res.Inputs[0].Branch)
+	require.Equal([]byte("preimage"), res.Inputs[0].Data)
+	require.Equal(ver.PayloadHash(), res.AsVersioned().PayloadHash())
+
+	empty := bytes.Replace(raw, []byte("\x00\x08preimage"), []byte{0, 0}, 1)
+	_, err = NewDecoder(empty).DecodeTransaction()
+	require.NotNil(err)
+	zero := bytes.Replace(raw, []byte{0x77, 0x62, 0, 3}, []byte{0x77, 0x62, 0, 0}, 1)
+	_, err = NewDecoder(zero).DecodeTransaction()
+	require.ErrorContains(err, "invalid input branch")
+}
+
 func TestCommonDataEncoding(t *testing.T) {
 	require := require.New(t)
 
@@ -0,0 +1,371 @@
+package common
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/util/base58"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	InscriptionOperationDeploy     = "deploy"
+	InscriptionOperationInscribe   = "inscribe"
+	InscriptionOperationDistribute = "distribute"
+	InscriptionOperationOccupy     = "occupy"
+
+	InscriptionDeploymentVersion = 1
+	InscriptionModeInstant       = 1
+	InscriptionModeDone          = 2
+
+	InscriptionValidationChecksum = "CHECKSUM:"
+	InscriptionValidationRegex    = "REGEX:"
+
+	InscriptionStateInscribed   = "inscribed"
+	InscriptionStateDistributed = "distributed"
+	InscriptionStateReleased    = "released"
+	InscriptionStateOccupied    = "occupied"
+
+	MixAddressPrefix  = "MIX"
+	MixAddressVersion = 2
+)
+
+type InscriptionTreasury struct {
+	Ratio     string `json:"ratio"`
+	Recipient string `json:"recipient"`
+}
+
+type Deployment struct {
+	Version     uint8                `json:"version"`
+	Operation   string               `json:"operation"`
+	Mode        uint8                `json:"mode"`
+	Unit        string               `json:"unit"`
+	Supply      string               `json:"supply"`
+	Symbol      string               `json:"symbol"`
+	Name        string               `json:"name"`
+	Description string               `json:"description,omitempty"`
+	Icon        string               `json:"icon"`
+	Validation  string               `json:"validation,omitempty"`
+	Treasury    *InscriptionTreasury `json:"treasury,omitempty"`
+}
+
+type Inscription struct {
+	Operation string `json:"operation"`
+	Recipient string `json:"recipient"`
+	Content   string `json:"content,omitempty"`
+}
+
+type Distribution struct {
+	Operation string `json:"operation"`
+	Sequence  uint64 `json:"sequence"`
+}
+
+type Occupation struct {
+	Operation string `json:"operation"`
+	Sequence  uint64 `json:"sequence"`
+}
+
+// InscriptionCollection is the indexed state of a deployment, the hash
+// is the deployment transaction hash, and the asset is decided by the
+// first valid distribution of the collection
+type InscriptionCollection struct {
+	Hash          crypto.Hash `json:"hash"`
+	Deployment    *Deployment `json:"deployment"`
+	Asset         crypto.Hash `json:"asset"`
+	Inscriptions  uint64      `json:"inscriptions"`
+	Distributions uint64      `json:"distributions"`
+	Timestamp     uint64      `json:"timestamp"`
+}
+
+// InscriptionItem is the indexed state of a single inscription, the owner
+// is the UTXO holding the NFT, and only valid when distributed or occupied
+type InscriptionItem struct {
+	Collection  crypto.Hash `json:"collection"`
+	Sequence    uint64      `json:"sequence"`
+	Hash        crypto.Hash `json:"hash"`
+	Recipient   string      `json:"recipient"`
+	ContentHash crypto.Hash `json:"content_hash"`
+	State       string      `json:"state"`
+	OwnerHash   crypto.Hash `json:"owner_hash"`
+	OwnerIndex  uint        `json:"owner_index"`
+	Timestamp   uint64      `json:"timestamp"`
+}
+
+func ParseInscriptionOperation(extra []byte) string {
+	if len(extra) < 2 || extra[0] != '{' {
+		return ""
+	}
+	var op struct {
+		Operation string `json:"operation"`
+	}
+	err := json.Unmarshal(extra, &op)
+	if err != nil {
+		return ""
+	}
+	return op.Operation
+}
+
+func ParseDeployment(extra []byte) (*Deployment, error) {
+	var d Deployment
+	err := json.Unmarshal(extra, &d)
+	if err != nil {
+		return nil, err
+	}
+	return &d, d.Validate()
+}
+
+func ParseInscription(extra []byte) (*Inscription, error) {
+	var i Inscription
+	err := json.Unmarshal(extra, &i)
+	if err != nil {
+		return nil, err
+	}
+	if i.Operation != InscriptionOperationInscribe {
+		return nil, fmt.Errorf("invalid inscription operation %s", i.Operation)
+	}
+	err = VerifyMixAddress(i.Recipient)
+	if err != nil {
+		return nil, err
+	}
+	if i.Content != "" {
+		_, _, err = DecodeInscriptionContent(i.Content)
+	}
+	return &i, err
+}
+
+func ParseDistribution(extra []byte) (*Distribution, error) {
+	var d Distribution
+	err := json.Unmarshal(extra, &d)
+	if err != nil {
+		return nil, err
+	}
+	if d.Operation != InscriptionOperationDistribute {
+		return nil, fmt.Errorf("invalid distribution operation %s", d.Operation)
+	}
+	return &d, nil
+}
+
+func ParseOccupation(extra []byte) (*Occupation, error) {
+	var o Occupation
+	err := json.Unmarshal(extra, &o)
+	if err != nil {
+		return nil, err
+	}
+	if o.Operation != InscriptionOperationOccupy {
+		return nil, fmt.Errorf("invalid occupation operation %s", o.Operation)
+	}
+	return &o, nil
+}
+
+func (d *Deployment) Validate() error {
+	if d.Version != InscriptionDeploymentVersion {
+		return fmt.Errorf("invalid deployment version %d", d.Version)
+	}
+	if d.Operation != InscriptionOperationDeploy {
+		return fmt.Errorf("invalid deployment operation %s", d.Operation)
+	}
+	if d.Mode != InscriptionModeInstant && d.Mode != InscriptionModeDone {
+		return fmt.Errorf("invalid deployment mode %d", d.Mode)
+	}
+
+	unit, err := parseInscriptionAmount(d.Unit)
+	if err != nil {
+		return err
+	}
+	supply, err := parseInscriptionAmount(d.Supply)
+	if err != nil {
+		return err
+	}
+	if supply.LessThan(unit) || !supply.Mod(unit).IsZero() {
+		return fmt.Errorf("invalid deployment supply %s unit %s", d.Supply, d.Unit)
+	}
+	if !supply.Div(unit).BigInt().IsUint64() {
+		return fmt.Errorf("invalid deployment supply %s unit %s", d.Supply, d.Unit)
+	}
+
+	if !isValidInscriptionText(d.Symbol) || !isValidInscriptionText(d.Name) {
+		return fmt.Errorf("invalid deployment symbol %s name %s", d.Symbol, d.Name)
+	}
+	if !utf8.ValidString(d.Description) {
+		return fmt.Errorf("invalid deployment description %s", d.Description)
+	}
+	_, mime, err := DecodeInscriptionContent(d.Icon)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(mime, "image/") {
+		return fmt.Errorf("invalid deployment icon type %s", mime)
+	}
+
+	switch {
+	case d.Validation == "":
+	case strings.HasPrefix(d.Validation, InscriptionValidationChecksum):
+		checksums, err := d.Checksums()
+		if err != nil {
+			return err
+		}
+		if uint64(len(checksums)) != d.Total() {
+			return fmt.Errorf("invalid deployment checksums count %d %d", len(checksums), d.Total())
+		}
+	case strings.HasPrefix(d.Validation, InscriptionValidationRegex):
+		_, err := d.Regexp()
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid deployment validation %s", d.Validation)
+	}
+
+	if t := d.Treasury; t != nil {
+		ratio, err := decimal.NewFromString(t.Ratio)
+		if err != nil {
+			return err
+		}
+		if ratio.Sign() <= 0 || ratio.Cmp(decimal.New(1, 0)) >= 0 {
+			return fmt.Errorf("invalid deployment treasury ratio %s", t.Ratio)
+		}
+		err = VerifyMixAddress(t.Recipient)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Total returns the number of inscriptions the collection could have,
+// the deployment must be validated before calling this
+func (d *Deployment) Total() uint64 {
+	unit := NewIntegerFromString(d.Unit)
+	return NewIntegerFromString(d.Supply).Count(unit)
+}
+
+func (d *Deployment) UnitAmount() Integer {
+	return NewIntegerFromString(d.Unit)
+}
+
+// InscriptionAmount is the amount of tokens the NFT UTXO should have in
+// the distribution, which excludes the treasury part
+func (d *Deployment) InscriptionAmount() Integer {
+	if d.Treasury == nil {
+		return d.UnitAmount()
+	}
+	unit := decimal.RequireFromString(d.Unit)
+	ratio := decimal.RequireFromString(d.Treasury.Ratio)
+	amount := unit.Sub(unit.Mul(ratio))
+	return NewIntegerFromString(amount.String())
+}
+
+func (d *Deployment) Checksums() ([]crypto.Hash, error) {
+	if !strings.HasPrefix(d.Validation, InscriptionValidationChecksum) {
+		return nil, nil
+	}
+	data := strings.TrimPrefix(d.Validation, InscriptionValidationChecksum)
+	b, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 || len(b)%32 != 0 {
+		return nil, fmt.Errorf("invalid deployment checksums size %d", len(b))
+	}
+	checksums := make([]crypto.Hash, len(b)/32)
+	filter := make(map[crypto.Hash]bool)
+	for i := range checksums {
+		copy(checksums[i][:], b[i*32:])
+		if filter[checksums[i]] {
+			return nil, fmt.Errorf("duplicated deployment checksum %s", checksums[i])
+		}
+		filter[checksums[i]] = true
+	}
+	return checksums, nil
+}
+
+func (d *Deployment) Regexp() (*regexp.Regexp, error) {
+	if !strings.HasPrefix(d.Validation, InscriptionValidationRegex) {
+		return nil, nil
+	}
+	return regexp.Compile(strings.TrimPrefix(d.Validation, InscriptionValidationRegex))
+}
+
+// DecodeInscriptionContent decodes the content in data URI scheme, the
+// data: prefix is optional, e.g. text/plain;charset=UTF-8,cedric.mao
+func DecodeInscriptionContent(uri string) ([]byte, string, error) {
+	uri = strings.TrimPrefix(uri, "data:")
+	ds := strings.SplitN(uri, ",", 2)
+	if len(ds) != 2 {
+		return nil, "", fmt.Errorf("invalid data uri %s", uri)
+	}
+	ms := strings.Split(ds[0], ";")
+	mime := strings.ToLower(ms[0])
+	if !strings.Contains(mime, "/") {
+		return nil, "", fmt.Errorf("invalid data uri type %s", ms[0])
+	}
+	if ms[len(ms)-1] != "base64" {
+		return []byte(ds[1]), mime, nil
+	}
+	b, err := base64.StdEncoding.DecodeString(ds[1])
+	if err != nil {
+		return nil, "", err
+	}
+	return b, mime, nil
+}
+
+// VerifyMixAddress checks a MIX address of either UUID or XIN members,
+// MIX | base58(version | threshold | total | members | checksum)
+func VerifyMixAddress(s string) error {
+	if !strings.HasPrefix(s, MixAddressPrefix) {
+		return fmt.Errorf("invalid mix address prefix %s", s)
+	}
+	data := base58.Decode(s[len(MixAddressPrefix):])
+	if len(data) < 3+16+4 {
+		return fmt.Errorf("invalid mix address size %s", s)
+	}
+	payload := data[:len(data)-4]
+	checksum := crypto.Sha256Hash(append([]byte(MixAddressPrefix), payload...))
+	if !bytes.Equal(checksum[:4], data[len(payload):]) {
+		return fmt.Errorf("invalid mix address checksum %s", s)
+	}
+	version, threshold, total := payload[0], payload[1], int(payload[2])
+	if version != MixAddressVersion {
+		return fmt.Errorf("invalid mix address version %d", version)
+	}
+	if threshold == 0 || int(threshold) > total || total > 64 {
+		return fmt.Errorf("invalid mix address threshold %d/%d", threshold, total)
+	}
+	members := payload[3:]
+	switch len(members) {
+	case total * 16:
+	case total * 64:
+		for i := 0; i < total; i++ {
+			var spend, view crypto.Key
+			copy(spend[:], members[i*64:])
+			copy(view[:], members[i*64+32:])
+			if !spend.CheckKey() || !view.CheckKey() {
+				return fmt.Errorf("invalid mix address member %d", i)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid mix address members %d/%d", len(members), total)
+	}
+	return nil
+}
+
+func parseInscriptionAmount(s string) (decimal.Decimal, error) {
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return d, err
+	}
+	if d.Sign() <= 0 || !d.Truncate(Precision).Equal(d) {
+		return d, fmt.Errorf("invalid inscription amount %s", s)
+	}
+	return d, nil
+}
+
+func isValidInscriptionText(s string) bool {
+	return s != "" && utf8.ValidString(s) && strings.TrimSpace(s) == s
+}
@@ -0,0 +1,111 @@
+package common
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/util/base58"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInscription(t *testing.T) {
+	require := require.New(t)
+
+	seed := make([]byte, 64)
+	for i := 0; i < len(seed); i++ {
+		seed[i] = byte(i + 1)
+	}
+	recipient := testBuildMixAddress(NewAddressFromSeed(seed))
+	require.Nil(VerifyMixAddress(recipient))
+	require.NotNil(VerifyMixAddress(recipient[:len(recipient)-1] + "1"))
+	require.NotNil(VerifyMixAddress("XIN" + recipient[3:]))
+
+	d := &Deployment{
+		Version:   1,
+		Operation: InscriptionOperationDeploy,
+		Mode:      InscriptionModeInstant,
+		Unit:      "1000000",
+		Supply:    "1000000000",
+		Symbol:    "MAO",
+		Name:      "Mixin Autonomous Organization",
+		Icon:      "data:image/webp;base64,iVBO",
+		Treasury: &InscriptionTreasury{
+			Ratio:     "0.9",
+			Recipient: recipient,
+		},
+	}
+	extra, _ := json.Marshal(d)
+	require.Equal(InscriptionOperationDeploy, ParseInscriptionOperation(extra))
+	d, err := ParseDeployment(extra)
+	require.Nil(err)
+	require.Equal(uint64(1000), d.Total())
+	require.Equal("1000000.00000000", d.UnitAmount().String())
+	require.Equal("100000.00000000", d.InscriptionAmount().String())
+
+	d.Supply = "1000000.5"
+	require.NotNil(d.Validate())
+	d.Supply = "1000000000"
+	d.Icon = "text/plain,mao"
+	require.NotNil(d.Validate())
+	d.Icon = "image/webp;base64,iVBO"
+	d.Treasury.Ratio = "1"
+	require.NotNil(d.Validate())
+	d.Treasury = nil
+	require.Nil(d.Validate())
+	require.Equal(d.UnitAmount(), d.InscriptionAmount())
+
+	d.Validation = InscriptionValidationRegex + "^[a-z]+\\.mao$"
+	require.Nil(d.Validate())
+	re, err := d.Regexp()
+	require.Nil(err)
+	require.True(re.MatchString("cedric.mao"))
+	d.Validation = InscriptionValidationRegex + "[a-z"
+	require.NotNil(d.Validate())
+
+	d.Supply = "2000000"
+	c1, c2 := crypto.Blake3Hash([]byte("one")), crypto.Blake3Hash([]byte("two"))
+	d.Validation = InscriptionValidationChecksum + base64.StdEncoding.EncodeToString(append(c1[:], c2[:]...))
+	require.Nil(d.Validate())
+	checksums, err := d.Checksums()
+	require.Nil(err)
+	require.Equal([]crypto.Hash{c1, c2}, checksums)
+	d.Validation = InscriptionValidationChecksum + base64.StdEncoding.EncodeToString(append(c1[:], c1[:]...))
+	require.NotNil(d.Validate())
+	d.Validation = InscriptionValidationChecksum + base64.StdEncoding.EncodeToString(c1[:])
+	require.NotNil(d.Validate())
+
+	extra = []byte(`{"operation":"inscribe","recipient":"` + recipient + `","content":"text/plain;charset=UTF-8;base64,Y2VkcmljLm1hbw=="}`)
+	require.Equal(InscriptionOperationInscribe, ParseInscriptionOperation(extra))
+	ins, err := ParseInscription(extra)
+	require.Nil(err)
+	data, mime, err := DecodeInscriptionContent(ins.Content)
+	require.Nil(err)
+	require.Equal("text/plain", mime)
+	require.Equal("cedric.mao", string(data))
+	_, err = ParseInscription([]byte(`{"operation":"inscribe","recipient":"` + recipient + `","content":"cedric.mao"}`))
+	require.NotNil(err)
+
+	dist, err := ParseDistribution([]byte(`{"operation":"distribute","sequence":7}`))
+	require.Nil(err)
+	require.Equal(uint64(7), dist.Sequence)
+	_, err = ParseDistribution([]byte(`{"operation":"occupy","sequence":7}`))
+	require.NotNil(err)
+	occ, err := ParseOccupation([]byte(`{"operation":"occupy","sequence":3}`))
+	require.Nil(err)
+	require.Equal(uint64(3), occ.Sequence)
+
+	require.Equal("", ParseInscriptionOperation([]byte("cedric.mao")))
+}
+
+func testBuildMixAddress(members ...Address) string {
+	payload := []byte{MixAddressVersion, 1, byte(len(members))}
+	for _, a := range members {
+		payload = append(payload, a.PublicSpendKey[:]...)
+		payload = append(payload, a.PublicViewKey[:]...)
+	}
+	checksum := crypto.Sha256Hash(append([]byte(MixAddressPrefix), payload...))
+	payload = append(payload, checksum[:4]...)
+	return MixAddressPrefix + base58.Encode(payload)
+}
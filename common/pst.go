@@ -0,0 +1,441 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const PSTVersion = 0x01
+
+var pstMagic = []byte("MPST")
+
+// PartiallySignedTransaction carries the unsigned transaction with the keys,
+// masks and scripts of all inputs, so the cosigners could sign offline and
+// combine their signatures before finalizing it
+type PartiallySignedTransaction struct {
+	Version             uint8
+	Transaction         *VersionedTransaction
+	Inputs              []*PSTInput
+	AggregatedSignature *AggregatedSignature
+}
+
+// PSTInput has nil UTXO for the deposit and mint inputs
+type PSTInput struct {
+	UTXO       *UTXOKeys
+	Signatures map[uint16]*crypto.Signature
+}
+
+func NewPartiallySignedTransaction(tx *Transaction, reader UTXOKeysReader) (*PartiallySignedTransaction, error) {
+	pst := &PartiallySignedTransaction{
+		Version:     PSTVersion,
+		Transaction: tx.AsVersioned(),
+	}
+	for _, in := range tx.Inputs {
+		pi := &PSTInput{Signatures: make(map[uint16]*crypto.Signature)}
+		if in.Deposit == nil && in.Mint == nil {
+			utxo, err := reader.ReadUTXOKeys(in.Hash, in.Index)
+			if err != nil {
+				return nil, err
+			}
+			if utxo == nil {
+				return nil, fmt.Errorf("input not found %s:%d", in.Hash.String(), in.Index)
+			}
+			pi.UTXO = utxo
+		}
+		pst.Inputs = append(pst.Inputs, pi)
+	}
+	return pst, nil
+}
+
+func (pst *PartiallySignedTransaction) ReadUTXOKeys(hash crypto.Hash, index uint) (*UTXOKeys, error) {
+	for i, in := range pst.Transaction.Inputs {
+		if in.Hash == hash && in.Index == index {
+			return pst.Inputs[i].UTXO, nil
+		}
+	}
+	return nil, nil
+}
+
+// Sign signs all inputs with the accounts owning their keys, and the accounts
+// not owning any key of an input are skipped for that input
+func (pst *PartiallySignedTransaction) Sign(accounts []*Address) error {
+	if pst.AggregatedSignature != nil {
+		return fmt.Errorf("transaction aggregated signed")
+	}
+	for i, in := range pst.Transaction.Inputs {
+		owners := pst.inputOwners(i, accounts)
+		if len(owners) == 0 {
+			continue
+		}
+		signed := &SignedTransaction{Transaction: pst.Transaction.Transaction}
+		err := signed.SignInput(pst, i, owners)
+		if err != nil {
+			return err
+		}
+		for k, sig := range signed.SignaturesMap[0] {
+			if in.Deposit == nil && in.Mint == nil && !pst.inputBranchHasKey(i, int(k)) {
+				continue
+			}
+			pst.Inputs[i].Signatures[k] = sig
+		}
+	}
+	return nil
+}
+
+// AggregateSign makes the aggregated signature with all the accounts, so the
+// accounts must be enough for all inputs and no more signatures could be
+// combined after it
+func (pst *PartiallySignedTransaction) AggregateSign(accounts []*Address, seed []byte) error {
+	aas := make([][]*Address, len(pst.Inputs))
+	for i := range pst.Inputs {
+		if pst.Inputs[i].UTXO == nil {
+			return fmt.Errorf("invalid aggregated input %d", i)
+		}
+		if len(pst.Inputs[i].Signatures) > 0 {
+			return fmt.Errorf("input %d signed", i)
+		}
+		var owners []*Address
+		for _, acc := range pst.inputOwners(i, accounts) {
+			if pst.inputBranchHasKey(i, pst.inputKeyIndex(i, acc)) {
+				owners = append(owners, acc)
+			}
+		}
+		sort.Slice(owners, func(a, b int) bool {
+			return pst.inputKeyIndex(i, owners[a]) < pst.inputKeyIndex(i, owners[b])
+		})
+		aas[i] = owners
+	}
+	signed := &SignedTransaction{Transaction: pst.Transaction.Transaction}
+	err := signed.AggregateSign(pst, aas, seed)
+	if err != nil {
+		return err
+	}
+	pst.AggregatedSignature = signed.AggregatedSignature
+	return nil
+}
+
+func (pst *PartiallySignedTransaction) inputOwners(index int, accounts []*Address) []*Address {
+	if pst.Inputs[index].UTXO == nil {
+		return accounts[:min(len(accounts), 1)]
+	}
+	var owners []*Address
+	for _, acc := range accounts {
+		if pst.inputKeyIndex(index, acc) >= 0 {
+			owners = append(owners, acc)
+		}
+	}
+	return owners
+}
+
+func (pst *PartiallySignedTransaction) inputKeyIndex(index int, acc *Address) int {
+	in, utxo := pst.Transaction.Inputs[index], pst.Inputs[index].UTXO
+	if utxo == nil {
+		return -1
+	}
+	priv := crypto.DeriveGhostPrivateKey(&utxo.Mask, &acc.PrivateViewKey, &acc.PrivateSpendKey, uint64(in.Index))
+	pub := priv.Public()
+	for i, k := range utxo.Keys {
+		if *k == pub {
+			return i
+		}
+	}
+	return -1
+}
+
+func (pst *PartiallySignedTransaction) inputBranches(index int) ([]*ScriptBranch, error) {
+	utxo := pst.Inputs[index].UTXO
+	return utxo.Script.SpendBranches(pst.Transaction.Inputs[index].Branch)
+}
+
+func (pst *PartiallySignedTransaction) inputBranchHasKey(index int, key int) bool {
+	branches, err := pst.inputBranches(index)
+	if err != nil {
+		return false
+	}
+	for _, b := range branches {
+		if b.HasKey(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Combine merges the signatures of the other partially signed transaction
+// with the same payload, and all of them are verified before merged
+func (pst *PartiallySignedTransaction) Combine(other *PartiallySignedTransaction) error {
+	if pst.Transaction.PayloadHash() != other.Transaction.PayloadHash() {
+		return fmt.Errorf("invalid transaction to combine %s %s", pst.Transaction.PayloadHash(), other.Transaction.PayloadHash())
+	}
+	err := pst.verify(other)
+	if err != nil {
+		return err
+	}
+	if as := other.AggregatedSignature; as != nil {
+		if pst.AggregatedSignature != nil || pst.signatures() > 0 {
+			return fmt.Errorf("aggregated signature conflicts")
+		}
+		pst.AggregatedSignature = as
+		return nil
+	}
+	if pst.AggregatedSignature != nil && other.signatures() > 0 {
+		return fmt.Errorf("aggregated signature conflicts")
+	}
+	for i, in := range other.Inputs {
+		for k, sig := range in.Signatures {
+			old := pst.Inputs[i].Signatures[k]
+			if old != nil && *old != *sig {
+				return fmt.Errorf("signature conflicts %d %d", i, k)
+			}
+			pst.Inputs[i].Signatures[k] = sig
+		}
+	}
+	return nil
+}
+
+// verify checks the signatures of the other against the UTXO keys of this
+// one, and the signatures of the deposit and mint inputs are left to the
+// transaction validation because their keys are not in the file
+func (pst *PartiallySignedTransaction) verify(other *PartiallySignedTransaction) error {
+	hash := pst.Transaction.PayloadHash()
+	if as := other.AggregatedSignature; as != nil {
+		var keys []*crypto.Key
+		for i, in := range pst.Inputs {
+			if in.UTXO == nil {
+				return fmt.Errorf("invalid aggregated input %d", i)
+			}
+			keys = append(keys, in.UTXO.Keys...)
+		}
+		err := crypto.AggregateVerify(&as.Signature, keys, as.Signers, hash)
+		if err != nil {
+			return fmt.Errorf("invalid aggregated signature %v", err)
+		}
+	}
+	for i, in := range other.Inputs {
+		utxo := pst.Inputs[i].UTXO
+		if utxo == nil {
+			continue
+		}
+		for k, sig := range in.Signatures {
+			if int(k) >= len(utxo.Keys) || !utxo.Keys[k].Verify(hash, *sig) {
+				return fmt.Errorf("invalid signature %d %d", i, k)
+			}
+		}
+	}
+	return nil
+}
+
+func (pst *PartiallySignedTransaction) signatures() int {
+	var count int
+	for _, in := range pst.Inputs {
+		count += len(in.Signatures)
+	}
+	return count
+}
+
+// Missing returns the signatures still needed by the branch of the input
+// closest to be satisfied, without checking the time and hash locks
+func (pst *PartiallySignedTransaction) Missing(index int) (int, error) {
+	in := pst.Inputs[index]
+	if in.UTXO == nil && len(in.Signatures) > 0 {
+		return 0, nil
+	} else if in.UTXO == nil {
+		return 1, nil
+	}
+	branches, err := pst.inputBranches(index)
+	if err != nil {
+		return 0, err
+	}
+	var signers []int
+	if as := pst.AggregatedSignature; as != nil {
+		offset := 0
+		for i := 0; i < index; i++ {
+			if u := pst.Inputs[i].UTXO; u != nil {
+				offset += len(u.Keys)
+			}
+		}
+		for _, m := range as.Signers {
+			if m >= offset && m < offset+len(in.UTXO.Keys) {
+				signers = append(signers, m-offset)
+			}
+		}
+	} else {
+		for k := range in.Signatures {
+			signers = append(signers, int(k))
+		}
+	}
+	missing := SliceCountLimit
+	for _, b := range branches {
+		missing = min(missing, max(int(b.Threshold)-b.Signed(signers), 0))
+	}
+	return missing, nil
+}
+
+// Finalize returns the signed transaction when all inputs have enough
+// signatures for any of their branches
+func (pst *PartiallySignedTransaction) Finalize() (*VersionedTransaction, error) {
+	err := pst.verify(pst)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pst.Inputs {
+		missing, err := pst.Missing(i)
+		if err != nil {
+			return nil, err
+		}
+		if missing > 0 {
+			return nil, fmt.Errorf("input %d missing %d signatures", i, missing)
+		}
+	}
+	signed := &SignedTransaction{Transaction: pst.Transaction.Transaction}
+	if pst.AggregatedSignature != nil {
+		signed.AggregatedSignature = pst.AggregatedSignature
+	} else {
+		for _, in := range pst.Inputs {
+			signed.SignaturesMap = append(signed.SignaturesMap, in.Signatures)
+		}
+	}
+	return signed.AsVersioned(), nil
+}
+
+func (pst *PartiallySignedTransaction) Marshal() []byte {
+	enc := NewEncoder()
+	enc.Write(pstMagic)
+	enc.Write([]byte{0x00, pst.Version})
+
+	payload := pst.Transaction.PayloadMarshal()
+	enc.WriteUint32(uint32(len(payload)))
+	enc.Write(payload)
+
+	enc.WriteInt(len(pst.Inputs))
+	for _, in := range pst.Inputs {
+		if u := in.UTXO; u == nil {
+			enc.Write(null)
+		} else {
+			enc.Write(magic)
+			enc.Write(u.Mask[:])
+			enc.WriteInt(len(u.Keys))
+			for _, k := range u.Keys {
+				enc.Write(k[:])
+			}
+			enc.WriteInt(len(u.Script))
+			enc.Write(u.Script)
+		}
+		enc.EncodeSignatures(in.Signatures)
+	}
+
+	if pst.AggregatedSignature == nil {
+		enc.Write(null)
+	} else {
+		enc.Write(magic)
+		enc.EncodeAggregatedSignature(pst.AggregatedSignature)
+	}
+	return enc.Bytes()
+}
+
+func UnmarshalPartiallySignedTransaction(b []byte) (*PartiallySignedTransaction, error) {
+	if len(b) < len(pstMagic)+2 || !bytes.Equal(pstMagic, b[:len(pstMagic)]) {
+		return nil, fmt.Errorf("invalid partially signed transaction %x", b[:min(len(b), 8)])
+	}
+	dec := NewDecoder(b[len(pstMagic):])
+	ver, err := dec.ReadUint16()
+	if err != nil {
+		return nil, err
+	}
+	if ver != PSTVersion {
+		return nil, fmt.Errorf("invalid partially signed transaction version %d", ver)
+	}
+	pst := &PartiallySignedTransaction{Version: uint8(ver)}
+
+	pl, err := dec.ReadUint32()
+	if err != nil {
+		return nil, err
+	}
+	if int64(pl) > int64(min(config.TransactionMaximumSize, len(b))) {
+		return nil, fmt.Errorf("invalid partially signed transaction payload size %d", pl)
+	}
+	payload := make([]byte, pl)
+	err = dec.Read(payload)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := NewDecoder(payload).DecodeTransaction()
+	if err != nil {
+		return nil, err
+	}
+	if tx.AggregatedSignature != nil || len(tx.SignaturesMap) > 0 {
+		return nil, fmt.Errorf("invalid partially signed transaction payload")
+	}
+	pst.Transaction = tx.AsVersioned()
+
+	il, err := dec.ReadInt()
+	if err != nil {
+		return nil, err
+	}
+	if il != len(tx.Inputs) {
+		return nil, fmt.Errorf("invalid partially signed transaction inputs %d %d", il, len(tx.Inputs))
+	}
+	for ; il > 0; il -= 1 {
+		in := &PSTInput{}
+		hu, err := dec.ReadMagic()
+		if err != nil {
+			return nil, err
+		} else if hu {
+			u := &UTXOKeys{}
+			err = dec.Read(u.Mask[:])
+			if err != nil {
+				return nil, err
+			}
+			kc, err := dec.ReadInt()
+			if err != nil {
+				return nil, err
+			}
+			for ; kc > 0; kc -= 1 {
+				var k crypto.Key
+				err = dec.Read(k[:])
+				if err != nil {
+					return nil, err
+				}
+				u.Keys = append(u.Keys, &k)
+			}
+			script, err := dec.ReadBytes()
+			if err != nil {
+				return nil, err
+			}
+			u.Script = script
+			in.UTXO = u
+		}
+		sigs, err := dec.ReadSignatures()
+		if err != nil {
+			return nil, err
+		}
+		in.Signatures = sigs
+		pst.Inputs = append(pst.Inputs, in)
+	}
+
+	ha, err := dec.ReadMagic()
+	if err != nil {
+		return nil, err
+	} else if ha {
+		sl, err := dec.ReadInt()
+		if err != nil {
+			return nil, err
+		}
+		prefix, err := dec.ReadInt()
+		if err != nil {
+			return nil, err
+		}
+		if sl != MaximumEncodingInt || prefix != AggregatedSignaturePrefix {
+			return nil, fmt.Errorf("invalid aggregated signature prefix %d %d", sl, prefix)
+		}
+		as, err := dec.ReadAggregatedSignature()
+		if err != nil {
+			return nil, err
+		}
+		pst.AggregatedSignature = as
+	}
+	return pst, nil
+}
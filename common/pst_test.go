@@ -0,0 +1,155 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartiallySignedTransaction(t *testing.T) {
+	require := require.New(t)
+
+	accounts := make([]*Address, 0)
+	for i := 0; i < 3; i++ {
+		a := randomAccount()
+		accounts = append(accounts, &a)
+	}
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	store := storeImpl{seed: seed, accounts: accounts}
+
+	tx := NewTransactionV5(XINAssetId)
+	tx.AddInput(crypto.Blake3Hash([]byte("pst")), 0)
+	tx.AddInput(crypto.Blake3Hash([]byte("pst")), 1)
+	tx.AddScriptOutput(accounts, NewThresholdScript(1), NewInteger(20000), seed)
+	pst, err := NewPartiallySignedTransaction(tx, store)
+	require.Nil(err)
+	require.Len(pst.Inputs, 2)
+	require.Len(pst.Inputs[1].UTXO.Keys, 3)
+
+	b := pst.Marshal()
+	require.Equal("MPST", string(b[:4]))
+	first, err := UnmarshalPartiallySignedTransaction(b)
+	require.Nil(err)
+	require.Equal(b, first.Marshal())
+	second, err := UnmarshalPartiallySignedTransaction(b)
+	require.Nil(err)
+
+	err = first.Sign(accounts[:1])
+	require.Nil(err)
+	missing, err := first.Missing(0)
+	require.Nil(err)
+	require.Equal(0, missing)
+	missing, err = first.Missing(1)
+	require.Nil(err)
+	require.Equal(1, missing)
+	_, err = first.Finalize()
+	require.ErrorContains(err, "input 1 missing 1 signatures")
+
+	err = second.Sign(accounts[2:])
+	require.Nil(err)
+	require.Len(second.Inputs[0].Signatures, 0)
+	require.Len(second.Inputs[1].Signatures, 1)
+	second, err = UnmarshalPartiallySignedTransaction(second.Marshal())
+	require.Nil(err)
+
+	err = first.Combine(second)
+	require.Nil(err)
+	ver, err := first.Finalize()
+	require.Nil(err)
+	require.Nil(ver.Validate(store, uint64(time.Now().UnixNano()), false))
+
+	other := NewTransactionV5(XINAssetId)
+	other.AddInput(crypto.Blake3Hash([]byte("other")), 0)
+	op, err := NewPartiallySignedTransaction(other, store)
+	require.Nil(err)
+	require.ErrorContains(first.Combine(op), "invalid transaction to combine")
+
+	pst, err = UnmarshalPartiallySignedTransaction(b)
+	require.Nil(err)
+	err = pst.AggregateSign(accounts, seed)
+	require.Nil(err)
+	require.Len(pst.AggregatedSignature.Signers, 5)
+	pst, err = UnmarshalPartiallySignedTransaction(pst.Marshal())
+	require.Nil(err)
+	require.ErrorContains(pst.Sign(accounts), "transaction aggregated signed")
+	require.ErrorContains(pst.Combine(second), "aggregated signature conflicts")
+	ver, err = pst.Finalize()
+	require.Nil(err)
+	require.Nil(ver.Validate(store, uint64(time.Now().UnixNano()), false))
+
+	store.script = NewBranchScript(&ScriptBranch{Threshold: 1, KeyOffset: 0, KeyCount: 1}, &ScriptBranch{Threshold: 1, KeyOffset: 1, KeyCount: 2})
+	tx.Version = TxVersionExtendedScript
+	pst, err = NewPartiallySignedTransaction(tx, store)
+	require.Nil(err)
+	err = pst.Sign(accounts)
+	require.Nil(err)
+	require.Len(pst.Inputs[1].Signatures, 3)
+	missing, err = pst.Missing(1)
+	require.Nil(err)
+	require.Equal(0, missing)
+
+	tx.Inputs[1].Branch = 1
+	pst, err = NewPartiallySignedTransaction(tx, store)
+	require.Nil(err)
+	err = pst.Sign(accounts)
+	require.Nil(err)
+	require.Len(pst.Inputs[0].Signatures, 2)
+	require.Len(pst.Inputs[1].Signatures, 2)
+	ver, err = pst.Finalize()
+	require.Nil(err)
+	require.Nil(ver.Validate(store, config.KernelExtendedScriptActivation, false))
+}
+
+func TestPartiallySignedTransactionInvalid(t *testing.T) {
+	require := require.New(t)
+
+	accounts := make([]*Address, 0)
+	for i := 0; i < 3; i++ {
+		a := randomAccount()
+		accounts = append(accounts, &a)
+	}
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	store := storeImpl{seed: seed, accounts: accounts}
+
+	tx := NewTransactionV5(XINAssetId)
+	tx.AddInput(crypto.Blake3Hash([]byte("pst")), 0)
+	tx.AddInput(crypto.Blake3Hash([]byte("pst")), 1)
+	tx.AddScriptOutput(accounts, NewThresholdScript(1), NewInteger(20000), seed)
+	pst, err := NewPartiallySignedTransaction(tx, store)
+	require.Nil(err)
+	b := pst.Marshal()
+
+	bad, err := UnmarshalPartiallySignedTransaction(b)
+	require.Nil(err)
+	err = bad.Sign(accounts[:1])
+	require.Nil(err)
+	bad.Inputs[0].Signatures[1] = bad.Inputs[0].Signatures[0]
+	require.ErrorContains(pst.Combine(bad), "invalid signature 0 1")
+	require.Len(pst.Inputs[0].Signatures, 0)
+	_, err = bad.Finalize()
+	require.ErrorContains(err, "invalid signature 0 1")
+
+	bad, err = UnmarshalPartiallySignedTransaction(b)
+	require.Nil(err)
+	err = bad.AggregateSign(accounts, seed)
+	require.Nil(err)
+	bad.AggregatedSignature.Signers = bad.AggregatedSignature.Signers[1:]
+	require.ErrorContains(pst.Combine(bad), "invalid aggregated signature")
+	require.Nil(pst.AggregatedSignature)
+
+	pst.Inputs[0].UTXO = nil
+	pst.AggregatedSignature = &AggregatedSignature{Signers: []int{0}}
+	missing, err := pst.Missing(1)
+	require.Nil(err)
+	require.Equal(1, missing)
+
+	huge := append([]byte{}, b[:6]...)
+	huge = append(huge, 0xff, 0xff, 0xff, 0xff)
+	_, err = UnmarshalPartiallySignedTransaction(huge)
+	require.ErrorContains(err, "invalid partially signed transaction payload size")
+}
@@ -1,44 +1,360 @@
 package common
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"strconv"
+
+	"github.com/MixinNetwork/mixin/crypto"
 )
 
 const (
-	Operator0   = 0x00
-	Operator64  = 0x40
-	OperatorSum = 0xfe
-	OperatorCmp = 0xff
+	Operator0       = 0x00
+	Operator64      = 0x40
+	OperatorOr      = 0xf9
+	OperatorKeys    = 0xf8
+	OperatorSHA256  = 0xfa
+	OperatorBlake3  = 0xfb
+	OperatorAge     = 0xfc
+	OperatorCLT     = 0xfd
+	OperatorSum     = 0xfe
+	OperatorCmp     = 0xff
+	ScriptBranchMax = 16
 )
 
+// Script is one or more branches separated by f9, and the output is spendable
+// when any branch is satisfied, or only the branch selected by the spending
+// input. Each branch is a list of optional clauses followed by the threshold
+// clause fffe<threshold>
+//
+//	fd<uint64> the snapshot timestamp of spending must not be before it
+//	fc<uint64> the nanoseconds elapsed since the output snapshot timestamp
+//	fa<hash> the input data is the sha256 preimage of the hash
+//	fb<hash> the input data is the blake3 preimage of the hash
+//	f8<offset><count> only the signatures of these output keys are counted
+//
+// The outputs of the transactions before TxVersionExtendedScript must have
+// the threshold clause only
 type Script []uint8
 
+type ScriptBranch struct {
+	Threshold uint8
+	LockTime  uint64
+	LockAge   uint64
+	HashLock  uint8
+	Hash      crypto.Hash
+	KeyOffset uint8
+	KeyCount  uint8
+}
+
 func NewThresholdScript(threshold uint8) Script {
 	return Script{OperatorCmp, OperatorSum, threshold}
 }
 
-func (s Script) VerifyFormat() error {
-	if len(s) != 3 {
-		return fmt.Errorf("invalid script length %d", len(s))
+// NewTimelockScript requires the spending snapshot timestamp to be at least
+// the absolute lock time in nanoseconds
+func NewTimelockScript(threshold uint8, lockTime uint64) Script {
+	s := Script{OperatorCLT}
+	s = binary.BigEndian.AppendUint64(s, lockTime)
+	return append(s, NewThresholdScript(threshold)...)
+}
+
+// NewDelayScript requires the spending snapshot timestamp to be at least
+// the age in nanoseconds after the snapshot timestamp of the output
+func NewDelayScript(threshold uint8, age uint64) Script {
+	s := Script{OperatorAge}
+	s = binary.BigEndian.AppendUint64(s, age)
+	return append(s, NewThresholdScript(threshold)...)
+}
+
+// NewHashlockScript requires the spending input data to be the preimage of
+// the hash, the op is either OperatorSHA256 or OperatorBlake3
+func NewHashlockScript(threshold uint8, op uint8, hash crypto.Hash) Script {
+	s := append(Script{op}, hash[:]...)
+	return append(s, NewThresholdScript(threshold)...)
+}
+
+// NewHTLCScript makes the output with two keys, the first key claims it with
+// the preimage of the hash, or the second key refunds it with the input branch
+// 1 after the lock time
+func NewHTLCScript(op uint8, hash crypto.Hash, lockTime uint64) Script {
+	return NewBranchScript(&ScriptBranch{
+		Threshold: 1,
+		HashLock:  op,
+		Hash:      hash,
+		KeyOffset: 0,
+		KeyCount:  1,
+	}, &ScriptBranch{
+		Threshold: 1,
+		LockTime:  lockTime,
+		KeyOffset: 1,
+		KeyCount:  1,
+	})
+}
+
+func NewBranchScript(branches ...*ScriptBranch) Script {
+	var s Script
+	for i, b := range branches {
+		if i > 0 {
+			s = append(s, OperatorOr)
+		}
+		if b.LockTime > 0 {
+			s = append(s, OperatorCLT)
+			s = binary.BigEndian.AppendUint64(s, b.LockTime)
+		}
+		if b.LockAge > 0 {
+			s = append(s, OperatorAge)
+			s = binary.BigEndian.AppendUint64(s, b.LockAge)
+		}
+		if b.HashLock > 0 {
+			s = append(s, b.HashLock)
+			s = append(s, b.Hash[:]...)
+		}
+		if b.KeyCount > 0 {
+			s = append(s, OperatorKeys, b.KeyOffset, b.KeyCount)
+		}
+		s = append(s, NewThresholdScript(b.Threshold)...)
+	}
+	return s
+}
+
+func (s Script) Parse() ([]*ScriptBranch, error) {
+	var branches []*ScriptBranch
+	for {
+		b, rest, err := parseScriptBranch(s)
+		if err != nil {
+			return nil, err
+		}
+		branches = append(branches, b)
+		if len(rest) == 0 {
+			break
+		}
+		if rest[0] != OperatorOr || len(branches) == ScriptBranchMax {
+			return nil, fmt.Errorf("invalid script branch %d %d", rest[0], len(branches))
+		}
+		s = rest[1:]
+	}
+	return branches, nil
+}
+
+func parseScriptBranch(s Script) (*ScriptBranch, Script, error) {
+	var b ScriptBranch
+	var seen [256]bool
+	for len(s) > 0 && s[0] != OperatorCmp {
+		op := s[0]
+		if seen[op] || (b.HashLock > 0 && (op == OperatorSHA256 || op == OperatorBlake3)) {
+			return nil, nil, fmt.Errorf("duplicated script operator %d", op)
+		}
+		seen[op] = true
+		switch op {
+		case OperatorCLT, OperatorAge:
+			if len(s) < 9 {
+				return nil, nil, fmt.Errorf("invalid script length %d", len(s))
+			}
+			v := binary.BigEndian.Uint64(s[1:9])
+			if v == 0 {
+				return nil, nil, fmt.Errorf("invalid script lock %d %d", op, v)
+			}
+			if op == OperatorCLT {
+				b.LockTime = v
+			} else {
+				b.LockAge = v
+			}
+			s = s[9:]
+		case OperatorSHA256, OperatorBlake3:
+			if len(s) < 33 {
+				return nil, nil, fmt.Errorf("invalid script length %d", len(s))
+			}
+			b.HashLock = op
+			copy(b.Hash[:], s[1:33])
+			s = s[33:]
+		case OperatorKeys:
+			if len(s) < 3 {
+				return nil, nil, fmt.Errorf("invalid script length %d", len(s))
+			}
+			if s[2] == 0 || int(s[1])+int(s[2]) > SliceCountLimit {
+				return nil, nil, fmt.Errorf("invalid script keys %d %d", s[1], s[2])
+			}
+			b.KeyOffset, b.KeyCount = s[1], s[2]
+			s = s[3:]
+		default:
+			return nil, nil, fmt.Errorf("invalid script operator %d", op)
+		}
+	}
+	if len(s) < 3 {
+		return nil, nil, fmt.Errorf("invalid script length %d", len(s))
 	}
 	if s[0] != OperatorCmp || s[1] != OperatorSum {
-		return fmt.Errorf("invalid script operators %d %d", s[0], s[1])
+		return nil, nil, fmt.Errorf("invalid script operators %d %d", s[0], s[1])
 	}
 	if s[2] > Operator64 {
-		return fmt.Errorf("invalid script threshold %d", s[2])
+		return nil, nil, fmt.Errorf("invalid script threshold %d", s[2])
+	}
+	if b.KeyCount > 0 && s[2] > b.KeyCount {
+		return nil, nil, fmt.Errorf("invalid script threshold %d for keys %d", s[2], b.KeyCount)
+	}
+	b.Threshold = s[2]
+	return &b, s[3:], nil
+}
+
+// IsThreshold reports whether the script is the only form accepted before
+// TxVersionExtendedScript, and the format must be verified first
+func (s Script) IsThreshold() bool {
+	return len(s) == 3
+}
+
+func (s Script) VerifyFormat() error {
+	_, err := s.Parse()
+	return err
+}
+
+// VerifyKeys checks the key ranges of all branches within the output keys
+func (s Script) VerifyKeys(count int) error {
+	branches, err := s.Parse()
+	if err != nil {
+		return err
+	}
+	for _, b := range branches {
+		if int(b.KeyOffset)+int(b.KeyCount) > count {
+			return fmt.Errorf("invalid script keys %d %d %d", b.KeyOffset, b.KeyCount, count)
+		}
 	}
 	return nil
 }
 
+// Validate checks the signatures sum against the thresholds only, it is
+// satisfied when any branch threshold is
 func (s Script) Validate(sum int) error {
-	err := s.VerifyFormat()
+	branches, err := s.Parse()
+	if err != nil {
+		return err
+	}
+	for _, b := range branches {
+		if sum >= int(b.Threshold) {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid signature keys %d %d", sum, branches[0].Threshold)
+}
+
+// ScriptSpend is the state of an input to validate against the script, the
+// signers are the indexes of the output keys with valid signatures, and the
+// branch 0 means no branch selected by the input
+type ScriptSpend struct {
+	Branch   uint8
+	Signers  []int
+	Data     []byte
+	SnapTime uint64
+	Created  func() (uint64, error)
+}
+
+// ValidateSpend returns the first branch satisfied by the spend, and the
+// error of the last branch if none satisfied
+func (s Script) ValidateSpend(sp *ScriptSpend) (*ScriptBranch, error) {
+	branches, err := s.SpendBranches(sp.Branch)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range branches {
+		err = b.validate(sp)
+		if err == nil {
+			return b, nil
+		}
+	}
+	return nil, err
+}
+
+// SpendBranches returns the branches could be satisfied by the input, all of
+// them if the input selects no branch
+func (s Script) SpendBranches(branch uint8) ([]*ScriptBranch, error) {
+	branches, err := s.Parse()
+	if err != nil {
+		return nil, err
+	}
+	if branch == 0 {
+		return branches, nil
+	}
+	if int(branch) >= len(branches) {
+		return nil, fmt.Errorf("invalid script branch %d %d", branch, len(branches))
+	}
+	return branches[branch : branch+1], nil
+}
+
+func (b *ScriptBranch) validate(sp *ScriptSpend) error {
+	err := b.ValidatePreimage(sp.Data)
+	if err != nil {
+		return err
+	}
+	err = b.ValidateTime(sp.SnapTime, sp.Created)
+	if err != nil {
+		return err
+	}
+	sum := b.Signed(sp.Signers)
+	if sum < int(b.Threshold) {
+		return fmt.Errorf("invalid signature keys %d %d", sum, b.Threshold)
+	}
+	return nil
+}
+
+// Signed counts the signers in the key group of the branch
+func (b *ScriptBranch) Signed(signers []int) int {
+	var sum int
+	for _, i := range signers {
+		if b.HasKey(i) {
+			sum += 1
+		}
+	}
+	return sum
+}
+
+// HasKey checks whether the output key index is in the key group of the
+// branch, and all keys are in the group without the keys clause
+func (b *ScriptBranch) HasKey(i int) bool {
+	if b.KeyCount == 0 {
+		return true
+	}
+	return i >= int(b.KeyOffset) && i < int(b.KeyOffset)+int(b.KeyCount)
+}
+
+// ValidatePreimage checks the input data against the hash lock, and the data
+// must be empty for the branch without a hash lock
+func (b *ScriptBranch) ValidatePreimage(data []byte) error {
+	var hash crypto.Hash
+	switch b.HashLock {
+	case 0:
+		if len(data) > 0 {
+			return fmt.Errorf("script input data without hash lock %x", data)
+		}
+		return nil
+	case OperatorSHA256:
+		hash = sha256.Sum256(data)
+	case OperatorBlake3:
+		hash = crypto.Blake3Hash(data)
+	}
+	if len(data) == 0 || hash != b.Hash {
+		return fmt.Errorf("script hash lock preimage %x %s", data, b.Hash)
+	}
+	return nil
+}
+
+// ValidateTime checks the time locks with the snapshot timestamp of the
+// spending transaction, and the output created timestamp is only read when
+// the branch has an age lock
+func (b *ScriptBranch) ValidateTime(snapTime uint64, created func() (uint64, error)) error {
+	if snapTime < b.LockTime {
+		return fmt.Errorf("script locked until %d %d", b.LockTime, snapTime)
+	}
+	if b.LockAge == 0 {
+		return nil
+	}
+	ts, err := created()
 	if err != nil {
 		return err
 	}
-	if sum < int(s[2]) {
-		return fmt.Errorf("invalid signature keys %d %d", sum, s[2])
+	if snapTime < b.LockAge || snapTime-b.LockAge < ts {
+		return fmt.Errorf("script locked for age %d %d %d", b.LockAge, ts, snapTime)
 	}
 	return nil
 }
@@ -1,8 +1,11 @@
 package common
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"testing"
 
+	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/stretchr/testify/require"
 )
 
@@ -46,3 +49,181 @@ func TestScript(t *testing.T) {
 	require.Nil(err)
 	require.Equal("fffe01", s.String())
 }
+
+func TestTimelockScript(t *testing.T) {
+	require := require.New(t)
+
+	s := NewTimelockScript(1, 1700000000000000000)
+	require.Equal("fd17979cfe362a0000fffe01", s.String())
+	branches, err := s.Parse()
+	require.Nil(err)
+	require.Len(branches, 1)
+	require.Equal(uint8(1), branches[0].Threshold)
+	require.Equal(uint64(1700000000000000000), branches[0].LockTime)
+	require.Equal(uint64(0), branches[0].LockAge)
+	require.Nil(s.Validate(1))
+	require.NotNil(s.Validate(0))
+
+	created := func() (uint64, error) { panic("no age lock") }
+	err = branches[0].ValidateTime(1699999999999999999, created)
+	require.ErrorContains(err, "script locked until")
+	err = branches[0].ValidateTime(1700000000000000000, created)
+	require.Nil(err)
+
+	s = NewDelayScript(2, 3600000000000)
+	require.Equal("fc0000034630b8a000fffe02", s.String())
+	branches, err = s.Parse()
+	require.Nil(err)
+	require.Equal(uint8(2), branches[0].Threshold)
+	require.Equal(uint64(3600000000000), branches[0].LockAge)
+	created = func() (uint64, error) { return 1700000000000000000, nil }
+	err = branches[0].ValidateTime(1700003599999999999, created)
+	require.ErrorContains(err, "script locked for age")
+	err = branches[0].ValidateTime(1700003600000000000, created)
+	require.Nil(err)
+	err = branches[0].ValidateTime(1000, created)
+	require.ErrorContains(err, "script locked for age")
+
+	both := append(NewTimelockScript(1, 1700000000000000000)[:9], NewDelayScript(1, 3600000000000)...)
+	branches, err = both.Parse()
+	require.Nil(err)
+	require.Equal(uint64(1700000000000000000), branches[0].LockTime)
+	require.Equal(uint64(3600000000000), branches[0].LockAge)
+	_, err = both.ValidateSpend(&ScriptSpend{Signers: []int{0}, SnapTime: 1700003599999999999, Created: created})
+	require.ErrorContains(err, "script locked for age")
+
+	var j Script
+	err = j.UnmarshalJSON([]byte(`"fd17979cfe362a0000fffe01"`))
+	require.Nil(err)
+	require.Nil(j.VerifyFormat())
+
+	for _, invalid := range []string{
+		"fd17979cfe362a0000",
+		"fd17979cfe362afffe01",
+		"fd0000000000000000fffe01",
+		"fb1797d2c6b4c80000fffe01",
+		"fd17979cfe362a0000fd17979cfe362a0000fffe01",
+		"fd17979cfe362a0000fffe41",
+		"fd17979cfe362a0000fffffe01",
+	} {
+		err = j.UnmarshalJSON([]byte(`"` + invalid + `"`))
+		require.Nil(err)
+		require.NotNil(j.VerifyFormat(), invalid)
+	}
+}
+
+func TestHashlockScript(t *testing.T) {
+	require := require.New(t)
+
+	preimage := []byte("mixin atomic swap preimage")
+	hash := crypto.Hash(sha256.Sum256(preimage))
+	s := NewHashlockScript(1, OperatorSHA256, hash)
+	require.Equal("fa"+hash.String()+"fffe01", s.String())
+	_, err := s.ValidateSpend(&ScriptSpend{Signers: []int{0}, Data: preimage})
+	require.Nil(err)
+	_, err = s.ValidateSpend(&ScriptSpend{Signers: []int{0}})
+	require.ErrorContains(err, "script hash lock preimage")
+	_, err = s.ValidateSpend(&ScriptSpend{Signers: []int{0}, Data: []byte("mixin")})
+	require.ErrorContains(err, "script hash lock preimage")
+	_, err = s.ValidateSpend(&ScriptSpend{Data: preimage})
+	require.ErrorContains(err, "invalid signature keys")
+	_, err = NewThresholdScript(1).ValidateSpend(&ScriptSpend{Signers: []int{0}, Data: preimage})
+	require.ErrorContains(err, "script input data without hash lock")
+
+	hash = crypto.Blake3Hash(preimage)
+	s = NewHTLCScript(OperatorBlake3, hash, 1700000000000000000)
+	require.Equal("fb"+hash.String()+"f80001fffe01f9fd17979cfe362a0000f80101fffe01", s.String())
+	require.Nil(s.VerifyKeys(2))
+	require.NotNil(s.VerifyKeys(1))
+	branches, err := s.Parse()
+	require.Nil(err)
+	require.Len(branches, 2)
+	require.Equal(uint8(OperatorBlake3), branches[0].HashLock)
+	require.Equal(hash, branches[0].Hash)
+	require.Equal(uint8(1), branches[1].KeyOffset)
+
+	claim := &ScriptSpend{Signers: []int{0}, Data: preimage, SnapTime: 1600000000000000000}
+	b, err := s.ValidateSpend(claim)
+	require.Nil(err)
+	require.Equal(branches[0], b)
+	claim.Signers = []int{1}
+	_, err = s.ValidateSpend(claim)
+	require.ErrorContains(err, "script input data without hash lock")
+
+	refund := &ScriptSpend{Signers: []int{1}, SnapTime: 1700000000000000000}
+	b, err = s.ValidateSpend(refund)
+	require.Nil(err)
+	require.Equal(branches[1], b)
+	refund = &ScriptSpend{Branch: 1, Signers: []int{1}, SnapTime: 1600000000000000000}
+	_, err = s.ValidateSpend(refund)
+	require.ErrorContains(err, "script locked until")
+	refund.SnapTime = 1700000000000000000
+	b, err = s.ValidateSpend(refund)
+	require.Nil(err)
+	require.Equal(branches[1], b)
+	refund.Signers = []int{0}
+	_, err = s.ValidateSpend(refund)
+	require.ErrorContains(err, "invalid signature keys 0 1")
+	refund.Signers = nil
+	_, err = s.ValidateSpend(refund)
+	require.ErrorContains(err, "invalid signature keys")
+	refund.Branch = 2
+	_, err = s.ValidateSpend(refund)
+	require.ErrorContains(err, "invalid script branch")
+
+	for _, invalid := range []string{
+		"fb" + hash.String()[2:] + "fffe01",
+		"fb" + hash.String() + "fa" + hash.String() + "fffe01",
+		"f80000fffe01",
+		"f80101fffe02",
+		"fffe01f9",
+		"fffe01fffe01",
+	} {
+		require.NotNil(Script(hexBytes(invalid)).VerifyFormat(), invalid)
+	}
+}
+
+func TestBranchScript(t *testing.T) {
+	require := require.New(t)
+
+	s := NewBranchScript(&ScriptBranch{
+		Threshold: 2,
+		KeyOffset: 0,
+		KeyCount:  3,
+	}, &ScriptBranch{
+		Threshold: 1,
+		LockAge:   3600000000000,
+		KeyOffset: 3,
+		KeyCount:  1,
+	})
+	require.Equal("f80003fffe02f9fc0000034630b8a000f80301fffe01", s.String())
+	require.Nil(s.VerifyKeys(4))
+	require.NotNil(s.VerifyKeys(3))
+
+	created := func() (uint64, error) { return 1700000000000000000, nil }
+	_, err := s.ValidateSpend(&ScriptSpend{Signers: []int{0, 2}, Created: created})
+	require.Nil(err)
+	_, err = s.ValidateSpend(&ScriptSpend{Signers: []int{0, 3}, Created: created})
+	require.ErrorContains(err, "script locked for age")
+	_, err = s.ValidateSpend(&ScriptSpend{Signers: []int{0, 1, 3}, Created: created})
+	require.Nil(err)
+	_, err = s.ValidateSpend(&ScriptSpend{Branch: 1, Signers: []int{0, 1}, SnapTime: 1700003600000000000, Created: created})
+	require.ErrorContains(err, "invalid signature keys 0 1")
+	_, err = s.ValidateSpend(&ScriptSpend{Branch: 1, Signers: []int{3}, SnapTime: 1700003599999999999, Created: created})
+	require.ErrorContains(err, "script locked for age")
+	_, err = s.ValidateSpend(&ScriptSpend{Branch: 1, Signers: []int{3}, SnapTime: 1700003600000000000, Created: created})
+	require.Nil(err)
+
+	var branches []*ScriptBranch
+	for i := 0; i < ScriptBranchMax; i++ {
+		branches = append(branches, &ScriptBranch{Threshold: 1})
+	}
+	require.Nil(NewBranchScript(branches...).VerifyFormat())
+	branches = append(branches, &ScriptBranch{Threshold: 1})
+	require.NotNil(NewBranchScript(branches...).VerifyFormat())
+}
+
+func hexBytes(s string) []byte {
+	b, _ := hex.DecodeString(s)
+	return b
+}
@@ -0,0 +1,153 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+type SimulationInput struct {
+	Hash    crypto.Hash `json:"hash"`
+	Index   uint        `json:"index"`
+	Type    uint8       `json:"type"`
+	Amount  Integer     `json:"amount"`
+	Source  string      `json:"source"`
+	Lock    crypto.Hash `json:"lock"`
+	Missing bool        `json:"missing"`
+}
+
+type SimulationFailure struct {
+	Rule  string `json:"rule"`
+	Error string `json:"error"`
+}
+
+// SimulationReport is the result of all the validation rules of a transaction
+// against the store, and the store should not lock anything for simulation
+type SimulationReport struct {
+	Hash         crypto.Hash          `json:"hash"`
+	Type         uint8                `json:"type"`
+	Size         int                  `json:"size"`
+	Inputs       []*SimulationInput   `json:"inputs"`
+	InputAmount  Integer              `json:"input_amount"`
+	OutputAmount Integer              `json:"output_amount"`
+	ExtraSize    int                  `json:"extra_size"`
+	ExtraLimit   int                  `json:"extra_limit"`
+	StorageFee   Integer              `json:"storage_fee"`
+	Failures     []*SimulationFailure `json:"failures"`
+}
+
+func (r *SimulationReport) Valid() bool {
+	return len(r.Failures) == 0
+}
+
+func (r *SimulationReport) fail(rule string, err error) {
+	r.Failures = append(r.Failures, &SimulationFailure{Rule: rule, Error: err.Error()})
+}
+
+// ExtraStorageFee returns the XIN amount of the storage output needed by the
+// extra size, zero if the size is within the general limit
+func ExtraStorageFee(size int) Integer {
+	if size <= ExtraSizeGeneralLimit {
+		return Zero
+	}
+	cells := (size + ExtraSizeStorageStep - 1) / ExtraSizeStorageStep
+	return NewIntegerFromString(ExtraStoragePriceStep).Mul(cells)
+}
+
+// Simulate checks the transaction with the same rules of Validate, but does
+// not stop at the first failure, the rules depending on a failed rule are
+// skipped and not reported
+func (ver *VersionedTransaction) Simulate(store DataStore, snapTime uint64) *SimulationReport {
+	tx := &ver.SignedTransaction
+	txType := tx.TransactionType()
+	report := &SimulationReport{
+		Hash:         ver.PayloadHash(),
+		Type:         txType,
+		Size:         len(ver.PayloadMarshal()),
+		InputAmount:  Zero,
+		OutputAmount: Zero,
+		ExtraSize:    len(tx.Extra),
+		StorageFee:   Zero,
+		Failures:     []*SimulationFailure{},
+	}
+	if ver.Version >= TxVersionHashSignature {
+		report.ExtraLimit = tx.GetExtraLimit()
+		if tx.Asset == XINAssetId {
+			report.StorageFee = ExtraStorageFee(len(tx.Extra))
+		}
+	}
+	for _, o := range tx.Outputs {
+		report.OutputAmount = report.OutputAmount.Add(o.Amount)
+	}
+
+	for _, r := range ver.formatRules(txType, snapTime) {
+		err := r.check()
+		if err != nil {
+			report.fail(r.name, err)
+		}
+	}
+	if !report.Valid() {
+		return report
+	}
+
+	err := report.resolveInputs(store, tx)
+	if err != nil {
+		report.fail("inputs", err)
+		return report
+	}
+	err = validateReferences(store, tx)
+	if err != nil {
+		report.fail("references", err)
+	}
+	inputsFilter, inputAmount, err := tx.validateInputs(store, ver.PayloadHash(), txType, snapTime, false)
+	if err != nil {
+		report.fail("inputs", err)
+		return report
+	}
+	if inputAmount.Sign() <= 0 {
+		report.fail("amount", fmt.Errorf("invalid input amount %s", inputAmount))
+		return report
+	}
+	err = tx.validateOutputs(store, ver.PayloadHash(), inputAmount, false)
+	if err != nil {
+		report.fail("outputs", err)
+	}
+	err = ver.validateType(store, txType, inputsFilter, snapTime)
+	if err != nil {
+		report.fail("transaction", err)
+	}
+	return report
+}
+
+func (r *SimulationReport) resolveInputs(store DataStore, tx *SignedTransaction) error {
+	for _, in := range tx.Inputs {
+		si := &SimulationInput{Hash: in.Hash, Index: in.Index, Amount: Zero}
+		r.Inputs = append(r.Inputs, si)
+		switch {
+		case in.Mint != nil:
+			si.Source, si.Amount = "mint", in.Mint.Amount
+		case in.Deposit != nil:
+			si.Source, si.Amount = "deposit", in.Deposit.Amount
+			lock, err := store.ReadDepositLock(in.Deposit)
+			if err != nil {
+				return err
+			}
+			si.Lock = lock
+		case len(in.Genesis) > 0:
+			si.Source = "genesis"
+		default:
+			si.Source = "utxo"
+			utxo, err := store.ReadUTXOLock(in.Hash, in.Index)
+			if err != nil {
+				return err
+			}
+			if utxo == nil {
+				si.Missing = true
+				continue
+			}
+			si.Type, si.Amount, si.Lock = utxo.Type, utxo.Amount, utxo.LockHash
+		}
+		r.InputAmount = r.InputAmount.Add(si.Amount)
+	}
+	return nil
+}
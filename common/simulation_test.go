@@ -0,0 +1,66 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulateTransaction(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal("0.00000000", ExtraStorageFee(ExtraSizeGeneralLimit).String())
+	require.Equal("0.00010000", ExtraStorageFee(ExtraSizeGeneralLimit+1).String())
+	require.Equal("0.00010000", ExtraStorageFee(ExtraSizeStorageStep).String())
+	require.Equal("0.00020000", ExtraStorageFee(ExtraSizeStorageStep+1).String())
+
+	accounts := make([]*Address, 0)
+	for i := 0; i < 2; i++ {
+		a := randomAccount()
+		accounts = append(accounts, &a)
+	}
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	store := storeImpl{seed: seed, accounts: accounts}
+
+	tx := NewTransactionV5(XINAssetId)
+	tx.AddInput(crypto.Blake3Hash([]byte("simulate")), 0)
+	tx.AddScriptOutput(accounts, NewThresholdScript(1), NewInteger(20000), seed)
+	tx.Extra = make([]byte, ExtraSizeGeneralLimit+1)
+	ver := tx.AsVersioned()
+
+	report := ver.Simulate(store, uint64(time.Now().UnixNano()))
+	require.False(report.Valid())
+	require.Equal(uint8(TransactionTypeScript), report.Type)
+	require.Equal(ExtraSizeGeneralLimit, report.ExtraLimit)
+	require.Equal("0.00010000", report.StorageFee.String())
+	require.Len(report.Failures, 2)
+	require.Equal("extra", report.Failures[0].Rule)
+	require.Equal("signatures", report.Failures[1].Rule)
+	require.Len(report.Inputs, 0)
+
+	tx.Extra = nil
+	ver = tx.AsVersioned()
+	err := ver.SignInput(store, 0, accounts[:1])
+	require.Nil(err)
+	report = ver.Simulate(store, uint64(time.Now().UnixNano()))
+	require.False(report.Valid())
+	require.Len(report.Inputs, 1)
+	require.Equal("utxo", report.Inputs[0].Source)
+	require.Equal("10000.00000000", report.InputAmount.String())
+	require.Equal("20000.00000000", report.OutputAmount.String())
+	require.Len(report.Failures, 1)
+	require.Equal("outputs", report.Failures[0].Rule)
+	require.Contains(report.Failures[0].Error, "invalid input output amount")
+	require.Equal(ver.Validate(store, uint64(time.Now().UnixNano()), false).Error(), report.Failures[0].Error)
+
+	tx.Outputs[0].Amount = NewInteger(10000)
+	ver = tx.AsVersioned()
+	err = ver.SignInput(store, 0, accounts[:1])
+	require.Nil(err)
+	report = ver.Simulate(store, uint64(time.Now().UnixNano()))
+	require.True(report.Valid())
+	require.Nil(ver.Validate(store, uint64(time.Now().UnixNano()), false))
+}
@@ -6,6 +6,10 @@ type TransactionReader interface {
 	ReadTransaction(hash crypto.Hash) (*VersionedTransaction, string, error)
 }
 
+type SnapshotReader interface {
+	ReadSnapshot(hash crypto.Hash) (*SnapshotWithTopologicalOrder, error)
+}
+
 type UTXOKeysReader interface {
 	ReadUTXOKeys(hash crypto.Hash, index uint) (*UTXOKeys, error)
 }
@@ -40,6 +44,7 @@ type AssetReader interface {
 
 type DataStore interface {
 	TransactionReader
+	SnapshotReader
 	UTXOLockReader
 	UTXOLocker
 	GhostLocker
@@ -10,13 +10,15 @@ import (
 )
 
 const (
-	TxVersionHashSignature = 0x05
+	TxVersionHashSignature  = 0x05
+	TxVersionExtendedScript = 0x06
 
 	ExtraSizeGeneralLimit    = 256
 	ExtraSizeStorageStep     = 1024
 	ExtraSizeStorageCapacity = 1024 * 1024 * 4
 	ExtraStoragePriceStep    = "0.0001"
 	SliceCountLimit          = 256
+	InputDataSizeLimit       = 256
 	ReferencesCountLimit     = 2
 
 	OutputTypeScript               = 0x00
@@ -51,6 +53,8 @@ type Input struct {
 	Genesis []byte
 	Deposit *DepositData
 	Mint    *MintData
+	Branch  uint8
+	Data    []byte
 }
 
 type Output struct {
@@ -79,6 +83,27 @@ type SignedTransaction struct {
 	SignaturesMap       []map[uint16]*crypto.Signature
 }
 
+// BranchKeys returns the key group of the script branch, and only the
+// signatures of these keys are counted for the branch
+func (o *Output) BranchKeys(branch uint8) ([]*crypto.Key, error) {
+	branches, err := o.Script.Parse()
+	if err != nil {
+		return nil, err
+	}
+	if int(branch) >= len(branches) {
+		return nil, fmt.Errorf("invalid script branch %d %d", branch, len(branches))
+	}
+	b := branches[branch]
+	if b.KeyCount == 0 {
+		return o.Keys, nil
+	}
+	end := int(b.KeyOffset) + int(b.KeyCount)
+	if end > len(o.Keys) {
+		return nil, fmt.Errorf("invalid script keys %d %d", end, len(o.Keys))
+	}
+	return o.Keys[b.KeyOffset:end], nil
+}
+
 func (tx *Transaction) ViewGhostKey(a *crypto.Key) []*Output {
 	outputs := make([]*Output, 0)
 
@@ -324,6 +349,15 @@ func NewTransactionV5(asset crypto.Hash) *Transaction {
 	}
 }
 
+// NewTransactionV6 is needed for the outputs with the script operators other
+// than the threshold, which are rejected by the nodes before this version
+func NewTransactionV6(asset crypto.Hash) *Transaction {
+	return &Transaction{
+		Version: TxVersionExtendedScript,
+		Asset:   asset,
+	}
+}
+
 func (tx *Transaction) AddInput(hash crypto.Hash, index uint) {
 	in := &Input{
 		Hash:  hash,
@@ -352,6 +386,21 @@ func (tx *Transaction) AddOutputWithType(ot uint8, accounts []*Address, s Script
 	tx.Outputs = append(tx.Outputs, out)
 }
 
+// AddBranchOutput makes the output keys with all groups in order, and each
+// branch is spendable by its own key group
+func (tx *Transaction) AddBranchOutput(groups [][]*Address, branches []*ScriptBranch, amount Integer, seed []byte) {
+	if len(groups) != len(branches) {
+		panic(fmt.Errorf("invalid branch groups %d %d", len(groups), len(branches)))
+	}
+	var accounts []*Address
+	for i, g := range groups {
+		branches[i].KeyOffset = uint8(len(accounts))
+		branches[i].KeyCount = uint8(len(g))
+		accounts = append(accounts, g...)
+	}
+	tx.AddOutputWithType(OutputTypeScript, accounts, NewBranchScript(branches...), amount, seed)
+}
+
 func (tx *Transaction) AddScriptOutput(accounts []*Address, s Script, amount Integer, seed []byte) {
 	tx.AddOutputWithType(OutputTypeScript, accounts, s, amount, seed)
 }
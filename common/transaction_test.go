@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/stretchr/testify/require"
 )
@@ -228,10 +229,127 @@ func TestTransactionReferences(t *testing.T) {
 	require.Equal(ver.Inputs[0].Hash, ver.References[0])
 }
 
+func TestBranchTransaction(t *testing.T) {
+	require := require.New(t)
+
+	accounts := make([]*Address, 0)
+	for i := 0; i < 3; i++ {
+		a := randomAccount()
+		accounts = append(accounts, &a)
+	}
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+
+	tx := NewTransactionV5(XINAssetId)
+	branches := []*ScriptBranch{{Threshold: 2}, {Threshold: 1, LockTime: 1}}
+	tx.AddBranchOutput([][]*Address{accounts[:2], accounts[2:]}, branches, NewInteger(1), seed)
+	require.Equal("f80002fffe02f9fd0000000000000001f80201fffe01", tx.Outputs[0].Script.String())
+	keys, err := tx.Outputs[0].BranchKeys(0)
+	require.Nil(err)
+	require.Equal(tx.Outputs[0].Keys[:2], keys)
+	keys, err = tx.Outputs[0].BranchKeys(1)
+	require.Nil(err)
+	require.Equal(tx.Outputs[0].Keys[2:], keys)
+	_, err = tx.Outputs[0].BranchKeys(2)
+	require.NotNil(err)
+
+	store := storeImpl{seed: seed, accounts: accounts, script: tx.Outputs[0].Script}
+	ver := NewTransactionV6(XINAssetId).AsVersioned()
+	ver.AddInput(crypto.Blake3Hash([]byte("branch")), 1)
+	ver.AddScriptOutput(accounts, NewThresholdScript(1), NewInteger(10000), seed)
+	err = ver.AggregateSign(store, [][]*Address{accounts[:2]}, seed)
+	require.Nil(err)
+	require.Nil(ver.Validate(store, config.KernelExtendedScriptActivation, false))
+	ver.AggregatedSignature = nil
+	err = ver.AggregateSign(store, [][]*Address{accounts[1:2]}, seed)
+	require.Nil(err)
+	err = ver.Validate(store, config.KernelExtendedScriptActivation, false)
+	require.ErrorContains(err, "invalid signature keys 0 1")
+	ver.AggregatedSignature = nil
+	err = ver.AggregateSign(store, [][]*Address{accounts[1:]}, seed)
+	require.Nil(err)
+	require.Nil(ver.Validate(store, config.KernelExtendedScriptActivation, false))
+
+	ver.Inputs[0].Branch = 1
+	ver.resetCache()
+	ver.AggregatedSignature = nil
+	err = ver.AggregateSign(store, [][]*Address{accounts[2:]}, seed)
+	require.Nil(err)
+	require.Nil(ver.Validate(store, config.KernelExtendedScriptActivation, false))
+	ver.AggregatedSignature = nil
+	err = ver.SignInput(store, 0, accounts[2:])
+	require.Nil(err)
+	require.Nil(ver.Validate(store, config.KernelExtendedScriptActivation, false))
+	ver.SignaturesMap = nil
+	err = ver.SignInput(store, 0, accounts[:1])
+	require.Nil(err)
+	err = ver.Validate(store, config.KernelExtendedScriptActivation, false)
+	require.ErrorContains(err, "invalid signature keys 0 1")
+
+	legacy := NewTransactionV5(XINAssetId)
+	legacy.AddInput(crypto.Blake3Hash([]byte("branch")), 1)
+	legacy.Inputs[0].Branch = 1
+	legacy.AddScriptOutput(accounts, NewThresholdScript(1), NewInteger(10000), seed)
+	require.Panics(func() { legacy.AsVersioned().PayloadMarshal() })
+}
+
+func TestTimelockTransaction(t *testing.T) {
+	require := require.New(t)
+
+	accounts := make([]*Address, 0)
+	for i := 0; i < 2; i++ {
+		a := randomAccount()
+		accounts = append(accounts, &a)
+	}
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	created := config.KernelExtendedScriptActivation - uint64(time.Hour)
+	store := storeImpl{seed: seed, accounts: accounts, script: NewDelayScript(1, uint64(time.Hour)), created: created}
+
+	ver := NewTransactionV5(XINAssetId).AsVersioned()
+	ver.AddInput(crypto.Blake3Hash([]byte("timelock")), 0)
+	ver.AddScriptOutput(accounts, NewThresholdScript(1), NewInteger(10000), seed)
+	err := ver.SignInput(store, 0, accounts[:1])
+	require.Nil(err)
+	err = ver.Validate(store, created+uint64(time.Minute), false)
+	require.ErrorContains(err, "script locked for age")
+	err = ver.Validate(store, created+uint64(time.Hour), false)
+	require.Nil(err)
+
+	ver = NewTransactionV5(XINAssetId).AsVersioned()
+	ver.AddInput(crypto.Blake3Hash([]byte("timelock")), 0)
+	ver.AddScriptOutput(accounts, NewTimelockScript(1, created), NewInteger(10000), seed)
+	err = ver.SignInput(store, 0, accounts[:1])
+	require.Nil(err)
+	err = ver.Validate(store, created+uint64(time.Hour), false)
+	require.ErrorContains(err, "for tx version 5")
+
+	ver = NewTransactionV6(XINAssetId).AsVersioned()
+	ver.AddInput(crypto.Blake3Hash([]byte("timelock")), 0)
+	ver.AddScriptOutput(accounts, NewTimelockScript(1, created), NewInteger(10000), seed)
+	err = ver.SignInput(store, 0, accounts[:1])
+	require.Nil(err)
+	err = ver.Validate(store, created+uint64(time.Minute), false)
+	require.ErrorContains(err, "tx version 6 not activated")
+	err = ver.Validate(store, created+uint64(time.Hour)-1, false)
+	require.ErrorContains(err, "tx version 6 not activated")
+	err = ver.Validate(store, created+uint64(time.Hour), false)
+	require.Nil(err)
+	report := ver.Simulate(store, created)
+	require.False(report.Valid())
+	_, err = UnmarshalVersionedTransactionAt(ver.Marshal(), created)
+	require.ErrorContains(err, "tx version 6 not activated")
+	ver, err = UnmarshalVersionedTransactionAt(ver.Marshal(), created+uint64(time.Hour))
+	require.Nil(err)
+	require.Equal(uint8(TxVersionExtendedScript), ver.Version)
+}
+
 type storeImpl struct {
 	custodian *Address
 	seed      []byte
 	accounts  []*Address
+	script    Script
+	created   uint64
 }
 
 func (store storeImpl) ReadAssetWithBalance(_ crypto.Hash) (*Asset, Integer, error) {
@@ -244,8 +362,9 @@ func (store storeImpl) ReadUTXOKeys(hash crypto.Hash, index uint) (*UTXOKeys, er
 		return nil, err
 	}
 	return &UTXOKeys{
-		Mask: utxo.Mask,
-		Keys: utxo.Keys,
+		Mask:   utxo.Mask,
+		Keys:   utxo.Keys,
+		Script: utxo.Script,
 	}, nil
 }
 
@@ -263,6 +382,9 @@ func (store storeImpl) ReadUTXOLock(hash crypto.Hash, index uint) (*UTXOWithLock
 		Script: Script{OperatorCmp, OperatorSum, uint8(index + 1)},
 		Mask:   genesisMaskR,
 	}
+	if store.script != nil {
+		out.Script = store.script
+	}
 	utxo := &UTXOWithLock{
 		UTXO: UTXO{
 			Input:  in,
@@ -294,6 +416,13 @@ func (store storeImpl) ReadTransaction(h crypto.Hash) (*VersionedTransaction, st
 	return &VersionedTransaction{}, h.String(), nil
 }
 
+func (store storeImpl) ReadSnapshot(_ crypto.Hash) (*SnapshotWithTopologicalOrder, error) {
+	if store.created == 0 {
+		return nil, nil
+	}
+	return &SnapshotWithTopologicalOrder{Snapshot: &Snapshot{Timestamp: store.created}}, nil
+}
+
 func (store storeImpl) ReadDepositLock(_ *DepositData) (crypto.Hash, error) {
 	return crypto.Hash{}, nil
 }
@@ -18,8 +18,9 @@ type UTXOWithLock struct {
 }
 
 type UTXOKeys struct {
-	Mask crypto.Key
-	Keys []*crypto.Key
+	Mask   crypto.Key
+	Keys   []*crypto.Key
+	Script Script
 }
 
 func (tx *VersionedTransaction) UnspentOutputs() []*UTXOWithLock {
@@ -11,40 +11,10 @@ func (ver *VersionedTransaction) Validate(store DataStore, snapTime uint64, fork
 	tx := &ver.SignedTransaction
 	txType := tx.TransactionType()
 
-	switch ver.Version {
-	case TxVersionHashSignature:
-	default:
-		return fmt.Errorf("invalid tx version %d", ver.Version)
-	}
-
-	if txType == TransactionTypeUnknown {
-		return fmt.Errorf("invalid tx type %d", txType)
-	}
-	if len(tx.Inputs) < 1 || len(tx.Outputs) < 1 {
-		return fmt.Errorf("invalid tx inputs or outputs %d %d",
-			len(tx.Inputs), len(tx.Outputs))
-	}
-	if len(tx.Inputs) > SliceCountLimit || len(tx.Outputs) > SliceCountLimit ||
-		len(tx.References) > SliceCountLimit {
-		return fmt.Errorf("invalid tx inputs or outputs %d %d %d",
-			len(tx.Inputs), len(tx.Outputs), len(tx.References))
-	}
-	if len(tx.Extra) > tx.GetExtraLimit() {
-		return fmt.Errorf("invalid extra size %d", len(tx.Extra))
-	}
-	if len(ver.PayloadMarshal()) > config.TransactionMaximumSize {
-		return fmt.Errorf("invalid transaction size %d", len(ver.PayloadMarshal()))
-	}
-
-	if tx.AggregatedSignature != nil {
-		if tx.SignaturesMap != nil {
-			return fmt.Errorf("invalid signatures map %d", len(tx.SignaturesMap))
-		}
-	} else {
-		if len(tx.Inputs) != len(tx.SignaturesMap) && txType != TransactionTypeNodeAccept &&
-			txType != TransactionTypeNodeRemove {
-			return fmt.Errorf("invalid tx signature number %d %d %d",
-				len(tx.Inputs), len(tx.SignaturesMap), txType)
+	for _, r := range ver.formatRules(txType, snapTime) {
+		err := r.check()
+		if err != nil {
+			return err
 		}
 	}
 
@@ -52,7 +22,7 @@ func (ver *VersionedTransaction) Validate(store DataStore, snapTime uint64, fork
 	if err != nil {
 		return err
 	}
-	inputsFilter, inputAmount, err := tx.validateInputs(store, ver.PayloadHash(), txType, fork)
+	inputsFilter, inputAmount, err := tx.validateInputs(store, ver.PayloadHash(), txType, snapTime, fork)
 	if err != nil {
 		return err
 	}
@@ -63,7 +33,64 @@ func (ver *VersionedTransaction) Validate(store DataStore, snapTime uint64, fork
 	if err != nil {
 		return err
 	}
+	return ver.validateType(store, txType, inputsFilter, snapTime)
+}
+
+type validationRule struct {
+	name  string
+	check func() error
+}
+
+// formatRules are independent of each other and the store, so they could
+// all be checked to report every failure
+func (ver *VersionedTransaction) formatRules(txType uint8, snapTime uint64) []*validationRule {
+	tx := &ver.SignedTransaction
+	return []*validationRule{{"version", func() error {
+		return CheckTxVersionActivation(ver.Version, snapTime)
+	}}, {"type", func() error {
+		if txType == TransactionTypeUnknown {
+			return fmt.Errorf("invalid tx type %d", txType)
+		}
+		return nil
+	}}, {"count", func() error {
+		if len(tx.Inputs) < 1 || len(tx.Outputs) < 1 {
+			return fmt.Errorf("invalid tx inputs or outputs %d %d",
+				len(tx.Inputs), len(tx.Outputs))
+		}
+		if len(tx.Inputs) > SliceCountLimit || len(tx.Outputs) > SliceCountLimit ||
+			len(tx.References) > SliceCountLimit {
+			return fmt.Errorf("invalid tx inputs or outputs %d %d %d",
+				len(tx.Inputs), len(tx.Outputs), len(tx.References))
+		}
+		return nil
+	}}, {"extra", func() error {
+		if len(tx.Extra) > tx.GetExtraLimit() {
+			return fmt.Errorf("invalid extra size %d", len(tx.Extra))
+		}
+		return nil
+	}}, {"size", func() error {
+		if len(ver.PayloadMarshal()) > config.TransactionMaximumSize {
+			return fmt.Errorf("invalid transaction size %d", len(ver.PayloadMarshal()))
+		}
+		return nil
+	}}, {"signatures", func() error {
+		if tx.AggregatedSignature != nil {
+			if tx.SignaturesMap != nil {
+				return fmt.Errorf("invalid signatures map %d", len(tx.SignaturesMap))
+			}
+		} else {
+			if len(tx.Inputs) != len(tx.SignaturesMap) && txType != TransactionTypeNodeAccept &&
+				txType != TransactionTypeNodeRemove {
+				return fmt.Errorf("invalid tx signature number %d %d %d",
+					len(tx.Inputs), len(tx.SignaturesMap), txType)
+			}
+		}
+		return nil
+	}}}
+}
 
+func (ver *VersionedTransaction) validateType(store DataStore, txType uint8, inputsFilter map[string]*UTXO, snapTime uint64) error {
+	tx := &ver.SignedTransaction
 	switch txType {
 	case TransactionTypeScript:
 		return validateScriptTransaction(inputsFilter)
@@ -121,6 +148,18 @@ func (tx *SignedTransaction) GetExtraLimit() int {
 	return int(limit)
 }
 
+// StorageFee is the XIN amount paid by the storage output, zero if none
+func (tx *SignedTransaction) StorageFee() Integer {
+	if tx.Asset != XINAssetId {
+		return Zero
+	}
+	out := tx.findStorageOutput()
+	if out == nil {
+		return Zero
+	}
+	return out.Amount
+}
+
 func (tx *SignedTransaction) findStorageOutput() *Output {
 	var so *Output
 	for _, out := range tx.Outputs {
@@ -155,11 +194,12 @@ func validateReferences(store TransactionReader, tx *SignedTransaction) error {
 	}
 
 	for _, r := range tx.References {
-		rtx, snap, err := store.ReadTransaction(r)
+		_, snap, err := store.ReadTransaction(r)
 		if err != nil {
 			return err
 		}
-		if rtx == nil || snap == "" {
+		// the finalized reference may have been pruned
+		if snap == "" {
 			return fmt.Errorf("reference not found %s", r)
 		}
 	}
@@ -167,7 +207,7 @@ func validateReferences(store TransactionReader, tx *SignedTransaction) error {
 	return nil
 }
 
-func (tx *SignedTransaction) validateInputs(store UTXOLockReader, hash crypto.Hash, txType uint8, fork bool) (map[string]*UTXO, Integer, error) {
+func (tx *SignedTransaction) validateInputs(store DataStore, hash crypto.Hash, txType uint8, snapTime uint64, fork bool) (map[string]*UTXO, Integer, error) {
 	inputAmount := NewInteger(0)
 	inputsFilter := make(map[string]*UTXO)
 	allKeys := make([]*crypto.Key, 0)
@@ -177,6 +217,12 @@ func (tx *SignedTransaction) validateInputs(store UTXOLockReader, hash crypto.Ha
 		if len(in.Genesis) > 0 {
 			return inputsFilter, inputAmount, fmt.Errorf("invalid genesis %v", in)
 		}
+		if (len(in.Data) > 0 || in.Branch > 0) && (in.Mint != nil || in.Deposit != nil) {
+			return inputsFilter, inputAmount, fmt.Errorf("invalid input data %d %x", in.Branch, in.Data)
+		}
+		if len(in.Data) > InputDataSizeLimit {
+			return inputsFilter, inputAmount, fmt.Errorf("invalid input data size %d", len(in.Data))
+		}
 		if in.Mint != nil {
 			return inputsFilter, in.Mint.Amount, nil
 		}
@@ -208,7 +254,7 @@ func (tx *SignedTransaction) validateInputs(store UTXOLockReader, hash crypto.Ha
 			}
 		}
 
-		err = validateUTXO(i, &utxo.UTXO, tx.SignaturesMap, tx.AggregatedSignature, txType, keySigs, len(allKeys))
+		err = validateUTXO(store, i, &utxo.UTXO, in, tx.SignaturesMap, tx.AggregatedSignature, txType, keySigs, len(allKeys), snapTime)
 		if err != nil {
 			return inputsFilter, inputAmount, err
 		}
@@ -290,10 +336,13 @@ func (tx *Transaction) validateOutputs(store GhostLocker, hash crypto.Hash, inpu
 				return fmt.Errorf("invalid output empty mask %s for kernel multisig transaction", o.Mask)
 			}
 		default:
-			err := o.Script.VerifyFormat()
+			err := o.Script.VerifyKeys(len(o.Keys))
 			if err != nil {
 				return err
 			}
+			if tx.Version < TxVersionExtendedScript && !o.Script.IsThreshold() {
+				return fmt.Errorf("invalid script %s for tx version %d", o.Script, tx.Version)
+			}
 			if !o.Mask.HasValue() {
 				return fmt.Errorf("invalid script output empty mask %s", o.Mask)
 			}
@@ -314,11 +363,12 @@ func (tx *Transaction) validateOutputs(store GhostLocker, hash crypto.Hash, inpu
 	return nil
 }
 
-func validateUTXO(index int, utxo *UTXO, sigs []map[uint16]*crypto.Signature, as *AggregatedSignature, txType uint8, keySigs map[*crypto.Key]*crypto.Signature, offset int) error {
+func validateUTXO(store DataStore, index int, utxo *UTXO, in *Input, sigs []map[uint16]*crypto.Signature, as *AggregatedSignature, txType uint8, keySigs map[*crypto.Key]*crypto.Signature, offset int, snapTime uint64) error {
 	switch utxo.Type {
 	case OutputTypeScript, OutputTypeNodeRemove:
+		var signers []int
 		if as != nil {
-			signers, limit := 0, offset+len(utxo.Keys)
+			limit := offset + len(utxo.Keys)
 			for _, m := range as.Signers {
 				if m >= limit {
 					break
@@ -326,18 +376,32 @@ func validateUTXO(index int, utxo *UTXO, sigs []map[uint16]*crypto.Signature, as
 					continue
 				}
 				keySigs[utxo.Keys[m-offset]] = nil
-				signers += 1
+				signers = append(signers, m-offset)
 			}
-			return utxo.Script.Validate(signers)
 		} else {
 			for i, sig := range sigs[index] {
 				if int(i) >= len(utxo.Keys) {
 					return fmt.Errorf("invalid signature map index %d %d", i, len(utxo.Keys))
 				}
 				keySigs[utxo.Keys[i]] = sig
+				signers = append(signers, int(i))
 			}
-			return utxo.Script.Validate(len(sigs[index]))
 		}
+		_, err := utxo.Script.ValidateSpend(&ScriptSpend{
+			Branch:   in.Branch,
+			Signers:  signers,
+			Data:     in.Data,
+			SnapTime: snapTime,
+			Created: func() (uint64, error) {
+				return readUTXOTimestamp(store, utxo)
+			},
+		})
+		return err
+	}
+	if in.Branch > 0 || len(in.Data) > 0 {
+		return fmt.Errorf("invalid input data %d %x for type %d", in.Branch, in.Data, utxo.Type)
+	}
+	switch utxo.Type {
 	case OutputTypeNodePledge:
 		if txType == TransactionTypeNodeAccept || txType == TransactionTypeNodeCancel {
 			return nil
@@ -354,3 +418,22 @@ func validateUTXO(index int, utxo *UTXO, sigs []map[uint16]*crypto.Signature, as
 		return fmt.Errorf("invalid input type %d", utxo.Type)
 	}
 }
+
+func readUTXOTimestamp(store DataStore, utxo *UTXO) (uint64, error) {
+	_, final, err := store.ReadTransaction(utxo.Hash)
+	if err != nil {
+		return 0, err
+	}
+	snap, err := crypto.HashFromString(final)
+	if err != nil {
+		return 0, fmt.Errorf("utxo transaction not finalized %s", utxo.Hash)
+	}
+	topo, err := store.ReadSnapshot(snap)
+	if err != nil {
+		return 0, err
+	}
+	if topo == nil {
+		return 0, fmt.Errorf("utxo snapshot not found %s %s", utxo.Hash, snap)
+	}
+	return topo.Timestamp, nil
+}
@@ -38,6 +38,36 @@ func UnmarshalVersionedTransaction(val []byte) (*VersionedTransaction, error) {
 	return unmarshalVersionedTransaction(val)
 }
 
+// UnmarshalVersionedTransactionAt decodes the transaction to be included in a
+// snapshot at snapTime, so the versions not activated yet are rejected
+func UnmarshalVersionedTransactionAt(val []byte, snapTime uint64) (*VersionedTransaction, error) {
+	ver, err := unmarshalVersionedTransaction(val)
+	if err != nil {
+		return nil, err
+	}
+	err = CheckTxVersionActivation(ver.Version, snapTime)
+	if err != nil {
+		return nil, err
+	}
+	return ver, nil
+}
+
+// CheckTxVersionActivation must be checked with the snapshot time, all nodes
+// will accept the TxVersionExtendedScript transactions since the activation
+func CheckTxVersionActivation(version uint8, snapTime uint64) error {
+	switch version {
+	case TxVersionHashSignature:
+		return nil
+	case TxVersionExtendedScript:
+		if snapTime < config.KernelExtendedScriptActivation {
+			return fmt.Errorf("tx version %d not activated until %d %d",
+				version, config.KernelExtendedScriptActivation, snapTime)
+		}
+		return nil
+	}
+	return fmt.Errorf("invalid tx version %d", version)
+}
+
 func (ver *VersionedTransaction) Marshal() []byte {
 	val := ver.marshal()
 	if config.Debug {
@@ -85,6 +115,7 @@ func checkTxVersion(val []byte) uint8 {
 	}
 	for _, i := range []byte{
 		TxVersionHashSignature,
+		TxVersionExtendedScript,
 	} {
 		v := append(magic, 0, i)
 		if bytes.Equal(v, val[:4]) {
@@ -124,7 +155,7 @@ func unmarshalVersionedTransaction(val []byte) (*VersionedTransaction, error) {
 
 func (ver *VersionedTransaction) marshal() []byte {
 	switch ver.Version {
-	case TxVersionHashSignature:
+	case TxVersionHashSignature, TxVersionExtendedScript:
 		return NewEncoder().EncodeTransaction(&ver.SignedTransaction)
 	default:
 		panic(ver.Version)
@@ -133,7 +164,7 @@ func (ver *VersionedTransaction) marshal() []byte {
 
 func (ver *VersionedTransaction) payloadMarshal() []byte {
 	switch ver.Version {
-	case TxVersionHashSignature:
+	case TxVersionHashSignature, TxVersionExtendedScript:
 		signed := &SignedTransaction{Transaction: ver.Transaction}
 		return NewEncoder().EncodeTransaction(signed)
 	default:
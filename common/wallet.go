@@ -0,0 +1,47 @@
+package common
+
+import (
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+// WalletOutput is a script output owned by a registered wallet key, the
+// sequence is the scanning order in the wallet, and spent is the hash of
+// the finalized transaction consuming it
+type WalletOutput struct {
+	Sequence  uint64        `json:"sequence"`
+	Asset     crypto.Hash   `json:"asset"`
+	Hash      crypto.Hash   `json:"hash"`
+	Index     uint          `json:"index"`
+	Amount    Integer       `json:"amount"`
+	Keys      []*crypto.Key `json:"keys"`
+	Mask      crypto.Key    `json:"mask"`
+	Script    Script        `json:"script"`
+	Snapshot  crypto.Hash   `json:"snapshot"`
+	Timestamp uint64        `json:"timestamp"`
+	Spent     crypto.Hash   `json:"spent"`
+}
+
+type WalletSpend struct {
+	Hash        crypto.Hash
+	Index       uint
+	Transaction crypto.Hash
+}
+
+// ViewOwnedOutputs returns the indexes of all script outputs with any ghost
+// key derived to the public spend key by the private view key
+func (tx *VersionedTransaction) ViewOwnedOutputs(view, spend *crypto.Key) []uint {
+	var owned []uint
+	for i, o := range tx.Outputs {
+		if o.Type != OutputTypeScript {
+			continue
+		}
+		for _, k := range o.Keys {
+			key := crypto.ViewGhostOutputKey(k, view, &o.Mask, uint64(i))
+			if *key == *spend {
+				owned = append(owned, uint(i))
+				break
+			}
+		}
+	}
+	return owned
+}
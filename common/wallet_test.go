@@ -0,0 +1,29 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestViewOwnedOutputs(t *testing.T) {
+	require := require.New(t)
+
+	owner := randomAccount()
+	other := randomAccount()
+	script := NewThresholdScript(1)
+
+	tx := NewTransactionV5(XINAssetId).AsVersioned()
+	tx.AddInput(crypto.Hash{}, 0)
+	tx.AddRandomScriptOutput([]*Address{&other}, script, NewInteger(1))
+	tx.AddRandomScriptOutput([]*Address{&owner}, script, NewInteger(2))
+	tx.AddRandomScriptOutput([]*Address{&other, &owner}, script, NewInteger(3))
+
+	owned := tx.ViewOwnedOutputs(&owner.PrivateViewKey, &owner.PublicSpendKey)
+	require.Equal([]uint{1, 2}, owned)
+	owned = tx.ViewOwnedOutputs(&other.PrivateViewKey, &other.PublicSpendKey)
+	require.Equal([]uint{0, 2}, owned)
+	owned = tx.ViewOwnedOutputs(&owner.PrivateViewKey, &other.PublicSpendKey)
+	require.Len(owned, 0)
+}
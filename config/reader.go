@@ -1,7 +1,10 @@
 package config
 
 import (
+	"bytes"
+	"fmt"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/MixinNetwork/mixin/crypto"
@@ -9,6 +12,8 @@ import (
 )
 
 const (
+	SignerPassphraseEnv = "MIXIN_SIGNER_PASSPHRASE"
+
 	Debug           = true
 	BuildVersion    = "v0.18.24-BUILD_VERSION"
 	KernelNetworkId = "74c6cdb7d51af57037faa1f5544f8331ced001df5964331911ca51385993b375"
@@ -35,36 +40,60 @@ const (
 	KernelNodePledgePeriodMinimum = 12 * time.Hour
 	KernelNodeAcceptPeriodMinimum = 12 * time.Hour
 	KernelNodeAcceptPeriodMaximum = 7 * 24 * time.Hour
+
+	KernelExtendedScriptActivation = uint64(1798761600000000000) // 2027-01-01 UTC
 )
 
 type Custom struct {
 	Node struct {
 		Signer               crypto.Key `toml:"-"`
 		SignerStr            string     `toml:"signer-key"`
+		SignerMnemonicFile   string     `toml:"signer-mnemonic-file"`
+		SignerKeystoreFile   string     `toml:"signer-keystore-file"`
+		SignerPassphraseFile string     `toml:"signer-passphrase-file"`
 		KernelOprationPeriod int        `toml:"kernel-operation-period"`
 		MemoryCacheSize      int        `toml:"memory-cache-size"`
 		CacheTTL             int        `toml:"cache-ttl"`
+		CachePriority        []string   `toml:"cache-priority"`
 	} `toml:"node"`
 	Storage struct {
-		ValueLogGC          bool `toml:"value-log-gc"`
-		MaxCompactionLevels int  `toml:"max-compaction-levels"`
+		ValueLogGC          bool   `toml:"value-log-gc"`
+		MaxCompactionLevels int    `toml:"max-compaction-levels"`
+		Prune               bool   `toml:"prune"`
+		PruneWindow         uint64 `toml:"prune-window"`
 	} `toml:"storage"`
 	P2P struct {
-		Port    int      `toml:"port"`
-		Seeds   []string `toml:"seeds"`
-		Relayer bool     `toml:"relayer"`
-		Metric  bool     `toml:"metric"`
+		Port          int      `toml:"port"`
+		Seeds         []string `toml:"seeds"`
+		Relayer       bool     `toml:"relayer"`
+		Metric        bool     `toml:"metric"`
+		Transports    []string `toml:"transports"`
+		SeedTransport string   `toml:"seed-transport"`
 	} `toml:"p2p"`
 	RPC struct {
 		Port         int  `toml:"port"`
 		Runtime      bool `toml:"runtime"`
 		ObjectServer bool `toml:"object-server"`
+		Subscription bool `toml:"subscription"`
+		Metrics      bool `toml:"metrics"`
 	} `toml:"rpc"`
+	Wallet struct {
+		Scan    bool         `toml:"scan"`
+		Keys    []*WalletKey `toml:"-"`
+		KeysStr []string     `toml:"keys"`
+	} `toml:"wallet"`
 	Dev struct {
 		Port int `toml:"port"`
 	} `toml:"dev"`
 }
 
+// WalletKey is registered for the output scanning, the private view key
+// finds the outputs and the public spend key identifies the owner
+type WalletKey struct {
+	PrivateView crypto.Key
+	PublicSpend crypto.Key
+}
+
 func Initialize(file string) (*Custom, error) {
 	f, err := os.ReadFile(file)
 	if err != nil {
@@ -75,11 +104,34 @@ func Initialize(file string) (*Custom, error) {
 	if err != nil {
 		return nil, err
 	}
-	key, err := crypto.KeyFromString(config.Node.SignerStr)
+	key, err := readSigner(file, &config)
 	if err != nil {
 		return nil, err
 	}
 	config.Node.Signer = key
+	for _, ks := range config.Wallet.KeysStr {
+		if len(ks) != 128 {
+			return nil, fmt.Errorf("invalid wallet key %s", ks)
+		}
+		view, err := crypto.KeyFromString(ks[:64])
+		if err != nil {
+			return nil, err
+		}
+		spend, err := crypto.KeyFromString(ks[64:])
+		if err != nil {
+			return nil, err
+		}
+		config.Wallet.Keys = append(config.Wallet.Keys, &WalletKey{
+			PrivateView: view,
+			PublicSpend: spend,
+		})
+	}
+	if config.Storage.PruneWindow == 0 {
+		config.Storage.PruneWindow = 100000
+	}
+	if config.Storage.Prune && config.Storage.PruneWindow < SnapshotSyncRoundThreshold*10 {
+		return nil, fmt.Errorf("prune window %d too small", config.Storage.PruneWindow)
+	}
 	if config.Node.KernelOprationPeriod == 0 {
 		config.Node.KernelOprationPeriod = 700
 	}
@@ -89,5 +141,90 @@ func Initialize(file string) (*Custom, error) {
 	if config.Node.CacheTTL == 0 {
 		config.Node.CacheTTL = 3600 * 2
 	}
+	if config.Node.CachePriority == nil {
+		config.Node.CachePriority = []string{"kernel", "fee", "fair"}
+	}
+	for _, p := range config.Node.CachePriority {
+		switch p {
+		case "kernel", "fee", "fair":
+		default:
+			return nil, fmt.Errorf("invalid cache priority %s", p)
+		}
+	}
+	if len(config.P2P.Transports) == 0 {
+		config.P2P.Transports = []string{"quic"}
+	}
+	if config.P2P.SeedTransport == "" {
+		config.P2P.SeedTransport = "quic"
+	}
+	for _, t := range append(config.P2P.Transports, config.P2P.SeedTransport) {
+		switch t {
+		case "quic", "tcp":
+		default:
+			return nil, fmt.Errorf("invalid p2p transport %s", t)
+		}
+	}
 	return &config, nil
 }
+
+// readSigner reads the signer from one of the hex key, the mnemonic file and
+// the encrypted keystore file, a relative file path is relative to the config
+// file directory
+func readSigner(file string, config *Custom) (crypto.Key, error) {
+	node := config.Node
+	configured := 0
+	for _, s := range []string{node.SignerStr, node.SignerMnemonicFile, node.SignerKeystoreFile} {
+		if s != "" {
+			configured++
+		}
+	}
+	if configured > 1 {
+		return crypto.Key{}, fmt.Errorf("only one of signer-key, signer-mnemonic-file and signer-keystore-file allowed")
+	}
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(filepath.Dir(file), p)
+	}
+
+	switch {
+	case node.SignerMnemonicFile != "":
+		words, err := os.ReadFile(resolve(node.SignerMnemonicFile))
+		if err != nil {
+			return crypto.Key{}, err
+		}
+		return crypto.KeyFromMnemonic(string(words))
+	case node.SignerKeystoreFile != "":
+		b, err := os.ReadFile(resolve(node.SignerKeystoreFile))
+		if err != nil {
+			return crypto.Key{}, err
+		}
+		ks, err := crypto.KeystoreFromJSON(b)
+		if err != nil {
+			return crypto.Key{}, err
+		}
+		passphrase, err := ReadPassphrase(resolve(node.SignerPassphraseFile), SignerPassphraseEnv)
+		if err != nil {
+			return crypto.Key{}, err
+		}
+		return ks.Decrypt(passphrase)
+	}
+	return crypto.KeyFromString(node.SignerStr)
+}
+
+// ReadPassphrase reads the passphrase from the file without the trailing line
+// break, or from the environment variable if no file
+func ReadPassphrase(file, env string) ([]byte, error) {
+	if file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.TrimRight(b, "\r\n"), nil
+	}
+	if p := os.Getenv(env); p != "" {
+		return []byte(p), nil
+	}
+	return nil, fmt.Errorf("passphrase file or environment variable %s required", env)
+}
@@ -1,8 +1,12 @@
 package config
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/stretchr/testify/require"
 )
 
@@ -19,9 +23,105 @@ func TestConfig(t *testing.T) {
 
 	require.Equal(true, custom.Storage.ValueLogGC)
 	require.Equal(7, custom.Storage.MaxCompactionLevels)
+	require.Equal(false, custom.Storage.Prune)
+	require.Equal(uint64(100000), custom.Storage.PruneWindow)
 
 	require.Equal(false, custom.P2P.Relayer)
+	require.Equal([]string{"quic"}, custom.P2P.Transports)
+	require.Equal("quic", custom.P2P.SeedTransport)
 	require.Len(custom.P2P.Seeds, 4)
 	require.Equal("06ff8589d5d8b40dd90a8120fa65b273d136ba4896e46ad20d76e53a9b73fd9f@seed.mixin.dev:5850", custom.P2P.Seeds[0])
 	require.Equal(false, custom.RPC.Runtime)
+	require.Equal(false, custom.RPC.Metrics)
+}
+
+func TestPruneWindow(t *testing.T) {
+	require := require.New(t)
+
+	example, err := os.ReadFile("./config.example.toml")
+	require.Nil(err)
+
+	file := filepath.Join(t.TempDir(), "config.toml")
+	data := strings.Replace(string(example), "prune-window = 100000", "prune-window = 10", 1)
+	err = os.WriteFile(file, []byte(data), 0600)
+	require.Nil(err)
+	custom, err := Initialize(file)
+	require.Nil(err)
+	require.Equal(uint64(10), custom.Storage.PruneWindow)
+
+	data = strings.Replace(data, "prune = false", "prune = true", 1)
+	err = os.WriteFile(file, []byte(data), 0600)
+	require.Nil(err)
+	_, err = Initialize(file)
+	require.ErrorContains(err, "prune window 10 too small")
+}
+
+func TestSignerMnemonic(t *testing.T) {
+	require := require.New(t)
+
+	example, err := os.ReadFile("./config.example.toml")
+	require.Nil(err)
+	key := "8bcfad3959892e8334fa287a3c9755fed017cd7a9e8c68d7540dc9e69fa4a00d"
+	signer, err := crypto.KeyFromString(key)
+	require.Nil(err)
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.toml")
+	data := strings.Replace(string(example), "# signer-mnemonic-file", "signer-mnemonic-file", 1)
+	err = os.WriteFile(file, []byte(data), 0600)
+	require.Nil(err)
+	err = os.WriteFile(filepath.Join(dir, "signer.mnemonic"), []byte(signer.Mnemonic()+"\n"), 0600)
+	require.Nil(err)
+	_, err = Initialize(file)
+	require.ErrorContains(err, "only one of signer-key, signer-mnemonic-file and signer-keystore-file allowed")
+
+	data = strings.Replace(data, key, "", 1)
+	err = os.WriteFile(file, []byte(data), 0600)
+	require.Nil(err)
+	custom, err := Initialize(file)
+	require.Nil(err)
+	require.Equal(key, custom.Node.Signer.String())
+}
+
+func TestSignerKeystore(t *testing.T) {
+	require := require.New(t)
+
+	example, err := os.ReadFile("./config.example.toml")
+	require.Nil(err)
+	key := "8bcfad3959892e8334fa287a3c9755fed017cd7a9e8c68d7540dc9e69fa4a00d"
+	signer, err := crypto.KeyFromString(key)
+	require.Nil(err)
+	ks, err := crypto.NewKeystore(signer, []byte("secret"))
+	require.Nil(err)
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.toml")
+	data := strings.Replace(string(example), key, "", 1)
+	data = strings.Replace(data, "# signer-keystore-file", "signer-keystore-file", 1)
+	err = os.WriteFile(file, []byte(data), 0600)
+	require.Nil(err)
+	err = os.WriteFile(filepath.Join(dir, "signer.keystore"), ks.JSON(), 0600)
+	require.Nil(err)
+
+	t.Setenv(SignerPassphraseEnv, "")
+	_, err = Initialize(file)
+	require.ErrorContains(err, "environment variable MIXIN_SIGNER_PASSPHRASE required")
+	t.Setenv(SignerPassphraseEnv, "wrong")
+	_, err = Initialize(file)
+	require.ErrorContains(err, "invalid keystore passphrase")
+	t.Setenv(SignerPassphraseEnv, "secret")
+	custom, err := Initialize(file)
+	require.Nil(err)
+	require.Equal(key, custom.Node.Signer.String())
+
+	pf := filepath.Join(dir, "passphrase")
+	err = os.WriteFile(pf, []byte("secret\n"), 0600)
+	require.Nil(err)
+	data = strings.Replace(data, "# signer-passphrase-file = \"/run/secrets/mixin-signer-passphrase\"", "signer-passphrase-file = \"passphrase\"", 1)
+	err = os.WriteFile(file, []byte(data), 0600)
+	require.Nil(err)
+	t.Setenv(SignerPassphraseEnv, "wrong")
+	custom, err = Initialize(file)
+	require.Nil(err)
+	require.Equal(key, custom.Node.Signer.String())
 }
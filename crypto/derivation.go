@@ -0,0 +1,166 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"filippo.io/edwards25519"
+	"github.com/zeebo/blake3"
+)
+
+const (
+	HardenedKeyStart = 0x80000000
+
+	extendedKeyPrivate = 0x00
+	extendedKeyPublic  = 0x01
+)
+
+// ExtendedKey is a private or public key with the chain code to derive the
+// children. The private child is the parent plus a tweak scalar, and the
+// public child is the parent point plus the tweak point, so the public
+// children could be derived without the private key, except the hardened ones.
+type ExtendedKey struct {
+	Key       Key
+	ChainCode [32]byte
+	Private   bool
+}
+
+func NewExtendedKeyFromSeed(seed []byte) *ExtendedKey {
+	out := make([]byte, 96)
+	blake3.DeriveKey("Mixin HD master key", seed, out)
+	k := &ExtendedKey{Key: NewKeyFromSeed(out[:64]), Private: true}
+	copy(k.ChainCode[:], out[64:])
+	return k
+}
+
+func ExtendedKeyFromString(s string) (*ExtendedKey, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) != 65 {
+		return nil, fmt.Errorf("invalid extended key size %d", len(b))
+	}
+	k := &ExtendedKey{Private: b[0] == extendedKeyPrivate}
+	copy(k.Key[:], b[1:33])
+	copy(k.ChainCode[:], b[33:])
+	switch b[0] {
+	case extendedKeyPrivate:
+		_, err = edwards25519.NewScalar().SetCanonicalBytes(k.Key[:])
+	case extendedKeyPublic:
+		_, err = edwards25519.NewIdentityPoint().SetBytes(k.Key[:])
+	default:
+		err = fmt.Errorf("invalid extended key version %d", b[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+func (k *ExtendedKey) PublicKey() Key {
+	if k.Private {
+		return k.Key.Public()
+	}
+	return k.Key
+}
+
+func (k *ExtendedKey) Public() *ExtendedKey {
+	return &ExtendedKey{Key: k.PublicKey(), ChainCode: k.ChainCode}
+}
+
+// Child derives the child key of the index, the hardened index requires the
+// private key, and the tweak is hashed from the private key instead
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	h, err := blake3.NewKeyed(k.ChainCode[:])
+	if err != nil {
+		panic(err)
+	}
+	if index >= HardenedKeyStart {
+		if !k.Private {
+			return nil, fmt.Errorf("hardened child %d from public key", index-HardenedKeyStart)
+		}
+		h.Write([]byte{extendedKeyPrivate})
+		h.Write(k.Key[:])
+	} else {
+		pub := k.PublicKey()
+		h.Write([]byte{extendedKeyPublic})
+		h.Write(pub[:])
+	}
+	h.Write(binary.BigEndian.AppendUint32(nil, index))
+	out := make([]byte, 96)
+	_, err = h.Digest().Read(out)
+	if err != nil {
+		panic(err)
+	}
+
+	t, err := edwards25519.NewScalar().SetUniformBytes(out[:64])
+	if err != nil {
+		panic(err)
+	}
+	child := &ExtendedKey{Private: k.Private}
+	copy(child.ChainCode[:], out[64:])
+	if k.Private {
+		x, err := edwards25519.NewScalar().SetCanonicalBytes(k.Key[:])
+		if err != nil {
+			return nil, err
+		}
+		copy(child.Key[:], edwards25519.NewScalar().Add(x, t).Bytes())
+	} else {
+		p, err := edwards25519.NewIdentityPoint().SetBytes(k.Key[:])
+		if err != nil {
+			return nil, err
+		}
+		q := edwards25519.NewIdentityPoint().ScalarBaseMult(t)
+		copy(child.Key[:], edwards25519.NewIdentityPoint().Add(p, q).Bytes())
+	}
+	if !child.Key.HasValue() || child.PublicKey() == (Key{1}) {
+		return nil, fmt.Errorf("invalid child %d", index)
+	}
+	return child, nil
+}
+
+func (k *ExtendedKey) Derive(path []uint32) (*ExtendedKey, error) {
+	for _, i := range path {
+		c, err := k.Child(i)
+		if err != nil {
+			return nil, err
+		}
+		k = c
+	}
+	return k, nil
+}
+
+func (k *ExtendedKey) String() string {
+	version := byte(extendedKeyPublic)
+	if k.Private {
+		version = extendedKeyPrivate
+	}
+	b := append([]byte{version}, k.Key[:]...)
+	return hex.EncodeToString(append(b, k.ChainCode[:]...))
+}
+
+// ParseDerivationPath parses the path like m/0'/1/2, the index with the
+// apostrophe or h suffix is hardened
+func ParseDerivationPath(path string) ([]uint32, error) {
+	parts := strings.Split(path, "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("invalid derivation path %s", path)
+	}
+	indexes := make([]uint32, 0)
+	for _, p := range parts[1:] {
+		var hardened uint32
+		if strings.HasSuffix(p, "'") || strings.HasSuffix(p, "h") {
+			p, hardened = p[:len(p)-1], HardenedKeyStart
+		}
+		i, err := strconv.ParseUint(p, 10, 32)
+		if err != nil || i >= HardenedKeyStart {
+			return nil, fmt.Errorf("invalid derivation path %s", path)
+		}
+		indexes = append(indexes, uint32(i)+hardened)
+	}
+	return indexes, nil
+}
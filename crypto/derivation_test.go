@@ -0,0 +1,68 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtendedKey(t *testing.T) {
+	require := require.New(t)
+	seed := make([]byte, 64)
+	for i := 0; i < len(seed); i++ {
+		seed[i] = byte(i + 1)
+	}
+	master := NewExtendedKeyFromSeed(seed)
+	require.Equal("00760cd96d0ffdf42b65059b12ee08b16d59e0396b74c14d8d269f6639d9f63801f8ee97d930b1ceac799cea35ed2829bf367d811e669916b9e566a6b9cfe34328", master.String())
+	require.Equal("b52ff4b2a8c51517571ae02e4527d59df3bc08e1dd65dae74ae8df537e9c03a3", master.PublicKey().String())
+
+	vectors := [][3]string{
+		{"m/0", "00fc8db44c36f845119c804fc44a81dd0db1ffc31d751d8d3a31789c9e833e27091fe83367915a403481c3b598d162e8e7e7a20b270f4c7ef03e3a5e4200cf7d4e", "01fa581a54f688a18af577dfda8106a6e47bd505cfc8f4449312f5604b2ba581"},
+		{"m/0'", "00f34aeecfae572e94770800e818f8aa738699d8c759c99ad8c8cf1a605a247c0b2f74a18eed46ba753ab7c38eccbc9282adaf93ef96b137316912f970097d273c", "c0e4d7691887be5ff7502e0b4c4e9c629b0d51877d619c919d21a5d127caca4f"},
+		{"m/0h/1", "0041058bcc76568c6c66f78e9673e1118adf2919832a64581cb36fa10ec6e8f10e76ddbab8b5999a674de0d2a8932affa312d6b972395ceab84161f9a3e9957d4e", "87b016f94b89729c7c7ea0b18d4a853927e0e5e93cce2cd3c11faa968fa087ab"},
+		{"m/44'/2365'/0'/0/7", "0073a3a769fbd066cb89fd6b0b35f9775c2df179d5180765cbbee932d1b60c3800df56e58c012378ec2dc13a0c1e39dce2a972f3329d6deb8534087623d5b199b8", "4f5ec293046df6913397531a00710a59f26eb1890cc7715e26d7e199809e620b"},
+	}
+	for _, v := range vectors {
+		path, err := ParseDerivationPath(v[0])
+		require.Nil(err)
+		child, err := master.Derive(path)
+		require.Nil(err)
+		require.Equal(v[1], child.String())
+		require.Equal(v[2], child.PublicKey().String())
+		require.Equal(v[2], child.Key.Public().String())
+	}
+
+	path, _ := ParseDerivationPath("m/44'/2365'/0'")
+	account, err := master.Derive(path)
+	require.Nil(err)
+	xpub, err := ExtendedKeyFromString(account.Public().String())
+	require.Nil(err)
+	require.False(xpub.Private)
+	for i := uint32(0); i < 8; i++ {
+		priv, err := account.Derive([]uint32{0, i})
+		require.Nil(err)
+		pub, err := xpub.Derive([]uint32{0, i})
+		require.Nil(err)
+		require.False(pub.Private)
+		require.Equal(priv.Key.Public(), pub.Key)
+		require.Equal(priv.ChainCode, pub.ChainCode)
+	}
+	_, err = xpub.Child(HardenedKeyStart)
+	require.ErrorContains(err, "hardened child 0 from public key")
+
+	for _, p := range []string{"", "0/1", "m/", "m/x", "m/2147483648", "m/1''", "n/1"} {
+		_, err := ParseDerivationPath(p)
+		require.NotNil(err, p)
+	}
+	path, err = ParseDerivationPath("m")
+	require.Nil(err)
+	require.Len(path, 0)
+	path, err = ParseDerivationPath("m/2147483647'/1")
+	require.Nil(err)
+	require.Equal([]uint32{0xffffffff, 1}, path)
+
+	_, err = ExtendedKeyFromString(master.String()[2:])
+	require.NotNil(err)
+	_, err = ExtendedKeyFromString("02" + master.String()[2:])
+	require.ErrorContains(err, "invalid extended key version 2")
+}
@@ -0,0 +1,138 @@
+package crypto
+
+import (
+	"crypto/cipher"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"filippo.io/edwards25519"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const (
+	KeystoreVersion = 1
+
+	keystoreKDF    = "argon2id"
+	keystoreCipher = "xchacha20-poly1305"
+)
+
+type KeystoreKDF struct {
+	Name    string `json:"name"`
+	Salt    string `json:"salt"`
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+}
+
+// Keystore is the private key encrypted with the key stretched from the
+// passphrase by argon2id, the public key is in plaintext to identify the
+// keystore, and it is authenticated as the additional data
+type Keystore struct {
+	Version    int         `json:"version"`
+	Public     Key         `json:"public"`
+	KDF        KeystoreKDF `json:"kdf"`
+	Cipher     string      `json:"cipher"`
+	Nonce      string      `json:"nonce"`
+	Ciphertext string      `json:"ciphertext"`
+}
+
+func NewKeystore(key Key, passphrase []byte) (*Keystore, error) {
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("empty keystore passphrase")
+	}
+	_, err := edwards25519.NewScalar().SetCanonicalBytes(key[:])
+	if err != nil {
+		return nil, err
+	}
+	ks := &Keystore{
+		Version: KeystoreVersion,
+		Public:  key.Public(),
+		KDF: KeystoreKDF{
+			Name:    keystoreKDF,
+			Time:    3,
+			Memory:  64 * 1024,
+			Threads: 4,
+		},
+		Cipher: keystoreCipher,
+	}
+	salt := make([]byte, 32)
+	ReadRand(salt)
+	nonce := make([]byte, chacha20poly1305.NonceSizeX)
+	ReadRand(nonce)
+	ks.KDF.Salt = hex.EncodeToString(salt)
+	ks.Nonce = hex.EncodeToString(nonce)
+
+	aead, err := ks.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := aead.Seal(nil, nonce, key[:], ks.Public[:])
+	ks.Ciphertext = hex.EncodeToString(ciphertext)
+	return ks, nil
+}
+
+func KeystoreFromJSON(b []byte) (*Keystore, error) {
+	var ks Keystore
+	err := json.Unmarshal(b, &ks)
+	if err != nil {
+		return nil, err
+	}
+	if ks.Version != KeystoreVersion {
+		return nil, fmt.Errorf("invalid keystore version %d", ks.Version)
+	}
+	if ks.KDF.Name != keystoreKDF || ks.Cipher != keystoreCipher {
+		return nil, fmt.Errorf("invalid keystore kdf %s or cipher %s", ks.KDF.Name, ks.Cipher)
+	}
+	return &ks, nil
+}
+
+func (ks *Keystore) Decrypt(passphrase []byte) (Key, error) {
+	var key Key
+	nonce, err := hex.DecodeString(ks.Nonce)
+	if err != nil || len(nonce) != chacha20poly1305.NonceSizeX {
+		return key, fmt.Errorf("invalid keystore nonce %s", ks.Nonce)
+	}
+	ciphertext, err := hex.DecodeString(ks.Ciphertext)
+	if err != nil {
+		return key, err
+	}
+	aead, err := ks.aead(passphrase)
+	if err != nil {
+		return key, err
+	}
+	plain, err := aead.Open(nil, nonce, ciphertext, ks.Public[:])
+	if err != nil {
+		return key, fmt.Errorf("invalid keystore passphrase")
+	}
+	if len(plain) != len(key) {
+		return key, fmt.Errorf("invalid keystore key size %d", len(plain))
+	}
+	copy(key[:], plain)
+	if key.Public() != ks.Public {
+		return key, fmt.Errorf("invalid keystore public key %s", ks.Public)
+	}
+	return key, nil
+}
+
+func (ks *Keystore) JSON() []byte {
+	b, err := json.MarshalIndent(ks, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func (ks *Keystore) aead(passphrase []byte) (cipher.AEAD, error) {
+	salt, err := hex.DecodeString(ks.KDF.Salt)
+	if err != nil || len(salt) < 16 {
+		return nil, fmt.Errorf("invalid keystore salt %s", ks.KDF.Salt)
+	}
+	if ks.KDF.Time == 0 || ks.KDF.Memory == 0 || ks.KDF.Threads == 0 ||
+		ks.KDF.Memory > 4*1024*1024 {
+		return nil, fmt.Errorf("invalid keystore kdf params %d %d %d", ks.KDF.Time, ks.KDF.Memory, ks.KDF.Threads)
+	}
+	secret := argon2.IDKey(passphrase, salt, ks.KDF.Time, ks.KDF.Memory, ks.KDF.Threads, chacha20poly1305.KeySize)
+	return chacha20poly1305.NewX(secret)
+}
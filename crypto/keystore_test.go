@@ -0,0 +1,45 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeystore(t *testing.T) {
+	require := require.New(t)
+
+	seed := make([]byte, 64)
+	ReadRand(seed)
+	key := NewKeyFromSeed(seed)
+	_, err := NewKeystore(key, nil)
+	require.ErrorContains(err, "empty keystore passphrase")
+
+	ks, err := NewKeystore(key, []byte("passphrase"))
+	require.Nil(err)
+	require.Equal(key.Public(), ks.Public)
+	require.NotContains(string(ks.JSON()), key.String())
+
+	ks, err = KeystoreFromJSON(ks.JSON())
+	require.Nil(err)
+	restored, err := ks.Decrypt([]byte("passphrase"))
+	require.Nil(err)
+	require.Equal(key, restored)
+	_, err = ks.Decrypt([]byte("Passphrase"))
+	require.ErrorContains(err, "invalid keystore passphrase")
+
+	other, err := NewKeystore(key, []byte("passphrase"))
+	require.Nil(err)
+	require.NotEqual(ks.KDF.Salt, other.KDF.Salt)
+	require.NotEqual(ks.Ciphertext, other.Ciphertext)
+
+	ks.Public = NewKeyFromSeed(append(seed[1:], 0)).Public()
+	_, err = ks.Decrypt([]byte("passphrase"))
+	require.ErrorContains(err, "invalid keystore passphrase")
+	ks.KDF.Memory = 0
+	_, err = ks.Decrypt([]byte("passphrase"))
+	require.ErrorContains(err, "invalid keystore kdf params")
+
+	_, err = KeystoreFromJSON([]byte(`{"version":2}`))
+	require.ErrorContains(err, "invalid keystore version 2")
+}
@@ -0,0 +1,115 @@
+package crypto
+
+import (
+	"crypto/pbkdf2"
+	"crypto/sha256"
+	"crypto/sha512"
+	_ "embed"
+	"fmt"
+	"slices"
+	"strings"
+
+	"filippo.io/edwards25519"
+)
+
+//go:embed mnemonic_english.txt
+var mnemonicEnglish string
+
+var (
+	mnemonicWords   = strings.Fields(mnemonicEnglish)
+	mnemonicIndexes = make(map[string]int, len(mnemonicWords))
+)
+
+func init() {
+	for i, w := range mnemonicWords {
+		mnemonicIndexes[w] = i
+	}
+}
+
+// NewMnemonic encodes the entropy of 16 to 32 bytes as the BIP39 English
+// words, each word holds 11 bits, and the last word includes the checksum
+// from the SHA-256 of the entropy
+func NewMnemonic(entropy []byte) (string, error) {
+	if len(entropy) < 16 || len(entropy) > 32 || len(entropy)%4 != 0 {
+		return "", fmt.Errorf("invalid mnemonic entropy size %d", len(entropy))
+	}
+	sum := sha256.Sum256(entropy)
+	bits := append(slices.Clone(entropy), sum[0])
+	count := (len(entropy)*8 + len(entropy)/4) / 11
+	words := make([]string, count)
+	for i := range words {
+		index := 0
+		for j := i * 11; j < i*11+11; j++ {
+			index = index<<1 | int(bits[j/8]>>(7-j%8)&1)
+		}
+		words[i] = mnemonicWords[index]
+	}
+	return strings.Join(words, " "), nil
+}
+
+// MnemonicToEntropy decodes the words and verifies the checksum
+func MnemonicToEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(strings.ToLower(mnemonic))
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, fmt.Errorf("invalid mnemonic words count %d", len(words))
+	}
+	bits := make([]byte, (len(words)*11+7)/8)
+	for i, w := range words {
+		index, found := mnemonicIndexes[w]
+		if !found {
+			return nil, fmt.Errorf("invalid mnemonic word %s", w)
+		}
+		for j := 0; j < 11; j++ {
+			k := i*11 + j
+			bits[k/8] |= byte(index>>(10-j)&1) << (7 - k%8)
+		}
+	}
+	size := len(words) * 4 / 3
+	entropy := bits[:size]
+	sum := sha256.Sum256(entropy)
+	mask := byte(0xff) << (8 - size/4)
+	if bits[size]&mask != sum[0]&mask {
+		return nil, fmt.Errorf("invalid mnemonic checksum")
+	}
+	return entropy, nil
+}
+
+// MnemonicToSeed stretches the mnemonic and the optional passphrase to the
+// 64 bytes seed with PBKDF2-HMAC-SHA512, the same mnemonic with a different
+// passphrase results in a different seed
+func MnemonicToSeed(mnemonic, passphrase string) ([]byte, error) {
+	_, err := MnemonicToEntropy(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+	mnemonic = strings.Join(strings.Fields(strings.ToLower(mnemonic)), " ")
+	salt := []byte("mnemonic" + passphrase)
+	return pbkdf2.Key(sha512.New, mnemonic, salt, 2048, 64)
+}
+
+// Mnemonic encodes the private key itself as 24 words, so it is a backup of
+// an existing key, not a seed to derive the key
+func (k Key) Mnemonic() string {
+	m, err := NewMnemonic(k[:])
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func KeyFromMnemonic(mnemonic string) (Key, error) {
+	var key Key
+	entropy, err := MnemonicToEntropy(mnemonic)
+	if err != nil {
+		return key, err
+	}
+	if len(entropy) != len(key) {
+		return key, fmt.Errorf("invalid key mnemonic words count %d", len(entropy)*3/4)
+	}
+	_, err = edwards25519.NewScalar().SetCanonicalBytes(entropy)
+	if err != nil {
+		return key, err
+	}
+	copy(key[:], entropy)
+	return key, nil
+}
@@ -0,0 +1,55 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMnemonic(t *testing.T) {
+	require := require.New(t)
+	require.Len(mnemonicWords, 2048)
+
+	vectors := [][3]string{
+		{"00000000000000000000000000000000", "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04"},
+		{"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f", "legal winner thank year wave sausage worth useful legal winner thank yellow", "2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607"},
+		{"0000000000000000000000000000000000000000000000000000000000000000", "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art", "bda85446c68413707090a52022edd26a1c9462295029f2e60cd7c4f2bbd3097170af7a4d73245cafa9c3cca8d561a7c3de6f5d4a10be8ed2a5e608d68f92fcc8"},
+		{"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff", "zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo vote", "dd48c104698c30cfe2b6142103248622fb7bb0ff692eebb00089b32d22484e1613912f0a5b694407be899ffd31ed3992c456cdf60f5d4564b8ba3f05a69890ad"},
+	}
+	for _, v := range vectors {
+		entropy, _ := hex.DecodeString(v[0])
+		m, err := NewMnemonic(entropy)
+		require.Nil(err)
+		require.Equal(v[1], m)
+		e, err := MnemonicToEntropy(" " + strings.ToUpper(m) + "\n")
+		require.Nil(err)
+		require.Equal(v[0], hex.EncodeToString(e))
+		seed, err := MnemonicToSeed(m, "TREZOR")
+		require.Nil(err)
+		require.Equal(v[2], hex.EncodeToString(seed))
+	}
+
+	_, err := NewMnemonic(make([]byte, 15))
+	require.ErrorContains(err, "invalid mnemonic entropy size 15")
+	_, err = MnemonicToEntropy(strings.Repeat("abandon ", 12))
+	require.ErrorContains(err, "invalid mnemonic checksum")
+	_, err = MnemonicToEntropy(strings.Repeat("abandon ", 11) + "abandons")
+	require.ErrorContains(err, "invalid mnemonic word abandons")
+	_, err = MnemonicToSeed(strings.Repeat("abandon ", 11), "")
+	require.ErrorContains(err, "invalid mnemonic words count 11")
+
+	seed := make([]byte, 64)
+	ReadRand(seed)
+	key := NewKeyFromSeed(seed)
+	m := key.Mnemonic()
+	require.Len(strings.Fields(m), 24)
+	restored, err := KeyFromMnemonic(m)
+	require.Nil(err)
+	require.Equal(key, restored)
+	_, err = KeyFromMnemonic(vectors[0][1])
+	require.ErrorContains(err, "invalid key mnemonic words count 12")
+	_, err = KeyFromMnemonic(vectors[3][1])
+	require.NotNil(err)
+}
@@ -16,6 +16,8 @@ func (node *Node) Loop() error {
 	go node.sendGraphToConcensusNodesAndPeers()
 	go node.loopCacheQueue()
 	go node.MintLoop()
+	go node.WalletScanLoop()
+	go node.PruneLoop()
 	node.ElectionLoop()
 	return nil
 }
@@ -25,6 +27,8 @@ func (node *Node) Teardown() {
 	<-node.cqc
 	<-node.mlc
 	<-node.elc
+	<-node.wsc
+	<-node.plc
 	node.chains.RLock()
 	for _, c := range node.chains.m {
 		c.Teardown()
@@ -10,7 +10,6 @@ import (
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/kernel/internal"
 	"github.com/MixinNetwork/mixin/kernel/internal/clock"
-	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/mixin/storage"
 )
 
@@ -76,7 +75,7 @@ type Chain struct {
 }
 
 func (node *Node) buildChain(chainId crypto.Hash) *Chain {
-	logger.Printf("node.buildChain(%s)", chainId)
+	kernelLogger.Info("node.buildChain", "chain", chainId)
 
 	chain := &Chain{
 		node:               node,
@@ -236,7 +235,8 @@ func (chain *Chain) loadState() error {
 }
 
 func (chain *Chain) QueuePollSnapshots() {
-	logger.Printf("QueuePollSnapshots(%s)\n", chain.ChainId)
+	log := kernelLogger.With("chain", chain.ChainId)
+	log.Info("QueuePollSnapshots")
 	defer close(chain.plc)
 
 	for chain.running {
@@ -245,23 +245,23 @@ func (chain *Chain) QueuePollSnapshots() {
 			index := (chain.FinalIndex + i) % FinalPoolSlotsLimit
 			round := chain.FinalPool[index]
 			if round == nil {
-				logger.Debugf("QueuePollSnapshots final round empty %s %d %d\n", chain.ChainId, chain.FinalIndex, index)
+				log.Debug("QueuePollSnapshots final round empty", "final", chain.FinalIndex, "index", index)
 				continue
 			}
 			if cs := chain.State; cs != nil && (round.Number < cs.CacheRound.Number || round.Number > cs.CacheRound.Number+1) {
-				logger.Debugf("QueuePollSnapshots final round number bad %s %d %d %d\n",
-					chain.ChainId, chain.FinalIndex, cs.CacheRound.Number, round.Number)
+				log.Debug("QueuePollSnapshots final round number bad",
+					"final", chain.FinalIndex, "cache", cs.CacheRound.Number, "round", round.Number)
 				continue
 			}
 			if round.Timestamp > chain.node.GraphTimestamp+uint64(config.KernelNodeAcceptPeriodMaximum) {
 				stale = true
 			}
-			logger.Debugf("QueuePollSnapshots final round good %s %d %d %d\n",
-				chain.ChainId, chain.FinalIndex, round.Number, round.Size)
+			log.Debug("QueuePollSnapshots final round good",
+				"final", chain.FinalIndex, "round", round.Number, "size", round.Size)
 			for j := 0; j < round.Size; j++ {
 				ps := round.Snapshots[j]
-				logger.Debugf("QueuePollSnapshots final snapshot %s %d %s %t %d\n",
-					chain.ChainId, chain.FinalIndex, ps.Snapshot.Hash, ps.finalized, len(ps.peers))
+				log.Debug("QueuePollSnapshots final snapshot", "final", chain.FinalIndex,
+					"snapshot", ps.Snapshot.Hash, "finalized", ps.finalized, "peers", len(ps.peers))
 				if ps.finalized {
 					continue
 				}
@@ -284,38 +284,36 @@ func (chain *Chain) QueuePollSnapshots() {
 					break
 				}
 			}
-			logger.Debugf("QueuePollSnapshots final round done %s %d %d %d\n",
-				chain.ChainId, chain.FinalIndex, round.Number, round.Size)
+			log.Debug("QueuePollSnapshots final round done",
+				"final", chain.FinalIndex, "round", round.Number, "size", round.Size)
 		}
 
-		logger.Debugf("QueuePollSnapshots cache pool begin %s when final %d %d\n",
-			chain.ChainId, chain.FinalIndex, chain.FinalCount)
+		log.Debug("QueuePollSnapshots cache pool begin", "final", chain.FinalIndex, "count", chain.FinalCount)
 		for {
-			logger.Debugf("QueuePollSnapshots cache pool step %s from %d when final %d %d\n",
-				chain.ChainId, cache, chain.FinalIndex, chain.FinalCount)
+			log.Debug("QueuePollSnapshots cache pool step from",
+				"cache", cache, "final", chain.FinalIndex, "count", chain.FinalCount)
 			m := chain.CachePool.Poll()
 			if m == nil {
-				logger.Verbosef("QueuePollSnapshots(%s) break at %d when final %d %d\n",
-					chain.ChainId, cache, chain.FinalIndex, chain.FinalCount)
+				log.Verbose("QueuePollSnapshots break",
+					"cache", cache, "final", chain.FinalIndex, "count", chain.FinalCount)
 				break
 			}
-			logger.Debugf("QueuePollSnapshots cache pool step %s got %v when final %d %d\n",
-				chain.ChainId, m, chain.FinalIndex, chain.FinalCount)
+			log.Debug("QueuePollSnapshots cache pool step got", "peer", m.PeerId, "action", m.Action,
+				"snapshot", m.SnapshotHash, "final", chain.FinalIndex, "count", chain.FinalCount)
 			_, err := chain.cosiHook(m)
 			if err != nil {
 				panic(err)
 			}
 			cache++
-			logger.Debugf("QueuePollSnapshots cache pool step %s to %d when final %d %d\n",
-				chain.ChainId, cache, chain.FinalIndex, chain.FinalCount)
+			log.Debug("QueuePollSnapshots cache pool step to",
+				"cache", cache, "final", chain.FinalIndex, "count", chain.FinalCount)
 			if cache > 256 {
-				logger.Verbosef("QueuePollSnapshots(%s) break at %d when final %d %d\n",
-					chain.ChainId, cache, chain.FinalIndex, chain.FinalCount)
+				log.Verbose("QueuePollSnapshots break",
+					"cache", cache, "final", chain.FinalIndex, "count", chain.FinalCount)
 				break
 			}
 		}
-		logger.Debugf("QueuePollSnapshots cache pool end %s when final %d %d\n",
-			chain.ChainId, chain.FinalIndex, chain.FinalCount)
+		log.Debug("QueuePollSnapshots cache pool end", "final", chain.FinalIndex, "count", chain.FinalCount)
 
 		if stale || final == 0 && cache == 0 {
 			time.Sleep(300 * time.Millisecond)
@@ -326,13 +324,15 @@ func (chain *Chain) QueuePollSnapshots() {
 }
 
 func (chain *Chain) StepForward() {
-	logger.Debugf("graph chain StepForward(%d, %d)\n", chain.FinalIndex, chain.FinalCount)
+	kernelLogger.Debug("graph chain StepForward", "chain", chain.ChainId,
+		"final", chain.FinalIndex, "count", chain.FinalCount)
 	chain.FinalIndex = (chain.FinalIndex + 1) % FinalPoolSlotsLimit
 	chain.FinalCount = chain.FinalCount + 1
 }
 
 func (chain *Chain) ConsumeFinalActions() {
-	logger.Printf("ConsumeFinalActions(%s)\n", chain.ChainId)
+	log := kernelLogger.With("chain", chain.ChainId)
+	log.Info("ConsumeFinalActions")
 	defer close(chain.clc)
 
 	for chain.running {
@@ -341,7 +341,7 @@ func (chain *Chain) ConsumeFinalActions() {
 			time.Sleep(100 * time.Millisecond)
 			continue
 		}
-		logger.Debugf("ConsumeFinalActions(%s) %s\n", chain.ChainId, ps.Snapshot.Hash)
+		log.Debug("ConsumeFinalActions", "peer", ps.PeerId, "snapshot", ps.Snapshot.Hash)
 		for chain.running {
 			retry, err := chain.appendFinalSnapshot(ps.PeerId, ps.Snapshot)
 			if err != nil {
@@ -356,26 +356,27 @@ func (chain *Chain) ConsumeFinalActions() {
 }
 
 func (chain *Chain) appendFinalSnapshot(peerId crypto.Hash, s *common.Snapshot) (bool, error) {
-	logger.Debugf("appendFinalSnapshot(%s, %s)\n", peerId, s.Hash)
+	log := kernelLogger.With("chain", chain.ChainId, "peer", peerId, "snapshot", s.Hash)
+	log.Debug("appendFinalSnapshot", "round", s.RoundNumber)
 	start, fi := uint64(0), chain.FinalIndex
 	if chain.State != nil {
 		start = chain.State.CacheRound.Number
 		pr := chain.FinalPool[fi]
 		if pr == nil || pr.Number == start || pr.Number+FinalPoolSlotsLimit == start {
-			logger.Debugf("appendFinalSnapshot(%s, %s) cache and index match %d\n", peerId, s.Hash, start)
+			log.Debug("appendFinalSnapshot cache and index match", "start", start)
 		} else {
-			logger.Verbosef("appendFinalSnapshot(%s, %s) cache and index malformed %d %d\n", peerId, s.Hash, start, pr.Number)
+			log.Verbose("appendFinalSnapshot cache and index malformed", "start", start, "pool", pr.Number)
 			return true, nil
 		}
 	}
 	if s.RoundNumber < start {
-		logger.Debugf("appendFinalSnapshot(%s, %s) expired on start %d %d\n", peerId, s.Hash, s.RoundNumber, start)
+		log.Debug("appendFinalSnapshot expired on start", "round", s.RoundNumber, "start", start)
 		return false, nil
 	}
 	offset := int(s.RoundNumber - start)
 	if offset >= FinalPoolSlotsLimit {
-		logger.Verbosef("appendFinalSnapshot(%s, %s) pool slots full %d %d %d %d\n",
-			peerId, s.Hash, start, s.RoundNumber, chain.FinalIndex, fi)
+		log.Verbose("appendFinalSnapshot pool slots full",
+			"start", start, "round", s.RoundNumber, "final", chain.FinalIndex, "index", fi)
 		return false, nil
 	}
 	offset = (offset + fi) % FinalPoolSlotsLimit
@@ -418,7 +419,7 @@ func (chain *Chain) appendFinalSnapshot(peerId crypto.Hash, s *common.Snapshot)
 }
 
 func (chain *Chain) AppendFinalSnapshot(peerId crypto.Hash, s *common.Snapshot) error {
-	logger.Debugf("AppendFinalSnapshot(%s, %s)\n", peerId, s.Hash)
+	kernelLogger.Debug("AppendFinalSnapshot", "chain", chain.ChainId, "peer", peerId, "snapshot", s.Hash)
 	if s.NodeId != chain.ChainId {
 		panic("final queue malformed")
 	}
@@ -435,7 +436,8 @@ func (chain *Chain) AppendFinalSnapshot(peerId crypto.Hash, s *common.Snapshot)
 }
 
 func (chain *Chain) AppendCosiAction(m *CosiAction) error {
-	logger.Debugf("AppendCosiAction(%s) %v\n", chain.ChainId, m)
+	log := kernelLogger.With("chain", chain.ChainId, "peer", m.PeerId, "action", m.Action, "snapshot", m.SnapshotHash)
+	log.Debug("AppendCosiAction")
 	switch m.Action {
 	case CosiActionSelfEmpty:
 		if m.PeerId != chain.ChainId {
@@ -464,7 +466,7 @@ func (chain *Chain) AppendCosiAction(m *CosiAction) error {
 
 	err := chain.CachePool.Offer(m)
 	if err != nil {
-		logger.Verbosef("AppendCosiAction(%s) %v FULL\n", chain.ChainId, m)
+		log.Verbose("AppendCosiAction FULL", "error", err)
 	}
 	return nil
 }
@@ -9,8 +9,11 @@ import (
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/kernel/internal/clock"
 	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/p2p"
 )
 
+var cosiLogger = logger.New("kernel.cosi")
+
 const (
 	CosiActionSelfEmpty = iota
 	CosiActionSelfCommitment
@@ -37,6 +40,7 @@ type CosiAction struct {
 	Challenge    *crypto.Key
 	random       *crypto.Key
 	finalized    bool
+	direct       bool
 	data         *CosiChainData
 }
 
@@ -54,6 +58,7 @@ type CosiAggregator struct {
 	FullChallenges map[crypto.Hash]bool
 	Commitments    map[int]*crypto.Key
 	Responses      map[int]*[32]byte
+	committedAt    time.Time
 }
 
 type CosiVerifier struct {
@@ -73,7 +78,8 @@ func (node *Node) cosiAcceptedNodesListShuffle(ts uint64) []*CNode {
 }
 
 func (chain *Chain) cosiHook(m *CosiAction) (bool, error) {
-	logger.Debugf("cosiHook(%s) %v\n", chain.ChainId, m)
+	log := cosiLogger.With("chain", chain.ChainId, "peer", m.PeerId, "snapshot", m.SnapshotHash)
+	log.Debug("cosiHook", "action", m.Action)
 	if !chain.running {
 		return false, nil
 	}
@@ -88,8 +94,8 @@ func (chain *Chain) cosiHook(m *CosiAction) (bool, error) {
 		return m.finalized, nil
 	}
 	err = chain.node.Peer.SendTransactionRequestMessage(m.PeerId, m.Snapshot.SoleTransaction())
-	logger.Debugf("cosiHook finalized snapshot without transaction %s %s %s %v\n",
-		m.PeerId, m.SnapshotHash, m.Snapshot.SoleTransaction(), err)
+	log.Debug("cosiHook finalized snapshot without transaction",
+		"transaction", m.Snapshot.SoleTransaction(), "error", err)
 	return m.finalized, nil
 }
 
@@ -101,7 +107,8 @@ func (chain *Chain) cosiHandleAction(m *CosiAction) error {
 		return chain.cosiAddCommitments(m)
 	}
 	if err := chain.checkActionSanity(m); err != nil {
-		logger.Debugf("cosiHandleAction checkActionSanity %v ERROR %s\n", m, err)
+		cosiLogger.Debug("cosiHandleAction checkActionSanity", "chain", chain.ChainId,
+			"peer", m.PeerId, "action", m.Action, "snapshot", m.SnapshotHash, "error", err)
 		return nil
 	}
 
@@ -288,6 +295,7 @@ func (chain *Chain) prepareAnnouncement(m *CosiAction) (bool, error) {
 	if chain.State == nil {
 		return false, nil
 	}
+	log := cosiLogger.With("chain", chain.ChainId, "transaction", s.SoleTransaction())
 
 	cache, final := chain.StateCopy()
 	if len(cache.Snapshots) == 0 && !chain.node.CheckBroadcastedToPeers() {
@@ -305,13 +313,13 @@ func (chain *Chain) prepareAnnouncement(m *CosiAction) (bool, error) {
 		best := chain.determineBestRound(s.Timestamp)
 		threshold := external.Timestamp + config.SnapshotReferenceThreshold*config.SnapshotRoundGap*36
 		if best != nil && best.NodeId != final.NodeId && threshold < best.Start {
-			logger.Verbosef("cosiSendAnnouncement new best external %s:%d:%d => %s:%d:%d\n",
-				external.NodeId, external.Number, external.Timestamp, best.NodeId, best.Number, best.Start)
+			log.Verbose("cosiSendAnnouncement new best external",
+				"external", external.NodeId, "number", external.Number, "timestamp", external.Timestamp,
+				"best", best.NodeId, "best_number", best.Number, "best_start", best.Start)
 			references := &common.RoundLink{Self: final.Hash, External: best.Hash}
 			err := chain.updateEmptyHeadRoundAndPersist(final, cache, references, s.Timestamp, true)
 			if err != nil {
-				logger.Verbosef("ERROR cosiSendAnnouncement updateEmptyHeadRoundAndPersist failed %s %s %v\n",
-					m.PeerId, s.Hash, err)
+				log.Verbose("cosiSendAnnouncement updateEmptyHeadRoundAndPersist", "error", err)
 				return false, nil
 			}
 			return false, chain.clearAndQueueSnapshotOrPanic(s)
@@ -319,7 +327,7 @@ func (chain *Chain) prepareAnnouncement(m *CosiAction) (bool, error) {
 	} else if start, _ := cache.Gap(); s.Timestamp >= start+config.SnapshotRoundGap {
 		best := chain.determineBestRound(s.Timestamp)
 		if best == nil {
-			logger.Verbosef("cosiSendAnnouncement no best available\n")
+			log.Verbose("cosiSendAnnouncement no best available")
 			return false, chain.clearAndQueueSnapshotOrPanic(s)
 		}
 		if best.NodeId == final.NodeId {
@@ -328,8 +336,7 @@ func (chain *Chain) prepareAnnouncement(m *CosiAction) (bool, error) {
 		references := &common.RoundLink{Self: cache.asFinal().Hash, External: best.Hash}
 		nc, nf, _, err := chain.startNewRoundAndPersist(cache, references, s.Timestamp, false)
 		if err != nil || nf == nil {
-			logger.Verbosef("cosiSendAnnouncement %s %v startNewRoundAndPersist %v %v\n",
-				m.PeerId, m.Snapshot, err, nf)
+			log.Verbose("cosiSendAnnouncement startNewRoundAndPersist", "error", err, "final", nf != nil)
 			return false, chain.clearAndQueueSnapshotOrPanic(s)
 		}
 		cache, final = nc, nf
@@ -356,7 +363,8 @@ func (chain *Chain) prepareAnnouncement(m *CosiAction) (bool, error) {
 }
 
 func (chain *Chain) cosiSendAnnouncement(m *CosiAction) error {
-	logger.Verbosef("cosiSendAnnouncement %v\n", m.Snapshot)
+	log := cosiLogger.With("chain", chain.ChainId, "transaction", m.Snapshot.SoleTransaction())
+	log.Verbose("cosiSendAnnouncement", "timestamp", m.Snapshot.Timestamp)
 	valid, err := chain.prepareAnnouncement(m)
 	if err != nil || !valid {
 		return err
@@ -368,11 +376,12 @@ func (chain *Chain) cosiSendAnnouncement(m *CosiAction) error {
 		s.Timestamp < ov.Snapshot.Timestamp+config.SnapshotRoundGap {
 		err := fmt.Errorf("a transaction %s only in one round %d of one chain %s",
 			s.SoleTransaction(), s.RoundNumber, chain.ChainId)
-		logger.Verbosef("cosiSendAnnouncement ERROR %s\n", err)
+		log.Verbose("cosiSendAnnouncement", "error", err)
 		return nil
 	}
 
 	s.Hash = s.PayloadHash()
+	log = log.With("snapshot", s.Hash)
 	agg := &CosiAggregator{
 		Snapshot:       s,
 		Transaction:    cd.TX,
@@ -398,8 +407,7 @@ func (chain *Chain) cosiSendAnnouncement(m *CosiAction) error {
 		if commitment == nil || chain.CosiCommunicatedAt[peerId].Before(clock.Now().Add(-time.Duration(config.SnapshotRoundGap)*10)) {
 			err := chain.node.Peer.SendSnapshotAnnouncementMessage(peerId, m.Snapshot, R, chain.node.Signer.PrivateSpendKey)
 			if err != nil {
-				logger.Verbosef("cosiSendAnnouncement SendSnapshotAnnouncementMessage(%s, %s) ERROR %v\n",
-					peerId, s.Hash, err)
+				log.Verbose("cosiSendAnnouncement SendSnapshotAnnouncementMessage", "peer", peerId, "error", err)
 			}
 			continue
 		}
@@ -413,14 +421,15 @@ func (chain *Chain) cosiSendAnnouncement(m *CosiAction) error {
 		}
 		err = chain.AppendCosiAction(cam)
 		if err != nil {
-			logger.Verbosef("cosiSendAnnouncement AppendCosiAction(%s, %s) ERROR %v\n", peerId, s.Hash, err)
+			log.Verbose("cosiSendAnnouncement AppendCosiAction", "peer", peerId, "error", err)
 		}
 	}
 	return nil
 }
 
 func (chain *Chain) cosiHandleAnnouncement(m *CosiAction) error {
-	logger.Verbosef("cosiHandleAnnouncement %s %v\n", m.PeerId, m.Snapshot)
+	log := cosiLogger.With("chain", chain.ChainId, "peer", m.PeerId, "snapshot", m.Snapshot.Hash)
+	log.Verbose("cosiHandleAnnouncement", "round", m.Snapshot.RoundNumber)
 	valid, err := chain.checkAnnouncementOrChallenge(m)
 	if err != nil || !valid {
 		return err
@@ -434,38 +443,40 @@ func (chain *Chain) cosiHandleAnnouncement(m *CosiAction) error {
 	chain.CosiVerifiers[s.SoleTransaction()] = v
 	err = chain.node.Peer.SendSnapshotCommitmentMessage(s.NodeId, s.Hash, r.Public(), cd.TX == nil)
 	if err != nil {
-		logger.Verbosef("cosiHandleAnnouncement SendSnapshotCommitmentMessage(%s, %s) ERROR %v\n",
-			s.NodeId, s.Hash, err)
+		log.Verbose("cosiHandleAnnouncement SendSnapshotCommitmentMessage", "error", err)
 	}
 	err = chain.cosiPrepareRandomsAndSendCommitments(s.NodeId)
 	if err != nil {
-		logger.Verbosef("cosiHandleAnnouncement SendCommitmentsMessage(%s) ERROR %v\n", s.NodeId, err)
+		log.Verbose("cosiHandleAnnouncement SendCommitmentsMessage", "error", err)
 	}
 	return nil
 }
 
 func (chain *Chain) cosiHandleCommitment(m *CosiAction) error {
-	logger.Verbosef("cosiHandleCommitment %v\n", m)
+	log := cosiLogger.With("chain", chain.ChainId, "peer", m.PeerId, "snapshot", m.SnapshotHash)
+	log.Verbose("cosiHandleCommitment", "action", m.Action, "want_tx", m.WantTx)
 
 	ann := chain.CosiAggregators[m.SnapshotHash]
 	s, cd := ann.Snapshot, m.data
 	if ann.Commitments[cd.PN.ConsensusIndex] != nil {
-		logger.Verbosef("cosiHandleCommitment %v REPEAT\n", m)
+		log.Verbose("cosiHandleCommitment REPEAT")
 		return nil
 	}
 	base := chain.node.ConsensusThreshold(ann.Snapshot.Timestamp, false)
 	if len(ann.Commitments) >= base {
-		logger.Verbosef("cosiHandleCommitment %v EXCEED\n", m)
+		log.Verbose("cosiHandleCommitment EXCEED")
 		return nil
 	}
 	ann.Commitments[cd.PN.ConsensusIndex] = m.Commitment
 	ann.WantTxs[m.PeerId] = m.WantTx
 	ann.FullChallenges[m.PeerId] = m.Action == CosiActionSelfFullCommitment
-	logger.Verbosef("cosiHandleCommitment %v NOW %d %d\nn", m, len(ann.Commitments), base)
+	log.Verbose("cosiHandleCommitment NOW", "commitments", len(ann.Commitments), "threshold", base)
 	if len(ann.Commitments) < base {
 		return nil
 	}
-	logger.Verbosef("cosiHandleCommitment %v ENOUGH\n", m)
+	log.Verbose("cosiHandleCommitment ENOUGH")
+	ann.committedAt = clock.Now()
+	cosiPhaseLatency.Observe(sinceUnixNano(s.Timestamp), "commitment")
 
 	cosi, err := crypto.CosiAggregateCommitment(ann.Commitments)
 	if err != nil {
@@ -496,15 +507,15 @@ func (chain *Chain) cosiHandleCommitment(m *CosiAction) error {
 			err = chain.node.Peer.SendTransactionChallengeMessage(id, m.SnapshotHash, cosi, nil)
 		}
 		if err != nil {
-			logger.Verbosef("cosiHandleCommitment SendTransactionChallengeMessage(%s, %s) ERROR %v\n",
-				id, m.SnapshotHash, err)
+			log.Verbose("cosiHandleCommitment SendTransactionChallengeMessage", "to", id, "error", err)
 		}
 	}
 	return nil
 }
 
 func (chain *Chain) cosiHandleFullChallenge(m *CosiAction) error {
-	logger.Verbosef("cosiHandleFullChallenge %v\n", m)
+	log := cosiLogger.With("chain", chain.ChainId, "peer", m.PeerId, "snapshot", m.SnapshotHash)
+	log.Verbose("cosiHandleFullChallenge")
 	if m.random == nil {
 		panic(m.SnapshotHash)
 	}
@@ -528,7 +539,7 @@ func (chain *Chain) cosiHandleFullChallenge(m *CosiAction) error {
 	}
 	err = chain.AppendCosiAction(ccm)
 	if err != nil {
-		logger.Verbosef("cosiHandleFullChallenge AppendCosiAction(%s) ERROR %v\n", s.Hash, err)
+		log.Verbose("cosiHandleFullChallenge AppendCosiAction", "error", err)
 	}
 	return nil
 }
@@ -538,32 +549,30 @@ func (chain *Chain) checkAnnouncementOrChallenge(m *CosiAction) (bool, error) {
 	if chain.IsPledging() && s.RoundNumber == 0 {
 		return true, nil
 	}
+	log := cosiLogger.With("chain", chain.ChainId, "peer", m.PeerId, "snapshot", s.Hash, "round", s.RoundNumber)
 	if chain.State == nil {
-		logger.Verbosef("checkAnnouncementOrChallenge %s %v empty final round\n", m.PeerId, m.Snapshot)
+		log.Verbose("checkAnnouncementOrChallenge empty final round")
 		return false, nil
 	}
 
 	cache, final := chain.StateCopy()
 	if s.RoundNumber < cache.Number {
-		logger.Verbosef("checkAnnouncementOrChallenge %s %v expired %d %d\n",
-			m.PeerId, m.Snapshot, s.RoundNumber, cache.Number)
+		log.Verbose("checkAnnouncementOrChallenge expired", "cache", cache.Number)
 		return false, nil
 	}
 	if s.RoundNumber > cache.Number+1 {
-		logger.Verbosef("checkAnnouncementOrChallenge %s %v in future %d %d\n",
-			m.PeerId, m.Snapshot, s.RoundNumber, cache.Number)
+		log.Verbose("checkAnnouncementOrChallenge in future", "cache", cache.Number)
 		return false, nil
 	}
 	if s.Timestamp <= final.Start+config.SnapshotRoundGap {
-		logger.Verbosef("checkAnnouncementOrChallenge %s %v invalid timestamp %d %d\n",
-			m.PeerId, m.Snapshot, s.Timestamp, final.Start+config.SnapshotRoundGap)
+		log.Verbose("checkAnnouncementOrChallenge invalid timestamp",
+			"timestamp", s.Timestamp, "start", final.Start+config.SnapshotRoundGap)
 		return false, nil
 	}
 	if s.RoundNumber == cache.Number && !s.References.Equal(cache.References) {
 		err := chain.updateEmptyHeadRoundAndPersist(final, cache, s.References, s.Timestamp, true)
 		if err != nil {
-			logger.Verbosef("checkAnnouncementOrChallenge %s %v updateEmptyHeadRoundAndPersist %v\n",
-				m.PeerId, m.Snapshot, err)
+			log.Verbose("checkAnnouncementOrChallenge updateEmptyHeadRoundAndPersist", "error", err)
 			return false, nil
 		}
 		return false, chain.AppendCosiAction(m)
@@ -571,12 +580,10 @@ func (chain *Chain) checkAnnouncementOrChallenge(m *CosiAction) (bool, error) {
 	if s.RoundNumber == cache.Number+1 {
 		nc, nf, _, err := chain.startNewRoundAndPersist(cache, s.References, s.Timestamp, false)
 		if err != nil {
-			logger.Verbosef("checkAnnouncementOrChallenge %s %v startNewRoundAndPersist %s\n",
-				m.PeerId, m.Snapshot, err)
+			log.Verbose("checkAnnouncementOrChallenge startNewRoundAndPersist", "error", err)
 			return false, chain.AppendCosiAction(m)
 		} else if nf == nil {
-			logger.Verbosef("checkAnnouncementOrChallenge %s %v startNewRoundAndPersist failed\n",
-				m.PeerId, m.Snapshot)
+			log.Verbose("checkAnnouncementOrChallenge startNewRoundAndPersist failed")
 			return false, nil
 		}
 		cache, final = nc, nf
@@ -587,15 +594,15 @@ func (chain *Chain) checkAnnouncementOrChallenge(m *CosiAction) (bool, error) {
 	}
 
 	if err := cache.ValidateSnapshot(s); err != nil {
-		logger.Verbosef("checkAnnouncementOrChallenge %s %v ValidateSnapshot %s\n",
-			m.PeerId, m.Snapshot, err)
+		log.Verbose("checkAnnouncementOrChallenge ValidateSnapshot", "error", err)
 		return false, nil
 	}
 	return true, nil
 }
 
 func (chain *Chain) cosiHandleChallenge(m *CosiAction) error {
-	logger.Verbosef("cosiHandleChallenge %v\n", m)
+	log := cosiLogger.With("chain", chain.ChainId, "peer", m.PeerId, "snapshot", m.SnapshotHash)
+	log.Verbose("cosiHandleChallenge")
 	v := chain.CosiVerifiers[m.SnapshotHash]
 	s, cd := v.Snapshot, m.data
 
@@ -606,12 +613,12 @@ func (chain *Chain) cosiHandleChallenge(m *CosiAction) error {
 	_, publics := chain.ConsensusKeys(s.RoundNumber, s.Timestamp)
 	challenge, err := m.Signature.Challenge(publics, m.SnapshotHash)
 	if err != nil {
-		logger.Verbosef("cosiHandleChallenge %v Challenge ERROR %s\n", m, err)
+		log.Verbose("cosiHandleChallenge Challenge", "error", err)
 		return nil
 	}
 	if !pub.VerifyWithChallenge(sig, challenge) {
-		logger.Verbosef("cosiHandleChallenge %v VerifyWithChallenge ERROR %v %v\n",
-			m, sig, challenge)
+		log.Verbose("cosiHandleChallenge VerifyWithChallenge failed", "signature", sig, "challenge", challenge)
+		chain.penalizeBogusCosi(m)
 		return nil
 	}
 	chain.CosiCommunicatedAt[m.PeerId] = clock.Now()
@@ -619,45 +626,46 @@ func (chain *Chain) cosiHandleChallenge(m *CosiAction) error {
 	priv := chain.node.Signer.PrivateSpendKey
 	response, err := m.Signature.Response(&priv, v.random, publics, m.SnapshotHash)
 	if err != nil {
-		logger.Verbosef("cosiHandleChallenge %v Response ERROR %s\n", m, err)
+		log.Verbose("cosiHandleChallenge Response", "error", err)
 		return err
 	}
 	err = chain.node.Peer.SendSnapshotResponseMessage(m.PeerId, m.SnapshotHash, response)
 	if err != nil {
-		logger.Verbosef("cosiHandleChallenge SendSnapshotResponseMessage(%s, %s) ERROR %v\n",
-			m.PeerId, m.SnapshotHash, err)
+		log.Verbose("cosiHandleChallenge SendSnapshotResponseMessage", "error", err)
 	}
 	return nil
 }
 
 func (chain *Chain) cosiHandleResponse(m *CosiAction) error {
-	logger.Verbosef("cosiHandleResponse %v\n", m)
+	log := cosiLogger.With("chain", chain.ChainId, "peer", m.PeerId, "snapshot", m.SnapshotHash)
+	log.Verbose("cosiHandleResponse")
 	agg := chain.CosiAggregators[m.SnapshotHash]
 	s, cd := agg.Snapshot, m.data
 	if agg.Responses[cd.PN.ConsensusIndex] != nil {
-		logger.Verbosef("cosiHandleResponse %v REPEAT\n", m)
+		log.Verbose("cosiHandleResponse REPEAT")
 		return nil
 	}
 	chain.CosiCommunicatedAt[m.PeerId] = clock.Now()
 	if len(agg.Responses) >= len(agg.Commitments) {
-		logger.Verbosef("cosiHandleResponse %v EXCEED\n", m)
+		log.Verbose("cosiHandleResponse EXCEED")
 		return nil
 	}
 	cids, publics := chain.ConsensusKeys(s.RoundNumber, s.Timestamp)
 	err := s.Signature.VerifyResponse(publics, cd.PN.ConsensusIndex, m.Response, m.SnapshotHash)
 	if err != nil {
-		logger.Verbosef("cosiHandleResponse %v RESPONSE ERROR %s\n", m, err)
+		log.Verbose("cosiHandleResponse VerifyResponse", "error", err)
+		chain.penalizeBogusCosi(m)
 		return nil
 	}
 
 	base := chain.node.ConsensusThreshold(s.Timestamp, false)
 	agg.Responses[cd.PN.ConsensusIndex] = m.Response
-	logger.Verbosef("cosiHandleResponse %v NOW %d %d %d\n",
-		m, len(agg.Responses), len(agg.Commitments), base)
+	log.Verbose("cosiHandleResponse NOW", "responses", len(agg.Responses),
+		"commitments", len(agg.Commitments), "threshold", base)
 	if len(agg.Responses) != len(agg.Commitments) {
 		return nil
 	}
-	logger.Verbosef("cosiHandleResponse %v ENOUGH\n", m)
+	log.Verbose("cosiHandleResponse ENOUGH")
 
 	err = s.Signature.AggregateResponse(publics, agg.Responses, m.SnapshotHash, false)
 	if err != nil {
@@ -665,10 +673,12 @@ func (chain *Chain) cosiHandleResponse(m *CosiAction) error {
 	}
 	signers, finalized := chain.node.cacheVerifyCosi(m.SnapshotHash, s.Signature, cids, publics, base)
 	if !finalized {
-		logger.Verbosef("cosiHandleResponse %v AGGREGATE ERROR\n", m)
+		log.Verbose("cosiHandleResponse cacheVerifyCosi failed")
 		return nil
 	}
-	logger.Verbosef("node.cacheVerifyCosi(%s, %s) FINAL\n", chain.node.Peer.Address, m.SnapshotHash)
+	log.Verbose("cosiHandleResponse FINAL", "address", chain.node.Peer.Address)
+	cosiPhaseLatency.Observe(clock.Now().Sub(agg.committedAt).Seconds(), "response")
+	cosiPhaseLatency.Observe(sinceUnixNano(s.Timestamp), "total")
 
 	if chain.IsPledging() && s.RoundNumber == 0 && cd.TX.TransactionType() == common.TransactionTypeNodeAccept {
 		err := chain.node.finalizeNodeAcceptSnapshot(s, signers)
@@ -681,17 +691,15 @@ func (chain *Chain) cosiHandleResponse(m *CosiAction) error {
 			panic(fmt.Sprintf("should never be here %d %d", cache.Number, s.RoundNumber))
 		}
 		if s.RoundNumber < cache.Number {
-			logger.Verbosef("cosiHandleResponse %v EXPIRE %d %d\n",
-				m, s.RoundNumber, cache.Number)
+			log.Verbose("cosiHandleResponse EXPIRE", "round", s.RoundNumber, "cache", cache.Number)
 			return nil
 		}
 		if !s.References.Equal(cache.References) {
-			logger.Verbosef("cosiHandleResponse %v REFERENCES %v %v\n",
-				m, s.References, cache.References)
+			log.Verbose("cosiHandleResponse REFERENCES", "references", s.References, "cache", cache.References)
 			return nil
 		}
 		if err := cache.ValidateSnapshot(s); err != nil {
-			logger.Verbosef("cosiHandleResponse %v ValidateSnapshot %s\n", m, err)
+			log.Verbose("cosiHandleResponse ValidateSnapshot", "error", err)
 			return nil
 		}
 
@@ -707,49 +715,53 @@ func (chain *Chain) cosiHandleResponse(m *CosiAction) error {
 		if agg.Responses[cn.ConsensusIndex] == nil {
 			err := chain.node.SendTransactionToPeer(id, s.SoleTransaction())
 			if err != nil {
-				logger.Verbosef("cosiHandleResponse SendTransactionToPeer(%s, %s) ERROR %v\n",
-					id, m.SnapshotHash, err)
+				log.Verbose("cosiHandleResponse SendTransactionToPeer", "to", id, "error", err)
 			}
 		}
 		err := chain.node.Peer.SendSnapshotFinalizationMessage(id, s)
 		if err != nil {
-			logger.Verbosef("cosiHandleResponse SendSnapshotFinalizationMessage(%s, %s) ERROR %v\n",
-				id, m.SnapshotHash, err)
+			log.Verbose("cosiHandleResponse SendSnapshotFinalizationMessage", "to", id, "error", err)
 		}
 	}
 	return chain.node.reloadConsensusState(s, cd.TX)
 }
 
+// penalizeBogusCosi only penalizes the neighbor sent the action itself, the
+// relayed actions could have the origin forged
+func (chain *Chain) penalizeBogusCosi(m *CosiAction) {
+	if m.direct {
+		chain.node.Peer.Penalize(m.PeerId, p2p.PeerMisbehaviorBogusCosi)
+	}
+}
+
 func (chain *Chain) prepareFinalization(m *CosiAction) (bool, error) {
 	s := m.Snapshot
 	if chain.IsPledging() && s.RoundNumber == 0 {
 		return true, nil
 	}
+	log := cosiLogger.With("chain", chain.ChainId, "peer", m.PeerId, "snapshot", s.Hash, "round", s.RoundNumber)
 	if chain.State == nil {
-		logger.Debugf("ERROR cosiHandleFinalization without consensus%s %s\n", m.PeerId, s.Hash)
+		log.Debug("cosiHandleFinalization without consensus")
 		return false, nil
 	}
 	cache := chain.State.CacheRound
 	if s.RoundNumber < cache.Number {
-		logger.Debugf("ERROR cosiHandleFinalization expired round %s %s %d %d\n",
-			m.PeerId, s.Hash, s.RoundNumber, cache.Number)
+		log.Debug("cosiHandleFinalization expired round", "cache", cache.Number)
 		return false, nil
 	}
 	if s.RoundNumber > cache.Number+1 {
-		logger.Debugf("ERROR cosiHandleFinalization in future %s %s %d %d\n",
-			m.PeerId, s.Hash, s.RoundNumber, cache.Number)
+		log.Debug("cosiHandleFinalization in future", "cache", cache.Number)
 		return false, nil
 	}
 	if s.RoundNumber == cache.Number+1 {
 		_, nf, dummy, err := chain.startNewRoundAndPersist(cache, s.References, s.Timestamp, true)
 		if err != nil || nf == nil {
-			logger.Verbosef("ERROR cosiHandleFinalization startNewRound %s %v %v %v\n",
-				m.PeerId, s, err, nf)
+			log.Verbose("cosiHandleFinalization startNewRoundAndPersist", "error", err, "final", nf != nil)
 			return false, nil
 		}
 		if dummy {
-			logger.Verbosef("ERROR cosiHandleFinalization startNewRound DUMMY %s %s %d\n",
-				m.PeerId, s.Hash, chain.node.ConsensusThreshold(s.Timestamp, true))
+			log.Verbose("cosiHandleFinalization startNewRoundAndPersist DUMMY",
+				"threshold", chain.node.ConsensusThreshold(s.Timestamp, true))
 			return false, nil
 		}
 	}
@@ -757,7 +769,8 @@ func (chain *Chain) prepareFinalization(m *CosiAction) (bool, error) {
 }
 
 func (chain *Chain) cosiHandleFinalization(m *CosiAction) error {
-	logger.Debugf("cosiHandleFinalization %s %v\n", m.PeerId, m.Snapshot)
+	log := cosiLogger.With("chain", chain.ChainId, "peer", m.PeerId, "snapshot", m.Snapshot.Hash)
+	log.Debug("cosiHandleFinalization", "round", m.Snapshot.RoundNumber)
 	valid, err := chain.prepareFinalization(m)
 	if err != nil || !valid {
 		return err
@@ -767,19 +780,17 @@ func (chain *Chain) cosiHandleFinalization(m *CosiAction) error {
 	m.WantTx = false
 	signers, finalized := chain.verifyFinalization(s)
 	if !finalized {
-		logger.Verbosef("ERROR cosiHandleFinalization verifyFinalization %s %v %d\n",
-			m.PeerId, s, chain.node.ConsensusThreshold(s.Timestamp, true))
+		log.Verbose("cosiHandleFinalization verifyFinalization failed",
+			"threshold", chain.node.ConsensusThreshold(s.Timestamp, true))
 		return nil
 	}
 
 	tx, _, err := chain.node.validateSnapshotTransaction(s, true)
 	if err != nil {
-		logger.Verbosef("ERROR handleFinalization validateSnapshotTransaction %s %s %d %v\n",
-			m.PeerId, s.Hash, chain.node.ConsensusThreshold(s.Timestamp, true), err)
+		log.Verbose("cosiHandleFinalization validateSnapshotTransaction", "error", err)
 		return nil
 	} else if tx == nil {
-		logger.Verbosef("ERROR handleFinalization validateSnapshotTransaction %s %s %d %s\n",
-			m.PeerId, s.Hash, chain.node.ConsensusThreshold(s.Timestamp, true), "tx empty")
+		log.Verbose("cosiHandleFinalization validateSnapshotTransaction empty", "transaction", s.SoleTransaction())
 		m.WantTx = true
 		return nil
 	}
@@ -798,14 +809,13 @@ func (chain *Chain) cosiHandleFinalization(m *CosiAction) error {
 	if !s.References.Equal(cache.References) {
 		err := chain.updateEmptyHeadRoundAndPersist(final, cache, s.References, s.Timestamp, false)
 		if err != nil {
-			logger.Debugf("ERROR cosiHandleFinalization updateEmptyHeadRoundAndPersist failed %s %s %v\n",
-				m.PeerId, s.Hash, err)
+			log.Debug("cosiHandleFinalization updateEmptyHeadRoundAndPersist", "error", err)
 		}
 		return nil
 	}
 
 	if err := cache.ValidateSnapshot(s); err != nil {
-		logger.Verbosef("ERROR cosiHandleFinalization ValidateSnapshot %s %v %v\n", m.PeerId, s, err)
+		log.Verbose("cosiHandleFinalization ValidateSnapshot", "error", err)
 		return nil
 	}
 	err = chain.AddSnapshot(final, cache, s, signers)
@@ -813,6 +823,7 @@ func (chain *Chain) cosiHandleFinalization(m *CosiAction) error {
 		panic(err)
 	}
 	m.finalized = true
+	cosiPhaseLatency.Observe(sinceUnixNano(s.Timestamp), "finalization")
 	return chain.node.reloadConsensusState(s, tx)
 }
 
@@ -850,8 +861,8 @@ func (chain *Chain) cosiAddCommitments(m *CosiAction) error {
 			commitments = append(commitments, k)
 		}
 	}
-	logger.Verbosef("cosiAddCommitments(%s, %d) => %d %d",
-		m.PeerId, len(m.Commitments), len(commitments), len(chain.UsedCommitments))
+	cosiLogger.Verbose("cosiAddCommitments", "chain", chain.ChainId, "peer", m.PeerId,
+		"received", len(m.Commitments), "commitments", len(commitments), "used", len(chain.UsedCommitments))
 	chain.CosiCommitments[m.PeerId] = commitments
 	return nil
 }
@@ -915,16 +926,16 @@ func (chain *Chain) cosiPrepareRandomsAndSendCommitments(peerId crypto.Hash) err
 }
 
 func (node *Node) CosiQueueExternalCommitments(peerId crypto.Hash, commitments []*crypto.Key, data []byte, sig *crypto.Signature) error {
-	logger.Debugf("CosiQueueExternalCommitments(%s, %d)\n", peerId, len(commitments))
+	log := cosiLogger.With("peer", peerId, "commitments", len(commitments))
+	log.Debug("CosiQueueExternalCommitments")
 	peer := node.GetAcceptedOrPledgingNode(peerId)
 	if peer == nil {
-		logger.Verbosef("CosiQueueExternalCommitments(%s, %d) from malicious node\n",
-			peerId, len(commitments))
+		log.Verbose("CosiQueueExternalCommitments from malicious node")
 		return nil
 	}
 	if !peer.Signer.PublicSpendKey.Verify(crypto.Blake3Hash(data), *sig) {
-		logger.Printf("CosiQueueExternalCommitments(%s) invalid signature\n", peerId)
-		return nil
+		log.Info("CosiQueueExternalCommitments invalid signature")
+		return p2p.NewMisbehaviorError(p2p.PeerMisbehaviorInvalidSignature, "invalid commitments signature %s", peerId)
 	}
 
 	m := &CosiAction{
@@ -934,22 +945,23 @@ func (node *Node) CosiQueueExternalCommitments(peerId crypto.Hash, commitments [
 	}
 	err := node.chain.AppendCosiAction(m)
 	if err != nil {
-		logger.Verbosef("CosiQueueExternalCommitments(%v) => %v\n", m, err)
+		log.Verbose("CosiQueueExternalCommitments AppendCosiAction", "error", err)
 	}
 	return nil
 }
 
 func (node *Node) CosiQueueExternalAnnouncement(peerId crypto.Hash, s *common.Snapshot, commitment *crypto.Key, sig *crypto.Signature) error {
-	logger.Debugf("CosiQueueExternalAnnouncement(%s, %v)\n", peerId, s)
+	log := cosiLogger.With("peer", peerId, "chain", s.NodeId, "round", s.RoundNumber)
+	log.Debug("CosiQueueExternalAnnouncement", "timestamp", s.Timestamp)
 	peer := node.GetAcceptedOrPledgingNode(peerId)
 	if peer == nil {
-		logger.Verbosef("CosiQueueExternalAnnouncement(%s, %v) from malicious node\n", peerId, s)
+		log.Verbose("CosiQueueExternalAnnouncement from malicious node")
 		return nil
 	}
 	data := append(commitment[:], s.VersionedMarshal()...)
 	if !peer.Signer.PublicSpendKey.Verify(crypto.Blake3Hash(data), *sig) {
-		logger.Printf("CosiQueueExternalAnnouncement(%s, %v) invalid signature\n", peerId, s)
-		return nil
+		log.Info("CosiQueueExternalAnnouncement invalid signature")
+		return p2p.NewMisbehaviorError(p2p.PeerMisbehaviorInvalidSignature, "invalid announcement signature %s", peerId)
 	}
 	chain := node.getOrCreateChain(s.NodeId)
 
@@ -963,21 +975,22 @@ func (node *Node) CosiQueueExternalAnnouncement(peerId crypto.Hash, s *common.Sn
 	}
 	err := chain.AppendCosiAction(m)
 	if err != nil {
-		logger.Verbosef("CosiQueueExternalAnnouncement(%v) => %v\n", m, err)
+		log.Verbose("CosiQueueExternalAnnouncement AppendCosiAction", "snapshot", s.Hash, "error", err)
 	}
 	return nil
 }
 
 func (node *Node) CosiAggregateSelfCommitments(peerId crypto.Hash, snap crypto.Hash, commitment *crypto.Key, wantTx bool, data []byte, sig *crypto.Signature) error {
-	logger.Debugf("CosiAggregateSelfCommitments(%s, %s)\n", peerId, snap)
+	log := cosiLogger.With("peer", peerId, "snapshot", snap)
+	log.Debug("CosiAggregateSelfCommitments", "want_tx", wantTx)
 	peer := node.GetAcceptedOrPledgingNode(peerId)
 	if peer == nil {
-		logger.Verbosef("CosiAggregateSelfCommitments(%s, %s) from malicious node\n", peerId, snap)
+		log.Verbose("CosiAggregateSelfCommitments from malicious node")
 		return nil
 	}
 	if !peer.Signer.PublicSpendKey.Verify(crypto.Blake3Hash(data), *sig) {
-		logger.Printf("CosiAggregateSelfCommitments(%s, %s) invalid signature\n", peerId, snap)
-		return nil
+		log.Info("CosiAggregateSelfCommitments invalid signature")
+		return p2p.NewMisbehaviorError(p2p.PeerMisbehaviorInvalidSignature, "invalid commitment signature %s", peerId)
 	}
 
 	m := &CosiAction{
@@ -989,15 +1002,16 @@ func (node *Node) CosiAggregateSelfCommitments(peerId crypto.Hash, snap crypto.H
 	}
 	err := node.chain.AppendCosiAction(m)
 	if err != nil {
-		logger.Verbosef("CosiAggregateSelfCommitments(%v) => %v\n", m, err)
+		log.Verbose("CosiAggregateSelfCommitments AppendCosiAction", "error", err)
 	}
 	return nil
 }
 
-func (node *Node) CosiQueueExternalChallenge(peerId crypto.Hash, snap crypto.Hash, cosi *crypto.CosiSignature, ver *common.VersionedTransaction) error {
-	logger.Debugf("CosiQueueExternalChallenge(%s, %s)\n", peerId, snap)
+func (node *Node) CosiQueueExternalChallenge(peerId crypto.Hash, snap crypto.Hash, cosi *crypto.CosiSignature, ver *common.VersionedTransaction, direct bool) error {
+	log := cosiLogger.With("peer", peerId, "snapshot", snap)
+	log.Debug("CosiQueueExternalChallenge", "transaction", ver != nil)
 	if node.GetAcceptedOrPledgingNode(peerId) == nil {
-		logger.Verbosef("CosiQueueExternalChallenge(%s, %s) from malicious node\n", peerId, snap)
+		log.Verbose("CosiQueueExternalChallenge from malicious node")
 		return nil
 	}
 	chain := node.getOrCreateChain(peerId)
@@ -1008,18 +1022,20 @@ func (node *Node) CosiQueueExternalChallenge(peerId crypto.Hash, snap crypto.Has
 		SnapshotHash: snap,
 		Signature:    cosi,
 		Transaction:  ver,
+		direct:       direct,
 	}
 	err := chain.AppendCosiAction(m)
 	if err != nil {
-		logger.Verbosef("CosiQueueExternalChallenge(%v) => %v\n", m, err)
+		log.Verbose("CosiQueueExternalChallenge AppendCosiAction", "error", err)
 	}
 	return nil
 }
 
 func (node *Node) CosiQueueExternalFullChallenge(peerId crypto.Hash, s *common.Snapshot, commitment, challenge *crypto.Key, cosi *crypto.CosiSignature, ver *common.VersionedTransaction) error {
-	logger.Debugf("CosiQueueExternalFullChallenge(%s, %v)\n", peerId, s)
+	log := cosiLogger.With("peer", peerId, "chain", s.NodeId, "round", s.RoundNumber)
+	log.Debug("CosiQueueExternalFullChallenge", "timestamp", s.Timestamp)
 	if node.GetAcceptedOrPledgingNode(peerId) == nil {
-		logger.Verbosef("CosiQueueExternalFullChallenge(%s, %v) from malicious node\n", peerId, s)
+		log.Verbose("CosiQueueExternalFullChallenge from malicious node")
 		return nil
 	}
 	chain := node.getOrCreateChain(peerId)
@@ -1037,15 +1053,16 @@ func (node *Node) CosiQueueExternalFullChallenge(peerId crypto.Hash, s *common.S
 	}
 	err := chain.AppendCosiAction(m)
 	if err != nil {
-		logger.Verbosef("CosiQueueExternalFullChallenge(%v) => %v\n", m, err)
+		log.Verbose("CosiQueueExternalFullChallenge AppendCosiAction", "snapshot", s.Hash, "error", err)
 	}
 	return nil
 }
 
-func (node *Node) CosiAggregateSelfResponses(peerId crypto.Hash, snap crypto.Hash, response *[32]byte) error {
-	logger.Debugf("CosiAggregateSelfResponses(%s, %s)\n", peerId, snap)
+func (node *Node) CosiAggregateSelfResponses(peerId crypto.Hash, snap crypto.Hash, response *[32]byte, direct bool) error {
+	log := cosiLogger.With("peer", peerId, "snapshot", snap)
+	log.Debug("CosiAggregateSelfResponses")
 	if node.GetAcceptedOrPledgingNode(peerId) == nil {
-		logger.Verbosef("CosiAggregateSelfResponses(%s, %s) from malicious node\n", peerId, snap)
+		log.Verbose("CosiAggregateSelfResponses from malicious node")
 		return nil
 	}
 
@@ -1054,33 +1071,34 @@ func (node *Node) CosiAggregateSelfResponses(peerId crypto.Hash, snap crypto.Has
 		Action:       CosiActionSelfResponse,
 		SnapshotHash: snap,
 		Response:     response,
+		direct:       direct,
 	}
 	err := node.chain.AppendCosiAction(m)
 	if err != nil {
-		logger.Verbosef("CosiAggregateSelfResponses(%v) => %v\n", m, err)
+		log.Verbose("CosiAggregateSelfResponses AppendCosiAction", "error", err)
 	}
 	return nil
 }
 
 func (node *Node) VerifyAndQueueAppendSnapshotFinalization(peerId crypto.Hash, s *common.Snapshot) error {
 	s.Hash = s.PayloadHash()
-	logger.Debugf("VerifyAndQueueAppendSnapshotFinalization(%s, %s)\n", peerId, s.Hash)
+	log := cosiLogger.With("peer", peerId, "chain", s.NodeId, "snapshot", s.Hash)
+	log.Debug("VerifyAndQueueAppendSnapshotFinalization")
 
 	node.Peer.ConfirmSnapshotForPeer(peerId, s.Hash)
 	err := node.Peer.SendSnapshotConfirmMessage(peerId, s.Hash)
 	if err != nil {
-		logger.Verbosef("VerifyAndQueueAppendSnapshotFinalization(%s, %s) SendSnapshotConfirmMessage error %s\n",
-			peerId, s.Hash, err)
+		log.Verbose("VerifyAndQueueAppendSnapshotFinalization SendSnapshotConfirmMessage", "error", err)
 		return nil
 	}
 
 	tx, finalized, err := node.checkTxInStorage(s.SoleTransaction())
 	if err != nil {
-		logger.Verbosef("VerifyAndQueueAppendSnapshotFinalization(%s, %s) check tx error %s\n", peerId, s.Hash, err)
+		log.Verbose("VerifyAndQueueAppendSnapshotFinalization checkTxInStorage", "error", err)
 	} else if tx == nil {
 		err = node.Peer.SendTransactionRequestMessage(peerId, s.SoleTransaction())
-		logger.Verbosef("VerifyAndQueueAppendSnapshotFinalization(%s, %s) SendTransactionRequestMessage %s %v\n",
-			peerId, s.Hash, s.SoleTransaction(), err)
+		log.Verbose("VerifyAndQueueAppendSnapshotFinalization SendTransactionRequestMessage",
+			"transaction", s.SoleTransaction(), "error", err)
 	} else if finalized == s.Hash.String() {
 		return nil
 	}
@@ -1090,15 +1108,14 @@ func (node *Node) VerifyAndQueueAppendSnapshotFinalization(peerId crypto.Hash, s
 		return nil
 	}
 	if _, finalized := chain.verifyFinalization(s); !finalized {
-		logger.Verbosef("ERROR VerifyAndQueueAppendSnapshotFinalization %s %v %d %t %v %v\n",
-			peerId, s, node.ConsensusThreshold(s.Timestamp, true), chain.IsPledging(), chain.State, chain.ConsensusInfo)
+		log.Verbose("VerifyAndQueueAppendSnapshotFinalization not finalized", "round", s.RoundNumber,
+			"threshold", node.ConsensusThreshold(s.Timestamp, true), "pledging", chain.IsPledging())
 		return nil
 	}
 
 	err = chain.AppendFinalSnapshot(s.NodeId, s)
 	if err != nil {
-		logger.Verbosef("VerifyAndQueueAppendSnapshotFinalization(%s, %s) chain error %s\n",
-			peerId, s.Hash, err)
+		log.Verbose("VerifyAndQueueAppendSnapshotFinalization AppendFinalSnapshot", "error", err)
 	}
 	return nil
 }
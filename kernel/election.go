@@ -11,7 +11,6 @@ import (
 	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/kernel/internal/clock"
-	"github.com/MixinNetwork/mixin/logger"
 )
 
 const MaxKernelNodesCount = 50
@@ -30,11 +29,11 @@ func (node *Node) ElectionLoop() {
 		case <-ticker.C:
 			err := chain.tryToSendAcceptTransaction()
 			if err != nil {
-				logger.Println("tryToSendAcceptTransaction", err)
+				kernelLogger.Info("tryToSendAcceptTransaction", "chain", chain.ChainId, "error", err)
 			}
 		}
 	}
-	logger.Println("ElectionLoop ACCEPTED!")
+	kernelLogger.Info("ElectionLoop ACCEPTED!", "chain", chain.ChainId)
 
 	for {
 		select {
@@ -43,7 +42,7 @@ func (node *Node) ElectionLoop() {
 		case <-ticker.C:
 			err := node.tryToSendRemoveTransaction()
 			if err != nil {
-				logger.Println("tryToSendRemoveTransaction", err)
+				kernelLogger.Info("tryToSendRemoveTransaction", "error", err)
 			}
 		}
 	}
@@ -171,13 +170,13 @@ func (node *Node) tryToSendRemoveTransaction() error {
 	if err != nil {
 		return err
 	}
-	logger.Verbosef("tryToSendRemoveTransaction %s\n", tx.PayloadHash())
+	kernelLogger.Verbose("tryToSendRemoveTransaction", "transaction", tx.PayloadHash())
 
 	err = tx.Validate(node.persistStore, node.GraphTimestamp, false)
 	if err != nil {
 		return err
 	}
-	err = node.persistStore.CachePutTransaction(tx)
+	err = node.persistStore.CachePutTransaction(tx, node.IdForNetwork)
 	if err != nil {
 		return err
 	}
@@ -308,13 +307,13 @@ func (chain *Chain) tryToSendAcceptTransaction() error {
 	if err != nil {
 		return err
 	}
-	logger.Verbosef("tryToSendAcceptTransaction %s\n", ver.PayloadHash())
+	kernelLogger.Verbose("tryToSendAcceptTransaction", "chain", chain.ChainId, "transaction", ver.PayloadHash())
 
 	err = ver.Validate(chain.node.persistStore, now, false)
 	if err != nil {
 		return err
 	}
-	err = chain.node.persistStore.CachePutTransaction(ver)
+	err = chain.node.persistStore.CachePutTransaction(ver, chain.node.IdForNetwork)
 	if err != nil {
 		return err
 	}
@@ -324,7 +323,8 @@ func (chain *Chain) tryToSendAcceptTransaction() error {
 	}
 	s.AddSoleTransaction(ver.PayloadHash())
 	err = chain.AppendSelfEmpty(s)
-	logger.Println("tryToSendAcceptTransaction", ver.PayloadHash(), hex.EncodeToString(ver.Marshal()), err)
+	kernelLogger.Info("tryToSendAcceptTransaction AppendSelfEmpty", "chain", chain.ChainId,
+		"transaction", ver.PayloadHash(), "raw", hex.EncodeToString(ver.Marshal()), "error", err)
 	return nil
 }
 
@@ -372,7 +372,8 @@ func (node *Node) reloadConsensusState(s *common.Snapshot, tx *common.VersionedT
 	default:
 		return nil
 	}
-	logger.Printf("reloadConsensusState(%v, %v)\n", s, tx)
+	kernelLogger.Info("reloadConsensusState", "chain", s.NodeId, "snapshot", s.Hash,
+		"transaction", s.SoleTransaction(), "type", tx.TransactionType())
 	err := node.LoadConsensusNodes()
 	if err != nil {
 		return err
@@ -412,7 +413,7 @@ func (node *Node) reloadConsensusState(s *common.Snapshot, tx *common.VersionedT
 }
 
 func (node *Node) finalizeNodeAcceptSnapshot(s *common.Snapshot, signers []crypto.Hash) error {
-	logger.Printf("finalizeNodeAcceptSnapshot(%v)\n", s)
+	kernelLogger.Info("finalizeNodeAcceptSnapshot", "chain", s.NodeId, "snapshot", s.Hash, "round", s.RoundNumber)
 	cache := &CacheRound{
 		NodeId:    s.NodeId,
 		Number:    s.RoundNumber,
@@ -93,6 +93,12 @@ ring-final-size = 16384
 listener = "mixin-node.example.com:7239"`)
 
 func setupTestNode(require *require.Assertions, dir string) *Node {
+	return setupTestNodeWithStore(require, dir, func(custom *config.Custom) (storage.Store, error) {
+		return storage.NewMemoryStore(custom)
+	})
+}
+
+func setupTestNodeWithStore(require *require.Assertions, dir string, open func(*config.Custom) (storage.Store, error)) *Node {
 	err := os.WriteFile(dir+"/config.toml", configData, 0644)
 	require.Nil(err)
 
@@ -113,7 +119,7 @@ func setupTestNode(require *require.Assertions, dir string) *Node {
 	})
 	require.Nil(err)
 
-	store, err := storage.NewBadgerStore(custom, dir)
+	store, err := open(custom)
 	require.Nil(err)
 	require.NotNil(store)
 	node, err := SetupNode(custom, store, cache, gns)
@@ -0,0 +1,90 @@
+package kernel
+
+import (
+	"sync"
+
+	"github.com/MixinNetwork/mixin/common"
+)
+
+const (
+	EventTypeSnapshot  = "snapshot"
+	EventTypeNodeState = "node"
+)
+
+type Event struct {
+	Type     string
+	Snapshot *common.SnapshotWithTopologicalOrder
+	Node     *CNode
+}
+
+type EventBus struct {
+	sync.RWMutex
+	subscribers map[*Subscription]bool
+}
+
+// Subscription receives events from the bus, the channel is closed when
+// the subscriber is too slow to consume, and the subscriber should resume
+// with the last received topology from the store
+type Subscription struct {
+	C   <-chan *Event
+	c   chan *Event
+	bus *EventBus
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[*Subscription]bool)}
+}
+
+func (node *Node) Subscribe(size int) *Subscription {
+	return node.events.Subscribe(size)
+}
+
+func (bus *EventBus) Subscribe(size int) *Subscription {
+	c := make(chan *Event, size)
+	sub := &Subscription{C: c, c: c, bus: bus}
+	bus.Lock()
+	bus.subscribers[sub] = true
+	bus.Unlock()
+	return sub
+}
+
+func (sub *Subscription) Close() {
+	sub.bus.Lock()
+	defer sub.bus.Unlock()
+
+	sub.bus.remove(sub)
+}
+
+func (bus *EventBus) remove(sub *Subscription) {
+	if bus.subscribers[sub] {
+		delete(bus.subscribers, sub)
+		close(sub.c)
+	}
+}
+
+// publish never blocks the kernel, all slow subscribers are dropped
+func (bus *EventBus) publish(e *Event) {
+	bus.Lock()
+	defer bus.Unlock()
+
+	for sub := range bus.subscribers {
+		select {
+		case sub.c <- e:
+		default:
+			bus.remove(sub)
+		}
+	}
+}
+
+func (node *Node) publishNodeStateChanges(old, cnodes []*CNode) {
+	states := make(map[string]string)
+	for _, n := range old {
+		states[n.IdForNetwork.String()+n.Transaction.String()] = n.State
+	}
+	for _, n := range cnodes {
+		if states[n.IdForNetwork.String()+n.Transaction.String()] == n.State {
+			continue
+		}
+		node.events.publish(&Event{Type: EventTypeNodeState, Node: n})
+	}
+}
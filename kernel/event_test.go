@@ -0,0 +1,67 @@
+package kernel
+
+import (
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBus(t *testing.T) {
+	require := require.New(t)
+
+	bus := NewEventBus()
+	fast, slow := bus.Subscribe(4), bus.Subscribe(1)
+	require.Len(bus.subscribers, 2)
+
+	s1 := &common.SnapshotWithTopologicalOrder{TopologicalOrder: 1}
+	bus.publish(&Event{Type: EventTypeSnapshot, Snapshot: s1})
+	require.Equal(s1, (<-fast.C).Snapshot)
+	require.Equal(s1, (<-slow.C).Snapshot)
+
+	s2 := &common.SnapshotWithTopologicalOrder{TopologicalOrder: 2}
+	s3 := &common.SnapshotWithTopologicalOrder{TopologicalOrder: 3}
+	bus.publish(&Event{Type: EventTypeSnapshot, Snapshot: s2})
+	bus.publish(&Event{Type: EventTypeSnapshot, Snapshot: s3})
+	require.Len(bus.subscribers, 1)
+	require.Equal(s2, (<-slow.C).Snapshot)
+	_, ok := <-slow.C
+	require.False(ok)
+	require.Equal(s2, (<-fast.C).Snapshot)
+	require.Equal(s3, (<-fast.C).Snapshot)
+	slow.Close()
+
+	fast.Close()
+	fast.Close()
+	_, ok = <-fast.C
+	require.False(ok)
+	require.Len(bus.subscribers, 0)
+	bus.publish(&Event{Type: EventTypeSnapshot, Snapshot: s3})
+}
+
+func TestPublishNodeStateChanges(t *testing.T) {
+	require := require.New(t)
+
+	node := &Node{events: NewEventBus()}
+	sub := node.Subscribe(8)
+	defer sub.Close()
+
+	a, b := crypto.Blake3Hash([]byte("a")), crypto.Blake3Hash([]byte("b"))
+	old := []*CNode{
+		{IdForNetwork: a, Transaction: a, State: common.NodeStatePledging},
+		{IdForNetwork: b, Transaction: b, State: common.NodeStateAccepted},
+	}
+	cnodes := []*CNode{
+		{IdForNetwork: a, Transaction: a, State: common.NodeStateAccepted},
+		{IdForNetwork: b, Transaction: b, State: common.NodeStateAccepted},
+		{IdForNetwork: b, Transaction: a, State: common.NodeStatePledging},
+	}
+	node.publishNodeStateChanges(old, cnodes)
+	require.Len(sub.C, 2)
+	e := <-sub.C
+	require.Equal(EventTypeNodeState, e.Type)
+	require.Equal(cnodes[0], e.Node)
+	e = <-sub.C
+	require.Equal(cnodes[2], e.Node)
+}
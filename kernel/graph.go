@@ -9,7 +9,6 @@ import (
 	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/kernel/internal/clock"
-	"github.com/MixinNetwork/mixin/logger"
 )
 
 func (chain *Chain) startNewRoundAndPersist(cache *CacheRound, references *common.RoundLink, timestamp uint64, finalized bool) (*CacheRound, *FinalRound, bool, error) {
@@ -158,7 +157,7 @@ func (chain *Chain) assignNewGraphRound(final *FinalRound, cache *CacheRound) {
 
 	rounds := chain.State.RoundHistory
 	if n := rounds[len(rounds)-1].Number; n == final.Number {
-		logger.Debugf("graph skip round %s %s %d\n", chain.node.IdForNetwork, chain.ChainId, final.Number)
+		kernelLogger.Debug("graph skip round", "node", chain.node.IdForNetwork, "chain", chain.ChainId, "round", final.Number)
 		return
 	} else if n+1 != final.Number {
 		panic(fmt.Errorf("should never be here %s %d %d", final.NodeId, final.Number, n))
@@ -292,7 +291,8 @@ func (node *Node) cacheVerifyCosi(snap crypto.Hash, sig *crypto.CosiSignature, c
 
 	err := sig.FullVerify(publics, threshold, snap)
 	if err != nil {
-		logger.Verbosef("cacheVerifyCosi(%s, %d, %d) ERROR %s\n", snap, len(publics), threshold, err.Error())
+		kernelLogger.Verbose("cacheVerifyCosi FullVerify", "snapshot", snap,
+			"publics", len(publics), "threshold", threshold, "error", err)
 		node.cacheStore.Set(key, []byte{0}, 1)
 		return nil, false
 	}
@@ -373,7 +373,7 @@ func (chain *Chain) verifyFinalization(s *common.Snapshot) ([]crypto.Hash, bool)
 		return signers, finalized
 	}
 
-	logger.Printf("verifyFinalization(%v) node removal time fork check", s)
+	kernelLogger.Info("verifyFinalization node removal time fork check", "chain", s.NodeId, "snapshot", s.Hash)
 	hour := (timestamp - chain.node.Epoch) / uint64(time.Hour) % 24
 	if hour < config.KernelNodeAcceptTimeBegin || hour > config.KernelNodeAcceptTimeEnd {
 		return signers, finalized
@@ -0,0 +1,138 @@
+package kernel
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/kernel/internal/clock"
+	"github.com/MixinNetwork/mixin/metrics"
+)
+
+var cosiPhaseLatency = metrics.NewHistogram("mixin_kernel_cosi_phase_seconds",
+	"Latency of the cosi phases since the snapshot announcement or the previous phase.",
+	metrics.DefaultLatencyBuckets, "phase")
+
+func sinceUnixNano(ts uint64) float64 {
+	return clock.Now().Sub(time.Unix(0, int64(ts))).Seconds()
+}
+
+// WriteMetrics writes the gauges read from the node state at the scrape time,
+// the works and round spaces are only for the accepted and pledging nodes
+func (node *Node) WriteMetrics(w *metrics.Writer) error {
+	w.Gauge("mixin_kernel_uptime_seconds", "Time since the node started.", node.Uptime().Seconds())
+	w.Gauge("mixin_kernel_topology", "Topological order of the last snapshot.", float64(node.TopologicalOrder()))
+	w.Gauge("mixin_kernel_sps", "Snapshots finalized per second.", node.SPS())
+	w.Gauge("mixin_kernel_tps", "Transactions finalized per second.", node.TPS())
+
+	caches, finals, state := node.QueueState()
+	w.Gauge("mixin_kernel_queue_depth", "Pending actions in the chain queues.", float64(caches), "queue", "cache")
+	w.Gauge("mixin_kernel_queue_depth", "Pending actions in the chain queues.", float64(finals), "queue", "final")
+	ids := make([]string, 0, len(state))
+	for id := range state {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		sa := state[id]
+		w.Gauge("mixin_kernel_chain_queue_depth", "Pending actions in the queues of each chain.", float64(sa[0]), "chain", id, "queue", "cache")
+		w.Gauge("mixin_kernel_chain_queue_depth", "Pending actions in the queues of each chain.", float64(sa[1]), "chain", id, "queue", "final")
+	}
+
+	pool, err := node.PoolSize()
+	if err != nil {
+		return err
+	}
+	w.Gauge("mixin_kernel_mint_batch", "Batch of the last mint distribution.", float64(node.LastMint))
+	amount, _ := strconv.ParseFloat(pool.String(), 64)
+	w.Gauge("mixin_kernel_mint_pool", "Remaining XIN in the mint pool.", amount)
+
+	list := node.NodesListWithoutState(node.GraphTimestamp, false)
+	for _, n := range list {
+		w.Gauge("mixin_kernel_nodes", "Kernel nodes in each state.", 1, "node", n.IdForNetwork.String(), "state", n.State)
+	}
+	cids := make([]crypto.Hash, 0)
+	for _, n := range list {
+		switch n.State {
+		case common.NodeStateAccepted, common.NodeStatePledging:
+			cids = append(cids, n.IdForNetwork)
+		}
+	}
+	day := uint32(node.GraphTimestamp / uint64(time.Hour*24))
+	works, err := node.persistStore.ListNodeWorks(cids, day)
+	if err != nil {
+		return err
+	}
+	offsets, err := node.persistStore.ListWorkOffsets(cids)
+	if err != nil {
+		return err
+	}
+	spaces, err := node.persistStore.ListAggregatedRoundSpaceCheckpoints(cids)
+	if err != nil {
+		return err
+	}
+	// each family is written in its own loop, the text format requires all
+	// samples of a family to be grouped together
+	for _, id := range cids {
+		w.Gauge("mixin_kernel_mint_works", "Mint works of the current day.", float64(works[id][0]), "node", id.String(), "kind", "lead")
+		w.Gauge("mixin_kernel_mint_works", "Mint works of the current day.", float64(works[id][1]), "node", id.String(), "kind", "sign")
+	}
+	for _, id := range cids {
+		w.Gauge("mixin_kernel_work_offset", "Round of the mint work aggregation.", float64(offsets[id]), "node", id.String())
+	}
+
+	batches := make(map[crypto.Hash][]*common.RoundSpace)
+	for _, id := range cids {
+		space := spaces[id]
+		if space == nil {
+			continue
+		}
+		batch, err := node.persistStore.ReadNodeRoundSpacesForBatch(id, space.Batch)
+		if err != nil {
+			return err
+		}
+		batches[id] = batch
+	}
+	for _, id := range cids {
+		if space := spaces[id]; space != nil {
+			w.Gauge("mixin_kernel_round_space_checkpoint", "Round of the round space aggregation.", float64(space.Round), "node", id.String())
+		}
+	}
+	for _, id := range cids {
+		if spaces[id] != nil {
+			w.Gauge("mixin_kernel_round_spaces", "Large round gaps in the aggregated batch.", float64(len(batches[id])), "node", id.String())
+		}
+	}
+	for _, id := range cids {
+		if spaces[id] == nil {
+			continue
+		}
+		var duration uint64
+		for _, s := range batches[id] {
+			duration = duration + s.Duration
+		}
+		w.Gauge("mixin_kernel_round_space_seconds", "Total duration of the large round gaps in the aggregated batch.", float64(duration)/float64(time.Second), "node", id.String())
+	}
+
+	stats := node.persistStore.ReadDBStats()
+	for _, db := range stats {
+		w.Gauge("mixin_storage_lsm_bytes", "Size of the badger LSM tree.", float64(db.LSMSize), "db", db.Name)
+	}
+	for _, db := range stats {
+		w.Gauge("mixin_storage_vlog_bytes", "Size of the badger value log.", float64(db.VlogSize), "db", db.Name)
+	}
+	for _, db := range stats {
+		for _, l := range db.Levels {
+			w.Gauge("mixin_storage_level_tables", "Tables in each badger LSM level.", float64(l.Tables), "db", db.Name, "level", fmt.Sprint(l.Level))
+		}
+	}
+	for _, db := range stats {
+		for _, l := range db.Levels {
+			w.Gauge("mixin_storage_level_bytes", "Size of each badger LSM level.", float64(l.Size), "db", db.Name, "level", fmt.Sprint(l.Level))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package kernel
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	require := require.New(t)
+
+	node := setupTestNode(require, t.TempDir())
+	buf := new(bytes.Buffer)
+	var merr error
+	err := metrics.Write(buf, func(w *metrics.Writer) {
+		merr = node.WriteMetrics(w)
+	})
+	require.Nil(err)
+	require.Nil(merr)
+
+	// every family has a single TYPE line followed by all its samples
+	families := make(map[string]int)
+	var current, kind string
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if strings.HasPrefix(line, "# HELP ") {
+			continue
+		}
+		if strings.HasPrefix(line, "# TYPE ") {
+			parts := strings.Fields(line)
+			require.Len(parts, 4, line)
+			current, kind = parts[2], parts[3]
+			_, found := families[current]
+			require.False(found, current)
+			families[current] = 0
+			continue
+		}
+		name := line[:strings.IndexAny(line, "{ ")]
+		if kind == "histogram" {
+			for _, suffix := range []string{"_bucket", "_sum", "_count"} {
+				name = strings.TrimSuffix(name, suffix)
+			}
+		}
+		require.Equal(current, name, line)
+		families[name] += 1
+	}
+
+	nodes := len(node.NodesListWithoutState(node.GraphTimestamp, true))
+	require.Greater(nodes, 0)
+	require.Equal(nodes*2, families["mixin_kernel_mint_works"])
+	require.Equal(nodes, families["mixin_kernel_work_offset"])
+	require.Equal(nodes, families["mixin_kernel_nodes"])
+	require.Equal(2, families["mixin_kernel_queue_depth"])
+	require.Equal(2, families["mixin_storage_lsm_bytes"])
+	require.Equal(2, families["mixin_storage_vlog_bytes"])
+}
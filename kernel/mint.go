@@ -14,6 +14,8 @@ import (
 	"github.com/dgraph-io/badger/v4"
 )
 
+var mintLogger = logger.New("kernel.mint")
+
 var (
 	MintPool        = common.NewInteger(500000)
 	MintLiquidity   = common.NewInteger(500000)
@@ -27,20 +29,21 @@ const (
 )
 
 func (chain *Chain) AggregateMintWork() {
-	logger.Printf("AggregateMintWork(%s)\n", chain.ChainId)
+	log := mintLogger.With("chain", chain.ChainId)
+	log.Info("AggregateMintWork")
 	defer close(chain.wlc)
 
 	round, err := chain.persistStore.ReadWorkOffset(chain.ChainId)
 	if err != nil {
 		panic(err)
 	}
-	logger.Printf("AggregateMintWork(%s) begin with %d\n", chain.ChainId, round)
+	log.Info("AggregateMintWork begin", "round", round)
 
 	wait := time.Duration(chain.node.custom.Node.KernelOprationPeriod/2) * time.Second
 
 	for chain.running {
 		if cs := chain.State; cs == nil {
-			logger.Printf("AggregateMintWork(%s) no state yet\n", chain.ChainId)
+			log.Info("AggregateMintWork no state yet")
 			chain.waitOrDone(wait)
 			continue
 		}
@@ -60,7 +63,7 @@ func (chain *Chain) AggregateMintWork() {
 		}
 		snapshots, err := chain.persistStore.ReadSnapshotWorksForNodeRound(chain.ChainId, round)
 		if err != nil {
-			logger.Printf("AggregateMintWork(%s) ERROR ReadSnapshotsForNodeRound %s\n", chain.ChainId, err.Error())
+			log.Info("AggregateMintWork ReadSnapshotWorksForNodeRound", "round", round, "error", err)
 			continue
 		}
 		rd := snapshots[0].Timestamp / OneDay
@@ -78,7 +81,7 @@ func (chain *Chain) AggregateMintWork() {
 		}
 	}
 
-	logger.Printf("AggregateMintWork(%s) end with %d\n", chain.ChainId, round)
+	log.Info("AggregateMintWork end", "round", round)
 }
 
 func (chain *Chain) checkRoundMature(round uint64) (uint64, bool) {
@@ -111,7 +114,7 @@ func (chain *Chain) writeRoundWork(round uint64, works []*common.SnapshotWork, c
 			return nil
 		}
 		if errors.Is(err, badger.ErrConflict) {
-			logger.Verbosef("AggregateMintWork(%s) ERROR WriteRoundWork %s\n", chain.ChainId, err.Error())
+			mintLogger.Verbose("AggregateMintWork WriteRoundWork", "chain", chain.ChainId, "round", round, "error", err)
 			time.Sleep(100 * time.Millisecond)
 			continue
 		}
@@ -136,7 +139,7 @@ func (node *Node) MintLoop() {
 				panic(err)
 			}
 			err = node.tryToMintUniversal(cur)
-			logger.Println(node.IdForNetwork, "tryToMintKernelUniversal", err)
+			mintLogger.Info("tryToMintKernelUniversal", "node", node.IdForNetwork, "error", err)
 		}
 	}
 }
@@ -159,7 +162,7 @@ func (node *Node) tryToMintUniversal(custodianRequest *common.CustodianUpdateReq
 	if err != nil {
 		return err
 	}
-	err = node.persistStore.CachePutTransaction(signed)
+	err = node.persistStore.CachePutTransaction(signed, node.IdForNetwork)
 	if err != nil {
 		return err
 	}
@@ -168,7 +171,7 @@ func (node *Node) tryToMintUniversal(custodianRequest *common.CustodianUpdateReq
 		NodeId:  node.IdForNetwork,
 	}
 	s.AddSoleTransaction(signed.PayloadHash())
-	logger.Println("tryToMintUniversal", signed.PayloadHash(), hex.EncodeToString(signed.Marshal()))
+	mintLogger.Info("tryToMintUniversal", "transaction", signed.PayloadHash(), "raw", hex.EncodeToString(signed.Marshal()))
 	return node.chain.AppendSelfEmpty(s)
 }
 
@@ -182,7 +185,7 @@ func (node *Node) buildUniversalMintTransaction(custodianRequest *common.Custodi
 	accepted := node.NodesListWithoutState(timestamp, true)
 	mints, err := node.distributeKernelMintByWorks(accepted, kernel, timestamp)
 	if err != nil {
-		logger.Printf("buildUniversalMintTransaction ERROR %s\n", err.Error())
+		mintLogger.Info("buildUniversalMintTransaction distributeKernelMintByWorks", "error", err)
 		return nil
 	}
 
@@ -339,8 +342,8 @@ func (node *Node) checkUniversalMintPossibility(timestamp uint64, validateOnly b
 	}
 
 	dist := node.lastMintDistribution()
-	logger.Verbosef("checkUniversalMintPossibility OLD %d %s %d\n",
-		batch, dist.Amount, dist.Batch)
+	mintLogger.Verbose("checkUniversalMintPossibility OLD",
+		"batch", batch, "last_amount", dist.Amount, "last_batch", dist.Batch)
 
 	if batch < dist.Batch {
 		return 0, common.Zero
@@ -353,8 +356,8 @@ func (node *Node) checkUniversalMintPossibility(timestamp uint64, validateOnly b
 	}
 
 	amount := mintMultiBatchesSize(dist.Batch, batch)
-	logger.Verbosef("checkUniversalMintPossibility NEW %s %d %s %d\n",
-		amount, batch, dist.Amount, dist.Batch)
+	mintLogger.Verbose("checkUniversalMintPossibility NEW", "amount", amount,
+		"batch", batch, "last_amount", dist.Amount, "last_batch", dist.Batch)
 	return batch, amount
 }
 
@@ -434,7 +437,7 @@ func (node *Node) distributeKernelMintByWorks(accepted []*CNode, base common.Int
 			// TODO enable this for universal mint distributions, need to ensure all nodes
 			// have their own transaction monitor, send some regular transactions
 			// otherwise this will not work in low transaction conditions
-			logger.Verbosef("node spaces %s %d %d\n", m.IdForNetwork, ns[0].Batch, len(ns))
+			mintLogger.Verbose("node spaces", "node", m.IdForNetwork, "batch", ns[0].Batch, "spaces", len(ns))
 		}
 
 		w := works[m.IdForNetwork]
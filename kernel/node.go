@@ -20,6 +20,8 @@ import (
 	"github.com/dgraph-io/ristretto/v2"
 )
 
+var kernelLogger = logger.New("kernel")
+
 type Node struct {
 	IdForNetwork crypto.Hash
 	Signer       common.Address
@@ -47,11 +49,14 @@ type Node struct {
 	persistStore    storage.Store
 	cacheStore      *ristretto.Cache[[]byte, any]
 	custom          *config.Custom
+	events          *EventBus
 
 	done chan struct{}
 	elc  chan struct{}
 	mlc  chan struct{}
 	cqc  chan struct{}
+	wsc  chan struct{}
+	plc  chan struct{}
 }
 
 type NodeStateSequence struct {
@@ -77,11 +82,14 @@ func SetupNode(custom *config.Custom, store storage.Store, cache *ristretto.Cach
 		persistStore:    store,
 		cacheStore:      cache,
 		custom:          custom,
+		events:          NewEventBus(),
 		startAt:         clock.Now(),
 		done:            make(chan struct{}),
 		elc:             make(chan struct{}),
 		mlc:             make(chan struct{}),
 		cqc:             make(chan struct{}),
+		wsc:             make(chan struct{}),
+		plc:             make(chan struct{}),
 	}
 
 	node.loadNodeConfig()
@@ -95,7 +103,7 @@ func SetupNode(custom *config.Custom, store storage.Store, cache *ristretto.Cach
 	}
 	node.TopoCounter = node.getTopologyCounter(store)
 
-	logger.Println("Validating graph entries...")
+	kernelLogger.Println("Validating graph entries...")
 	start := clock.Now()
 	total, invalid, err := node.persistStore.ValidateGraphEntries(node.networkId, 10)
 	if err != nil {
@@ -103,7 +111,7 @@ func SetupNode(custom *config.Custom, store storage.Store, cache *ristretto.Cach
 	} else if invalid > 0 {
 		return nil, fmt.Errorf("validate graph with %d/%d invalid entries", invalid, total)
 	}
-	logger.Printf("Validate graph with %d total entries in %s\n", total, clock.Now().Sub(start).String())
+	kernelLogger.Info("Validate graph", "entries", total, "duration", clock.Now().Sub(start))
 
 	err = node.LoadConsensusNodes()
 	if err != nil {
@@ -121,10 +129,10 @@ func SetupNode(custom *config.Custom, store storage.Store, cache *ristretto.Cach
 	}
 	node.chain = node.BootChain(node.IdForNetwork)
 
-	logger.Printf("Signer:\t%s\n", node.Signer.String())
-	logger.Printf("Network:\t%s\n", node.networkId.String())
-	logger.Printf("Node Id:\t%s\n", node.IdForNetwork.String())
-	logger.Printf("Topology:\t%d\n", node.TopoCounter.seq)
+	kernelLogger.Printf("Signer:\t%s\n", node.Signer.String())
+	kernelLogger.Printf("Network:\t%s\n", node.networkId.String())
+	kernelLogger.Printf("Node Id:\t%s\n", node.IdForNetwork.String())
+	kernelLogger.Printf("Topology:\t%d\n", node.TopoCounter.seq)
 	return node, nil
 }
 
@@ -293,7 +301,8 @@ func (node *Node) ConsensusThreshold(timestamp uint64, final bool) int {
 		}
 	}
 	if consensusBase < config.KernelMinimumNodesCount {
-		logger.Debugf("invalid consensus base %d %d %d\n", timestamp, consensusBase, config.KernelMinimumNodesCount)
+		kernelLogger.Debug("invalid consensus base", "timestamp", timestamp,
+			"base", consensusBase, "minimum", config.KernelMinimumNodesCount)
 		return 1000
 	}
 	return consensusBase*2/3 + 1
@@ -323,8 +332,10 @@ func (node *Node) LoadConsensusNodes() error {
 			Timestamp:    n.Timestamp,
 			State:        n.State,
 		}
-		logger.Printf("LoadConsensusNode %v\n", cnodes[i])
+		kernelLogger.Info("LoadConsensusNode", "node", cnodes[i].IdForNetwork, "transaction", n.Transaction,
+			"timestamp", n.Timestamp, "state", n.State)
 	}
+	node.publishNodeStateChanges(node.allNodesSortedWithState, cnodes)
 	node.allNodesSortedWithState = cnodes
 	node.nodeStateSequences = node.buildNodeStateSequences(cnodes, false)
 	node.acceptedNodeStateSequences = node.buildNodeStateSequences(cnodes, true)
@@ -343,7 +354,11 @@ func (node *Node) NewTransaction(assetId crypto.Hash) *common.Transaction {
 func (node *Node) addRelayersFromConfig() error {
 	addr := fmt.Sprintf(":%d", node.custom.P2P.Port)
 	node.Peer = p2p.NewPeer(node, node.IdForNetwork, addr, node.isRelayer)
+	if node.custom.P2P.Metric {
+		node.Peer.EnableMetric()
+	}
 
+	var seeds []crypto.Hash
 	for _, s := range node.custom.P2P.Seeds {
 		parts := strings.Split(s, "@")
 		if len(parts) != 2 {
@@ -356,8 +371,14 @@ func (node *Node) addRelayersFromConfig() error {
 		if nid == node.IdForNetwork {
 			continue
 		}
-		go node.Peer.ConnectRelayer(nid, parts[1])
+		addr := parts[1]
+		if !strings.Contains(addr, "://") {
+			addr = node.custom.P2P.SeedTransport + "://" + addr
+		}
+		seeds = append(seeds, nid)
+		go node.Peer.ConnectRelayer(nid, addr)
 	}
+	go node.Peer.DiscoverRelayers(seeds)
 	return nil
 }
 
@@ -365,7 +386,7 @@ func (node *Node) listenConsumers() {
 	if !node.isRelayer {
 		return
 	}
-	err := node.Peer.ListenConsumers()
+	err := node.Peer.ListenConsumers(node.custom.P2P.Transports)
 	if err != nil {
 		panic(err)
 	}
@@ -474,7 +495,26 @@ func (node *Node) SendTransactionToPeer(peerId, hash crypto.Hash) error {
 }
 
 func (node *Node) CachePutTransaction(peerId crypto.Hash, tx *common.VersionedTransaction) error {
-	return node.persistStore.CachePutTransaction(tx)
+	if common.CheckTxVersionActivation(tx.Version, clock.NowUnixNano()) != nil {
+		return nil
+	}
+	return node.persistStore.CachePutTransaction(tx, peerId)
+}
+
+func (node *Node) ReadPeerBans() (map[crypto.Hash]uint64, error) {
+	return node.persistStore.ReadPeerBans()
+}
+
+func (node *Node) WritePeerBan(peerId crypto.Hash, until uint64) error {
+	return node.persistStore.WritePeerBan(peerId, until)
+}
+
+func (node *Node) ReadPeerAddresses() (map[crypto.Hash][]byte, error) {
+	return node.persistStore.ReadPeerAddresses()
+}
+
+func (node *Node) WritePeerAddress(peerId crypto.Hash, data []byte) error {
+	return node.persistStore.WritePeerAddress(peerId, data)
 }
 
 func (node *Node) ReadAllNodesWithoutState() []crypto.Hash {
@@ -491,6 +531,12 @@ func (node *Node) ReadSnapshotsSinceTopology(offset, count uint64) ([]*common.Sn
 }
 
 func (node *Node) ReadSnapshotsForNodeRound(nodeIdWithNetwork crypto.Hash, round uint64) ([]*common.SnapshotWithTopologicalOrder, error) {
+	if node.custom.Storage.Prune {
+		checkpoint, err := node.persistStore.ReadPruneCheckpoint(nodeIdWithNetwork)
+		if err != nil || round < checkpoint {
+			return nil, err
+		}
+	}
 	return node.persistStore.ReadSnapshotsForNodeRound(nodeIdWithNetwork, round)
 }
 
@@ -508,7 +554,7 @@ func (node *Node) sendGraphToConcensusNodesAndPeers() {
 		for id := range peers {
 			err := node.Peer.SendGraphMessage(id)
 			if err != nil {
-				logger.Debugf("SendGraphMessage(%s) => %v\n", id, err)
+				kernelLogger.Debug("SendGraphMessage", "peer", id, "error", err)
 			}
 		}
 		time.Sleep(time.Duration(config.SnapshotRoundGap / 2))
@@ -518,7 +564,7 @@ func (node *Node) sendGraphToConcensusNodesAndPeers() {
 func (node *Node) UpdateSyncPoint(peerId crypto.Hash, points []*p2p.SyncPoint, data []byte, sig *crypto.Signature) error {
 	peer := node.GetAcceptedOrPledgingNode(peerId)
 	if peer != nil && !peer.Signer.PublicSpendKey.Verify(crypto.Blake3Hash(data), *sig) {
-		return fmt.Errorf("invalid graph signature %s", peerId)
+		return p2p.NewMisbehaviorError(p2p.PeerMisbehaviorInvalidSignature, "invalid graph signature %s", peerId)
 	}
 	for _, p := range points {
 		if p.NodeId == node.IdForNetwork {
@@ -571,32 +617,33 @@ func (node *Node) CheckCatchUpWithPeers() bool {
 			continue
 		}
 		if remote.Number > final+1 {
-			logger.Verbosef("CheckCatchUpWithPeers local(%d)+1 < remote(%s:%d)\n", final, cn.IdForNetwork, remote.Number)
+			kernelLogger.Verbose("CheckCatchUpWithPeers local behind remote",
+				"local", final, "peer", cn.IdForNetwork, "remote", remote.Number)
 			return false
 		}
 		if cache == nil {
-			logger.Verbosef("CheckCatchUpWithPeers local cache nil\n")
+			kernelLogger.Verbose("CheckCatchUpWithPeers local cache nil")
 			return false
 		}
 		cf := cache.asFinal()
 		if cf == nil {
-			logger.Verbosef("CheckCatchUpWithPeers local cache empty\n")
+			kernelLogger.Verbose("CheckCatchUpWithPeers local cache empty")
 			return false
 		}
 		if cf.Hash != remote.Hash {
-			logger.Verbosef("CheckCatchUpWithPeers local(%s) != remote(%s)\n",
-				cf.Hash, remote.Hash)
+			kernelLogger.Verbose("CheckCatchUpWithPeers local round mismatch",
+				"peer", cn.IdForNetwork, "local", cf.Hash, "remote", remote.Hash)
 			return false
 		}
 		if now := clock.NowUnixNano(); cf.Start+config.SnapshotRoundGap*100 > now {
-			logger.Verbosef("CheckCatchUpWithPeers local start(%d)+%d > now(%d)\n",
-				cf.Start, config.SnapshotRoundGap*100, now)
+			kernelLogger.Verbose("CheckCatchUpWithPeers local round too recent",
+				"start", cf.Start, "gap", config.SnapshotRoundGap*100, "now", now)
 			return false
 		}
 	}
 
 	if updated < threshold {
-		logger.Verbosef("CheckCatchUpWithPeers updated(%d) < threshold(%d)\n", updated, threshold)
+		kernelLogger.Verbose("CheckCatchUpWithPeers not enough peers", "updated", updated, "threshold", threshold)
 	}
 	return updated >= threshold
 }
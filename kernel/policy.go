@@ -0,0 +1,128 @@
+package kernel
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+const (
+	CacheQueueWindow = 500
+	CacheQueueLimit  = 100
+)
+
+// cachePolicy is the priority list to order the queued transactions, the
+// transactions are sent in the queued order if no priority configured
+type cachePolicy []string
+
+type cacheCandidate struct {
+	entry *storage.CacheTransaction
+	class int
+	fee   common.Integer
+	size  int
+	turn  int
+}
+
+// cacheClass ranks the node and custodian operations and mints first, then
+// the withdrawals and deposits, and all other transactions at last
+func cacheClass(tx *common.VersionedTransaction) int {
+	switch tx.TransactionType() {
+	case common.TransactionTypeScript, common.TransactionTypeUnknown:
+		return 2
+	case common.TransactionTypeWithdrawalSubmit,
+		common.TransactionTypeWithdrawalClaim,
+		common.TransactionTypeDeposit:
+		return 1
+	}
+	return 0
+}
+
+func newCacheCandidate(entry *storage.CacheTransaction) *cacheCandidate {
+	tx := entry.Transaction
+	return &cacheCandidate{
+		entry: entry,
+		class: cacheClass(tx),
+		fee:   tx.StorageFee(),
+		size:  len(tx.Marshal()),
+	}
+}
+
+// compareFee compares the storage fee per byte of two candidates
+func (a *cacheCandidate) compareFee(b *cacheCandidate) int {
+	return a.fee.Mul(b.size).Cmp(b.fee.Mul(a.size))
+}
+
+func (p cachePolicy) compare(a, b *cacheCandidate) int {
+	for _, rule := range p {
+		switch rule {
+		case "kernel":
+			if a.class != b.class {
+				return a.class - b.class
+			}
+		case "fee":
+			if c := b.compareFee(a); c != 0 {
+				return c
+			}
+		case "fair":
+			if a.turn != b.turn {
+				return a.turn - b.turn
+			}
+		}
+	}
+	return 0
+}
+
+// order sorts the queued transactions by the priority, the turn of a sender
+// increases with each of its transactions, so one sender can't take all the
+// queue. A transaction spending the same input with a prior one is deferred
+// in the queue, and it will be invalid once the prior one locks the input.
+func (p cachePolicy) order(entries []*storage.CacheTransaction) []*storage.CacheTransaction {
+	turns := make(map[crypto.Hash]int)
+	candidates := make([]*cacheCandidate, len(entries))
+	for i, e := range entries {
+		candidates[i] = newCacheCandidate(e)
+		candidates[i].turn = turns[e.Sender]
+		turns[e.Sender] = turns[e.Sender] + 1
+	}
+	slices.SortStableFunc(candidates, p.compare)
+
+	var ordered []*storage.CacheTransaction
+	spent := make(map[string]bool)
+	for _, c := range candidates {
+		var keys []string
+		conflict := false
+		for _, in := range c.entry.Transaction.Inputs {
+			if !in.Hash.HasValue() {
+				continue
+			}
+			key := fmt.Sprintf("%s:%d", in.Hash, in.Index)
+			conflict = conflict || spent[key]
+			keys = append(keys, key)
+		}
+		if conflict {
+			continue
+		}
+		for _, k := range keys {
+			spent[k] = true
+		}
+		ordered = append(ordered, c.entry)
+	}
+	return ordered
+}
+
+// cacheReplaces checks whether the transaction could replace a queued one
+// spending the same input, it must be of a more important class, or of the
+// same class and pays at least 10% more storage fee per byte. The replaced
+// one is removed from the cache, but it could still be finalized if it has
+// locked the inputs before the replacement.
+func cacheReplaces(tx *common.VersionedTransaction, old *storage.CacheTransaction) bool {
+	a := newCacheCandidate(&storage.CacheTransaction{Transaction: tx})
+	b := newCacheCandidate(old)
+	if a.class != b.class {
+		return a.class < b.class
+	}
+	return a.fee.Mul(b.size*10).Cmp(b.fee.Mul(a.size*11)) > 0
+}
@@ -0,0 +1,95 @@
+package kernel
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachePolicy(t *testing.T) {
+	require := require.New(t)
+
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	account := common.NewAddressFromSeed(seed)
+	accounts := []*common.Address{&account}
+	build := func(input string, fee string, ot uint8) *common.VersionedTransaction {
+		tx := common.NewTransactionV5(common.XINAssetId)
+		tx.AddInput(crypto.Blake3Hash([]byte(input)), 0)
+		tx.AddOutputWithType(ot, accounts, common.NewThresholdScript(1), common.NewInteger(1), seed)
+		if fee != "" {
+			tx.AddScriptOutput(accounts, common.Script{0xff, 0xfe, 0x40}, common.NewIntegerFromString(fee), seed)
+		}
+		return tx.AsVersioned()
+	}
+	alice, bob := crypto.Blake3Hash([]byte("alice")), crypto.Blake3Hash([]byte("bob"))
+	a1 := &storage.CacheTransaction{Transaction: build("a1", "", common.OutputTypeScript), Sender: alice}
+	a2 := &storage.CacheTransaction{Transaction: build("a2", "", common.OutputTypeScript), Sender: alice}
+	a3 := &storage.CacheTransaction{Transaction: build("a3", "0.001", common.OutputTypeScript), Sender: alice}
+	b1 := &storage.CacheTransaction{Transaction: build("b1", "", common.OutputTypeScript), Sender: bob}
+	w1 := &storage.CacheTransaction{Transaction: build("w1", "", common.OutputTypeWithdrawalSubmit), Sender: bob}
+	c1 := &storage.CacheTransaction{Transaction: build("a1", "0.01", common.OutputTypeScript), Sender: bob}
+	entries := []*storage.CacheTransaction{a1, a2, a3, b1, w1, c1}
+
+	ordered := cachePolicy{}.order(entries)
+	require.Equal([]*storage.CacheTransaction{a1, a2, a3, b1, w1}, ordered)
+	ordered = cachePolicy{"kernel", "fee", "fair"}.order(entries)
+	require.Equal([]*storage.CacheTransaction{w1, c1, a3, b1, a2}, ordered)
+	ordered = cachePolicy{"fair", "kernel"}.order(entries)
+	require.Equal([]*storage.CacheTransaction{a1, b1, w1, a2, a3}, ordered)
+
+	require.True(cacheReplaces(c1.Transaction, a1))
+	require.False(cacheReplaces(a1.Transaction, c1))
+	require.False(cacheReplaces(a1.Transaction, a1))
+	require.True(cacheReplaces(w1.Transaction, c1))
+	require.False(cacheReplaces(c1.Transaction, w1))
+	c2 := &storage.CacheTransaction{Transaction: build("a1", "0.0105", common.OutputTypeScript)}
+	require.False(cacheReplaces(c2.Transaction, c1))
+	c2 = &storage.CacheTransaction{Transaction: build("a1", "0.0111", common.OutputTypeScript)}
+	require.True(cacheReplaces(c2.Transaction, c1))
+}
+
+func TestCacheQueueCongestion(t *testing.T) {
+	require := require.New(t)
+
+	node := setupTestNode(require, t.TempDir())
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	account := common.NewAddressFromSeed(seed)
+	accounts := []*common.Address{&account}
+	build := func(input string, ot uint8) *common.VersionedTransaction {
+		tx := common.NewTransactionV5(common.XINAssetId)
+		tx.AddInput(crypto.Blake3Hash([]byte(input)), 0)
+		tx.AddOutputWithType(ot, accounts, common.NewThresholdScript(1), common.NewInteger(1), seed)
+		return tx.AsVersioned()
+	}
+	alice, bob := crypto.Blake3Hash([]byte("alice")), crypto.Blake3Hash([]byte("bob"))
+	for i := range CacheQueueWindow + 10 {
+		tx := build(fmt.Sprintf("s%d", i), common.OutputTypeScript)
+		err := node.persistStore.CachePutTransaction(tx, alice)
+		require.Nil(err)
+	}
+	w := build("w", common.OutputTypeWithdrawalSubmit)
+	err := node.persistStore.CachePutTransaction(w, bob)
+	require.Nil(err)
+
+	policy := cachePolicy{"kernel", "fee", "fair"}
+	entries, err := node.selectCacheTransactions(policy, true)
+	require.Nil(err)
+	require.Len(entries, 1)
+	require.Equal(w.PayloadHash(), entries[0].Transaction.PayloadHash())
+	require.Equal(bob, entries[0].Sender)
+	entries, err = node.selectCacheTransactions(policy, true)
+	require.Nil(err)
+	require.Len(entries, 0)
+	entries, err = node.selectCacheTransactions(policy, false)
+	require.Nil(err)
+	require.Len(entries, CacheQueueLimit)
+	for _, e := range entries {
+		require.Equal(alice, e.Sender)
+	}
+}
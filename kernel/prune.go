@@ -0,0 +1,82 @@
+package kernel
+
+import (
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/kernel/internal/clock"
+)
+
+const pruneRoundsBatch = 100
+
+// PruneLoop removes the spent history of all node rounds behind the prune
+// window, the rounds not yet aggregated for the mint works and the round
+// spaces are always kept
+func (node *Node) PruneLoop() {
+	defer close(node.plc)
+
+	if !node.custom.Storage.Prune {
+		return
+	}
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-node.done:
+			return
+		case <-ticker.C:
+		}
+		nodes := node.NodesListWithoutState(clock.NowUnixNano(), false)
+		for _, cn := range nodes {
+			before := node.pruneRoundsLimit(cn.IdForNetwork)
+			for node.pruneNodeRoundsBatch(cn.IdForNetwork, before) {
+				select {
+				case <-node.done:
+					return
+				default:
+				}
+			}
+		}
+	}
+}
+
+func (node *Node) pruneRoundsLimit(id crypto.Hash) uint64 {
+	head, err := node.persistStore.ReadRound(id)
+	if err != nil {
+		panic(err)
+	}
+	window := node.custom.Storage.PruneWindow
+	if head == nil || head.Number <= window {
+		return 0
+	}
+	before := head.Number - window
+
+	offset, err := node.persistStore.ReadWorkOffset(id)
+	if err != nil {
+		panic(err)
+	}
+	_, space, err := node.persistStore.ReadRoundSpaceCheckpoint(id)
+	if err != nil {
+		panic(err)
+	}
+	return min(before, offset, space)
+}
+
+func (node *Node) pruneNodeRoundsBatch(id crypto.Hash, before uint64) bool {
+	old, err := node.persistStore.ReadPruneCheckpoint(id)
+	if err != nil {
+		panic(err)
+	}
+	if old >= before {
+		return false
+	}
+	checkpoint, err := node.persistStore.PruneNodeRounds(id, before, pruneRoundsBatch)
+	if err != nil {
+		kernelLogger.Error("PruneNodeRounds", "chain", id, "before", before, "error", err)
+		return false
+	}
+	kernelLogger.Debug("pruneNodeRoundsBatch", "chain", id, "before", before, "from", old, "to", checkpoint)
+	return checkpoint < before
+}
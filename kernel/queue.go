@@ -1,6 +1,7 @@
 package kernel
 
 import (
+	"fmt"
 	"math/big"
 	"time"
 
@@ -8,10 +9,12 @@ import (
 	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/kernel/internal/clock"
-	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/storage"
 )
 
-func (node *Node) QueueTransaction(tx *common.VersionedTransaction) (string, error) {
+// QueueTransaction validates and queues the transaction submitted by the
+// source, the fair policy takes turns among the sources
+func (node *Node) QueueTransaction(tx *common.VersionedTransaction, source crypto.Hash) (string, error) {
 	hash := tx.PayloadHash()
 	_, finalized, err := node.persistStore.ReadTransaction(hash)
 	if err != nil {
@@ -26,14 +29,18 @@ func (node *Node) QueueTransaction(tx *common.VersionedTransaction) (string, err
 		return "", err
 	}
 	if old != nil {
-		return old.PayloadHash().String(), node.persistStore.CachePutTransaction(tx)
+		return old.PayloadHash().String(), node.persistStore.CachePutTransaction(tx, source)
 	}
 
 	err = tx.Validate(node.persistStore, clock.NowUnixNano(), false)
 	if err != nil {
 		return "", err
 	}
-	err = node.persistStore.CachePutTransaction(tx)
+	err = node.replaceCacheConflicts(tx)
+	if err != nil {
+		return "", err
+	}
+	err = node.persistStore.CachePutTransaction(tx, source)
 	if err != nil {
 		return "", err
 	}
@@ -46,14 +53,40 @@ func (node *Node) QueueTransaction(tx *common.VersionedTransaction) (string, err
 	return tx.PayloadHash().String(), err
 }
 
+// replaceCacheConflicts removes the queued transactions spending any input of
+// the transaction, only if the transaction could replace all of them
+func (node *Node) replaceCacheConflicts(tx *common.VersionedTransaction) error {
+	conflicts, err := node.persistStore.CacheReadConflicts(tx)
+	if err != nil {
+		return err
+	}
+	var replaced []crypto.Hash
+	for _, c := range conflicts {
+		if !c.Queued {
+			continue
+		}
+		old := c.Transaction.PayloadHash()
+		if !cacheReplaces(tx, c) {
+			return fmt.Errorf("input conflicts with cached transaction %s", old)
+		}
+		replaced = append(replaced, old)
+	}
+	if len(replaced) == 0 {
+		return nil
+	}
+	kernelLogger.Verbose("QueueTransaction replaces", "transaction", tx.PayloadHash(), "replaced", replaced)
+	return node.persistStore.CacheRemoveTransactions(replaced)
+}
+
 func (node *Node) loopCacheQueue() {
 	defer close(node.cqc)
 
+	policy := cachePolicy(node.custom.Node.CachePriority)
 	for !node.waitOrDone(time.Duration(config.SnapshotRoundGap)) {
 		caches, finals, _ := node.QueueState()
-		if caches > 1000 || finals > 500 {
-			logger.Printf("LoopCacheQueue QueueState too big %d %d\n", caches, finals)
-			continue
+		congested := caches > 1000 || finals > 500
+		if congested {
+			kernelLogger.Info("LoopCacheQueue QueueState too big", "caches", caches, "finals", finals)
 		}
 
 		allNodes := node.ListWorkingAcceptedNodes(clock.NowUnixNano())
@@ -61,16 +94,17 @@ func (node *Node) loopCacheQueue() {
 			continue
 		}
 
-		txs, err := node.persistStore.CacheRetrieveTransactions(100)
+		entries, err := node.selectCacheTransactions(policy, congested)
 		if err != nil {
-			logger.Printf("LoopCacheQueue CacheRetrieveTransactions ERROR %s\n", err)
+			kernelLogger.Info("LoopCacheQueue CacheSelectTransactions", "error", err)
 			continue
 		}
 
 		var stale []crypto.Hash
 		filter := make(map[crypto.Hash]bool)
 		leadingNodes, leadingFilter := node.filterLeadingNodes(allNodes)
-		for _, tx := range txs {
+		for _, e := range entries {
+			tx := e.Transaction
 			hash := tx.PayloadHash()
 			if filter[hash] {
 				continue
@@ -78,7 +112,7 @@ func (node *Node) loopCacheQueue() {
 			filter[hash] = true
 			_, finalized, err := node.persistStore.ReadTransaction(hash)
 			if err != nil {
-				logger.Printf("LoopCacheQueue ReadTransaction ERROR %s %s\n", hash, err)
+				kernelLogger.Info("LoopCacheQueue ReadTransaction", "transaction", hash, "error", err)
 				continue
 			}
 			if len(finalized) > 0 {
@@ -88,7 +122,7 @@ func (node *Node) loopCacheQueue() {
 			now := clock.Now()
 			err = tx.Validate(node.persistStore, uint64(now.UnixNano()), false)
 			if err != nil {
-				logger.Debugf("LoopCacheQueue Validate ERROR %s %s\n", hash, err)
+				kernelLogger.Debug("LoopCacheQueue Validate", "transaction", hash, "error", err)
 				// FIXME not mark invalid tx as stale is to ensure final graph sync
 				// but we need some way to mitigate cache transaction DoS attack from nodes
 				continue
@@ -106,15 +140,28 @@ func (node *Node) loopCacheQueue() {
 		}
 		err = node.persistStore.CacheRemoveTransactions(stale)
 		if err != nil {
-			logger.Printf("LoopCacheQueue CacheRemoveTransactions ERROR %s\n", err)
+			kernelLogger.Info("LoopCacheQueue CacheRemoveTransactions", "stale", len(stale), "error", err)
 		}
 	}
 }
 
+// selectCacheTransactions sends only the kernel operations when the queue is
+// congested, the other transactions are skipped in the storage scan and stay
+// queued, so the kernel operations behind them are not starved
+func (node *Node) selectCacheTransactions(policy cachePolicy, congested bool) ([]*storage.CacheTransaction, error) {
+	var filter func(*storage.CacheTransaction) bool
+	if congested {
+		filter = func(e *storage.CacheTransaction) bool {
+			return cacheClass(e.Transaction) <= 1
+		}
+	}
+	return node.persistStore.CacheSelectTransactions(CacheQueueWindow, CacheQueueLimit, filter, policy.order)
+}
+
 func (node *Node) sendTransactionToNode(hash, nbor crypto.Hash) {
 	if nbor != node.IdForNetwork {
 		err := node.SendTransactionToPeer(nbor, hash)
-		logger.Debugf("queue.SendTransactionToPeer(%s, %s) => %v", hash, nbor, err)
+		kernelLogger.Debug("queue.SendTransactionToPeer", "transaction", hash, "peer", nbor, "error", err)
 	} else {
 		s := &common.Snapshot{
 			Version: common.SnapshotVersionCommonEncoding,
@@ -122,7 +169,7 @@ func (node *Node) sendTransactionToNode(hash, nbor crypto.Hash) {
 		}
 		s.AddSoleTransaction(hash)
 		err := node.chain.AppendSelfEmpty(s)
-		logger.Debugf("queue.AppendSelfEmpty(%v) => %v", s, err)
+		kernelLogger.Debug("queue.AppendSelfEmpty", "transaction", hash, "error", err)
 	}
 }
 
@@ -162,7 +209,8 @@ func (node *Node) findRandomHeadNodeWithPossibleTail(all, leading []*CNode, filt
 
 	idx = new(big.Int).Mod(ib, big.NewInt(int64(len(leading)))).Int64()
 	lid := leading[idx].IdForNetwork
-	logger.Debugf("findRandomHeadNodeWithPossibleTail(%s, %d, %d) => %s %s", hash, len(all), len(leading), id, lid)
+	kernelLogger.Debug("findRandomHeadNodeWithPossibleTail", "transaction", hash,
+		"all", len(all), "leading", len(leading), "head", id, "tail", lid)
 	return []crypto.Hash{id, lid}
 }
 
@@ -201,3 +249,78 @@ func (chain *Chain) clearAndQueueSnapshotOrPanic(s *common.Snapshot) error {
 	ns.AddSoleTransaction(s.SoleTransaction())
 	return chain.AppendSelfEmpty(ns)
 }
+
+// DiagnoseCacheTransaction explains why a cached transaction is not finalized,
+// the inputs locked by other transactions are reported before the failures
+// of the validation against the current state
+func (node *Node) DiagnoseCacheTransaction(hash crypto.Hash) (*storage.CacheTransaction, []string, error) {
+	entry, err := node.persistStore.CacheGetTransactionEntry(hash)
+	if err != nil || entry == nil {
+		return nil, nil, err
+	}
+	_, finalized, err := node.persistStore.ReadTransaction(hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(finalized) > 0 {
+		return entry, []string{fmt.Sprintf("finalized by snapshot %s", finalized)}, nil
+	}
+
+	reasons := make([]string, 0)
+	if !entry.Queued {
+		reasons = append(reasons, "retrieved from the queue but not finalized")
+	}
+	for _, in := range entry.Transaction.Inputs {
+		lock, err := node.readInputLock(in)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !lock.HasValue() || lock == hash {
+			continue
+		}
+		state, err := node.readLockState(lock)
+		if err != nil {
+			return nil, nil, err
+		}
+		reasons = append(reasons, fmt.Sprintf("input %s:%d locked by %s transaction %s", in.Hash, in.Index, state, lock))
+	}
+
+	store := &simulationStore{Store: node.persistStore}
+	report := entry.Transaction.Simulate(store, clock.NowUnixNano())
+	for _, f := range report.Failures {
+		reasons = append(reasons, fmt.Sprintf("%s: %s", f.Rule, f.Error))
+	}
+	return entry, reasons, nil
+}
+
+func (node *Node) readInputLock(in *common.Input) (crypto.Hash, error) {
+	switch {
+	case in.Deposit != nil:
+		return node.persistStore.ReadDepositLock(in.Deposit)
+	case in.Mint != nil || len(in.Genesis) > 0:
+		return crypto.Hash{}, nil
+	}
+	utxo, err := node.persistStore.ReadUTXOLock(in.Hash, in.Index)
+	if err != nil || utxo == nil {
+		return crypto.Hash{}, err
+	}
+	return utxo.LockHash, nil
+}
+
+func (node *Node) readLockState(lock crypto.Hash) (string, error) {
+	_, finalized, err := node.persistStore.ReadTransaction(lock)
+	if err != nil {
+		return "", err
+	}
+	if len(finalized) > 0 {
+		return "finalized", nil
+	}
+	cached, err := node.persistStore.CacheGetTransaction(lock)
+	if err != nil {
+		return "", err
+	}
+	if cached != nil {
+		return "cached", nil
+	}
+	return "pending", nil
+}
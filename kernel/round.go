@@ -9,7 +9,6 @@ import (
 	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/kernel/internal/clock"
-	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/mixin/storage"
 )
 
@@ -41,7 +40,8 @@ func (node *Node) LoadAllChainsAndGraphTimestamp(store storage.Store, networkId
 			node.GraphTimestamp = t
 		}
 	}
-	logger.Printf("node.LoadAllChainsAndGraphTimestamp(%s) => %d %d", networkId, len(nodes), node.GraphTimestamp)
+	kernelLogger.Info("node.LoadAllChainsAndGraphTimestamp", "network", networkId,
+		"nodes", len(nodes), "timestamp", node.GraphTimestamp)
 
 	node.chains.RLock()
 	for _, chain := range node.chains.m {
@@ -9,7 +9,6 @@ import (
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
-	"github.com/MixinNetwork/mixin/logger"
 	"github.com/dgraph-io/badger/v4"
 )
 
@@ -92,43 +91,43 @@ func (node *Node) validateKernelSnapshot(s *common.Snapshot, tx *common.Versione
 		}
 		err := node.validateMintSnapshot(s, tx)
 		if err != nil {
-			logger.Printf("validateMintSnapshot ERROR %v %s %s\n",
-				s, hex.EncodeToString(tx.PayloadMarshal()), err.Error())
+			kernelLogger.Info("validateMintSnapshot", "snapshot", s.Hash, "node", s.NodeId,
+				"raw", hex.EncodeToString(tx.PayloadMarshal()), "error", err)
 			return err
 		}
 	case common.TransactionTypeNodePledge:
 		err := node.validateNodePledgeSnapshot(s, tx, finalized)
 		if err != nil {
-			logger.Printf("validateNodePledgeSnapshot ERROR %v %s %s\n",
-				s, hex.EncodeToString(tx.PayloadMarshal()), err.Error())
+			kernelLogger.Info("validateNodePledgeSnapshot", "snapshot", s.Hash, "node", s.NodeId,
+				"raw", hex.EncodeToString(tx.PayloadMarshal()), "error", err)
 			return err
 		}
 	case common.TransactionTypeNodeCancel:
 		err := node.validateNodeCancelSnapshot(s, tx, finalized)
 		if err != nil {
-			logger.Printf("validateNodeCancelSnapshot ERROR %v %s %s\n",
-				s, hex.EncodeToString(tx.PayloadMarshal()), err.Error())
+			kernelLogger.Info("validateNodeCancelSnapshot", "snapshot", s.Hash, "node", s.NodeId,
+				"raw", hex.EncodeToString(tx.PayloadMarshal()), "error", err)
 			return err
 		}
 	case common.TransactionTypeNodeAccept:
 		err := node.validateNodeAcceptSnapshot(s, tx, finalized)
 		if err != nil {
-			logger.Printf("validateNodeAcceptSnapshot ERROR %v %s %s\n",
-				s, hex.EncodeToString(tx.PayloadMarshal()), err.Error())
+			kernelLogger.Info("validateNodeAcceptSnapshot", "snapshot", s.Hash, "node", s.NodeId,
+				"raw", hex.EncodeToString(tx.PayloadMarshal()), "error", err)
 			return err
 		}
 	case common.TransactionTypeNodeRemove:
 		err := node.validateNodeRemoveSnapshot(s, tx, finalized)
 		if err != nil {
-			logger.Printf("validateNodeRemoveSnapshot ERROR %v %s %s\n",
-				s, hex.EncodeToString(tx.PayloadMarshal()), err.Error())
+			kernelLogger.Info("validateNodeRemoveSnapshot", "snapshot", s.Hash, "node", s.NodeId,
+				"raw", hex.EncodeToString(tx.PayloadMarshal()), "error", err)
 			return err
 		}
 	case common.TransactionTypeCustodianUpdateNodes:
 		err := node.validateCustodianUpdateNodes(s, tx, finalized)
 		if err != nil {
-			logger.Printf("validateCustodianUpdateNodes ERROR %v %s %s\n",
-				s, hex.EncodeToString(tx.PayloadMarshal()), err.Error())
+			kernelLogger.Info("validateCustodianUpdateNodes", "snapshot", s.Hash, "node", s.NodeId,
+				"raw", hex.EncodeToString(tx.PayloadMarshal()), "error", err)
 			return err
 		}
 	case common.TransactionTypeCustodianSlashNodes:
@@ -0,0 +1,72 @@
+package kernel
+
+import (
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/kernel/internal/clock"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+// simulationStore reads the locks without writing them, and fails if another
+// transaction holds them, the UTXO locks are checked by the validation itself
+type simulationStore struct {
+	storage.Store
+}
+
+func (s *simulationStore) LockGhostKeys(keys []*crypto.Key, tx crypto.Hash, fork bool) error {
+	filter := make(map[crypto.Key]bool)
+	for _, ghost := range keys {
+		if filter[*ghost] {
+			return fmt.Errorf("duplicated ghost key %s", ghost.String())
+		}
+		filter[*ghost] = true
+		by, err := s.ReadGhostKeyLock(*ghost)
+		if err != nil {
+			return err
+		}
+		if by != nil && *by != tx {
+			return fmt.Errorf("ghost key %s locked for transaction %s", ghost.String(), by.String())
+		}
+	}
+	return nil
+}
+
+func (s *simulationStore) LockUTXOs(inputs []*common.Input, tx crypto.Hash, fork bool) error {
+	return nil
+}
+
+func (s *simulationStore) LockDepositInput(deposit *common.DepositData, tx crypto.Hash, fork bool) error {
+	by, err := s.ReadDepositLock(deposit)
+	if err != nil {
+		return err
+	}
+	if by.HasValue() && by != tx {
+		return fmt.Errorf("deposit locked for transaction %s", by.String())
+	}
+	return nil
+}
+
+func (s *simulationStore) LockMintInput(mint *common.MintData, tx crypto.Hash, fork bool) error {
+	dist, err := s.ReadMintLock(mint)
+	if err != nil || dist == nil {
+		return err
+	}
+	if dist.Transaction != tx || dist.Amount.Cmp(mint.Amount) != 0 {
+		return fmt.Errorf("mint locked for transaction %s amount %s", dist.Transaction.String(), dist.Amount.String())
+	}
+	return nil
+}
+
+// SimulateTransaction validates the transaction against the current state
+// without locking or queuing anything, the finalized snapshot is returned if
+// the transaction has been finalized already
+func (node *Node) SimulateTransaction(tx *common.VersionedTransaction) (*common.SimulationReport, string, error) {
+	_, finalized, err := node.persistStore.ReadTransaction(tx.PayloadHash())
+	if err != nil {
+		return nil, "", err
+	}
+	store := &simulationStore{Store: node.persistStore}
+	return tx.Simulate(store, clock.NowUnixNano()), finalized, nil
+}
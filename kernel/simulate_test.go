@@ -0,0 +1,55 @@
+package kernel
+
+import (
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulationStoreLocks(t *testing.T) {
+	require := require.New(t)
+
+	node := setupTestNode(require, t.TempDir())
+	store := &simulationStore{Store: node.persistStore}
+	locked := crypto.Blake3Hash([]byte("locked"))
+	other := crypto.Blake3Hash([]byte("other"))
+
+	deposit := &common.DepositData{
+		Chain:       crypto.Blake3Hash([]byte("chain")),
+		AssetKey:    "0xa974c709cfb4566686553a20790685a47aceaa33",
+		Transaction: "0xMIXINTODAMOONTRANSACTION",
+		Index:       0,
+		Amount:      common.NewInteger(10),
+	}
+	err := store.LockDepositInput(deposit, other, false)
+	require.Nil(err)
+	err = node.persistStore.LockDepositInput(deposit, locked, false)
+	require.Nil(err)
+	err = store.LockDepositInput(deposit, locked, false)
+	require.Nil(err)
+	err = store.LockDepositInput(deposit, other, false)
+	require.ErrorContains(err, "deposit locked for transaction "+locked.String())
+	by, err := node.persistStore.ReadDepositLock(deposit)
+	require.Nil(err)
+	require.Equal(locked, by)
+
+	mint := &common.MintData{
+		Group:  "UNIVERSAL",
+		Batch:  1000000,
+		Amount: common.NewInteger(100),
+	}
+	err = store.LockMintInput(mint, other, false)
+	require.Nil(err)
+	err = node.persistStore.LockMintInput(mint, locked, false)
+	require.Nil(err)
+	err = store.LockMintInput(mint, locked, false)
+	require.Nil(err)
+	err = store.LockMintInput(mint, other, false)
+	require.ErrorContains(err, "mint locked for transaction "+locked.String())
+	err = store.LockMintInput(&common.MintData{Group: mint.Group, Batch: mint.Batch, Amount: common.NewInteger(1)}, locked, false)
+	require.ErrorContains(err, "mint locked")
+	err = store.LockMintInput(&common.MintData{Group: mint.Group, Batch: mint.Batch + 1, Amount: mint.Amount}, other, false)
+	require.Nil(err)
+}
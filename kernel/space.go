@@ -6,23 +6,23 @@ import (
 
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/config"
-	"github.com/MixinNetwork/mixin/logger"
 )
 
 func (chain *Chain) AggregateRoundSpace() {
-	logger.Printf("AggregateRoundSpace(%s)\n", chain.ChainId)
+	log := kernelLogger.With("chain", chain.ChainId)
+	log.Info("AggregateRoundSpace")
 	defer close(chain.slc)
 
 	batch, round, err := chain.persistStore.ReadRoundSpaceCheckpoint(chain.ChainId)
 	if err != nil {
 		panic(err)
 	}
-	logger.Printf("AggregateRoundSpace(%s) begin with %d:%d\n", chain.ChainId, batch, round)
+	log.Info("AggregateRoundSpace begin", "batch", batch, "round", round)
 
 	wait := time.Duration(chain.node.custom.Node.KernelOprationPeriod/2) * time.Second
 	for chain.running {
 		if cs := chain.State; cs == nil {
-			logger.Printf("AggregateRoundSpace(%s) no state yet\n", chain.ChainId)
+			log.Info("AggregateRoundSpace no state yet")
 			chain.waitOrDone(wait)
 			continue
 		}
@@ -37,14 +37,12 @@ func (chain *Chain) AggregateRoundSpace() {
 
 		nextTime, err := chain.readFinalRoundTimestamp(round + 1)
 		if err != nil {
-			logger.Verbosef("AggregateRoundSpace(%s) ERROR readFinalRoundTimestamp %d %v\n",
-				chain.ChainId, round+1, err)
+			log.Verbose("AggregateRoundSpace readFinalRoundTimestamp", "round", round+1, "error", err)
 			continue
 		}
 		checkTime, err := chain.readFinalRoundTimestamp(round)
 		if err != nil {
-			logger.Verbosef("AggregateRoundSpace(%s) ERROR readFinalRoundTimestamp %d %v\n",
-				chain.ChainId, round, err)
+			log.Verbose("AggregateRoundSpace readFinalRoundTimestamp", "round", round, "error", err)
 			continue
 		}
 
@@ -61,14 +59,14 @@ func (chain *Chain) AggregateRoundSpace() {
 		}
 
 		if space.Duration > uint64(config.CheckpointDuration) {
-			logger.Printf("AggregateRoundSpace(%s) => large gap %d:%d %d\n", chain.ChainId, batch, round, space.Duration)
+			log.Info("AggregateRoundSpace large gap", "batch", batch, "round", round, "duration", space.Duration)
 		} else {
 			space.Duration = 0
 		}
 
 		err = chain.persistStore.WriteRoundSpaceAndState(space)
 		if err != nil {
-			logger.Verbosef("AggregateRoundSpace(%s) ERROR WriteRoundSpaceAndState %d %v\n", chain.ChainId, round, err)
+			log.Verbose("AggregateRoundSpace WriteRoundSpaceAndState", "round", round, "error", err)
 			continue
 		}
 		round = round + 1
@@ -78,7 +76,7 @@ func (chain *Chain) AggregateRoundSpace() {
 	if err != nil {
 		panic(err)
 	}
-	logger.Printf("AggregateRoundSpace(%s) end with %d:%d\n", chain.ChainId, batch, round)
+	log.Info("AggregateRoundSpace end", "batch", batch, "round", round)
 }
 
 func (chain *Chain) readFinalRoundTimestamp(round uint64) (uint64, error) {
@@ -0,0 +1,191 @@
+package kernel
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/kernel/internal/clock"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/dgraph-io/ristretto/v2"
+)
+
+// VerifyImportedState loads the consensus nodes and chains from an imported
+// state without booting them, then checks the snapshot at the topology point
+// and the final round snapshots of all nodes are finalized by the cosi
+// signatures of the consensus nodes at that time. The consensus nodes come
+// from the state itself, so the topology point must match the trusted anchor
+// snapshot obtained out of band. Each UTXO must match the output of its
+// transaction finalized by a valid snapshot, or be spent by a finalized
+// transaction if its transaction is pruned, and all of them must match the
+// header UTXOs hash.
+func VerifyImportedState(custom *config.Custom, store *storage.BadgerStore, cache *ristretto.Cache[[]byte, any], gns *common.Genesis, header *storage.StateHeader, anchor crypto.Hash) error {
+	point := header.Snapshot
+	if !anchor.HasValue() {
+		return fmt.Errorf("state anchor snapshot not specified")
+	}
+	if point != anchor {
+		return fmt.Errorf("state snapshot %s not match the anchor %s", point, anchor)
+	}
+	node := &Node{
+		chains:          &chainsMap{m: make(map[crypto.Hash]*Chain)},
+		genesisNodesMap: make(map[crypto.Hash]bool),
+		persistStore:    store,
+		cacheStore:      cache,
+		custom:          custom,
+		events:          NewEventBus(),
+	}
+	node.loadNodeConfig()
+
+	_, snapshots, _, err := gns.BuildSnapshots()
+	if err != nil {
+		return err
+	}
+	loaded, err := store.CheckGenesisLoad(snapshots)
+	if err != nil {
+		return err
+	}
+	if !loaded {
+		return fmt.Errorf("genesis not found in the state")
+	}
+	genesis := make(map[crypto.Hash]bool)
+	for _, s := range snapshots {
+		genesis[s.PayloadHash()] = true
+	}
+	err = node.LoadGenesis(gns)
+	if err != nil {
+		return err
+	}
+	err = node.LoadConsensusNodes()
+	if err != nil {
+		return err
+	}
+
+	topo, err := store.ReadSnapshot(point)
+	if err != nil {
+		return err
+	}
+	if topo == nil || topo.PayloadHash() != point {
+		return fmt.Errorf("state snapshot %s not found", point)
+	}
+	if last, _ := store.LastSnapshot(); last.PayloadHash() != point {
+		return fmt.Errorf("state snapshot %s not the last %s", point, last.PayloadHash())
+	}
+	verify := []*common.Snapshot{topo.Snapshot}
+	for _, cn := range node.NodesListWithoutState(clock.NowUnixNano(), false) {
+		chain := node.getOrCreateChain(cn.IdForNetwork)
+		if chain.State == nil {
+			continue
+		}
+		final := chain.State.FinalRound
+		topos, err := store.ReadSnapshotsForNodeRound(final.NodeId, final.Number)
+		if err != nil {
+			return err
+		}
+		for _, t := range topos {
+			verify = append(verify, t.Snapshot)
+		}
+	}
+
+	for _, s := range verify {
+		err = node.verifyStateSnapshot(s, genesis)
+		if err != nil {
+			return err
+		}
+	}
+
+	finalized := make(map[crypto.Hash]bool)
+	utxos, err := store.ReadStateUTXOs(func(utxo *common.UTXOWithLock) error {
+		return node.verifyStateUTXO(utxo, genesis, finalized)
+	})
+	if err != nil {
+		return err
+	}
+	if utxos != header.UTXOs {
+		return fmt.Errorf("state utxos hash %s not match %s", utxos, header.UTXOs)
+	}
+	return nil
+}
+
+func (node *Node) verifyStateSnapshot(s *common.Snapshot, genesis map[crypto.Hash]bool) error {
+	s.Hash = s.PayloadHash()
+	if genesis[s.Hash] {
+		return nil
+	}
+	chain := node.getOrCreateChain(s.NodeId)
+	_, finalized := chain.verifyFinalization(s)
+	if !finalized {
+		return fmt.Errorf("state snapshot %s not finalized", s.Hash)
+	}
+	return nil
+}
+
+func (node *Node) verifyStateUTXO(utxo *common.UTXOWithLock, genesis, finalized map[crypto.Hash]bool) error {
+	tx, snap, err := node.persistStore.ReadTransaction(utxo.Hash)
+	if err != nil {
+		return err
+	}
+	if snap == "" {
+		return fmt.Errorf("state utxo %s:%d without transaction", utxo.Hash, utxo.Index)
+	}
+	if tx == nil {
+		return node.verifyStatePrunedUTXO(utxo)
+	}
+
+	var expected *common.UTXOWithLock
+	for _, out := range tx.UnspentOutputs() {
+		if out.Index == utxo.Index {
+			expected = out
+		}
+	}
+	if expected == nil {
+		return fmt.Errorf("state utxo %s:%d not found", utxo.Hash, utxo.Index)
+	}
+	expected.LockHash = utxo.LockHash
+	if !bytes.Equal(expected.Marshal(), utxo.Marshal()) {
+		return fmt.Errorf("state utxo %s:%d not match", utxo.Hash, utxo.Index)
+	}
+
+	hash, err := crypto.HashFromString(snap)
+	if err != nil {
+		return err
+	}
+	if finalized[hash] {
+		return nil
+	}
+	s, err := node.persistStore.ReadSnapshot(hash)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		return fmt.Errorf("state utxo %s:%d snapshot %s not found", utxo.Hash, utxo.Index, hash)
+	}
+	if s.SoleTransaction() != utxo.Hash {
+		return fmt.Errorf("state utxo %s:%d snapshot %s not match", utxo.Hash, utxo.Index, hash)
+	}
+	err = node.verifyStateSnapshot(s.Snapshot, genesis)
+	if err != nil {
+		return err
+	}
+	finalized[hash] = true
+	return nil
+}
+
+// verifyStatePrunedUTXO accepts the UTXO of a pruned transaction only if the
+// transaction finalization is kept and the UTXO is spent by a transaction
+// finalized in the state, because the pruning never removes unspent outputs
+func (node *Node) verifyStatePrunedUTXO(utxo *common.UTXOWithLock) error {
+	if !utxo.LockHash.HasValue() {
+		return fmt.Errorf("state utxo %s:%d pruned but unspent", utxo.Hash, utxo.Index)
+	}
+	_, snap, err := node.persistStore.ReadTransaction(utxo.LockHash)
+	if err != nil {
+		return err
+	}
+	if snap == "" {
+		return fmt.Errorf("state utxo %s:%d pruned but lock %s not finalized", utxo.Hash, utxo.Index, utxo.LockHash)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package kernel
+
+import (
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyImportedState(t *testing.T) {
+	require := require.New(t)
+
+	root := t.TempDir()
+	node := setupTestNodeWithStore(require, root, func(custom *config.Custom) (storage.Store, error) {
+		return storage.NewBadgerStore(custom, t.TempDir())
+	})
+	store := node.persistStore.(*storage.BadgerStore)
+
+	file := t.TempDir() + "/state"
+	header, _, err := store.ExportState(file, node.networkId, 1000)
+	require.Nil(err)
+
+	gns, err := common.ReadGenesis(root + "/genesis.json")
+	require.Nil(err)
+	imported, err := storage.NewBadgerStore(node.custom, t.TempDir())
+	require.Nil(err)
+	defer imported.Close()
+	staging := t.TempDir() + "/staging"
+	_, _, err = imported.ImportState(file, node.networkId, staging, func(stage *storage.BadgerStore, h *storage.StateHeader) error {
+		forged := *h
+		forged.UTXOs = crypto.Blake3Hash(h.UTXOs[:])
+		err := VerifyImportedState(node.custom, stage, node.cacheStore, gns, &forged, crypto.Hash{})
+		require.ErrorContains(err, "anchor snapshot not specified")
+		err = VerifyImportedState(node.custom, stage, node.cacheStore, gns, &forged, crypto.Blake3Hash(h.Snapshot[:]))
+		require.ErrorContains(err, "not match the anchor")
+		err = VerifyImportedState(node.custom, stage, node.cacheStore, gns, &forged, header.Snapshot)
+		require.ErrorContains(err, "state utxos hash")
+		return err
+	})
+	require.ErrorContains(err, "state utxos hash")
+	snaps, err := imported.ReadSnapshotsSinceTopology(0, 10)
+	require.Nil(err)
+	require.Len(snaps, 0)
+	_, _, err = imported.ImportState(file, node.networkId, staging, func(stage *storage.BadgerStore, h *storage.StateHeader) error {
+		return VerifyImportedState(node.custom, stage, node.cacheStore, gns, h, header.Snapshot)
+	})
+	require.Nil(err)
+	last, _ := imported.LastSnapshot()
+	require.Equal(header.Snapshot, last.PayloadHash())
+
+	empty, err := storage.NewBadgerStore(node.custom, t.TempDir())
+	require.Nil(err)
+	defer empty.Close()
+	err = VerifyImportedState(node.custom, empty, node.cacheStore, gns, header, header.Snapshot)
+	require.ErrorContains(err, "genesis not found")
+}
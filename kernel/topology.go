@@ -8,7 +8,6 @@ import (
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/kernel/internal/clock"
-	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/mixin/storage"
 )
 
@@ -51,7 +50,7 @@ func (node *Node) WitnessSnapshot(s *common.SnapshotWithTopologicalOrder) *Snaps
 }
 
 func (node *Node) TopoWrite(s *common.Snapshot, signers []crypto.Hash) *common.SnapshotWithTopologicalOrder {
-	logger.Debugf("node.TopoWrite(%v)\n", s)
+	kernelLogger.Debug("node.TopoWrite", "chain", s.NodeId, "snapshot", s.Hash, "round", s.RoundNumber)
 	node.TopoCounter.Lock()
 	defer node.TopoCounter.Unlock()
 
@@ -75,6 +74,7 @@ func (node *Node) TopoWrite(s *common.Snapshot, signers []crypto.Hash) *common.S
 	if err != nil {
 		panic(err)
 	}
+	node.events.publish(&Event{Type: EventTypeSnapshot, Snapshot: topo})
 	return topo
 }
 
@@ -0,0 +1,101 @@
+package kernel
+
+import (
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+)
+
+const walletScanBatch = 100
+
+// WalletScanLoop walks all finalized transactions in topological order for
+// each registered wallet key, and records the owned outputs and spends
+func (node *Node) WalletScanLoop() {
+	defer close(node.wsc)
+
+	if !node.custom.Wallet.Scan || len(node.custom.Wallet.Keys) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-node.done:
+			return
+		case <-ticker.C:
+		}
+		for _, key := range node.custom.Wallet.Keys {
+			for node.scanWalletBatch(key) == walletScanBatch {
+				select {
+				case <-node.done:
+					return
+				default:
+				}
+			}
+		}
+	}
+}
+
+func (node *Node) scanWalletBatch(key *config.WalletKey) int {
+	addr := common.Address{
+		PrivateViewKey: key.PrivateView,
+		PublicViewKey:  key.PrivateView.Public(),
+		PublicSpendKey: key.PublicSpend,
+	}
+	wallet := addr.Hash()
+
+	offset, err := node.persistStore.ReadWalletCheckpoint(wallet)
+	if err != nil {
+		panic(err)
+	}
+	snapshots, transactions, err := node.persistStore.ReadSnapshotWithTransactionsSinceTopology(offset, walletScanBatch)
+	if err != nil {
+		panic(err)
+	}
+	if len(snapshots) == 0 {
+		return 0
+	}
+
+	var outputs []*common.WalletOutput
+	var spends []*common.WalletSpend
+	for i, s := range snapshots {
+		tx := transactions[i]
+		hash := tx.PayloadHash()
+		for _, index := range tx.ViewOwnedOutputs(&key.PrivateView, &key.PublicSpend) {
+			out := tx.Outputs[index]
+			outputs = append(outputs, &common.WalletOutput{
+				Asset:     tx.Asset,
+				Hash:      hash,
+				Index:     index,
+				Amount:    out.Amount,
+				Keys:      out.Keys,
+				Mask:      out.Mask,
+				Script:    out.Script,
+				Snapshot:  s.PayloadHash(),
+				Timestamp: s.Timestamp,
+			})
+		}
+		for _, in := range tx.Inputs {
+			if !in.Hash.HasValue() {
+				continue
+			}
+			spends = append(spends, &common.WalletSpend{
+				Hash:        in.Hash,
+				Index:       in.Index,
+				Transaction: hash,
+			})
+		}
+	}
+
+	checkpoint := snapshots[len(snapshots)-1].TopologicalOrder + 1
+	err = node.persistStore.WriteWalletScan(wallet, checkpoint, outputs, spends)
+	if err != nil {
+		panic(err)
+	}
+	kernelLogger.Debug("scanWalletBatch", "wallet", addr, "checkpoint", checkpoint,
+		"outputs", len(outputs), "spends", len(spends))
+	return len(snapshots)
+}
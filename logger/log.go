@@ -1,9 +1,15 @@
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -13,15 +19,128 @@ const (
 	DEBUG   = 7
 )
 
-// FIXME GLOBAL VARIABLES
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
 
-var (
+// the settings are replaced as a whole on each change, so the hot paths only
+// load the pointer without any lock
+type settings struct {
 	level  int
+	levels map[string]int
 	filter *regexp.Regexp
+	format string
+}
+
+var (
+	mutex   sync.Mutex
+	current atomic.Pointer[settings]
 )
 
+func init() {
+	current.Store(&settings{levels: make(map[string]int), format: FormatText})
+}
+
+func update(fn func(s *settings)) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	old := current.Load()
+	s := &settings{
+		level:  old.level,
+		levels: make(map[string]int, len(old.levels)),
+		filter: old.filter,
+		format: old.format,
+	}
+	for k, v := range old.levels {
+		s.levels[k] = v
+	}
+	fn(s)
+	current.Store(s)
+}
+
 func SetLevel(l int) {
-	level = l
+	update(func(s *settings) { s.level = l })
+}
+
+// SetSubsystemLevel overrides the level of a subsystem and all its children,
+// e.g. kernel applies to kernel.cosi unless kernel.cosi has its own level,
+// a negative level removes the override
+func SetSubsystemLevel(subsystem string, l int) {
+	if subsystem == "" {
+		SetLevel(l)
+		return
+	}
+	update(func(s *settings) {
+		if l < 0 {
+			delete(s.levels, subsystem)
+		} else {
+			s.levels[subsystem] = l
+		}
+	})
+}
+
+// SetSubsystemLevels parses the levels list like kernel.cosi=7,p2p=1
+func SetSubsystemLevels(list string) error {
+	if strings.TrimSpace(list) == "" {
+		return nil
+	}
+	levels := make(map[string]int)
+	for _, item := range strings.Split(list, ",") {
+		parts := strings.Split(strings.TrimSpace(item), "=")
+		if len(parts) != 2 || parts[0] == "" {
+			return fmt.Errorf("invalid subsystem level %s", item)
+		}
+		l, err := ParseLevel(parts[1])
+		if err != nil {
+			return err
+		}
+		levels[parts[0]] = l
+	}
+	for k, l := range levels {
+		SetSubsystemLevel(k, l)
+	}
+	return nil
+}
+
+// Levels returns the default level with the empty subsystem and all overrides
+func Levels() map[string]int {
+	s := current.Load()
+	levels := map[string]int{"": s.level}
+	for k, v := range s.levels {
+		levels[k] = v
+	}
+	return levels
+}
+
+func ParseLevel(v string) (int, error) {
+	switch strings.ToLower(v) {
+	case "error":
+		return ERROR, nil
+	case "info":
+		return INFO, nil
+	case "verbose":
+		return VERBOSE, nil
+	case "debug":
+		return DEBUG, nil
+	}
+	l, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid log level %s", v)
+	}
+	return l, nil
+}
+
+func SetFormat(format string) error {
+	switch format {
+	case "":
+		return nil
+	case FormatText, FormatJSON:
+		update(func(s *settings) { s.format = format })
+		return nil
+	}
+	return fmt.Errorf("invalid log format %s", format)
 }
 
 func SetFilter(pattern string) error {
@@ -33,45 +152,199 @@ func SetFilter(pattern string) error {
 	if err != nil {
 		return err
 	}
-	filter = reg
+	update(func(s *settings) { s.filter = reg })
 	return nil
 }
 
-func Println(v ...any) {
-	if level >= INFO {
-		log.Println(v...)
+func (s *settings) levelFor(subsystem string) int {
+	for subsystem != "" {
+		if l, found := s.levels[subsystem]; found {
+			return l
+		}
+		i := strings.LastIndexByte(subsystem, '.')
+		if i < 0 {
+			break
+		}
+		subsystem = subsystem[:i]
 	}
+	return s.level
 }
 
-func Printf(format string, v ...any) {
-	if level >= INFO {
-		log.Printf(format, v...)
+// Logger writes the messages of a subsystem, with the key and value fields
+// attached to all messages, e.g. the chain id or the peer id
+type Logger struct {
+	subsystem string
+	fields    []any
+}
+
+var std = &Logger{}
+
+func New(subsystem string) *Logger {
+	return &Logger{subsystem: subsystem}
+}
+
+func (l *Logger) With(kv ...any) *Logger {
+	if len(kv)%2 != 0 {
+		panic(fmt.Errorf("logger fields %v", kv))
 	}
+	fields := make([]any, 0, len(l.fields)+len(kv))
+	fields = append(fields, l.fields...)
+	fields = append(fields, kv...)
+	return &Logger{subsystem: l.subsystem, fields: fields}
+}
+
+func (l *Logger) Enabled(lvl int) bool {
+	return current.Load().levelFor(l.subsystem) >= lvl
+}
+
+func (l *Logger) Println(v ...any) {
+	if l.Enabled(INFO) {
+		l.output(INFO, false, strings.TrimSuffix(fmt.Sprintln(v...), "\n"), nil)
+	}
+}
+
+func (l *Logger) Printf(format string, v ...any) {
+	if l.Enabled(INFO) {
+		l.output(INFO, false, fmt.Sprintf(format, v...), nil)
+	}
+}
+
+func (l *Logger) Errorf(format string, v ...any) {
+	if l.Enabled(ERROR) {
+		l.output(ERROR, false, fmt.Sprintf(format, v...), nil)
+	}
+}
+
+func (l *Logger) Verbosef(format string, v ...any) {
+	if l.Enabled(VERBOSE) {
+		l.output(VERBOSE, true, fmt.Sprintf(format, v...), nil)
+	}
+}
+
+func (l *Logger) Debugf(format string, v ...any) {
+	if l.Enabled(DEBUG) {
+		l.output(DEBUG, true, fmt.Sprintf(format, v...), nil)
+	}
+}
+
+func (l *Logger) Error(msg string, kv ...any) {
+	l.output(ERROR, false, msg, kv)
+}
+
+func (l *Logger) Info(msg string, kv ...any) {
+	l.output(INFO, false, msg, kv)
+}
+
+func (l *Logger) Verbose(msg string, kv ...any) {
+	l.output(VERBOSE, true, msg, kv)
+}
+
+func (l *Logger) Debug(msg string, kv ...any) {
+	l.output(DEBUG, true, msg, kv)
+}
+
+func Println(v ...any) {
+	std.Println(v...)
+}
+
+func Printf(format string, v ...any) {
+	std.Printf(format, v...)
 }
 
 func Verbosef(format string, v ...any) {
-	printfAtLevel(VERBOSE, format, v...)
+	std.Verbosef(format, v...)
 }
 
 func Debugf(format string, v ...any) {
-	printfAtLevel(DEBUG, format, v...)
+	std.Debugf(format, v...)
 }
 
-func printfAtLevel(l int, format string, v ...any) {
-	if level < l {
+func (l *Logger) output(lvl int, filtered bool, msg string, kv []any) {
+	s := current.Load()
+	if s.levelFor(l.subsystem) < lvl {
 		return
 	}
-	out := filterOutput(format, v...)
-	if out == "" {
+	if len(kv)%2 != 0 {
+		kv = append(kv, "MISSING")
+	}
+	fields := append(l.fields[:len(l.fields):len(l.fields)], kv...)
+	var out string
+	switch s.format {
+	case FormatJSON:
+		out = l.formatJSON(lvl, msg, fields)
+	default:
+		out = l.formatText(msg, fields)
+	}
+	if filtered && !s.match(out) {
 		return
 	}
-	log.Print(out)
+	if s.format == FormatJSON {
+		fmt.Fprintln(log.Writer(), out)
+	} else {
+		log.Print(out)
+	}
+}
+
+func (s *settings) match(out string) bool {
+	return s.filter == nil || s.filter.MatchString(out)
+}
+
+func (l *Logger) formatText(msg string, fields []any) string {
+	if l.subsystem == "" && len(fields) == 0 {
+		return msg
+	}
+	var b strings.Builder
+	if l.subsystem != "" {
+		b.WriteString("[" + l.subsystem + "] ")
+	}
+	b.WriteString(strings.TrimRight(msg, "\n"))
+	for i := 0; i < len(fields); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", fields[i], fields[i+1])
+	}
+	return b.String()
+}
+
+func (l *Logger) formatJSON(lvl int, msg string, fields []any) string {
+	entry := map[string]any{
+		"time":  time.Now().UTC().Format(time.RFC3339Nano),
+		"level": levelName(lvl),
+		"msg":   strings.TrimRight(msg, "\n"),
+	}
+	if l.subsystem != "" {
+		entry["subsystem"] = l.subsystem
+	}
+	for i := 0; i < len(fields); i += 2 {
+		entry[fmt.Sprint(fields[i])] = jsonValue(fields[i+1])
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+func jsonValue(v any) any {
+	switch v := v.(type) {
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	case string, bool, int, int64, uint64, uint32, int32, uint16, uint8, float64:
+		return v
+	}
+	return fmt.Sprint(v)
 }
 
-func filterOutput(format string, v ...any) string {
-	out := fmt.Sprintf(format, v...)
-	if filter == nil || filter.MatchString(out) {
-		return out
+func levelName(lvl int) string {
+	switch lvl {
+	case ERROR:
+		return "error"
+	case INFO:
+		return "info"
+	case VERBOSE:
+		return "verbose"
+	case DEBUG:
+		return "debug"
 	}
-	return ""
+	return strconv.Itoa(lvl)
 }
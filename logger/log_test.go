@@ -1,6 +1,11 @@
 package logger
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
 	"testing"
 	"time"
 
@@ -10,42 +15,112 @@ import (
 func TestLogger(t *testing.T) {
 	require := require.New(t)
 
-	out := filterOutput("hello from mixin %d", time.Now().UnixNano())
+	buf := new(bytes.Buffer)
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+	defer update(func(s *settings) {
+		s.level = 0
+		s.filter = nil
+	})
+	SetLevel(VERBOSE)
+	output := func(format string) string {
+		buf.Reset()
+		Verbosef(format, time.Now().UnixNano())
+		return buf.String()
+	}
+
+	out := output("hello from mixin %d")
 	require.Contains(out, "mixin")
 
 	err := SetFilter("bitcoin")
 	require.Nil(err)
-	out = filterOutput("hello from mixin %d", time.Now().UnixNano())
+	out = output("hello from mixin %d")
 	require.NotContains(out, "mixin")
-	out = filterOutput("Bitcoin from mixin %d", time.Now().UnixNano())
+	out = output("Bitcoin from mixin %d")
 	require.NotContains(out, "mixin")
-	out = filterOutput("bitcoin from mixin %d", time.Now().UnixNano())
+	out = output("bitcoin from mixin %d")
 	require.Contains(out, "mixin")
 
 	err = SetFilter("(?i)bitcoin")
 	require.Nil(err)
-	out = filterOutput("hello from mixin %d", time.Now().UnixNano())
+	out = output("hello from mixin %d")
 	require.NotContains(out, "mixin")
-	out = filterOutput("Bitcoin from mixin %d", time.Now().UnixNano())
+	out = output("Bitcoin from mixin %d")
 	require.Contains(out, "mixin")
-	out = filterOutput("bitcoin from mixin %d", time.Now().UnixNano())
+	out = output("bitcoin from mixin %d")
 	require.Contains(out, "mixin")
-	out = filterOutput("ethereum from mixin %d", time.Now().UnixNano())
+	out = output("ethereum from mixin %d")
 	require.NotContains(out, "mixin")
 
 	err = SetFilter("(?i)bitcoin|Mixin")
 	require.Nil(err)
-	out = filterOutput("hello from mixin %d", time.Now().UnixNano())
+	out = output("hello from mixin %d")
 	require.Contains(out, "mixin")
-	out = filterOutput("Bitcoin from mixin %d", time.Now().UnixNano())
+	out = output("Bitcoin from mixin %d")
 	require.Contains(out, "mixin")
-	out = filterOutput("bitcoin from mixin %d", time.Now().UnixNano())
+	out = output("bitcoin from mixin %d")
 	require.Contains(out, "mixin")
-	out = filterOutput("ethereum from mixin %d", time.Now().UnixNano())
+	out = output("ethereum from mixin %d")
 	require.Contains(out, "mixin")
-	out = filterOutput("ethereum or bitcoin %d", time.Now().UnixNano())
+	out = output("ethereum or bitcoin %d")
 	require.NotContains(out, "mixin")
+	require.Contains(out, "bitcoin")
+
+	buf.Reset()
+	Printf("hello from ethereum %d", 1)
+	require.Contains(buf.String(), "hello from ethereum 1")
+}
+
+func TestSubsystemLogger(t *testing.T) {
+	require := require.New(t)
+
+	buf := new(bytes.Buffer)
+	log.SetOutput(buf)
+	log.SetFlags(0)
+	defer log.SetOutput(os.Stderr)
+	defer log.SetFlags(log.LstdFlags)
+	defer update(func(s *settings) {
+		s.level = 0
+		s.levels = make(map[string]int)
+		s.format = FormatText
+	})
 
-	level = 0
-	filter = nil
+	SetLevel(INFO)
+	cosi := New("kernel.cosi").With("chain", "c1")
+	cosi.Debug("announcement", "snapshot", "s1")
+	require.Equal("", buf.String())
+	err := SetSubsystemLevels("kernel=debug,p2p=error")
+	require.Nil(err)
+	cosi.Debug("announcement", "snapshot", "s1")
+	require.Equal("[kernel.cosi] announcement chain=c1 snapshot=s1\n", buf.String())
+	New("p2p").Printf("peer %d", 1)
+	New("rpc").Printf("call %d", 1)
+	require.Equal("[kernel.cosi] announcement chain=c1 snapshot=s1\n[rpc] call 1\n", buf.String())
+
+	SetSubsystemLevel("kernel.cosi", ERROR)
+	require.False(cosi.Enabled(INFO))
+	require.True(New("kernel.mint").Enabled(DEBUG))
+	SetSubsystemLevel("kernel.cosi", -1)
+	require.True(cosi.Enabled(DEBUG))
+	require.Equal(map[string]int{"": INFO, "kernel": DEBUG, "p2p": ERROR}, Levels())
+	err = SetSubsystemLevels("kernel")
+	require.NotNil(err)
+	_, err = ParseLevel("loud")
+	require.NotNil(err)
+
+	buf.Reset()
+	err = SetFormat("xml")
+	require.NotNil(err)
+	err = SetFormat(FormatJSON)
+	require.Nil(err)
+	cosi.Verbose("finalization", "round", 7, "error", errors.New("bad"))
+	var entry map[string]any
+	err = json.Unmarshal(buf.Bytes(), &entry)
+	require.Nil(err)
+	require.Equal("verbose", entry["level"])
+	require.Equal("kernel.cosi", entry["subsystem"])
+	require.Equal("finalization", entry["msg"])
+	require.Equal("c1", entry["chain"])
+	require.Equal(float64(7), entry["round"])
+	require.Equal("bad", entry["error"])
 }
@@ -36,7 +36,7 @@ func main() {
 			Name:    "node",
 			Aliases: []string{"n"},
 			Value:   defaultRPC,
-			Usage:   "the RPC endpoint, and the default value is read from environment variable MIXIN_KERNEL_RPC",
+			Usage:   "the RPC endpoints separated by comma for failover, and the default value is read from environment variable MIXIN_KERNEL_RPC",
 		},
 		&cli.StringFlag{
 			Name:    "dir",
@@ -78,6 +78,15 @@ func main() {
 					Name:  "filter",
 					Usage: "the RE2 regex pattern to filter log",
 				},
+				&cli.StringFlag{
+					Name:  "log-levels",
+					Usage: "the subsystem log levels, e.g. kernel.cosi=debug,p2p=error",
+				},
+				&cli.StringFlag{
+					Name:  "log-format",
+					Value: logger.FormatText,
+					Usage: "the log format text or json",
+				},
 			},
 		},
 		{
@@ -110,6 +119,157 @@ func main() {
 					Name:  "suffix",
 					Usage: "a string suffix the final address should have",
 				},
+				&cli.StringFlag{
+					Name:  "path",
+					Usage: "the derivation `PATH` like m/44'/0'/1, prefix and suffix are ignored",
+				},
+				&cli.StringFlag{
+					Name:  "seed",
+					Usage: "the master seed `HEX` to derive the path instead of a random one",
+				},
+				&cli.StringFlag{
+					Name:  "xpub",
+					Usage: "the extended public key to derive the watch-only address of the path",
+				},
+			},
+		},
+		{
+			Name:   "createmnemonic",
+			Usage:  "Create a mnemonic to backup an address seed or a private key",
+			Action: createMnemonicCmd,
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name:  "words",
+					Value: 24,
+					Usage: "the words count of the mnemonic, 12, 15, 18, 21 or 24",
+				},
+				&cli.StringFlag{
+					Name:  "passphrase",
+					Usage: "the optional passphrase to derive the seed from the mnemonic",
+				},
+				&cli.StringFlag{
+					Name:  "path",
+					Usage: "the derivation `PATH` of the address from the seed",
+				},
+				&cli.BoolFlag{
+					Name:  "public",
+					Usage: "whether mark all my transactions public",
+				},
+				&cli.StringFlag{
+					Name:  "key",
+					Usage: "the private key `HEX` to encode as 24 words instead of a new seed",
+				},
+			},
+		},
+		{
+			Name:   "verifymnemonic",
+			Usage:  "Verify the words and checksum of a mnemonic",
+			Action: verifyMnemonicCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "mnemonic",
+					Usage: "the mnemonic words",
+				},
+				&cli.StringFlag{
+					Name:  "file",
+					Usage: "the file to read the mnemonic words",
+				},
+				&cli.BoolFlag{
+					Name:  "key",
+					Usage: "whether the mnemonic encodes a private key",
+				},
+			},
+		},
+		{
+			Name:   "restoremnemonic",
+			Usage:  "Restore the address or the private key from a mnemonic",
+			Action: restoreMnemonicCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "mnemonic",
+					Usage: "the mnemonic words",
+				},
+				&cli.StringFlag{
+					Name:  "file",
+					Usage: "the file to read the mnemonic words",
+				},
+				&cli.StringFlag{
+					Name:  "passphrase",
+					Usage: "the passphrase used to create the mnemonic",
+				},
+				&cli.StringFlag{
+					Name:  "path",
+					Usage: "the derivation `PATH` of the address from the seed",
+				},
+				&cli.BoolFlag{
+					Name:  "public",
+					Usage: "whether mark all my transactions public",
+				},
+				&cli.BoolFlag{
+					Name:  "key",
+					Usage: "whether the mnemonic encodes a private key",
+				},
+			},
+		},
+		{
+			Name:   "importkeystore",
+			Usage:  "Encrypt a private key to a new keystore file",
+			Action: importKeystoreCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "key",
+					Usage: "the private key `HEX`",
+				},
+				&cli.StringFlag{
+					Name:  "mnemonic-file",
+					Usage: "the file of the 24 words key mnemonic instead of the hex key",
+				},
+				&cli.StringFlag{
+					Name:  "passphrase-file",
+					Usage: "the passphrase file, or read from MIXIN_SIGNER_PASSPHRASE",
+				},
+				&cli.StringFlag{
+					Name:  "output",
+					Usage: "the keystore file to create",
+				},
+			},
+		},
+		{
+			Name:   "exportkeystore",
+			Usage:  "Decrypt the private key from a keystore file",
+			Action: exportKeystoreCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "keystore",
+					Usage: "the keystore file",
+				},
+				&cli.StringFlag{
+					Name:  "passphrase-file",
+					Usage: "the passphrase file, or read from MIXIN_SIGNER_PASSPHRASE",
+				},
+				&cli.BoolFlag{
+					Name:  "mnemonic",
+					Usage: "whether export the key as 24 words mnemonic",
+				},
+			},
+		},
+		{
+			Name:   "rotatekeystore",
+			Usage:  "Encrypt the keystore file with a new passphrase",
+			Action: rotateKeystoreCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "keystore",
+					Usage: "the keystore file",
+				},
+				&cli.StringFlag{
+					Name:  "passphrase-file",
+					Usage: "the current passphrase file, or read from MIXIN_SIGNER_PASSPHRASE",
+				},
+				&cli.StringFlag{
+					Name:  "new-passphrase-file",
+					Usage: "the new passphrase file, or read from MIXIN_SIGNER_NEW_PASSPHRASE",
+				},
 			},
 		},
 		{
@@ -201,6 +361,37 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:   "exportstate",
+			Usage:  "Export the ledger state to a checksummed file for fast bootstrapping",
+			Action: exportStateCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "file",
+					Usage: "the state file path",
+				},
+				&cli.Uint64Flag{
+					Name:  "rounds",
+					Value: 1000,
+					Usage: "the number of latest rounds to keep full history for each node",
+				},
+			},
+		},
+		{
+			Name:   "importstate",
+			Usage:  "Import the ledger state file to an empty data directory and verify it",
+			Action: importStateCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "file",
+					Usage: "the state file path",
+				},
+				&cli.StringFlag{
+					Name:  "snapshot",
+					Usage: "the trusted anchor snapshot hash at the state topology",
+				},
+			},
+		},
 		{
 			Name:   "buildrawtransaction",
 			Usage:  "Build a script raw transaction",
@@ -255,6 +446,81 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:   "createpst",
+			Usage:  "Create a partially signed transaction file from a JSON encoded transaction",
+			Action: createPSTCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "raw",
+					Usage: "the JSON encoded raw transaction",
+				},
+				&cli.StringFlag{
+					Name:  "seed",
+					Usage: "the mask seed to hide the recipient public key",
+				},
+				&cli.StringFlag{
+					Name:  "file",
+					Usage: "the partially signed transaction file path",
+				},
+			},
+		},
+		{
+			Name:   "decodepst",
+			Usage:  "Decode a partially signed transaction file as JSON",
+			Action: decodePSTCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "file",
+					Usage: "the partially signed transaction file path",
+				},
+			},
+		},
+		{
+			Name:   "signpst",
+			Usage:  "Sign a partially signed transaction file offline",
+			Action: signPSTCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "file",
+					Usage: "the partially signed transaction file path",
+				},
+				&cli.StringSliceFlag{
+					Name:  "key",
+					Usage: "the private key to sign the transaction",
+				},
+				&cli.BoolFlag{
+					Name:  "aggregate",
+					Usage: "make the aggregated signature with all the keys for all inputs",
+				},
+			},
+		},
+		{
+			Name:   "combinepst",
+			Usage:  "Combine the signatures of partially signed transaction files",
+			Action: combinePSTCmd,
+			Flags: []cli.Flag{
+				&cli.StringSliceFlag{
+					Name:  "input",
+					Usage: "the partially signed transaction file paths to combine",
+				},
+				&cli.StringFlag{
+					Name:  "file",
+					Usage: "the combined partially signed transaction file path",
+				},
+			},
+		},
+		{
+			Name:   "finalizepst",
+			Usage:  "Finalize a partially signed transaction file as the hex encoded signed raw transaction",
+			Action: finalizePSTCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "file",
+					Usage: "the partially signed transaction file path",
+				},
+			},
+		},
 		{
 			Name:   "sendrawtransaction",
 			Usage:  "Broadcast a hex encoded signed raw transaction",
@@ -266,6 +532,17 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:   "simulatetransaction",
+			Usage:  "Validate a hex encoded signed raw transaction without sending it",
+			Action: simulateTransactionCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "raw",
+					Usage: "the hex encoded signed raw transaction",
+				},
+			},
+		},
 		{
 			Name:   "decoderawtransaction",
 			Usage:  "Decode a raw transaction as JSON",
@@ -515,6 +792,64 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:   "listcachetransactions",
+			Usage:  "List the transactions in cache",
+			Action: listCacheTransactionsCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "offset",
+					Usage: "the transaction hash to list after",
+				},
+				&cli.Uint64Flag{
+					Name:  "count",
+					Value: 100,
+					Usage: "the maximum transactions count",
+				},
+				&cli.StringFlag{
+					Name:  "asset",
+					Usage: "only list the transactions of the asset",
+				},
+				&cli.IntFlag{
+					Name:  "type",
+					Value: -1,
+					Usage: "only list the transactions of the type",
+				},
+				&cli.Uint64Flag{
+					Name:  "age",
+					Usage: "only list the transactions cached for at least the seconds",
+				},
+			},
+		},
+		{
+			Name:   "getcachestats",
+			Usage:  "Get the cache queue stats",
+			Action: getCacheStatsCmd,
+		},
+		{
+			Name:   "diagnosecachetransaction",
+			Usage:  "Explain why the transaction in cache is not finalized",
+			Action: diagnoseCacheTransactionCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "hash",
+					Aliases: []string{"x"},
+					Usage:   "the transaction hash",
+				},
+			},
+		},
+		{
+			Name:   "evictcachetransaction",
+			Usage:  "Remove the transaction from cache, only from the local node",
+			Action: evictCacheTransactionCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "hash",
+					Aliases: []string{"x"},
+					Usage:   "the transaction hash",
+				},
+			},
+		},
 		{
 			Name:   "getdeposittransaction",
 			Usage:  "Get the deposit transaction by external chain transaction",
@@ -587,6 +922,46 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:   "listoutputs",
+			Usage:  "List the outputs owned by a registered wallet",
+			Action: listOutputsCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "address",
+					Aliases: []string{"a"},
+					Usage:   "the registered wallet address",
+				},
+				&cli.Uint64Flag{
+					Name:    "since",
+					Aliases: []string{"s"},
+					Value:   0,
+					Usage:   "the output sequence to begin with",
+				},
+				&cli.Uint64Flag{
+					Name:    "count",
+					Aliases: []string{"c"},
+					Value:   10,
+					Usage:   "the up limit of the returned outputs",
+				},
+			},
+		},
+		{
+			Name:   "getbalance",
+			Usage:  "Get the asset balance of a registered wallet",
+			Action: getBalanceCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:    "address",
+					Aliases: []string{"a"},
+					Usage:   "the registered wallet address",
+				},
+				&cli.StringFlag{
+					Name:  "asset",
+					Usage: "the asset id",
+				},
+			},
+		},
 		{
 			Name:   "listcustodianupdates",
 			Usage:  "List all custodian updates",
@@ -672,6 +1047,27 @@ func main() {
 			Usage:  "Dump the graph head",
 			Action: dumpGraphHeadCmd,
 		},
+		{
+			Name:   "getloglevels",
+			Usage:  "Get the log levels of all subsystems",
+			Action: getLogLevelsCmd,
+		},
+		{
+			Name:   "setloglevel",
+			Usage:  "Change the log level of a subsystem at runtime",
+			Action: setLogLevelCmd,
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:  "subsystem",
+					Usage: "the subsystem e.g. kernel.cosi, p2p, storage or rpc, empty for the default",
+				},
+				&cli.StringFlag{
+					Name:  "level",
+					Value: "info",
+					Usage: "the level error, info, verbose, debug or a number, -1 to remove the override",
+				},
+			},
+		},
 	}
 	err := app.Run(os.Args)
 	if err != nil {
@@ -691,6 +1087,14 @@ func kernelCmd(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	err = logger.SetSubsystemLevels(c.String("log-levels"))
+	if err != nil {
+		return err
+	}
+	err = logger.SetFormat(c.String("log-format"))
+	if err != nil {
+		return err
+	}
 
 	gns, err := common.ReadGenesis(c.String("dir") + "/genesis.json")
 	if err != nil {
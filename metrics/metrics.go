@@ -0,0 +1,242 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// a minimal prometheus text exposition, the counters and histograms are
+// updated by the events in kernel and p2p, while the gauges are read from
+// the node state and written at the scrape time
+
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var DefaultLatencyBuckets = []float64{
+	0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30,
+}
+
+// all families are registered once by the subsystems at init, and a node
+// process exposes them from a single registry
+
+var (
+	mutex    sync.RWMutex
+	families = make(map[string]*family)
+)
+
+type family struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+	mutex   sync.Mutex
+	series  map[string]*series
+}
+
+type series struct {
+	values  []string
+	value   float64
+	count   uint64
+	buckets []uint64
+}
+
+type Counter struct {
+	f *family
+}
+
+type Histogram struct {
+	f *family
+}
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{f: register(name, help, "counter", nil, labels)}
+}
+
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if !sort.Float64sAreSorted(buckets) {
+		panic(fmt.Errorf("histogram %s buckets not sorted", name))
+	}
+	return &Histogram{f: register(name, help, "histogram", buckets, labels)}
+}
+
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		panic(fmt.Errorf("counter %s decreased %f", c.f.name, v))
+	}
+	c.f.mutex.Lock()
+	defer c.f.mutex.Unlock()
+	s := c.f.get(values)
+	s.value += v
+}
+
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.f.mutex.Lock()
+	defer h.f.mutex.Unlock()
+	s := h.f.get(values)
+	s.value += v
+	s.count += 1
+	for i, b := range h.f.buckets {
+		if v <= b {
+			s.buckets[i] += 1
+		}
+	}
+}
+
+func register(name, help, kind string, buckets []float64, labels []string) *family {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if families[name] != nil {
+		panic(fmt.Errorf("metric %s registered", name))
+	}
+	f := &family{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+	families[name] = f
+	return f
+}
+
+func (f *family) get(values []string) *series {
+	if len(values) != len(f.labels) {
+		panic(fmt.Errorf("metric %s labels %v %v", f.name, f.labels, values))
+	}
+	key := strings.Join(values, "\xff")
+	s := f.series[key]
+	if s == nil {
+		s = &series{values: values, buckets: make([]uint64, len(f.buckets))}
+		f.series[key] = s
+	}
+	return s
+}
+
+type Writer struct {
+	buf  *bytes.Buffer
+	seen map[string]bool
+}
+
+// Gauge writes a gauge sample, the labels are name and value pairs
+func (w *Writer) Gauge(name, help string, value float64, labels ...string) {
+	if len(labels)%2 != 0 {
+		panic(fmt.Errorf("gauge %s labels %v", name, labels))
+	}
+	w.header(name, help, "gauge")
+	w.sample(name, labels, value)
+}
+
+func (w *Writer) header(name, help, kind string) {
+	if w.seen[name] {
+		return
+	}
+	w.seen[name] = true
+	fmt.Fprintf(w.buf, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w.buf, "# TYPE %s %s\n", name, kind)
+}
+
+func (w *Writer) sample(name string, labels []string, value float64) {
+	w.buf.WriteString(name)
+	if len(labels) > 0 {
+		w.buf.WriteByte('{')
+		for i := 0; i < len(labels); i += 2 {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			w.buf.WriteString(labels[i])
+			w.buf.WriteString(`="`)
+			w.buf.WriteString(escapeLabel(labels[i+1]))
+			w.buf.WriteByte('"')
+		}
+		w.buf.WriteByte('}')
+	}
+	w.buf.WriteByte(' ')
+	w.buf.WriteString(formatValue(value))
+	w.buf.WriteByte('\n')
+}
+
+func (f *family) write(w *Writer) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if len(f.series) == 0 {
+		return
+	}
+	w.header(f.name, f.help, f.kind)
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := f.series[k]
+		labels := make([]string, 0, len(f.labels)*2+2)
+		for i, l := range f.labels {
+			labels = append(labels, l, s.values[i])
+		}
+		if f.kind != "histogram" {
+			w.sample(f.name, labels, s.value)
+			continue
+		}
+		for i, b := range f.buckets {
+			le := append(labels, "le", formatValue(b))
+			w.sample(f.name+"_bucket", le, float64(s.buckets[i]))
+		}
+		w.sample(f.name+"_bucket", append(labels, "le", "+Inf"), float64(s.count))
+		w.sample(f.name+"_sum", labels, s.value)
+		w.sample(f.name+"_count", labels, float64(s.count))
+	}
+}
+
+// Write outputs all registered counters and histograms, then the gauges
+// written by the collect function
+func Write(out io.Writer, collect func(w *Writer)) error {
+	w := &Writer{buf: new(bytes.Buffer), seen: make(map[string]bool)}
+
+	mutex.RLock()
+	names := make([]string, 0, len(families))
+	for n := range families {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		families[n].write(w)
+	}
+	mutex.RUnlock()
+
+	if collect != nil {
+		collect(w)
+	}
+	_, err := out.Write(w.buf.Bytes())
+	return err
+}
+
+func escapeLabel(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, "\n", `\n`)
+	return strings.ReplaceAll(v, `"`, `\"`)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
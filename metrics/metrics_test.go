@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	require := require.New(t)
+
+	counter := NewCounter("mixin_test_messages_total", "Test messages.", "peer", "type")
+	histogram := NewHistogram("mixin_test_latency_seconds", "Test latency.", []float64{0.1, 1}, "phase")
+	require.Panics(func() { NewCounter("mixin_test_messages_total", "Test messages.") })
+	require.Panics(func() { counter.Inc("peer") })
+	require.Panics(func() { counter.Add(-1, "peer", "graph") })
+
+	counter.Inc("b", "graph")
+	counter.Add(3, "a", "graph")
+	counter.Inc("a", `quo"te`)
+	histogram.Observe(0.05, "commitment")
+	histogram.Observe(0.5, "commitment")
+	histogram.Observe(5, "commitment")
+
+	buf := new(bytes.Buffer)
+	err := Write(buf, func(w *Writer) {
+		w.Gauge("mixin_test_queue_depth", "Test queue.", 7, "queue", "cache")
+		w.Gauge("mixin_test_queue_depth", "Test queue.", 1.5, "queue", "final")
+		w.Gauge("mixin_test_tps", "Test tps.", 0)
+	})
+	require.Nil(err)
+	require.Equal(`# HELP mixin_test_latency_seconds Test latency.
+# TYPE mixin_test_latency_seconds histogram
+mixin_test_latency_seconds_bucket{phase="commitment",le="0.1"} 1
+mixin_test_latency_seconds_bucket{phase="commitment",le="1"} 2
+mixin_test_latency_seconds_bucket{phase="commitment",le="+Inf"} 3
+mixin_test_latency_seconds_sum{phase="commitment"} 5.55
+mixin_test_latency_seconds_count{phase="commitment"} 3
+# HELP mixin_test_messages_total Test messages.
+# TYPE mixin_test_messages_total counter
+mixin_test_messages_total{peer="a",type="graph"} 3
+mixin_test_messages_total{peer="a",type="quo\"te"} 1
+mixin_test_messages_total{peer="b",type="graph"} 1
+# HELP mixin_test_queue_depth Test queue.
+# TYPE mixin_test_queue_depth gauge
+mixin_test_queue_depth{queue="cache"} 7
+mixin_test_queue_depth{queue="final"} 1.5
+# HELP mixin_test_tps Test tps.
+# TYPE mixin_test_tps gauge
+mixin_test_tps 0
+`, buf.String())
+}
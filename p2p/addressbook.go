@@ -0,0 +1,442 @@
+package p2p
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const (
+	RelayersDiscoveryPeriod = time.Minute
+
+	relayersDiscoveryTarget = 3
+	addressBookLimit        = 1024
+	addressBookShareLimit   = 64
+	addressBookShareExpiry  = 24 * time.Hour
+	addressBookFailureLimit = 16
+	addressMaxSize          = 256
+)
+
+// AddressEntry is a relayer learned from the seeds or shared by the other
+// relayers, the successes and failures are the dials to the relayer, and a
+// dial succeeds only if any message received from the relayer
+type AddressEntry struct {
+	Id        crypto.Hash `json:"id"`
+	Address   string      `json:"address"`
+	LastSeen  time.Time   `json:"last_seen"`
+	Successes uint64      `json:"successes"`
+	Failures  uint64      `json:"failures"`
+
+	dirty bool
+}
+
+// SuccessRate is smoothed so a relayer never dialed ranks between the good
+// and the bad ones
+func (e *AddressEntry) SuccessRate() float64 {
+	return float64(e.Successes+1) / float64(e.Successes+e.Failures+2)
+}
+
+func (e *AddressEntry) marshal() []byte {
+	enc := common.NewMinimumEncoder()
+	enc.WriteInt(len(e.Address))
+	enc.Write([]byte(e.Address))
+	var seen uint64
+	if !e.LastSeen.IsZero() {
+		seen = uint64(e.LastSeen.UnixNano())
+	}
+	enc.WriteUint64(seen)
+	enc.WriteUint64(e.Successes)
+	enc.WriteUint64(e.Failures)
+	return enc.Bytes()
+}
+
+func unmarshalAddressEntry(id crypto.Hash, b []byte) (*AddressEntry, error) {
+	dec, err := common.NewMinimumDecoder(b)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	seen, err := dec.ReadUint64()
+	if err != nil {
+		return nil, err
+	}
+	e := &AddressEntry{Id: id, Address: string(addr)}
+	if seen > 0 {
+		e.LastSeen = time.Unix(0, int64(seen))
+	}
+	e.Successes, err = dec.ReadUint64()
+	if err != nil {
+		return nil, err
+	}
+	e.Failures, err = dec.ReadUint64()
+	return e, err
+}
+
+func validateRelayerAddress(addr string) error {
+	if len(addr) > addressMaxSize {
+		return fmt.Errorf("invalid address size %d", len(addr))
+	}
+	transport, host := ParseRelayerAddress(addr)
+	if transport != TransportQuic && transport != TransportTcp {
+		return fmt.Errorf("invalid address %s transport %s", addr, transport)
+	}
+	h, p, err := net.SplitHostPort(host)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(p)
+	if err != nil || h == "" || port < 80 || port > 65535 {
+		return fmt.Errorf("invalid address %s", addr)
+	}
+	return nil
+}
+
+// addressBook persists the relayers to the node database, so the node could
+// connect the relayers learned before when all the seeds are down
+type addressBook struct {
+	sync.Mutex
+	m       map[crypto.Hash]*AddressEntry
+	dialing map[crypto.Hash]bool
+	handle  SyncHandle
+}
+
+func newAddressBook(handle SyncHandle) *addressBook {
+	ab := &addressBook{
+		m:       make(map[crypto.Hash]*AddressEntry),
+		dialing: make(map[crypto.Hash]bool),
+		handle:  handle,
+	}
+	if handle == nil {
+		return ab
+	}
+	entries, err := handle.ReadPeerAddresses()
+	if err != nil {
+		panic(err)
+	}
+	for id, b := range entries {
+		e, err := unmarshalAddressEntry(id, b)
+		if err != nil || validateRelayerAddress(e.Address) != nil {
+			p2pLogger.Info("unmarshalAddressEntry", "peer", id, "size", len(b), "error", err)
+			continue
+		}
+		ab.m[id] = e
+	}
+	return ab
+}
+
+func (ab *addressBook) write(e *AddressEntry, remove bool) {
+	e.dirty = false
+	if ab.handle == nil {
+		return
+	}
+	var data []byte
+	if !remove {
+		data = e.marshal()
+	}
+	err := ab.handle.WritePeerAddress(e.Id, data)
+	if err != nil {
+		p2pLogger.Info("WritePeerAddress", "peer", e.Id, "addr", e.Address, "remove", remove, "error", err)
+	}
+}
+
+// learn adds the relayer shared by others, the address of a known relayer is
+// replaced only if it fails more than succeeds
+func (ab *addressBook) learn(id crypto.Hash, addr string) {
+	ab.Lock()
+	defer ab.Unlock()
+
+	e := ab.m[id]
+	if e != nil && (e.Address == addr || e.Failures <= e.Successes) {
+		return
+	}
+	if e == nil && len(ab.m) >= addressBookLimit {
+		ab.evict()
+	}
+	e = &AddressEntry{Id: id, Address: addr}
+	ab.m[id] = e
+	ab.write(e, false)
+}
+
+// book adds the relayer to dial if not known, e.g. the seeds on first boot,
+// so the messages received are seen and the dial could succeed
+func (ab *addressBook) book(id crypto.Hash, addr string) {
+	ab.Lock()
+	defer ab.Unlock()
+
+	e := ab.m[id]
+	if e != nil && e.Address == addr {
+		return
+	}
+	if e == nil && len(ab.m) >= addressBookLimit {
+		ab.evict()
+	}
+	e = &AddressEntry{Id: id, Address: addr}
+	ab.m[id] = e
+	ab.write(e, false)
+}
+
+// evict removes the worst relayer not dialing
+func (ab *addressBook) evict() {
+	var worst *AddressEntry
+	for _, e := range ab.m {
+		if _, found := ab.dialing[e.Id]; found {
+			continue
+		}
+		if worst == nil || e.SuccessRate() < worst.SuccessRate() ||
+			e.SuccessRate() == worst.SuccessRate() && e.LastSeen.Before(worst.LastSeen) {
+			worst = e
+		}
+	}
+	if worst != nil {
+		delete(ab.m, worst.Id)
+		ab.write(worst, true)
+	}
+}
+
+// seen is called when any message received from the relayer, and the last
+// seen is persisted by the flush to avoid too many writes
+func (ab *addressBook) seen(id crypto.Hash, now time.Time) {
+	ab.Lock()
+	defer ab.Unlock()
+
+	e := ab.m[id]
+	if e == nil {
+		return
+	}
+	e.LastSeen = now
+	e.dirty = true
+}
+
+// dialed records the dial result, the relayer is removed if it keeps failing
+func (ab *addressBook) dialed(id crypto.Hash, addr string, start time.Time) {
+	ab.Lock()
+	defer ab.Unlock()
+
+	e := ab.m[id]
+	if e == nil || e.Address != addr {
+		e = &AddressEntry{Id: id, Address: addr}
+		ab.m[id] = e
+	}
+	if e.LastSeen.Before(start) {
+		e.Failures = e.Failures + 1
+	} else {
+		e.Successes = e.Successes + 1
+	}
+	remove := e.Failures >= e.Successes+addressBookFailureLimit
+	if remove {
+		delete(ab.m, id)
+	}
+	ab.write(e, remove)
+}
+
+func (ab *addressBook) flush() {
+	ab.Lock()
+	defer ab.Unlock()
+
+	for _, e := range ab.m {
+		if e.dirty {
+			ab.write(e, false)
+		}
+	}
+}
+
+// dial marks the relayer dialing to avoid duplicated connections, the seeds
+// are marked forever and never dialed from the address book
+func (ab *addressBook) dial(id crypto.Hash, booked bool) bool {
+	ab.Lock()
+	defer ab.Unlock()
+
+	if _, found := ab.dialing[id]; found {
+		return false
+	}
+	ab.dialing[id] = booked
+	return true
+}
+
+func (ab *addressBook) undial(id crypto.Hash) {
+	ab.Lock()
+	defer ab.Unlock()
+
+	delete(ab.dialing, id)
+}
+
+func (ab *addressBook) dialingBooked() int {
+	ab.Lock()
+	defer ab.Unlock()
+
+	var count int
+	for _, booked := range ab.dialing {
+		if booked {
+			count++
+		}
+	}
+	return count
+}
+
+// candidates returns the relayers not dialing ordered by the success rate
+func (ab *addressBook) candidates(exclude func(crypto.Hash) bool) []*AddressEntry {
+	ab.Lock()
+	defer ab.Unlock()
+
+	var entries []*AddressEntry
+	for id, e := range ab.m {
+		if _, found := ab.dialing[id]; found || exclude(id) {
+			continue
+		}
+		c := *e
+		entries = append(entries, &c)
+	}
+	sortAddressEntries(entries, func(a, b *AddressEntry) int {
+		if a.SuccessRate() != b.SuccessRate() {
+			return cmp.Compare(b.SuccessRate(), a.SuccessRate())
+		}
+		return b.LastSeen.Compare(a.LastSeen)
+	})
+	return entries
+}
+
+// share returns the relayers ever connected and seen recently
+func (ab *addressBook) share(now time.Time) []*AddressEntry {
+	ab.Lock()
+	defer ab.Unlock()
+
+	var entries []*AddressEntry
+	for _, e := range ab.m {
+		if e.Successes == 0 || e.LastSeen.Add(addressBookShareExpiry).Before(now) {
+			continue
+		}
+		c := *e
+		entries = append(entries, &c)
+	}
+	sortAddressEntries(entries, func(a, b *AddressEntry) int {
+		return b.LastSeen.Compare(a.LastSeen)
+	})
+	if len(entries) > addressBookShareLimit {
+		entries = entries[:addressBookShareLimit]
+	}
+	return entries
+}
+
+func sortAddressEntries(entries []*AddressEntry, cmp func(a, b *AddressEntry) int) {
+	slices.SortFunc(entries, func(a, b *AddressEntry) int {
+		if c := cmp(a, b); c != 0 {
+			return c
+		}
+		return bytes.Compare(a.Id[:], b.Id[:])
+	})
+}
+
+func marshalAddressEntries(entries []*AddressEntry) []byte {
+	enc := common.NewMinimumEncoder()
+	enc.WriteInt(len(entries))
+	for _, e := range entries {
+		enc.Write(e.Id[:])
+		enc.WriteInt(len(e.Address))
+		enc.Write([]byte(e.Address))
+	}
+	return enc.Bytes()
+}
+
+func unmarshalAddressEntries(b []byte) ([]*AddressEntry, error) {
+	dec, err := common.NewMinimumDecoder(b)
+	if err != nil {
+		return nil, err
+	}
+	count, err := dec.ReadInt()
+	if err != nil {
+		return nil, err
+	}
+	if count > addressBookShareLimit {
+		return nil, fmt.Errorf("too much relayers %d", count)
+	}
+	entries := make([]*AddressEntry, count)
+	for i := range entries {
+		e := &AddressEntry{}
+		err = dec.Read(e.Id[:])
+		if err != nil {
+			return nil, err
+		}
+		addr, err := dec.ReadBytes()
+		if err != nil {
+			return nil, err
+		}
+		e.Address = string(addr)
+		err = validateRelayerAddress(e.Address)
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = e
+	}
+	return entries, nil
+}
+
+func (me *Peer) buildRelayersMessage() []byte {
+	entries := me.addresses.share(time.Now())
+	data := marshalAddressEntries(entries)
+	return append([]byte{PeerMessageTypeRelayers}, data...)
+}
+
+// updateAddressBook learns the relayers shared by a relayer neighbor
+func (me *Peer) updateAddressBook(peerId crypto.Hash, entries []*AddressEntry) error {
+	p2pLogger.Verbose("me.updateAddressBook", "peer", peerId, "entries", len(entries))
+	nbrs := me.GetNeighbors(peerId)
+	if !slices.ContainsFunc(nbrs, func(p *Peer) bool { return p.isRelayer }) {
+		return nil
+	}
+	for _, e := range entries {
+		if e.Id == me.IdForNetwork || me.Banned(e.Id) {
+			continue
+		}
+		me.addresses.learn(e.Id, e.Address)
+	}
+	return nil
+}
+
+// DiscoverRelayers connects the relayers in the address book when there are
+// not enough relayers connected, e.g. the seeds are down, and the seeds are
+// always connected by ConnectRelayer
+func (me *Peer) DiscoverRelayers(seeds []crypto.Hash) {
+	for _, id := range seeds {
+		me.addresses.dial(id, false)
+	}
+	for !me.closing {
+		me.connectRelayersFromBook()
+		time.Sleep(RelayersDiscoveryPeriod)
+		if me.closing {
+			return
+		}
+		me.addresses.flush()
+	}
+}
+
+func (me *Peer) connectRelayersFromBook() {
+	missing := relayersDiscoveryTarget - len(me.relayers.Slice()) - me.addresses.dialingBooked()
+	if missing <= 0 {
+		return
+	}
+	entries := me.addresses.candidates(func(id crypto.Hash) bool {
+		return id == me.IdForNetwork || me.relayers.Get(id) != nil || me.Banned(id)
+	})
+	for _, e := range entries[:min(missing, len(entries))] {
+		if !me.addresses.dial(e.Id, true) {
+			continue
+		}
+		go func(e *AddressEntry) {
+			defer me.addresses.undial(e.Id)
+			relayer := NewPeer(nil, e.Id, e.Address, true)
+			err := me.connectRelayer(relayer)
+			p2pLogger.Info("me.connectRelayer BOOK", "peer", e.Id, "addr", e.Address, "error", err)
+		}(e)
+	}
+}
@@ -0,0 +1,85 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddressBook(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(validateRelayerAddress("quic://seed.mixin.dev:5850"))
+	require.Nil(validateRelayerAddress("tcp://127.0.0.1:7239"))
+	require.Nil(validateRelayerAddress("127.0.0.1:7239"))
+	require.NotNil(validateRelayerAddress("udp://127.0.0.1:7239"))
+	require.NotNil(validateRelayerAddress("quic://:7239"))
+	require.NotNil(validateRelayerAddress("quic://127.0.0.1:22"))
+	require.NotNil(validateRelayerAddress("quic://127.0.0.1"))
+
+	now := time.Now()
+	ab := newAddressBook(nil)
+	a, b, c := crypto.Blake3Hash([]byte("a")), crypto.Blake3Hash([]byte("b")), crypto.Blake3Hash([]byte("c"))
+	ab.learn(a, "quic://127.0.0.1:7001")
+	ab.learn(b, "quic://127.0.0.1:7002")
+	ab.learn(c, "quic://127.0.0.1:7003")
+	require.Len(ab.share(now), 0)
+
+	ab.seen(a, now)
+	ab.dialed(a, "quic://127.0.0.1:7001", now.Add(-time.Second))
+	ab.dialed(b, "quic://127.0.0.1:7002", now)
+	require.Equal(uint64(1), ab.m[a].Successes)
+	require.Equal(uint64(1), ab.m[b].Failures)
+	ab.learn(a, "quic://127.0.0.1:8001")
+	require.Equal("quic://127.0.0.1:7001", ab.m[a].Address)
+	ab.learn(b, "quic://127.0.0.1:8002")
+	require.Equal("quic://127.0.0.1:8002", ab.m[b].Address)
+	require.Equal(uint64(0), ab.m[b].Failures)
+
+	entries := ab.candidates(func(id crypto.Hash) bool { return false })
+	require.Len(entries, 3)
+	require.Equal(a, entries[0].Id)
+	require.True(ab.dial(a, true))
+	require.False(ab.dial(a, true))
+	require.True(ab.dial(b, false))
+	require.Equal(1, ab.dialingBooked())
+	entries = ab.candidates(func(id crypto.Hash) bool { return false })
+	require.Len(entries, 1)
+	require.Equal(c, entries[0].Id)
+	ab.undial(a)
+	require.Equal(0, ab.dialingBooked())
+
+	shared := ab.share(now)
+	require.Len(shared, 1)
+	require.Equal(a, shared[0].Id)
+	msg, err := parseNetworkMessage(TransportMessageVersion, append([]byte{PeerMessageTypeRelayers}, marshalAddressEntries(shared)...))
+	require.Nil(err)
+	require.Len(msg.Relayers, 1)
+	require.Equal(a, msg.Relayers[0].Id)
+	require.Equal("quic://127.0.0.1:7001", msg.Relayers[0].Address)
+
+	e, err := unmarshalAddressEntry(a, ab.m[a].marshal())
+	require.Nil(err)
+	require.Equal(ab.m[a].Address, e.Address)
+	require.True(ab.m[a].LastSeen.Equal(e.LastSeen))
+	require.Equal(uint64(1), e.Successes)
+
+	d := crypto.Blake3Hash([]byte("d"))
+	start := now.Add(-time.Second)
+	ab.book(d, "quic://127.0.0.1:7004")
+	ab.seen(d, now)
+	ab.dialed(d, "quic://127.0.0.1:7004", start)
+	require.Equal(uint64(1), ab.m[d].Successes)
+	require.Equal(uint64(0), ab.m[d].Failures)
+	require.Len(ab.share(now), 2)
+	ab.book(d, "quic://127.0.0.1:7004")
+	require.Equal(uint64(1), ab.m[d].Successes)
+
+	for range addressBookFailureLimit {
+		ab.dialed(c, "quic://127.0.0.1:7003", now)
+	}
+	require.Nil(ab.m[c])
+	require.Len(ab.m, 3)
+}
@@ -9,7 +9,6 @@ import (
 
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/crypto"
-	"github.com/MixinNetwork/mixin/logger"
 	"github.com/dgraph-io/ristretto/v2"
 )
 
@@ -31,6 +30,7 @@ const (
 
 	PeerMessageTypeRelay     = 200
 	PeerMessageTypeConsumers = 201
+	PeerMessageTypeRelayers  = 202
 
 	MsgPriorityNormal = 0
 	MsgPriorityHigh   = 1
@@ -49,11 +49,14 @@ type PeerMessage struct {
 	WantTx          bool
 	Commitments     []*crypto.Key
 	Graph           []*SyncPoint
+	Relayers        []*AddressEntry
 	Data            []byte
 
-	unsigned  []byte
-	signature *crypto.Signature
-	version   byte
+	unsigned   []byte
+	signature  *crypto.Signature
+	version    byte
+	direct     bool
+	receivedAt time.Time
 }
 
 type AuthToken struct {
@@ -77,11 +80,15 @@ type SyncHandle interface {
 	CachePutTransaction(peerId crypto.Hash, ver *common.VersionedTransaction) error
 	CosiQueueExternalAnnouncement(peerId crypto.Hash, s *common.Snapshot, R *crypto.Key, sig *crypto.Signature) error
 	CosiAggregateSelfCommitments(peerId crypto.Hash, snap crypto.Hash, commitment *crypto.Key, wantTx bool, data []byte, sig *crypto.Signature) error
-	CosiQueueExternalChallenge(peerId crypto.Hash, snap crypto.Hash, cosi *crypto.CosiSignature, ver *common.VersionedTransaction) error
+	CosiQueueExternalChallenge(peerId crypto.Hash, snap crypto.Hash, cosi *crypto.CosiSignature, ver *common.VersionedTransaction, direct bool) error
 	CosiQueueExternalFullChallenge(peerId crypto.Hash, s *common.Snapshot, commitment, challenge *crypto.Key, cosi *crypto.CosiSignature, ver *common.VersionedTransaction) error
-	CosiAggregateSelfResponses(peerId crypto.Hash, snap crypto.Hash, response *[32]byte) error
+	CosiAggregateSelfResponses(peerId crypto.Hash, snap crypto.Hash, response *[32]byte, direct bool) error
 	VerifyAndQueueAppendSnapshotFinalization(peerId crypto.Hash, s *common.Snapshot) error
 	CosiQueueExternalCommitments(peerId crypto.Hash, commitments []*crypto.Key, data []byte, sig *crypto.Signature) error
+	ReadPeerBans() (map[crypto.Hash]uint64, error)
+	WritePeerBan(peerId crypto.Hash, until uint64) error
+	ReadPeerAddresses() (map[crypto.Hash][]byte, error)
+	WritePeerAddress(peerId crypto.Hash, data []byte) error
 }
 
 func (me *Peer) SendGraphMessage(idForNetwork crypto.Hash) error {
@@ -425,29 +432,56 @@ func parseNetworkMessage(version uint8, data []byte) (*PeerMessage, error) {
 		msg.Data = data
 	case PeerMessageTypeConsumers:
 		msg.Data = data[1:]
+	case PeerMessageTypeRelayers:
+		relayers, err := unmarshalAddressEntries(data[1:])
+		if err != nil {
+			return nil, err
+		}
+		msg.Relayers = relayers
 	}
 	return msg, nil
 }
 
 func (me *Peer) relayOrHandlePeerMessage(relayerId crypto.Hash, msg *PeerMessage) error {
-	logger.Verbosef("me.relayOrHandlePeerMessage(%s, %s) => %s %v", me.Address, me.IdForNetwork, relayerId, msg.Data)
+	log := p2pLogger.With("addr", me.Address, "relayer", relayerId)
+	log.Verbose("me.relayOrHandlePeerMessage", "size", len(msg.Data))
 	if len(msg.Data) < 65 {
 		return nil
 	}
 	var from, to crypto.Hash
 	copy(from[:], msg.Data[1:33])
 	copy(to[:], msg.Data[33:65])
+	if nbr := me.GetNeighbors(relayerId); len(nbr) > 0 && !nbr[0].isRelayer && from != relayerId {
+		return NewMisbehaviorError(PeerMisbehaviorMalformedMessage, "relay from %s by consumer %s", from, relayerId)
+	}
 	if to == me.IdForNetwork {
+		me.recordRelay(relayerId, "delivered")
 		rm, err := parseNetworkMessage(msg.version, msg.Data[65:])
-		logger.Verbosef("me.relayOrHandlePeerMessage.ME(%s, %s) => %s %v %v", me.Address, me.IdForNetwork, from, rm, err)
-		if err != nil {
-			return err
+		log.Verbose("me.relayOrHandlePeerMessage delivered", "from", from, "error", err)
+		if err != nil && from == relayerId {
+			return NewMisbehaviorError(PeerMisbehaviorMalformedMessage, "relay from %s %v", from, err)
+		} else if err != nil {
+			return nil
+		}
+		// the relayers never check the relayed content, and the origin is not
+		// authenticated, so only the neighbor sending its own message is penalized
+		rm.direct = from == relayerId
+		err = me.handlePeerMessage(from, rm)
+		var mbe *MisbehaviorError
+		if !rm.direct && errors.As(err, &mbe) {
+			log.Verbose("me.relayOrHandlePeerMessage relayed", "from", from, "error", err)
+			return nil
 		}
-		return me.handlePeerMessage(from, rm)
+		return err
 	}
 	if !me.IsRelayer() {
 		return nil
 	}
+	if from == relayerId {
+		me.recordRelay(relayerId, "1")
+	} else {
+		me.recordRelay(relayerId, "2+")
+	}
 
 	var relayers []*Peer
 	if nbrs := me.GetNeighbors(to); len(nbrs) > 0 {
@@ -466,13 +500,13 @@ func (me *Peer) relayOrHandlePeerMessage(relayerId crypto.Hash, msg *PeerMessage
 		if success {
 			return nil
 		}
-		logger.Verbosef("me.offerToPeerWithCacheCheck(%s) relayer timeout\n", peer.IdForNetwork)
+		log.Verbose("me.offerToPeerWithCacheCheck relayer timeout", "to", to, "peer", peer.IdForNetwork)
 	}
 	return nil
 }
 
 func (me *Peer) updateRemoteRelayerConsumers(relayerId crypto.Hash, data []byte) error {
-	logger.Verbosef("me.updateRemoteRelayerConsumers(%s, %s) => %x", me.Address, relayerId, data)
+	p2pLogger.Verbose("me.updateRemoteRelayerConsumers", "addr", me.Address, "relayer", relayerId, "size", len(data))
 	if !me.IsRelayer() {
 		return nil
 	}
@@ -494,17 +528,20 @@ func (me *Peer) updateRemoteRelayerConsumers(relayerId crypto.Hash, data []byte)
 }
 
 func (me *Peer) handlePeerMessage(peerId crypto.Hash, msg *PeerMessage) error {
+	log := p2pLogger.With("peer", peerId)
 	switch msg.Type {
 	case PeerMessageTypeRelay:
 		return me.relayOrHandlePeerMessage(peerId, msg)
 	case PeerMessageTypeConsumers:
 		return me.updateRemoteRelayerConsumers(peerId, msg.Data)
+	case PeerMessageTypeRelayers:
+		return me.updateAddressBook(peerId, msg.Relayers)
 	case PeerMessageTypePing:
 	case PeerMessageTypeCommitments:
-		logger.Verbosef("network.handle handlePeerMessage PeerMessageTypeCommitments %s %d\n", peerId, len(msg.Commitments))
+		log.Verbose("network.handle handlePeerMessage PeerMessageTypeCommitments", "commitments", len(msg.Commitments))
 		return me.handle.CosiQueueExternalCommitments(peerId, msg.Commitments, msg.unsigned, msg.signature)
 	case PeerMessageTypeGraph:
-		logger.Verbosef("network.handle handlePeerMessage PeerMessageTypeGraph %s\n", peerId)
+		log.Verbose("network.handle handlePeerMessage PeerMessageTypeGraph")
 		err := me.handle.UpdateSyncPoint(peerId, msg.Graph, msg.unsigned, msg.signature)
 		if err != nil {
 			return err
@@ -518,32 +555,33 @@ func (me *Peer) handlePeerMessage(peerId crypto.Hash, msg *PeerMessage) error {
 		}
 		return nil
 	case PeerMessageTypeTransactionRequest:
-		logger.Verbosef("network.handle handlePeerMessage PeerMessageTypeTransactionRequest %s %s\n", peerId, msg.TransactionHash)
+		log.Verbose("network.handle handlePeerMessage PeerMessageTypeTransactionRequest", "transaction", msg.TransactionHash)
 		return me.handle.SendTransactionToPeer(peerId, msg.TransactionHash)
 	case PeerMessageTypeTransaction:
-		logger.Verbosef("network.handle handlePeerMessage PeerMessageTypeTransaction %s\n", peerId)
+		log.Verbose("network.handle handlePeerMessage PeerMessageTypeTransaction")
 		return me.handle.CachePutTransaction(peerId, msg.Transaction)
 	case PeerMessageTypeSnapshotConfirm:
-		logger.Verbosef("network.handle handlePeerMessage PeerMessageTypeSnapshotConfirm %s %s\n", peerId, msg.SnapshotHash)
+		log.Verbose("network.handle handlePeerMessage PeerMessageTypeSnapshotConfirm", "snapshot", msg.SnapshotHash)
 		me.ConfirmSnapshotForPeer(peerId, msg.SnapshotHash)
 		return nil
 	case PeerMessageTypeSnapshotAnnouncement:
-		logger.Verbosef("network.handle handlePeerMessage PeerMessageTypeSnapshotAnnouncement %s %s\n", peerId, msg.Snapshot.SoleTransaction())
+		log.Verbose("network.handle handlePeerMessage PeerMessageTypeSnapshotAnnouncement", "transaction", msg.Snapshot.SoleTransaction())
 		return me.handle.CosiQueueExternalAnnouncement(peerId, msg.Snapshot, &msg.Commitment, msg.signature)
 	case PeerMessageTypeSnapshotCommitment:
-		logger.Verbosef("network.handle handlePeerMessage PeerMessageTypeSnapshotCommitment %s %s\n", peerId, msg.SnapshotHash)
+		log.Verbose("network.handle handlePeerMessage PeerMessageTypeSnapshotCommitment", "snapshot", msg.SnapshotHash)
 		return me.handle.CosiAggregateSelfCommitments(peerId, msg.SnapshotHash, &msg.Commitment, msg.WantTx, msg.unsigned, msg.signature)
 	case PeerMessageTypeTransactionChallenge:
-		logger.Verbosef("network.handle handlePeerMessage PeerMessageTypeTransactionChallenge %s %s %t\n", peerId, msg.SnapshotHash, msg.Transaction != nil)
-		return me.handle.CosiQueueExternalChallenge(peerId, msg.SnapshotHash, &msg.Cosi, msg.Transaction)
+		log.Verbose("network.handle handlePeerMessage PeerMessageTypeTransactionChallenge", "snapshot", msg.SnapshotHash, "transaction", msg.Transaction != nil)
+		return me.handle.CosiQueueExternalChallenge(peerId, msg.SnapshotHash, &msg.Cosi, msg.Transaction, msg.direct)
 	case PeerMessageTypeFullChallenge:
-		logger.Verbosef("network.handle handlePeerMessage PeerMessageTypeFullChallenge %s %v %v\n", peerId, msg.Snapshot, msg.Transaction)
+		log.Verbose("network.handle handlePeerMessage PeerMessageTypeFullChallenge", "chain", msg.Snapshot.NodeId,
+			"round", msg.Snapshot.RoundNumber, "transaction", msg.Transaction != nil)
 		return me.handle.CosiQueueExternalFullChallenge(peerId, msg.Snapshot, &msg.Commitment, &msg.Challenge, &msg.Cosi, msg.Transaction)
 	case PeerMessageTypeSnapshotResponse:
-		logger.Verbosef("network.handle handlePeerMessage PeerMessageTypeSnapshotResponse %s %s\n", peerId, msg.SnapshotHash)
-		return me.handle.CosiAggregateSelfResponses(peerId, msg.SnapshotHash, &msg.Response)
+		log.Verbose("network.handle handlePeerMessage PeerMessageTypeSnapshotResponse", "snapshot", msg.SnapshotHash)
+		return me.handle.CosiAggregateSelfResponses(peerId, msg.SnapshotHash, &msg.Response, msg.direct)
 	case PeerMessageTypeSnapshotFinalization:
-		logger.Verbosef("network.handle handlePeerMessage PeerMessageTypeSnapshotFinalization %s %s\n", peerId, msg.Snapshot.SoleTransaction())
+		log.Verbose("network.handle handlePeerMessage PeerMessageTypeSnapshotFinalization", "transaction", msg.Snapshot.SoleTransaction())
 		return me.handle.VerifyAndQueueAppendSnapshotFinalization(peerId, msg.Snapshot)
 	}
 	return nil
@@ -2,11 +2,93 @@ package p2p
 
 import (
 	"encoding/json"
+	"fmt"
+	"sync"
 	"sync/atomic"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/metrics"
+)
+
+// the peer is not a label of the prometheus series, because any remote could
+// connect with a new id and grow the series without bound, so the detail of
+// each peer is only in the listpeers metric pools
+var (
+	peerMessages = metrics.NewCounter("mixin_p2p_messages_total",
+		"Messages sent to or received from the peers.", "direction", "type")
+	peerMessageBytes = metrics.NewCounter("mixin_p2p_message_bytes_total",
+		"Message bytes sent to or received from the peers.", "direction", "type")
+	peerRingDrops = metrics.NewCounter("mixin_p2p_ring_drops_total",
+		"Messages dropped because the sending ring of the peer is full.", "priority")
+	peerMessageLatency = metrics.NewHistogram("mixin_p2p_message_latency_seconds",
+		"Time to send a message to the wire, or to handle a message received.",
+		latencySeconds(latencyBuckets), "direction")
 )
 
+var latencyBuckets = []time.Duration{
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+func latencySeconds(buckets []time.Duration) []float64 {
+	seconds := make([]float64, len(buckets))
+	for i, b := range buckets {
+		seconds[i] = b.Seconds()
+	}
+	return seconds
+}
+
+var priorityNames = map[int]string{
+	MsgPriorityNormal: "normal",
+	MsgPriorityHigh:   "high",
+}
+
+var messageTypeNames = map[uint8]string{
+	PeerMessageTypePing:                 "ping",
+	PeerMessageTypeAuthentication:       "authentication",
+	PeerMessageTypeGraph:                "graph",
+	PeerMessageTypeSnapshotConfirm:      "snapshot-confirm",
+	PeerMessageTypeTransactionRequest:   "transaction-request",
+	PeerMessageTypeTransaction:          "transaction",
+	PeerMessageTypeSnapshotAnnouncement: "snapshot-announcement",
+	PeerMessageTypeSnapshotCommitment:   "snapshot-commitment",
+	PeerMessageTypeTransactionChallenge: "transaction-challenge",
+	PeerMessageTypeSnapshotResponse:     "snapshot-response",
+	PeerMessageTypeSnapshotFinalization: "snapshot-finalization",
+	PeerMessageTypeCommitments:          "commitments",
+	PeerMessageTypeFullChallenge:        "full-challenge",
+	PeerMessageTypeRelay:                "relay",
+	PeerMessageTypeConsumers:            "consumers",
+	PeerMessageTypeRelayers:             "relayers",
+}
+
+func messageTypeName(typ uint8) string {
+	if n, found := messageTypeNames[typ]; found {
+		return n
+	}
+	return fmt.Sprint(typ)
+}
+
+func recordPeerMessage(direction string, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	typ := messageTypeName(data[0])
+	peerMessages.Inc(direction, typ)
+	peerMessageBytes.Add(float64(len(data)), direction, typ)
+}
+
+// MetricPool is the metric of all the neighbors, or a single neighbor, the
+// sent and received are in different pools. The bytes are on the wire, the
+// latency of a sent message is the time to write it to the wire, and of a
+// received message is the time from received to handled
 type MetricPool struct {
 	enabled bool
+	mutex   sync.Mutex
 
 	PeerMessageTypePing               uint32 `json:"ping"`
 	PeerMessageTypeAuthentication     uint32 `json:"authentication"`
@@ -24,6 +106,110 @@ type MetricPool struct {
 	PeerMessageTypeFullChallenge        uint32 `json:"full-challenge"`
 
 	PeerMessageTypeRelay uint32 `json:"relay"`
+
+	CompressedMessages uint32 `json:"compressed-messages"`
+	CompressedSize     uint64 `json:"compressed-size"`
+	UncompressedSize   uint64 `json:"uncompressed-size"`
+
+	Bytes     map[string]uint64 `json:"bytes,omitempty"`
+	Drops     map[string]uint64 `json:"drops,omitempty"`
+	Latency   map[string]uint64 `json:"latency,omitempty"`
+	RelayHops map[string]uint64 `json:"relay-hops,omitempty"`
+}
+
+func (mp *MetricPool) add(m *map[string]uint64, key string, v uint64) {
+	if !mp.enabled {
+		return
+	}
+	mp.mutex.Lock()
+	defer mp.mutex.Unlock()
+
+	if *m == nil {
+		*m = make(map[string]uint64)
+	}
+	(*m)[key] = (*m)[key] + v
+}
+
+func (mp *MetricPool) bytes(typ uint8, size int) {
+	mp.add(&mp.Bytes, messageTypeName(typ), uint64(size))
+}
+
+func (mp *MetricPool) drop(priority int) {
+	mp.add(&mp.Drops, priorityNames[priority], 1)
+}
+
+// latency counts the durations in the buckets of the upper bounds
+func (mp *MetricPool) latency(d time.Duration) {
+	bucket := "+Inf"
+	for _, b := range latencyBuckets {
+		if d <= b {
+			bucket = b.String()
+			break
+		}
+	}
+	mp.add(&mp.Latency, bucket, 1)
+}
+
+// relay counts the relay messages received, the hop is 1 if the relayer
+// receives it from the sender, or 2+ from another relayer, and delivered if
+// the message is for the receiver
+func (mp *MetricPool) relay(hop string) {
+	mp.add(&mp.RelayHops, hop, 1)
+}
+
+// compress records the messages compressed on the wire, the size is the data
+// size and the wire size is smaller if compressed
+func (mp *MetricPool) compress(size, wire int) {
+	if !mp.enabled || wire >= size {
+		return
+	}
+	atomic.AddUint32(&mp.CompressedMessages, 1)
+	atomic.AddUint64(&mp.CompressedSize, uint64(wire))
+	atomic.AddUint64(&mp.UncompressedSize, uint64(size))
+}
+
+// CompressionRatio is the uncompressed size divided by the compressed size
+// of all the compressed messages
+func (mp *MetricPool) CompressionRatio() float64 {
+	compressed := atomic.LoadUint64(&mp.CompressedSize)
+	if compressed == 0 {
+		return 0
+	}
+	return float64(atomic.LoadUint64(&mp.UncompressedSize)) / float64(compressed)
+}
+
+func (mp *MetricPool) MarshalJSON() ([]byte, error) {
+	mp.mutex.Lock()
+	defer mp.mutex.Unlock()
+
+	type pool MetricPool
+	snapshot := &pool{
+		PeerMessageTypePing:                 atomic.LoadUint32(&mp.PeerMessageTypePing),
+		PeerMessageTypeAuthentication:       atomic.LoadUint32(&mp.PeerMessageTypeAuthentication),
+		PeerMessageTypeGraph:                atomic.LoadUint32(&mp.PeerMessageTypeGraph),
+		PeerMessageTypeSnapshotConfirm:      atomic.LoadUint32(&mp.PeerMessageTypeSnapshotConfirm),
+		PeerMessageTypeTransactionRequest:   atomic.LoadUint32(&mp.PeerMessageTypeTransactionRequest),
+		PeerMessageTypeTransaction:          atomic.LoadUint32(&mp.PeerMessageTypeTransaction),
+		PeerMessageTypeSnapshotAnnouncement: atomic.LoadUint32(&mp.PeerMessageTypeSnapshotAnnouncement),
+		PeerMessageTypeSnapshotCommitment:   atomic.LoadUint32(&mp.PeerMessageTypeSnapshotCommitment),
+		PeerMessageTypeTransactionChallenge: atomic.LoadUint32(&mp.PeerMessageTypeTransactionChallenge),
+		PeerMessageTypeSnapshotResponse:     atomic.LoadUint32(&mp.PeerMessageTypeSnapshotResponse),
+		PeerMessageTypeSnapshotFinalization: atomic.LoadUint32(&mp.PeerMessageTypeSnapshotFinalization),
+		PeerMessageTypeCommitments:          atomic.LoadUint32(&mp.PeerMessageTypeCommitments),
+		PeerMessageTypeFullChallenge:        atomic.LoadUint32(&mp.PeerMessageTypeFullChallenge),
+		PeerMessageTypeRelay:                atomic.LoadUint32(&mp.PeerMessageTypeRelay),
+		CompressedMessages:                  atomic.LoadUint32(&mp.CompressedMessages),
+		CompressedSize:                      atomic.LoadUint64(&mp.CompressedSize),
+		UncompressedSize:                    atomic.LoadUint64(&mp.UncompressedSize),
+		Bytes:                               mp.Bytes,
+		Drops:                               mp.Drops,
+		Latency:                             mp.Latency,
+		RelayHops:                           mp.RelayHops,
+	}
+	return json.Marshal(struct {
+		*pool
+		CompressionRatio float64 `json:"compression-ratio"`
+	}{snapshot, mp.CompressionRatio()})
 }
 
 func (mp *MetricPool) handle(msg uint8) {
@@ -70,3 +256,42 @@ func (mp *MetricPool) String() string {
 	}
 	return string(b)
 }
+
+func (me *Peer) recordSent(p *Peer, data []byte, wire int, latency time.Duration) {
+	p.sentMetric.handle(data[0])
+	for _, mp := range []*MetricPool{me.sentMetric, p.sentMetric} {
+		mp.bytes(data[0], wire)
+		mp.compress(len(data), wire)
+		mp.latency(latency)
+	}
+	recordPeerMessage("sent", data)
+	peerMessageLatency.Observe(latency.Seconds(), "sent")
+}
+
+func (me *Peer) recordReceived(p *Peer, tm *TransportMessage) {
+	p.receivedMetric.handle(tm.Data[0])
+	for _, mp := range []*MetricPool{me.receivedMetric, p.receivedMetric} {
+		mp.bytes(tm.Data[0], int(tm.Size))
+		mp.compress(len(tm.Data), int(tm.Size))
+	}
+	recordPeerMessage("received", tm.Data)
+}
+
+func (me *Peer) recordHandled(p *Peer, latency time.Duration) {
+	me.receivedMetric.latency(latency)
+	p.receivedMetric.latency(latency)
+	peerMessageLatency.Observe(latency.Seconds(), "received")
+}
+
+func (me *Peer) recordDrop(p *Peer, priority int) {
+	me.sentMetric.drop(priority)
+	p.sentMetric.drop(priority)
+	peerRingDrops.Inc(priorityNames[priority])
+}
+
+func (me *Peer) recordRelay(relayerId crypto.Hash, hop string) {
+	me.receivedMetric.relay(hop)
+	for _, p := range me.GetNeighbors(relayerId) {
+		p.receivedMetric.relay(hop)
+	}
+}
@@ -0,0 +1,80 @@
+package p2p
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricPool(t *testing.T) {
+	require := require.New(t)
+
+	me := NewPeer(nil, crypto.Blake3Hash([]byte("me")), "127.0.0.1:7001", true)
+	p := NewPeer(nil, crypto.Blake3Hash([]byte("p")), "127.0.0.1:7002", true)
+	me.recordDrop(p, MsgPriorityNormal)
+	require.Len(me.Metric(), 0)
+	require.Nil(me.sentMetric.Drops)
+
+	me.EnableMetric()
+	me.neighborMetric(p)
+	for range cap(p.normalRing) {
+		require.True(me.offer(p, MsgPriorityNormal, &ChanMsg{nil, []byte{PeerMessageTypeGraph}}))
+	}
+	require.False(me.offer(p, MsgPriorityNormal, &ChanMsg{nil, []byte{PeerMessageTypeGraph}}))
+	require.True(me.offer(p, MsgPriorityHigh, &ChanMsg{nil, []byte{PeerMessageTypeGraph}}))
+	require.Equal(map[string]uint64{"normal": 1}, me.sentMetric.Drops)
+	require.Equal(map[string]uint64{"normal": 1}, p.sentMetric.Drops)
+
+	data := make([]byte, 8192)
+	data[0] = PeerMessageTypeTransaction
+	me.recordSent(p, data, 1024, 5*time.Millisecond)
+	me.recordSent(p, data[:100], 100, time.Minute)
+	me.recordReceived(p, &TransportMessage{Version: TransportMessageVersion, Size: 100, Data: data[:100]})
+	me.recordHandled(p, time.Millisecond)
+	me.recordRelay(p.IdForNetwork, "delivered")
+	require.Equal(map[string]uint64{"transaction": 1124}, p.sentMetric.Bytes)
+	require.Equal(map[string]uint64{"10ms": 1, "+Inf": 1}, p.sentMetric.Latency)
+	require.Equal(uint32(2), p.sentMetric.PeerMessageTypeTransaction)
+	require.Equal(uint32(0), me.sentMetric.PeerMessageTypeTransaction)
+	require.Equal(float64(8), me.sentMetric.CompressionRatio())
+	require.Equal(map[string]uint64{"transaction": 100}, me.receivedMetric.Bytes)
+	require.Equal(map[string]uint64{"1ms": 1}, me.receivedMetric.Latency)
+	require.Equal(map[string]uint64{"delivered": 1}, me.receivedMetric.RelayHops)
+	require.Nil(p.receivedMetric.RelayHops)
+
+	buf := new(bytes.Buffer)
+	err := metrics.Write(buf, nil)
+	require.Nil(err)
+	require.Contains(buf.String(), `mixin_p2p_ring_drops_total{priority="normal"}`)
+	require.Contains(buf.String(), `mixin_p2p_message_bytes_total{direction="sent",type="transaction"}`)
+	require.NotContains(buf.String(), "peer=")
+
+	b, err := json.Marshal(p.Metric())
+	require.Nil(err)
+	var metric map[string]*MetricPool
+	require.Nil(json.Unmarshal(b, &metric))
+	require.Equal(p.sentMetric.Bytes, metric["sent"].Bytes)
+	require.Equal(p.sentMetric.Drops, metric["sent"].Drops)
+	require.Equal(float64(8), metric["sent"].CompressionRatio())
+	require.Equal(p.receivedMetric.Latency, metric["received"].Latency)
+	require.Equal(uint32(2), metric["sent"].PeerMessageTypeTransaction)
+
+	done := make(chan struct{})
+	go func() {
+		for range 1000 {
+			p.sentMetric.handle(PeerMessageTypeTransaction)
+		}
+		close(done)
+	}()
+	for range 10 {
+		_ = p.sentMetric.String()
+	}
+	<-done
+	require.Equal(uint32(1002), p.sentMetric.PeerMessageTypeTransaction)
+	require.Equal([]float64{0.001, 0.01, 0.1, 1, 10}, latencySeconds(latencyBuckets))
+}
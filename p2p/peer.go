@@ -2,6 +2,7 @@ package p2p
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"sync"
@@ -12,6 +13,8 @@ import (
 	"github.com/MixinNetwork/mixin/logger"
 )
 
+var p2pLogger = logger.New("p2p")
+
 type Peer struct {
 	IdForNetwork crypto.Hash
 	Address      string
@@ -31,7 +34,9 @@ type Peer struct {
 	ops             chan struct{}
 	stn             chan struct{}
 
-	relayer        *QuicRelayer
+	listeners      []Listener
+	scores         *scoreMap
+	addresses      *addressBook
 	consumerAuth   *AuthToken
 	isRelayer      bool
 	remoteRelayers *relayersMap
@@ -54,7 +59,9 @@ func (me *Peer) IsRelayer() bool {
 }
 
 func (me *Peer) ConnectRelayer(idForNetwork crypto.Hash, addr string) {
-	if a, err := net.ResolveUDPAddr("udp", addr); err != nil {
+	if transport, host := ParseRelayerAddress(addr); transport != TransportQuic && transport != TransportTcp {
+		panic(fmt.Errorf("invalid address %s transport %s", addr, transport))
+	} else if a, err := net.ResolveTCPAddr("tcp", host); err != nil {
 		panic(fmt.Errorf("invalid address %s %s", addr, err))
 	} else if a.Port < 80 || a.IP == nil {
 		panic(fmt.Errorf("invalid address %s %d %s", addr, a.Port, a.IP))
@@ -62,23 +69,34 @@ func (me *Peer) ConnectRelayer(idForNetwork crypto.Hash, addr string) {
 	if me.isRelayer {
 		me.remoteRelayers = &relayersMap{m: make(map[crypto.Hash][]*remoteRelayer)}
 	}
+	me.addresses.dial(idForNetwork, false)
 
 	for !me.closing {
 		time.Sleep(time.Duration(config.SnapshotRoundGap))
+		if me.Banned(idForNetwork) {
+			continue
+		}
 		old := me.relayers.Get(idForNetwork)
 		if old != nil {
 			panic(fmt.Errorf("ConnectRelayer(%s) => %s", idForNetwork, old.Address))
 		}
 		relayer := NewPeer(nil, idForNetwork, addr, true)
 		err := me.connectRelayer(relayer)
-		logger.Printf("me.connectRelayer(%s, %v) => %v", me.Address, relayer, err)
+		p2pLogger.Info("me.connectRelayer", "peer", idForNetwork, "addr", addr, "error", err)
 	}
 }
 
 func (me *Peer) connectRelayer(relayer *Peer) error {
-	logger.Printf("me.connectRelayer(%s, %s) => %v", me.Address, me.IdForNetwork, relayer)
-	client, err := NewQuicConsumer(me.ctx, relayer.Address)
-	logger.Printf("NewQuicConsumer(%s) => %v %v", relayer.Address, client, err)
+	log := p2pLogger.With("peer", relayer.IdForNetwork, "addr", relayer.Address)
+	log.Info("me.connectRelayer", "local", me.Address)
+	me.addresses.book(relayer.IdForNetwork, relayer.Address)
+	defer func(start time.Time) {
+		if !me.closing {
+			me.addresses.dialed(relayer.IdForNetwork, relayer.Address, start)
+		}
+	}(time.Now())
+	client, err := NewConsumer(me.ctx, relayer.Address)
+	log.Info("NewConsumer", "error", err)
 	if err != nil {
 		return err
 	}
@@ -86,12 +104,13 @@ func (me *Peer) connectRelayer(relayer *Peer) error {
 	defer relayer.disconnect()
 
 	auth := me.handle.BuildAuthenticationMessage(relayer.IdForNetwork)
-	err = client.Send(buildAuthenticationMessage(auth))
-	logger.Printf("client.SendAuthenticationMessage(%x) => %v", auth, err)
+	_, err = client.Send(buildAuthenticationMessage(auth))
+	log.Info("client.SendAuthenticationMessage", "error", err)
 	if err != nil {
 		return err
 	}
 	me.sentMetric.handle(PeerMessageTypeAuthentication)
+	me.neighborMetric(relayer)
 	if !me.relayers.Put(relayer.IdForNetwork, relayer) {
 		panic(fmt.Errorf("ConnectRelayer(%s) => %s", relayer.IdForNetwork, relayer.Address))
 	}
@@ -100,7 +119,7 @@ func (me *Peer) connectRelayer(relayer *Peer) error {
 	go me.syncToNeighborLoop(relayer)
 	go me.loopReceiveMessage(relayer, client)
 	_, err = me.loopSendingStream(relayer, client)
-	logger.Printf("me.loopSendingStream(%s, %s) => %v", me.Address, client.RemoteAddr().String(), err)
+	log.Info("me.loopSendingStream", "remote", client.RemoteAddr().String(), "error", err)
 	return err
 }
 
@@ -122,6 +141,17 @@ func (p *Peer) disconnect() {
 	<-p.stn
 }
 
+func (me *Peer) EnableMetric() {
+	me.sentMetric.enabled = true
+	me.receivedMetric.enabled = true
+}
+
+// neighborMetric enables the metric of the neighbor if enabled for me
+func (me *Peer) neighborMetric(p *Peer) {
+	p.sentMetric.enabled = me.sentMetric.enabled
+	p.receivedMetric.enabled = me.receivedMetric.enabled
+}
+
 func (me *Peer) Metric() map[string]*MetricPool {
 	metrics := make(map[string]*MetricPool)
 	if me.sentMetric.enabled {
@@ -149,6 +179,8 @@ func NewPeer(handle SyncHandle, idForNetwork crypto.Hash, addr string, isRelayer
 		ops:            make(chan struct{}),
 		stn:            make(chan struct{}),
 		isRelayer:      isRelayer,
+		scores:         newScoreMap(handle),
+		addresses:      newAddressBook(handle),
 	}
 	peer.ctx = context.Background() // FIXME use real context
 	if handle != nil {
@@ -159,8 +191,8 @@ func NewPeer(handle SyncHandle, idForNetwork crypto.Hash, addr string, isRelayer
 
 func (me *Peer) Teardown() {
 	me.closing = true
-	if me.relayer != nil {
-		me.relayer.Close()
+	for _, l := range me.listeners {
+		l.Close()
 	}
 	close(me.highRing)
 	close(me.normalRing)
@@ -175,23 +207,42 @@ func (me *Peer) Teardown() {
 		}(p)
 	}
 	wg.Wait()
-	logger.Printf("Teardown(%s, %s)\n", me.IdForNetwork, me.Address)
+	p2pLogger.Info("Teardown", "node", me.IdForNetwork, "addr", me.Address)
 }
 
-func (me *Peer) ListenConsumers() error {
-	logger.Printf("me.ListenConsumers(%s, %s)", me.Address, me.IdForNetwork)
-	relayer, err := NewQuicRelayer(me.Address)
-	if err != nil {
-		return err
+// ListenConsumers listens on all the transports with the same port number,
+// and QUIC is used if no transport specified
+func (me *Peer) ListenConsumers(transports []string) error {
+	p2pLogger.Info("me.ListenConsumers", "node", me.IdForNetwork, "addr", me.Address, "transports", transports)
+	if len(transports) == 0 {
+		transports = []string{TransportQuic}
+	}
+	for _, t := range transports {
+		l, err := NewListener(t, me.Address)
+		if err != nil {
+			for _, l := range me.listeners {
+				l.Close()
+			}
+			return err
+		}
+		me.listeners = append(me.listeners, l)
 	}
-	me.relayer = relayer
 	me.remoteRelayers = &relayersMap{m: make(map[crypto.Hash][]*remoteRelayer)}
 
 	go func() {
+		var sharedAt time.Time
 		for !me.closing {
 			neighbors := me.Neighbors()
 			msg := me.buildConsumersMessage()
+			var relayers []byte
+			if time.Since(sharedAt) > RelayersDiscoveryPeriod {
+				relayers = me.buildRelayersMessage()
+				sharedAt = time.Now()
+			}
 			for _, p := range neighbors {
+				if relayers != nil {
+					me.offerToPeerWithCacheCheck(p, MsgPriorityNormal, &ChanMsg{nil, relayers})
+				}
 				if !p.isRelayer {
 					continue
 				}
@@ -202,20 +253,38 @@ func (me *Peer) ListenConsumers() error {
 		}
 	}()
 
+	var wg sync.WaitGroup
+	for _, l := range me.listeners {
+		wg.Add(1)
+		go func(l Listener) {
+			defer wg.Done()
+			me.acceptConsumers(l)
+		}(l)
+	}
+	wg.Wait()
+
+	p2pLogger.Info("ListenConsumers DONE", "node", me.IdForNetwork, "addr", me.Address)
+	return nil
+}
+
+func (me *Peer) acceptConsumers(l Listener) {
 	for !me.closing {
-		c, err := me.relayer.Accept(me.ctx)
-		logger.Printf("me.relayer.Accept(%s) => %v %v", me.Address, c, err)
+		c, err := l.Accept(me.ctx)
 		if err != nil {
+			p2pLogger.Info("me.relayer.Accept", "addr", me.Address, "error", err)
 			continue
 		}
 		go func(c Client) {
 			defer c.Close("authenticateNeighbor")
 
+			log := p2pLogger.With("addr", me.Address, "remote", c.RemoteAddr().String())
 			peer, err := me.authenticateNeighbor(c)
-			logger.Printf("me.authenticateNeighbor(%s, %s) => %v %v", me.Address, c.RemoteAddr().String(), peer, err)
 			if err != nil {
+				log.Info("me.authenticateNeighbor", "error", err)
 				return
 			}
+			log = log.With("peer", peer.IdForNetwork)
+			log.Info("me.authenticateNeighbor")
 			defer peer.disconnect()
 
 			old := me.consumers.Get(peer.IdForNetwork)
@@ -223,6 +292,7 @@ func (me *Peer) ListenConsumers() error {
 				old.disconnect()
 				me.consumers.Delete(old.IdForNetwork)
 			}
+			me.neighborMetric(peer)
 			if !me.consumers.Put(peer.IdForNetwork, peer) {
 				panic(peer.IdForNetwork)
 			}
@@ -231,12 +301,9 @@ func (me *Peer) ListenConsumers() error {
 			go me.syncToNeighborLoop(peer)
 			go me.loopReceiveMessage(peer, c)
 			_, err = me.loopSendingStream(peer, c)
-			logger.Printf("me.loopSendingStream(%s, %s) => %v", me.Address, c.RemoteAddr().String(), err)
+			log.Info("me.loopSendingStream", "error", err)
 		}(c)
 	}
-
-	logger.Printf("ListenConsumers(%s, %s) DONE\n", me.IdForNetwork, me.Address)
-	return nil
 }
 
 func (me *Peer) loopSendingStream(p *Peer, consumer Client) (*ChanMsg, error) {
@@ -254,10 +321,12 @@ func (me *Peer) loopSendingStream(p *Peer, consumer Client) (*ChanMsg, error) {
 		}
 
 		for _, m := range msgs {
-			err := consumer.Send(m.data)
+			start := time.Now()
+			size, err := consumer.Send(m.data)
 			if err != nil {
 				return m, fmt.Errorf("consumer.Send(%s, %d) => %v", p.Address, len(m.data), err)
 			}
+			me.recordSent(p, m.data, size, time.Since(start))
 			if m.key != nil {
 				me.snapshotsCaches.store(m.key, time.Now())
 			}
@@ -284,7 +353,8 @@ func (me *Peer) pollRingWithCache(ring chan *ChanMsg, limit int) []*ChanMsg {
 }
 
 func (me *Peer) loopReceiveMessage(peer *Peer, client Client) {
-	logger.Printf("me.loopReceiveMessage(%s, %s)", me.Address, client.RemoteAddr().String())
+	log := p2pLogger.With("peer", peer.IdForNetwork, "addr", client.RemoteAddr().String())
+	log.Info("loopReceiveMessage")
 	receive := make(chan *PeerMessage, 1024)
 	defer close(receive)
 	defer client.Close("loopReceiveMessage")
@@ -294,31 +364,57 @@ func (me *Peer) loopReceiveMessage(peer *Peer, client Client) {
 
 		for msg := range receive {
 			err := me.handlePeerMessage(peer.IdForNetwork, msg)
+			me.recordHandled(peer, time.Since(msg.receivedAt))
 			if err == nil {
 				continue
 			}
-			logger.Printf("me.handlePeerMessage(%s) => %v", peer.IdForNetwork, err)
+			var mbe *MisbehaviorError
+			if errors.As(err, &mbe) {
+				log.Debug("handlePeerMessage", "type", messageTypeName(msg.Type), "error", err)
+				me.Penalize(peer.IdForNetwork, mbe.Misbehavior)
+				continue
+			}
+			log.Info("handlePeerMessage", "type", messageTypeName(msg.Type), "error", err)
 			return
 		}
 	}()
 
+	limiter := newRateLimiter()
+	var seenAt time.Time
 	for !me.closing {
 		tm, err := client.Receive()
 		if err != nil {
-			logger.Printf("client.Receive %s %v", peer.Address, err)
+			log.Info("client.Receive", "error", err)
+			return
+		}
+		if me.Banned(peer.IdForNetwork) {
+			log.Info("peer banned")
 			return
 		}
 		msg, err := parseNetworkMessage(tm.Version, tm.Data)
 		if err != nil {
-			logger.Debugf("parseNetworkMessage %s %v", peer.Address, err)
+			log.Debug("parseNetworkMessage", "version", tm.Version, "size", len(tm.Data), "error", err)
+			me.Penalize(peer.IdForNetwork, PeerMisbehaviorMalformedMessage)
 			return
 		}
+		msg.receivedAt = time.Now()
+		msg.direct = true
 		me.receivedMetric.handle(msg.Type)
+		me.recordReceived(peer, tm)
+		if now := time.Now(); now.Sub(seenAt) > time.Minute {
+			me.addresses.seen(peer.IdForNetwork, now)
+			seenAt = now
+		}
+		if !limiter.allow(msg.Type, time.Now()) {
+			log.Debug("peer rate limited", "type", messageTypeName(msg.Type))
+			me.Penalize(peer.IdForNetwork, PeerMisbehaviorRateLimit)
+			continue
+		}
 
 		select {
 		case receive <- msg:
 		default:
-			logger.Printf("peer receive timeout %s", peer.Address)
+			log.Info("peer receive timeout", "type", messageTypeName(msg.Type))
 			return
 		}
 	}
@@ -349,6 +445,10 @@ func (me *Peer) authenticateNeighbor(client Client) (*Peer, error) {
 			auth <- err
 			return
 		}
+		if me.Banned(token.PeerId) {
+			auth <- fmt.Errorf("peer %s banned", token.PeerId)
+			return
+		}
 
 		addr := client.RemoteAddr().String()
 		peer = NewPeer(nil, token.PeerId, addr, token.IsRelayer)
@@ -378,10 +478,10 @@ func (me *Peer) offerToPeerWithCacheCheck(p *Peer, priority int, msg *ChanMsg) b
 	if me.snapshotsCaches.contains(msg.key, time.Minute) {
 		return true
 	}
-	return p.offer(priority, msg)
+	return me.offer(p, priority, msg)
 }
 
-func (p *Peer) offer(priority int, msg *ChanMsg) bool {
+func (me *Peer) offer(p *Peer, priority int, msg *ChanMsg) bool {
 	if p.closing {
 		return false
 	}
@@ -391,6 +491,7 @@ func (p *Peer) offer(priority int, msg *ChanMsg) bool {
 		case p.normalRing <- msg:
 			return true
 		default:
+			me.recordDrop(p, priority)
 			return false
 		}
 	case MsgPriorityHigh:
@@ -398,6 +499,7 @@ func (p *Peer) offer(priority int, msg *ChanMsg) bool {
 		case p.highRing <- msg:
 			return true
 		default:
+			me.recordDrop(p, priority)
 			return false
 		}
 	}
@@ -415,11 +517,11 @@ func (me *Peer) sendToPeer(to crypto.Hash, typ byte, key, data []byte, priority
 
 	nbrs := me.GetNeighbors(to)
 	for _, peer := range nbrs {
-		success := peer.offer(priority, &ChanMsg{key, data})
+		success := me.offer(peer, priority, &ChanMsg{key, data})
 		if success { // no double send for the same message to avoid errors
 			return nil
 		}
-		logger.Verbosef("peer.offer(%s) send timeout\n", peer.IdForNetwork)
+		p2pLogger.Verbose("peer.offer send timeout", "to", to, "peer", peer.IdForNetwork)
 	}
 
 	rm := me.buildRelayMessage(to, data)
@@ -438,7 +540,7 @@ func (me *Peer) sendToPeer(to crypto.Hash, typ byte, key, data []byte, priority
 		if success {
 			return nil
 		}
-		logger.Verbosef("me.offerToPeerWithCacheCheck(%s) send timeout\n", peer.IdForNetwork)
+		p2pLogger.Verbose("me.offerToPeerWithCacheCheck send timeout", "to", to, "peer", peer.IdForNetwork)
 	}
 	return nil
 }
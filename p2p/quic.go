@@ -6,10 +6,8 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
-	"encoding/binary"
 	"encoding/pem"
 	"fmt"
-	"io"
 	"math/big"
 	"net"
 	"time"
@@ -33,6 +31,7 @@ const (
 type QuicClient struct {
 	session quic.Connection
 	stream  quic.Stream
+	codec   transportCodec
 }
 
 type QuicRelayer struct {
@@ -41,7 +40,7 @@ type QuicRelayer struct {
 }
 
 func NewQuicRelayer(listenAddr string) (*QuicRelayer, error) {
-	tls := generateTLSConfig()
+	tls := generateTLSConfig("mixin-quic-peer")
 	l, err := quic.ListenAddr(listenAddr, tls, &quic.Config{
 		MaxIncomingStreams:   MaxIncomingStreams,
 		HandshakeIdleTimeout: HandshakeTimeout,
@@ -108,46 +107,15 @@ func (c *QuicClient) Receive() (*TransportMessage, error) {
 	if err != nil {
 		return nil, err
 	}
-	m := &TransportMessage{}
-	header := make([]byte, TransportMessageHeaderSize)
-	s, err := io.ReadFull(c.stream, header)
-	if err != nil {
-		return nil, err
-	}
-	if s != TransportMessageHeaderSize {
-		return nil, fmt.Errorf("quic receive invalid message header size %d", s)
-	}
-	m.Version = header[0]
-	if m.Version != TransportMessageVersion {
-		return nil, fmt.Errorf("quic receive invalid message version %d", m.Version)
-	}
-	m.Size = binary.BigEndian.Uint32(header[2:])
-	if m.Size > TransportMessageMaxSize {
-		return nil, fmt.Errorf("quic receive invalid message size %d", m.Size)
-	}
-
-	m.Data = make([]byte, m.Size)
-	_, err = io.ReadFull(c.stream, m.Data)
-	return m, err
+	return c.codec.read(TransportQuic, c.stream)
 }
 
-func (c *QuicClient) Send(data []byte) error {
-	if l := len(data); l < 1 || l > TransportMessageMaxSize {
-		return fmt.Errorf("quic send invalid message size %d", l)
-	}
-
+func (c *QuicClient) Send(data []byte) (int, error) {
 	err := c.stream.SetWriteDeadline(time.Now().Add(WriteDeadline))
 	if err != nil {
-		return err
-	}
-	header := []byte{TransportMessageVersion, 0, 0, 0, 0, 0}
-	binary.BigEndian.PutUint32(header[2:], uint32(len(data)))
-	_, err = c.stream.Write(header)
-	if err != nil {
-		return err
+		return 0, err
 	}
-	_, err = c.stream.Write(data)
-	return err
+	return c.codec.write(TransportQuic, c.stream, data)
 }
 
 func (c *QuicClient) Close(code string) error {
@@ -155,7 +123,7 @@ func (c *QuicClient) Close(code string) error {
 	return c.session.CloseWithError(0, code)
 }
 
-func generateTLSConfig() *tls.Config {
+func generateTLSConfig(proto string) *tls.Config {
 	key, err := rsa.GenerateKey(crypto.RandReader(), 2048)
 	if err != nil {
 		panic(err)
@@ -181,6 +149,6 @@ func generateTLSConfig() *tls.Config {
 	}
 	return &tls.Config{
 		Certificates: []tls.Certificate{tlsCert},
-		NextProtos:   []string{"mixin-quic-peer"},
+		NextProtos:   []string{proto},
 	}
 }
@@ -1,6 +1,7 @@
 package p2p
 
 import (
+	"bytes"
 	"context"
 	"testing"
 
@@ -16,6 +17,51 @@ func TestQuic(t *testing.T) {
 	require.NotNil(serverTrans)
 	defer serverTrans.Close()
 
+	client, err := NewQuicConsumer(context.Background(), addr)
+	require.Nil(err)
+	require.NotNil(client)
+	testTransport(require, serverTrans, client)
+}
+
+func TestTcp(t *testing.T) {
+	require := require.New(t)
+
+	addr := "127.0.0.1:7000"
+	serverTrans, err := NewTcpRelayer(addr)
+	require.Nil(err)
+	require.NotNil(serverTrans)
+	defer serverTrans.Close()
+
+	client, err := NewTcpConsumer(context.Background(), addr)
+	require.Nil(err)
+	require.NotNil(client)
+	testTransport(require, serverTrans, client)
+}
+
+func TestListeners(t *testing.T) {
+	require := require.New(t)
+
+	addr := "127.0.0.1:7001"
+	quic, err := NewListener(TransportQuic, addr)
+	require.Nil(err)
+	defer quic.Close()
+	tcp, err := NewListener(TransportTcp, addr)
+	require.Nil(err)
+	defer tcp.Close()
+	_, err = NewListener("udp", addr)
+	require.ErrorContains(err, "invalid transport udp")
+
+	client, err := NewConsumer(context.Background(), addr)
+	require.Nil(err)
+	require.IsType(&QuicClient{}, client)
+	testTransport(require, quic, client)
+	client, err = NewConsumer(context.Background(), "tcp://"+addr)
+	require.Nil(err)
+	require.IsType(&TcpClient{}, client)
+	testTransport(require, tcp, client)
+}
+
+func testTransport(require *require.Assertions, serverTrans Listener, client Client) {
 	wait := make(chan struct{})
 	go func() {
 		server, err := serverTrans.Accept(context.Background())
@@ -24,13 +70,73 @@ func TestQuic(t *testing.T) {
 		msg, err := server.Receive()
 		require.Nil(err)
 		require.Equal("hello mixin", string(msg.Data))
+		_, err = server.Send([]byte("hello relayer"))
+		require.Nil(err)
+		large := bytes.Repeat([]byte("hello compression"), 1024)
+		size, err := server.Send(large)
+		require.Nil(err)
+		require.Less(size, len(large))
 		wait <- struct{}{}
 	}()
 
-	client, err := NewQuicConsumer(context.Background(), addr)
+	_, err := client.Send([]byte("hello mixin"))
 	require.Nil(err)
-	require.NotNil(client)
-	err = client.Send([]byte("hello mixin"))
+	msg, err := client.Receive()
 	require.Nil(err)
+	require.Equal(uint8(TransportMessageVersion), msg.Version)
+	require.Equal("hello relayer", string(msg.Data))
+	msg, err = client.Receive()
+	require.Nil(err)
+	require.Equal(uint8(TransportMessageVersionZstd), msg.Version)
+	require.Less(int(msg.Size), len(msg.Data))
+	require.Equal(bytes.Repeat([]byte("hello compression"), 1024), msg.Data)
+	_, err = client.Send(nil)
+	require.ErrorContains(err, "send invalid message size 0")
 	<-wait
+	client.Close("test")
+}
+
+func TestTransportCompression(t *testing.T) {
+	require := require.New(t)
+
+	large := bytes.Repeat([]byte("hello compression"), 1024)
+	old := []byte{TransportMessageVersion, 0, 0, 0, 0, 5, 'h', 'e', 'l', 'l', 'o'}
+	var tc transportCodec
+	msg, err := tc.read("test", bytes.NewReader(old))
+	require.Nil(err)
+	require.Equal("hello", string(msg.Data))
+	require.False(tc.zstd.Load())
+
+	var buf bytes.Buffer
+	size, err := tc.write("test", &buf, large)
+	require.Nil(err)
+	require.Equal(len(large), size)
+	require.Equal(byte(TransportMessageVersion), buf.Bytes()[0])
+	require.Equal(byte(TransportFeatureZstd), buf.Bytes()[1])
+
+	var remote transportCodec
+	msg, err = remote.read("test", &buf)
+	require.Nil(err)
+	require.Equal(large, msg.Data)
+	require.True(remote.zstd.Load())
+	size, err = remote.write("test", &buf, large)
+	require.Nil(err)
+	require.Less(size, len(large))
+	require.Equal(byte(TransportMessageVersionZstd), buf.Bytes()[0])
+	size, err = remote.write("test", &buf, []byte("hello"))
+	require.Nil(err)
+	require.Equal(5, size)
+
+	msg, err = tc.read("test", &buf)
+	require.Nil(err)
+	require.Equal(uint8(TransportMessageVersionZstd), msg.Version)
+	require.Equal(large, msg.Data)
+	msg, err = tc.read("test", &buf)
+	require.Nil(err)
+	require.Equal(uint8(TransportMessageVersion), msg.Version)
+	require.Equal("hello", string(msg.Data))
+
+	invalid := []byte{TransportMessageVersionZstd, 0, 0, 0, 0, 3, 1, 2, 3}
+	_, err = tc.read("test", bytes.NewReader(invalid))
+	require.ErrorContains(err, "receive invalid zstd message")
 }
@@ -0,0 +1,262 @@
+package p2p
+
+import (
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const (
+	PeerMisbehaviorRateLimit        = "rate-limit"
+	PeerMisbehaviorMalformedMessage = "malformed-message"
+	PeerMisbehaviorInvalidSignature = "invalid-signature"
+	PeerMisbehaviorBogusCosi        = "bogus-cosi"
+
+	PeerScoreBan      = -100
+	PeerScoreRecovery = time.Minute
+	PeerBanDuration   = time.Hour
+
+	peerScoresLimit = 4096
+)
+
+var peerPenalties = map[string]int{
+	PeerMisbehaviorRateLimit:        1,
+	PeerMisbehaviorMalformedMessage: 25,
+	PeerMisbehaviorInvalidSignature: 20,
+	PeerMisbehaviorBogusCosi:        10,
+}
+
+type rateLimit struct {
+	rate  float64
+	burst float64
+}
+
+// the limits are generous enough for the snapshots sync, and only to stop a
+// neighbor flooding the relayer
+var messageRateLimits = map[uint8]rateLimit{
+	PeerMessageTypePing:               {1, 10},
+	PeerMessageTypeAuthentication:     {1, 5},
+	PeerMessageTypeGraph:              {5, 20},
+	PeerMessageTypeConsumers:          {5, 20},
+	PeerMessageTypeRelayers:           {1, 5},
+	PeerMessageTypeTransactionRequest: {1000, 5000},
+	PeerMessageTypeTransaction:        {1000, 5000},
+	PeerMessageTypeRelay:              {10000, 50000},
+}
+
+var messageRateLimitDefault = rateLimit{5000, 20000}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter is the token buckets of each message type for a neighbor, it
+// is only used by the receiving loop so no lock required
+type rateLimiter struct {
+	buckets map[uint8]*tokenBucket
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: make(map[uint8]*tokenBucket)}
+}
+
+func (rl *rateLimiter) allow(typ uint8, now time.Time) bool {
+	limit, found := messageRateLimits[typ]
+	if !found {
+		limit = messageRateLimitDefault
+	}
+	b := rl.buckets[typ]
+	if b == nil {
+		b = &tokenBucket{tokens: limit.burst, last: now}
+		rl.buckets[typ] = b
+	}
+	b.tokens = min(limit.burst, b.tokens+now.Sub(b.last).Seconds()*limit.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens = b.tokens - 1
+	return true
+}
+
+// PeerScore starts from zero, decreases by the penalties and recovers one
+// point each minute, the peer is banned for an hour once the score drops to
+// the ban threshold, and the ban persists across restarts
+type PeerScore struct {
+	Score       int            `json:"score"`
+	Penalties   map[string]int `json:"penalties"`
+	BannedUntil time.Time      `json:"banned_until"`
+
+	updatedAt time.Time
+}
+
+type scoreMap struct {
+	sync.Mutex
+	m      map[crypto.Hash]*PeerScore
+	handle SyncHandle
+}
+
+func newScoreMap(handle SyncHandle) *scoreMap {
+	sm := &scoreMap{m: make(map[crypto.Hash]*PeerScore), handle: handle}
+	if handle == nil {
+		return sm
+	}
+	bans, err := handle.ReadPeerBans()
+	if err != nil {
+		panic(err)
+	}
+	now := time.Now()
+	for id, until := range bans {
+		bu := time.Unix(0, int64(until))
+		if bu.Before(now) {
+			continue
+		}
+		sm.m[id] = &PeerScore{Penalties: make(map[string]int), BannedUntil: bu, updatedAt: now}
+	}
+	return sm
+}
+
+func (sm *scoreMap) recover(id crypto.Hash, now time.Time) *PeerScore {
+	s := sm.m[id]
+	if s == nil {
+		s = &PeerScore{Penalties: make(map[string]int), updatedAt: now}
+		sm.m[id] = s
+	}
+	if !s.BannedUntil.IsZero() && !s.BannedUntil.After(now) {
+		s.Score, s.BannedUntil = 0, time.Time{}
+		clear(s.Penalties)
+		if sm.handle != nil {
+			err := sm.handle.WritePeerBan(id, 0)
+			p2pLogger.Info("WritePeerBan", "peer", id, "until", 0, "error", err)
+		}
+	}
+	if points := int(now.Sub(s.updatedAt) / PeerScoreRecovery); points > 0 {
+		s.Score = min(0, s.Score+points)
+		s.updatedAt = s.updatedAt.Add(time.Duration(points) * PeerScoreRecovery)
+	}
+	return s
+}
+
+// penalize returns true if the peer is banned
+func (sm *scoreMap) penalize(id crypto.Hash, misbehavior string, now time.Time) bool {
+	sm.Lock()
+	defer sm.Unlock()
+
+	if len(sm.m) >= peerScoresLimit {
+		sm.prune(now)
+	}
+	s := sm.recover(id, now)
+	if !s.BannedUntil.IsZero() {
+		return true
+	}
+	s.Score = s.Score - peerPenalties[misbehavior]
+	s.Penalties[misbehavior] = s.Penalties[misbehavior] + 1
+	if s.Score > PeerScoreBan {
+		return false
+	}
+	s.BannedUntil = now.Add(PeerBanDuration)
+	if sm.handle != nil {
+		err := sm.handle.WritePeerBan(id, uint64(s.BannedUntil.UnixNano()))
+		p2pLogger.Info("WritePeerBan", "peer", id, "until", s.BannedUntil, "error", err)
+	}
+	return true
+}
+
+// prune removes the peers recovered to zero score
+func (sm *scoreMap) prune(now time.Time) {
+	for id := range sm.m {
+		s := sm.recover(id, now)
+		if s.Score == 0 && s.BannedUntil.IsZero() {
+			delete(sm.m, id)
+		}
+	}
+}
+
+func (sm *scoreMap) banned(id crypto.Hash, now time.Time) bool {
+	sm.Lock()
+	defer sm.Unlock()
+
+	if sm.m[id] == nil {
+		return false
+	}
+	return !sm.recover(id, now).BannedUntil.IsZero()
+}
+
+func (sm *scoreMap) get(id crypto.Hash, now time.Time) *PeerScore {
+	sm.Lock()
+	defer sm.Unlock()
+
+	if sm.m[id] == nil {
+		return &PeerScore{Penalties: make(map[string]int)}
+	}
+	s := *sm.recover(id, now)
+	s.Penalties = maps.Clone(s.Penalties)
+	return &s
+}
+
+func (sm *scoreMap) bans(now time.Time) map[crypto.Hash]*PeerScore {
+	sm.Lock()
+	defer sm.Unlock()
+
+	bans := make(map[crypto.Hash]*PeerScore)
+	for id := range sm.m {
+		s := *sm.recover(id, now)
+		if s.BannedUntil.IsZero() {
+			continue
+		}
+		s.Penalties = maps.Clone(s.Penalties)
+		bans[id] = &s
+	}
+	return bans
+}
+
+// MisbehaviorError is returned by the sync handle for the invalid message
+// content, and only the neighbor sent the message itself is penalized, because
+// the origin of a relayed message is not authenticated and could be forged
+type MisbehaviorError struct {
+	Misbehavior string
+	Err         error
+}
+
+func NewMisbehaviorError(misbehavior string, format string, args ...any) error {
+	return &MisbehaviorError{Misbehavior: misbehavior, Err: fmt.Errorf(format, args...)}
+}
+
+func (e *MisbehaviorError) Error() string {
+	return fmt.Sprintf("%s %v", e.Misbehavior, e.Err)
+}
+
+func (e *MisbehaviorError) Unwrap() error {
+	return e.Err
+}
+
+// Penalize lowers the score of the peer for the misbehavior, and the peer
+// will be disconnected and refused if banned
+func (me *Peer) Penalize(id crypto.Hash, misbehavior string) {
+	if me.penalize(id, misbehavior) {
+		p2pLogger.Info("me.Penalize BANNED", "peer", id, "misbehavior", misbehavior)
+	}
+}
+
+func (me *Peer) penalize(id crypto.Hash, misbehavior string) bool {
+	if id == me.IdForNetwork {
+		return false
+	}
+	return me.scores.penalize(id, misbehavior, time.Now())
+}
+
+func (me *Peer) Banned(id crypto.Hash) bool {
+	return me.scores.banned(id, time.Now())
+}
+
+func (me *Peer) Score(id crypto.Hash) *PeerScore {
+	return me.scores.get(id, time.Now())
+}
+
+func (me *Peer) BannedPeers() map[crypto.Hash]*PeerScore {
+	return me.scores.bans(time.Now())
+}
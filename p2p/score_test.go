@@ -0,0 +1,117 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	require := require.New(t)
+
+	now := time.Now()
+	rl := newRateLimiter()
+	for range 5 {
+		require.True(rl.allow(PeerMessageTypeAuthentication, now))
+	}
+	require.False(rl.allow(PeerMessageTypeAuthentication, now))
+	require.True(rl.allow(PeerMessageTypePing, now))
+
+	now = now.Add(time.Second)
+	require.True(rl.allow(PeerMessageTypeAuthentication, now))
+	require.False(rl.allow(PeerMessageTypeAuthentication, now))
+	now = now.Add(time.Hour)
+	for range 5 {
+		require.True(rl.allow(PeerMessageTypeAuthentication, now))
+	}
+	require.False(rl.allow(PeerMessageTypeAuthentication, now))
+}
+
+func TestPeerScore(t *testing.T) {
+	require := require.New(t)
+
+	now := time.Now()
+	sm := newScoreMap(nil)
+	id := crypto.Blake3Hash([]byte("peer"))
+	require.False(sm.banned(id, now))
+	require.Equal(0, sm.get(id, now).Score)
+
+	require.False(sm.penalize(id, PeerMisbehaviorInvalidSignature, now))
+	require.False(sm.penalize(id, PeerMisbehaviorRateLimit, now))
+	s := sm.get(id, now)
+	require.Equal(-21, s.Score)
+	require.Equal(1, s.Penalties[PeerMisbehaviorInvalidSignature])
+	require.Equal(1, s.Penalties[PeerMisbehaviorRateLimit])
+
+	now = now.Add(10 * PeerScoreRecovery)
+	require.Equal(-11, sm.get(id, now).Score)
+	now = now.Add(time.Hour)
+	require.Equal(0, sm.get(id, now).Score)
+
+	for range 3 {
+		require.False(sm.penalize(id, PeerMisbehaviorMalformedMessage, now))
+	}
+	require.True(sm.penalize(id, PeerMisbehaviorMalformedMessage, now))
+	require.True(sm.banned(id, now))
+	require.Len(sm.bans(now), 1)
+	require.True(sm.penalize(id, PeerMisbehaviorRateLimit, now))
+	require.Equal(4, sm.get(id, now).Penalties[PeerMisbehaviorMalformedMessage])
+
+	now = now.Add(PeerBanDuration)
+	require.False(sm.banned(id, now))
+	require.Len(sm.bans(now), 0)
+	s = sm.get(id, now)
+	require.Equal(0, s.Score)
+	require.Len(s.Penalties, 0)
+}
+
+func TestRelayMisbehavior(t *testing.T) {
+	require := require.New(t)
+
+	me := NewPeer(nil, crypto.Blake3Hash([]byte("me")), "127.0.0.1:7001", true)
+	handle := &testRelayHandle{}
+	me.handle = handle
+	honest := crypto.Blake3Hash([]byte("honest"))
+	relayer := crypto.Blake3Hash([]byte("relayer"))
+	relay := func(from crypto.Hash, payload []byte) *PeerMessage {
+		data := append([]byte{PeerMessageTypeRelay}, from[:]...)
+		data = append(data, me.IdForNetwork[:]...)
+		data = append(data, payload...)
+		return &PeerMessage{Type: PeerMessageTypeRelay, Data: data, version: TransportMessageVersion}
+	}
+
+	malformed := []byte{PeerMessageTypeCommitments, 0}
+	err := me.relayOrHandlePeerMessage(relayer, relay(honest, malformed))
+	require.Nil(err)
+	err = me.relayOrHandlePeerMessage(relayer, relay(relayer, malformed))
+	var mbe *MisbehaviorError
+	require.ErrorAs(err, &mbe)
+	require.Equal(PeerMisbehaviorMalformedMessage, mbe.Misbehavior)
+
+	commitments := buildCommitmentsMessage(handle, []*crypto.Key{{}})
+	err = me.relayOrHandlePeerMessage(relayer, relay(honest, commitments))
+	require.Nil(err)
+	require.Equal([]crypto.Hash{honest}, handle.peers)
+	err = me.relayOrHandlePeerMessage(relayer, relay(relayer, commitments))
+	require.ErrorAs(err, &mbe)
+	require.Equal(PeerMisbehaviorInvalidSignature, mbe.Misbehavior)
+	require.Equal([]crypto.Hash{honest, relayer}, handle.peers)
+	require.Equal(0, me.Score(honest).Score)
+	require.Equal(0, me.Score(relayer).Score)
+}
+
+type testRelayHandle struct {
+	SyncHandle
+	peers []crypto.Hash
+}
+
+func (h *testRelayHandle) SignData(data []byte) crypto.Signature {
+	return crypto.Signature{}
+}
+
+func (h *testRelayHandle) CosiQueueExternalCommitments(peerId crypto.Hash, commitments []*crypto.Key, data []byte, sig *crypto.Signature) error {
+	h.peers = append(h.peers, peerId)
+	return NewMisbehaviorError(PeerMisbehaviorInvalidSignature, "invalid commitments signature %s", peerId)
+}
@@ -7,7 +7,6 @@ import (
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
-	"github.com/MixinNetwork/mixin/logger"
 )
 
 func (me *Peer) cacheReadSnapshotsForNodeRound(nodeId crypto.Hash, number uint64) ([]*common.SnapshotWithTopologicalOrder, error) {
@@ -21,6 +20,7 @@ func (me *Peer) cacheReadSnapshotsSinceTopology(offset, limit uint64) ([]*common
 // FIXME this could result in a very small topology due to already removed node
 // and sync to neighbor since this offset will take substantial time
 func (me *Peer) compareRoundGraphAndGetTopologicalOffset(p *Peer, local, remote []*SyncPoint) (uint64, error) {
+	log := p2pLogger.With("peer", p.IdForNetwork)
 	remoteFilter := make(map[crypto.Hash]*SyncPoint)
 	for _, p := range remote {
 		remoteFilter[p.NodeId] = p
@@ -34,14 +34,15 @@ func (me *Peer) compareRoundGraphAndGetTopologicalOffset(p *Peer, local, remote
 			continue
 		}
 		number := r.Number + 2 // because the node may be stale or removed, and with cache
-		logger.Verbosef("network.sync compareRoundGraphAndGetTopologicalOffset %s try %s:%d\n", p.IdForNetwork, l.NodeId, number)
+		log.Verbose("network.sync compareRoundGraphAndGetTopologicalOffset try", "chain", l.NodeId, "round", number)
 
 		ss, err := me.cacheReadSnapshotsForNodeRound(l.NodeId, number)
 		if err != nil {
 			return offset, err
 		}
 		if len(ss) == 0 {
-			logger.Verbosef("network.sync compareRoundGraphAndGetTopologicalOffset %s local round empty %s:%d:%d\n", p.IdForNetwork, l.NodeId, number, l.Number)
+			log.Verbose("network.sync compareRoundGraphAndGetTopologicalOffset local round empty",
+				"chain", l.NodeId, "round", number, "local", l.Number)
 			continue
 		}
 		topo := ss[0].TopologicalOrder
@@ -53,7 +54,7 @@ func (me *Peer) compareRoundGraphAndGetTopologicalOffset(p *Peer, local, remote
 }
 
 func (me *Peer) syncToNeighborSince(graph map[crypto.Hash]*SyncPoint, p *Peer, offset uint64) (uint64, error) {
-	logger.Verbosef("network.sync syncToNeighborSince %s %d\n", p.IdForNetwork, offset)
+	p2pLogger.Verbose("network.sync syncToNeighborSince", "peer", p.IdForNetwork, "offset", offset)
 	limit := 200
 	snapshots, err := me.cacheReadSnapshotsSinceTopology(offset, uint64(limit))
 	if err != nil {
@@ -95,13 +96,14 @@ func (me *Peer) syncHeadRoundToRemote(local, remote map[crypto.Hash]*SyncPoint,
 	if remoteFinal > localFinal {
 		return
 	}
-	logger.Verbosef("network.sync syncHeadRoundToRemote %s %s:%d\n", p.IdForNetwork, nodeId, remoteFinal)
+	log := p2pLogger.With("peer", p.IdForNetwork, "chain", nodeId)
+	log.Verbose("network.sync syncHeadRoundToRemote", "round", remoteFinal)
 	for i := remoteFinal; i <= remoteFinal+config.SnapshotReferenceThreshold+2; i++ {
 		ss, _ := me.cacheReadSnapshotsForNodeRound(nodeId, i)
 		for _, s := range ss {
 			err := me.SendSnapshotFinalizationMessage(p.IdForNetwork, s.Snapshot)
 			if err != nil {
-				logger.Verbosef("network.sync SendSnapshotFinalizationMessage %s %v\n", p.IdForNetwork, err)
+				log.Verbose("network.sync SendSnapshotFinalizationMessage", "snapshot", s.Snapshot.Hash, "error", err)
 			}
 		}
 	}
@@ -109,10 +111,11 @@ func (me *Peer) syncHeadRoundToRemote(local, remote map[crypto.Hash]*SyncPoint,
 
 func (me *Peer) syncToNeighborLoop(p *Peer) {
 	defer close(p.stn)
+	log := p2pLogger.With("peer", p.IdForNetwork)
 
 	for !me.closing && !p.closing {
 		graph, offset := me.getSyncPointOffset(p)
-		logger.Verbosef("network.sync syncToNeighborLoop getSyncPointOffset %s %d %v\n", p.IdForNetwork, offset, graph != nil)
+		log.Verbose("network.sync syncToNeighborLoop getSyncPointOffset", "offset", offset, "graph", graph != nil)
 		if graph == nil {
 			time.Sleep(time.Duration(config.SnapshotRoundGap))
 			continue
@@ -131,7 +134,7 @@ func (me *Peer) syncToNeighborLoop(p *Peer) {
 		for !me.closing && !p.closing && offset > 0 {
 			off, err := me.syncToNeighborSince(graph, p, offset)
 			if err != nil {
-				logger.Verbosef("network.sync syncToNeighborLoop syncToNeighborSince %s %d DONE with %s", p.IdForNetwork, offset, err)
+				log.Verbose("network.sync syncToNeighborLoop syncToNeighborSince DONE", "offset", offset, "error", err)
 				break
 			}
 			offset = off
@@ -159,7 +162,7 @@ func (me *Peer) getSyncPointOffset(p *Peer) (map[crypto.Hash]*SyncPoint, uint64)
 		}
 		off, err := me.compareRoundGraphAndGetTopologicalOffset(p, me.handle.BuildGraph(), g)
 		if err != nil {
-			logger.Verbosef("network.sync compareRoundGraphAndGetTopologicalOffset %s error %v\n", p.IdForNetwork, err)
+			p2pLogger.Verbose("network.sync compareRoundGraphAndGetTopologicalOffset", "peer", p.IdForNetwork, "error", err)
 		}
 		if off > 0 {
 			offset = off
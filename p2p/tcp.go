@@ -0,0 +1,92 @@
+package p2p
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+)
+
+// TcpClient frames the messages the same as the QUIC stream over TLS, for
+// the networks which throttle or drop UDP
+type TcpClient struct {
+	conn  *tls.Conn
+	codec transportCodec
+}
+
+type TcpRelayer struct {
+	addr     string
+	listener net.Listener
+}
+
+func NewTcpRelayer(listenAddr string) (*TcpRelayer, error) {
+	l, err := tls.Listen("tcp", listenAddr, generateTLSConfig("mixin-tcp-peer"))
+	if err != nil {
+		return nil, err
+	}
+	return &TcpRelayer{
+		addr:     listenAddr,
+		listener: l,
+	}, nil
+}
+
+func NewTcpConsumer(ctx context.Context, relayer string) (*TcpClient, error) {
+	d := &net.Dialer{Timeout: HandshakeTimeout, KeepAlive: IdleTimeout / 2}
+	conn, err := d.DialContext(ctx, "tcp", relayer)
+	if err != nil {
+		return nil, fmt.Errorf("net.Dial(%s) => %v", relayer, err)
+	}
+	// the handshake is done by the first send or receive, and the deadline
+	// limits the handshake before the send or receive deadline set
+	err = conn.SetDeadline(time.Now().Add(HandshakeTimeout))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &TcpClient{conn: tls.Client(conn, &tls.Config{
+		InsecureSkipVerify: true,
+		NextProtos:         []string{"mixin-tcp-peer"},
+	})}, nil
+}
+
+func (t *TcpRelayer) Close() error {
+	return t.listener.Close()
+}
+
+func (t *TcpRelayer) Accept(ctx context.Context) (Client, error) {
+	conn, err := t.listener.Accept()
+	if err != nil {
+		return nil, fmt.Errorf("tls.Accept() => %v", err)
+	}
+	err = conn.SetDeadline(time.Now().Add(HandshakeTimeout))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &TcpClient{conn: conn.(*tls.Conn)}, nil
+}
+
+func (c *TcpClient) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *TcpClient) Receive() (*TransportMessage, error) {
+	err := c.conn.SetReadDeadline(time.Now().Add(ReadDeadline))
+	if err != nil {
+		return nil, err
+	}
+	return c.codec.read(TransportTcp, c.conn)
+}
+
+func (c *TcpClient) Send(data []byte) (int, error) {
+	err := c.conn.SetWriteDeadline(time.Now().Add(WriteDeadline))
+	if err != nil {
+		return 0, err
+	}
+	return c.codec.write(TransportTcp, c.conn, data)
+}
+
+func (c *TcpClient) Close(code string) error {
+	return c.conn.Close()
+}
@@ -2,25 +2,46 @@ package p2p
 
 import (
 	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
 	"net"
+	"strings"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/zstd"
 )
 
 const (
-	TransportMessageVersion    = 2
-	TransportMessageMaxSize    = 32 * 1024 * 1024
-	TransportMessageHeaderSize = 6
+	TransportMessageVersion     = 2
+	TransportMessageVersionZstd = 3
+	TransportMessageMaxSize     = 32 * 1024 * 1024
+	TransportMessageHeaderSize  = 6
+
+	// the second header byte is ignored by the version 2 peers, so it is
+	// used to tell the remote the features supported
+	TransportFeatureZstd = 1
+
+	TransportCompressionThreshold = 4 * 1024
+
+	TransportQuic = "quic"
+	TransportTcp  = "tcp"
 )
 
+// TransportMessage is the message received, the size is on the wire, and the
+// data is decompressed if the version is zstd
 type TransportMessage struct {
 	Version uint8
 	Size    uint32
 	Data    []byte
 }
 
+// Client sends the data and returns the size on the wire, which is smaller
+// than the data if compressed
 type Client interface {
 	RemoteAddr() net.Addr
 	Receive() (*TransportMessage, error)
-	Send([]byte) error
+	Send([]byte) (int, error)
 	Close(string) error
 }
 
@@ -30,3 +51,111 @@ type Transport interface {
 	Accept(ctx context.Context) (Client, error)
 	Close() error
 }
+
+// Listener accepts the consumers of a relayer, a relayer could listen on
+// multiple transports at the same time
+type Listener interface {
+	Accept(ctx context.Context) (Client, error)
+	Close() error
+}
+
+func NewListener(transport, addr string) (Listener, error) {
+	switch transport {
+	case TransportQuic:
+		return NewQuicRelayer(addr)
+	case TransportTcp:
+		return NewTcpRelayer(addr)
+	}
+	return nil, fmt.Errorf("invalid transport %s", transport)
+}
+
+// NewConsumer connects the relayer address with the transport prefix like
+// tcp://seed.mixin.dev:5850, and QUIC is used if no prefix
+func NewConsumer(ctx context.Context, relayer string) (Client, error) {
+	transport, addr := ParseRelayerAddress(relayer)
+	switch transport {
+	case TransportQuic:
+		return NewQuicConsumer(ctx, addr)
+	case TransportTcp:
+		return NewTcpConsumer(ctx, addr)
+	}
+	return nil, fmt.Errorf("invalid transport %s", transport)
+}
+
+func ParseRelayerAddress(relayer string) (string, string) {
+	transport, addr, found := strings.Cut(relayer, "://")
+	if !found {
+		return TransportQuic, relayer
+	}
+	return transport, addr
+}
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest))
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(TransportMessageMaxSize))
+)
+
+// transportCodec frames the messages of a connection, every message has the
+// zstd feature flag, and the data larger than the threshold is compressed
+// only after any message with the flag received, so the version 2 peers
+// always receive the version 2 messages
+type transportCodec struct {
+	zstd atomic.Bool
+}
+
+func (tc *transportCodec) read(transport string, r io.Reader) (*TransportMessage, error) {
+	m := &TransportMessage{}
+	header := make([]byte, TransportMessageHeaderSize)
+	s, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, err
+	}
+	if s != TransportMessageHeaderSize {
+		return nil, fmt.Errorf("%s receive invalid message header size %d", transport, s)
+	}
+	m.Version = header[0]
+	if m.Version != TransportMessageVersion && m.Version != TransportMessageVersionZstd {
+		return nil, fmt.Errorf("%s receive invalid message version %d", transport, m.Version)
+	}
+	if header[1]&TransportFeatureZstd != 0 {
+		tc.zstd.Store(true)
+	}
+	m.Size = binary.BigEndian.Uint32(header[2:])
+	if m.Size > TransportMessageMaxSize {
+		return nil, fmt.Errorf("%s receive invalid message size %d", transport, m.Size)
+	}
+
+	m.Data = make([]byte, m.Size)
+	_, err = io.ReadFull(r, m.Data)
+	if err != nil || m.Version != TransportMessageVersionZstd {
+		return m, err
+	}
+	m.Data, err = zstdDecoder.DecodeAll(m.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s receive invalid zstd message %v", transport, err)
+	}
+	if len(m.Data) > TransportMessageMaxSize {
+		return nil, fmt.Errorf("%s receive invalid message size %d", transport, len(m.Data))
+	}
+	return m, nil
+}
+
+func (tc *transportCodec) write(transport string, w io.Writer, data []byte) (int, error) {
+	if l := len(data); l < 1 || l > TransportMessageMaxSize {
+		return 0, fmt.Errorf("%s send invalid message size %d", transport, l)
+	}
+	header := []byte{TransportMessageVersion, TransportFeatureZstd, 0, 0, 0, 0}
+	if len(data) > TransportCompressionThreshold && tc.zstd.Load() {
+		compressed := zstdEncoder.EncodeAll(data, nil)
+		if len(compressed) < len(data) {
+			header[0], data = TransportMessageVersionZstd, compressed
+		}
+	}
+	binary.BigEndian.PutUint32(header[2:], uint32(len(data)))
+	_, err := w.Write(header)
+	if err != nil {
+		return 0, err
+	}
+	_, err = w.Write(data)
+	return len(data), err
+}
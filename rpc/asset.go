@@ -0,0 +1,24 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+type Asset struct {
+	Id       crypto.Hash    `json:"id"`
+	Chain    crypto.Hash    `json:"chain"`
+	AssetKey string         `json:"asset_key"`
+	Balance  common.Integer `json:"balance"`
+}
+
+func (c *Client) GetAsset(ctx context.Context, id crypto.Hash) (*Asset, error) {
+	var asset Asset
+	ok, err := c.callInto(ctx, "getasset", []any{id.String()}, &asset)
+	if !ok {
+		return nil, err
+	}
+	return &asset, nil
+}
@@ -2,23 +2,148 @@ package rpc
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sync/atomic"
 	"time"
+
+	"github.com/MixinNetwork/mixin/rpc/internal/server"
 )
 
-func CallMixinRPC(node, method string, params []any) ([]byte, error) {
-	client := &http.Client{Timeout: 20 * time.Second}
+const (
+	JSONRPCVersion = server.JSONRPCVersion
+
+	ErrorCodeServer = server.ErrorCodeServer
+
+	defaultClientTimeout = 20 * time.Second
+	defaultClientRetries = 2
+	defaultClientBackoff = 500 * time.Millisecond
+)
+
+// Error is the same error rendered by the kernel RPC server
+type Error = server.Error
+
+// Client calls the kernel RPC with failover among all the endpoints, an
+// endpoint is only skipped on transport or HTTP failures, an error returned
+// by the kernel itself is final and never retried on other endpoints.
+type Client struct {
+	endpoints []string
+	current   atomic.Int64
+	http      *http.Client
+	retries   int
+	backoff   time.Duration
+	jsonrpc   bool
+}
+
+func NewClient(endpoints ...string) *Client {
+	if len(endpoints) == 0 {
+		panic("empty rpc endpoints")
+	}
+	return &Client{
+		endpoints: endpoints,
+		http:      &http.Client{Timeout: defaultClientTimeout},
+		retries:   defaultClientRetries,
+		backoff:   defaultClientBackoff,
+	}
+}
+
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.http.Timeout = timeout
+}
+
+func (c *Client) SetRetries(retries int, backoff time.Duration) {
+	c.retries = max(retries, 0)
+	c.backoff = backoff
+}
+
+func (c *Client) UseJSONRPC(enabled bool) {
+	c.jsonrpc = enabled
+}
+
+func (c *Client) Endpoints() []string {
+	return c.endpoints
+}
+
+func (c *Client) Call(ctx context.Context, method string, params []any) ([]byte, error) {
+	if params == nil {
+		params = []any{}
+	}
+	var lastErr error
+	for i := 0; i <= c.retries; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(c.backoff * time.Duration(i)):
+			}
+		}
+		start := c.current.Load()
+		for j := range int64(len(c.endpoints)) {
+			index := (start + j) % int64(len(c.endpoints))
+			node := c.endpoints[index]
+			data, err := callMixinRPC(ctx, c.http, node, method, params, c.buildCall(method, params))
+			var re *Error
+			if err == nil || errors.As(err, &re) {
+				c.current.Store(index)
+				return data, err
+			}
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			lastErr = err
+		}
+	}
+	return nil, lastErr
+}
 
-	body, err := json.Marshal(map[string]any{
+func (c *Client) buildCall(method string, params []any) map[string]any {
+	call := map[string]any{
 		"method": method,
 		"params": params,
-	})
+	}
+	if c.jsonrpc {
+		call["jsonrpc"] = JSONRPCVersion
+		call["id"] = fmt.Sprint(time.Now().UnixNano())
+	}
+	return call
+}
+
+func (c *Client) callInto(ctx context.Context, method string, params []any, v any) (bool, error) {
+	raw, err := c.Call(ctx, method, params)
+	if err != nil || raw == nil {
+		return false, err
+	}
+	err = json.Unmarshal(raw, v)
+	if err != nil {
+		return false, fmt.Errorf("%s => malformed result %s", method, string(raw))
+	}
+	return true, nil
+}
+
+// CallMixinRPC uses the legacy envelope, and the response could be
+// either the legacy {data,error} or the JSON-RPC 2.0 {result,error}
+func CallMixinRPC(node, method string, params []any) ([]byte, error) {
+	client := NewClient(node)
+	client.SetRetries(0, 0)
+	return client.Call(context.Background(), method, params)
+}
+
+func CallMixinJSONRPC(node, method string, params []any) ([]byte, error) {
+	client := NewClient(node)
+	client.SetRetries(0, 0)
+	client.UseJSONRPC(true)
+	return client.Call(context.Background(), method, params)
+}
+
+func callMixinRPC(ctx context.Context, client *http.Client, node, method string, params []any, call map[string]any) ([]byte, error) {
+	body, err := json.Marshal(call)
 	if err != nil {
 		panic(err)
 	}
-	req, err := http.NewRequest("POST", node, bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(ctx, "POST", node, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
@@ -35,8 +160,10 @@ func CallMixinRPC(node, method string, params []any) ([]byte, error) {
 	}
 
 	var result struct {
-		Data  any `json:"data"`
-		Error any `json:"error"`
+		JSONRPC string          `json:"jsonrpc"`
+		Data    any             `json:"data"`
+		Result  any             `json:"result"`
+		Error   json.RawMessage `json:"error"`
 	}
 	dec := json.NewDecoder(resp.Body)
 	dec.UseNumber()
@@ -44,12 +171,30 @@ func CallMixinRPC(node, method string, params []any) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	if result.Error != nil {
-		return nil, fmt.Errorf("CallMixinRPC(%s, %s, %s) => %v", node, method, params, result.Error)
+	if len(result.Error) > 0 && string(result.Error) != "null" {
+		return nil, fmt.Errorf("CallMixinRPC(%s, %s, %s) => %w", node, method, params, decodeError(result.Error))
+	}
+	data := result.Data
+	if result.JSONRPC != "" {
+		data = result.Result
 	}
-	if result.Data == nil {
+	if data == nil {
 		return nil, nil
 	}
 
-	return json.Marshal(result.Data)
+	return json.Marshal(data)
+}
+
+func decodeError(raw json.RawMessage) *Error {
+	var re Error
+	err := json.Unmarshal(raw, &re)
+	if err == nil && re.Message != "" {
+		return &re
+	}
+	var msg any
+	err = json.Unmarshal(raw, &msg)
+	if err != nil {
+		return &Error{Code: ErrorCodeServer, Message: string(raw)}
+	}
+	return &Error{Code: ErrorCodeServer, Message: fmt.Sprint(msg)}
 }
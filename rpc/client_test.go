@@ -0,0 +1,124 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testEndpoint struct {
+	*httptest.Server
+	hits atomic.Int64
+}
+
+func newTestEndpoint(status int, body string) *testEndpoint {
+	e := &testEndpoint{}
+	e.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e.hits.Add(1)
+		var call map[string]any
+		err := json.NewDecoder(r.Body).Decode(&call)
+		if err != nil || call["method"] != "getinfo" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	return e
+}
+
+func TestClientFailover(t *testing.T) {
+	require := require.New(t)
+
+	down := newTestEndpoint(http.StatusBadGateway, "")
+	defer down.Close()
+	up := newTestEndpoint(http.StatusOK, `{"data":{"network":"mixin"}}`)
+	defer up.Close()
+	failed := newTestEndpoint(http.StatusOK, `{"error":"invalid params count"}`)
+	defer failed.Close()
+
+	client := NewClient(down.URL, up.URL, failed.URL)
+	client.SetRetries(2, time.Millisecond)
+	data, err := client.Call(context.Background(), "getinfo", nil)
+	require.Nil(err)
+	require.Equal(`{"network":"mixin"}`, string(data))
+	require.Equal(int64(1), down.hits.Load())
+	require.Equal(int64(1), up.hits.Load())
+	require.Equal(int64(1), client.current.Load())
+
+	data, err = client.Call(context.Background(), "getinfo", nil)
+	require.Nil(err)
+	require.Equal(`{"network":"mixin"}`, string(data))
+	require.Equal(int64(1), down.hits.Load())
+	require.Equal(int64(2), up.hits.Load())
+
+	client = NewClient(down.URL, failed.URL, up.URL)
+	client.SetRetries(2, time.Millisecond)
+	_, err = client.Call(context.Background(), "getinfo", nil)
+	var re *Error
+	require.True(errors.As(err, &re))
+	require.Equal(ErrorCodeServer, re.Code)
+	require.Equal("invalid params count", re.Message)
+	require.Equal(int64(2), down.hits.Load())
+	require.Equal(int64(1), failed.hits.Load())
+	require.Equal(int64(2), up.hits.Load())
+	require.Equal(int64(1), client.current.Load())
+}
+
+func TestClientRetries(t *testing.T) {
+	require := require.New(t)
+
+	a := newTestEndpoint(http.StatusBadGateway, "")
+	defer a.Close()
+	b := newTestEndpoint(http.StatusServiceUnavailable, "")
+	defer b.Close()
+
+	client := NewClient(a.URL, b.URL)
+	client.SetRetries(2, time.Millisecond)
+	_, err := client.Call(context.Background(), "getinfo", nil)
+	require.ErrorContains(err, "status 503")
+	var re *Error
+	require.False(errors.As(err, &re))
+	require.Equal(int64(3), a.hits.Load())
+	require.Equal(int64(3), b.hits.Load())
+	require.Equal(int64(0), client.current.Load())
+
+	client.SetRetries(-1, time.Millisecond)
+	_, err = client.Call(context.Background(), "getinfo", nil)
+	require.ErrorContains(err, "status 503")
+	require.Equal(int64(4), a.hits.Load())
+	require.Equal(int64(4), b.hits.Load())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	client.SetRetries(5, time.Hour)
+	_, err = client.Call(ctx, "getinfo", nil)
+	require.ErrorIs(err, context.DeadlineExceeded)
+	require.Equal(int64(5), a.hits.Load())
+	require.Equal(int64(5), b.hits.Load())
+}
+
+func TestClientJSONRPC(t *testing.T) {
+	require := require.New(t)
+
+	up := newTestEndpoint(http.StatusOK, `{"jsonrpc":"2.0","id":"1","result":{"network":"mixin"}}`)
+	defer up.Close()
+	data, err := CallMixinJSONRPC(up.URL, "getinfo", nil)
+	require.Nil(err)
+	require.Equal(`{"network":"mixin"}`, string(data))
+
+	failed := newTestEndpoint(http.StatusOK, `{"jsonrpc":"2.0","id":"1","error":{"code":-32602,"message":"invalid params count"}}`)
+	defer failed.Close()
+	_, err = CallMixinRPC(failed.URL, "getinfo", nil)
+	var re *Error
+	require.True(errors.As(err, &re))
+	require.Equal(-32602, re.Code)
+	require.Equal("invalid params count", re.Message)
+}
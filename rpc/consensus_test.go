@@ -67,7 +67,7 @@ func testConsensus(t *testing.T, extrenalRelayers bool) {
 		custom, err := config.Initialize(dir + "/config.toml")
 		require.Nil(err)
 		cache := newCache(custom)
-		store, err := storage.NewBadgerStore(custom, dir)
+		store, err := storage.NewMemoryStore(custom)
 		require.Nil(err)
 		require.NotNil(store)
 		if i == 0 {
@@ -657,7 +657,7 @@ func testPledgeNewNode(t *testing.T, nodes []*Node, domain common.Address, genes
 	gns, err := common.ReadGenesis(dir + "/genesis.json")
 	require.Nil(err)
 	cache := newCache(custom)
-	store, err := storage.NewBadgerStore(custom, dir)
+	store, err := storage.NewMemoryStore(custom)
 	require.Nil(err)
 	require.NotNil(store)
 	pnode, err := kernel.SetupNode(custom, store, cache, gns)
@@ -843,7 +843,7 @@ func setupTestNet(root string, extrenalRelayers bool) ([]common.Address, []commo
 			gns, _ := common.ReadGenesis(dir + "/genesis.json")
 			custom, _ := config.Initialize(dir + "/config.toml")
 			cache := newCache(custom)
-			store, _ := storage.NewBadgerStore(custom, dir)
+			store, _ := storage.NewMemoryStore(custom)
 			node, _ := kernel.SetupNode(custom, store, cache, gns)
 
 			server := NewServer(custom, store, node, rpcPort)
@@ -0,0 +1,19 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+type CustodianUpdate struct {
+	Custodian   string      `json:"custodian"`
+	Transaction crypto.Hash `json:"transaction"`
+	Timestamp   uint64      `json:"timestamp"`
+}
+
+func (c *Client) ListCustodianUpdates(ctx context.Context) ([]*CustodianUpdate, error) {
+	var updates []*CustodianUpdate
+	_, err := c.callInto(ctx, "listcustodianupdates", []any{}, &updates)
+	return updates, err
+}
@@ -1,29 +1,20 @@
 package rpc
 
 import (
-	"encoding/hex"
-	"encoding/json"
+	"context"
 
 	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
 )
 
 func GetDepositTransaction(rpc, chain, hash string, index uint64) (*common.VersionedTransaction, string, error) {
-	raw, err := CallMixinRPC(rpc, "getdeposittransaction", []any{chain, hash, index})
-	if err != nil || raw == nil {
-		return nil, "", err
-	}
-	var signed map[string]any
-	err = json.Unmarshal(raw, &signed)
+	id, err := crypto.HashFromString(chain)
 	if err != nil {
-		panic(string(raw))
-	}
-	hex, err := hex.DecodeString(signed["hex"].(string))
-	if err != nil {
-		panic(string(raw))
-	}
-	ver, err := common.UnmarshalVersionedTransaction(hex)
-	if err != nil {
-		panic(string(raw))
+		return nil, "", err
 	}
-	return ver, signed["snapshot"].(string), nil
+	return NewClient(rpc).GetDepositTransaction(context.Background(), id, hash, index)
+}
+
+func (c *Client) GetDepositTransaction(ctx context.Context, chain crypto.Hash, hash string, index uint64) (*common.VersionedTransaction, string, error) {
+	return c.readTransaction(ctx, "getdeposittransaction", []any{chain.String(), hash, index})
 }
@@ -1,29 +1,132 @@
 package rpc
 
 import (
-	"encoding/json"
+	"context"
 
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/p2p"
 )
 
 type KernelInfo struct {
-	Consensus crypto.Hash `json:"consensus"`
+	Network   crypto.Hash `json:"network"`
+	Node      crypto.Hash `json:"node"`
+	Version   string      `json:"version"`
+	Uptime    string      `json:"uptime"`
+	Epoch     string      `json:"epoch"`
 	Timestamp string      `json:"timestamp"`
+	Consensus crypto.Hash `json:"consensus"`
 	Mint      struct {
 		PoolSize common.Integer `json:"pool"`
+		Batch    uint64         `json:"batch"`
+		Pledge   common.Integer `json:"pledge"`
 	} `json:"mint"`
+	Graph struct {
+		Consensus []*ConsensusNode       `json:"consensus"`
+		Cache     map[string]*GraphCache `json:"cache"`
+		Final     map[string]*GraphFinal `json:"final"`
+		Topology  uint64                 `json:"topology"`
+		SPS       float64                `json:"sps"`
+		TPS       float64                `json:"tps"`
+	} `json:"graph"`
+	Queue struct {
+		Finals uint64               `json:"finals"`
+		Caches uint64               `json:"caches"`
+		State  map[string][2]uint64 `json:"state"`
+	} `json:"queue"`
+	Metric struct {
+		Transport map[string]*p2p.MetricPool `json:"transport"`
+	} `json:"metric"`
+}
+
+type ConsensusNode struct {
+	Node        crypto.Hash `json:"node"`
+	Signer      string      `json:"signer"`
+	Payee       string      `json:"payee"`
+	State       string      `json:"state"`
+	Timestamp   uint64      `json:"timestamp"`
+	Transaction crypto.Hash `json:"transaction"`
+	Aggregator  uint64      `json:"aggregator"`
+	Works       [2]uint64   `json:"works"`
+	Spaces      *[2]uint64  `json:"spaces,omitempty"`
+}
+
+type GraphCache struct {
+	Node       crypto.Hash      `json:"node"`
+	Round      uint64           `json:"round"`
+	Timestamp  uint64           `json:"timestamp"`
+	Snapshots  []*CacheSnapshot `json:"snapshots"`
+	References *RoundReferences `json:"references"`
+}
+
+type CacheSnapshot struct {
+	Version      uint8                 `json:"version"`
+	Node         crypto.Hash           `json:"node"`
+	References   *RoundReferences      `json:"references"`
+	Round        uint64                `json:"round"`
+	Timestamp    uint64                `json:"timestamp"`
+	Hash         crypto.Hash           `json:"hash"`
+	Transactions []crypto.Hash         `json:"transactions"`
+	Signature    *crypto.CosiSignature `json:"signature"`
+}
+
+type GraphFinal struct {
+	Node  crypto.Hash `json:"node"`
+	Round uint64      `json:"round"`
+	Start uint64      `json:"start"`
+	End   uint64      `json:"end"`
+	Hash  crypto.Hash `json:"hash"`
+}
+
+type Peer struct {
+	Id          crypto.Hash                `json:"id"`
+	Address     string                     `json:"address"`
+	Relayer     bool                       `json:"relayer"`
+	Score       int                        `json:"score"`
+	Penalties   map[string]int             `json:"penalties"`
+	BannedUntil uint64                     `json:"banned_until"`
+	Metric      map[string]*p2p.MetricPool `json:"metric"`
 }
 
 func GetInfo(rpc string) (*KernelInfo, error) {
-	raw, err := CallMixinRPC(rpc, "getinfo", []any{})
-	if err != nil || raw == nil {
-		return nil, err
-	}
+	return NewClient(rpc).GetInfo(context.Background())
+}
+
+func (c *Client) GetInfo(ctx context.Context) (*KernelInfo, error) {
 	var info KernelInfo
-	err = json.Unmarshal(raw, &info)
-	if err != nil {
-		panic(string(raw))
+	ok, err := c.callInto(ctx, "getinfo", []any{}, &info)
+	if !ok {
+		return nil, err
 	}
 	return &info, nil
 }
+
+func (c *Client) ListPeers(ctx context.Context) ([]*Peer, error) {
+	var peers []*Peer
+	_, err := c.callInto(ctx, "listpeers", []any{}, &peers)
+	return peers, err
+}
+
+func (c *Client) ListRelayers(ctx context.Context, id crypto.Hash) ([]*Peer, error) {
+	var peers []*Peer
+	_, err := c.callInto(ctx, "listrelayers", []any{id.String()}, &peers)
+	return peers, err
+}
+
+func (c *Client) DumpGraphHead(ctx context.Context) ([]*p2p.SyncPoint, error) {
+	var points []*p2p.SyncPoint
+	_, err := c.callInto(ctx, "dumpgraphhead", []any{}, &points)
+	return points, err
+}
+
+func (c *Client) GetLogLevels(ctx context.Context) (map[string]int, error) {
+	var levels map[string]int
+	_, err := c.callInto(ctx, "getloglevels", []any{}, &levels)
+	return levels, err
+}
+
+func (c *Client) SetLogLevel(ctx context.Context, subsystem string, level int) (map[string]int, error) {
+	var levels map[string]int
+	_, err := c.callInto(ctx, "setloglevel", []any{subsystem, level}, &levels)
+	return levels, err
+}
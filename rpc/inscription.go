@@ -0,0 +1,63 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+type Collection struct {
+	Hash          crypto.Hash                 `json:"hash"`
+	Mode          uint8                       `json:"mode"`
+	Unit          string                      `json:"unit"`
+	Supply        string                      `json:"supply"`
+	Symbol        string                      `json:"symbol"`
+	Name          string                      `json:"name"`
+	Description   string                      `json:"description"`
+	Icon          string                      `json:"icon"`
+	Total         uint64                      `json:"total"`
+	Inscriptions  uint64                      `json:"inscriptions"`
+	Distributions uint64                      `json:"distributions"`
+	Timestamp     uint64                      `json:"timestamp"`
+	Asset         *crypto.Hash                `json:"asset,omitempty"`
+	Treasury      *common.InscriptionTreasury `json:"treasury,omitempty"`
+}
+
+type Inscription struct {
+	Collection  crypto.Hash  `json:"collection"`
+	Sequence    uint64       `json:"sequence"`
+	Hash        crypto.Hash  `json:"hash"`
+	Recipient   string       `json:"recipient"`
+	State       string       `json:"state"`
+	Timestamp   uint64       `json:"timestamp"`
+	ContentHash *crypto.Hash `json:"content_hash,omitempty"`
+	Owner       *struct {
+		Hash  crypto.Hash `json:"hash"`
+		Index uint        `json:"index"`
+	} `json:"owner,omitempty"`
+}
+
+func (c *Client) GetCollection(ctx context.Context, hash crypto.Hash) (*Collection, error) {
+	var collection Collection
+	ok, err := c.callInto(ctx, "getcollection", []any{hash.String()}, &collection)
+	if !ok {
+		return nil, err
+	}
+	return &collection, nil
+}
+
+func (c *Client) GetInscription(ctx context.Context, hash crypto.Hash) (*Inscription, error) {
+	var inscription Inscription
+	ok, err := c.callInto(ctx, "getinscription", []any{hash.String()}, &inscription)
+	if !ok {
+		return nil, err
+	}
+	return &inscription, nil
+}
+
+func (c *Client) ListInscriptions(ctx context.Context, collection crypto.Hash, offset, count uint64) ([]*Inscription, error) {
+	var inscriptions []*Inscription
+	_, err := c.callInto(ctx, "listinscriptions", []any{collection.String(), offset, count}, &inscriptions)
+	return inscriptions, err
+}
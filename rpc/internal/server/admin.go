@@ -0,0 +1,42 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+var rpcLogger = logger.New("rpc")
+
+func isLocalRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.RemoteAddr, "127.0.0.1:")
+}
+
+func getLogLevels(r *http.Request, _ []any) (map[string]int, error) {
+	if !isLocalRequest(r) {
+		return nil, errors.New("admin method from remote")
+	}
+	return logger.Levels(), nil
+}
+
+// setLogLevel changes the level of a subsystem at runtime, the empty subsystem
+// is the default level, and a negative level removes the subsystem override
+func setLogLevel(r *http.Request, params []any) (map[string]int, error) {
+	if !isLocalRequest(r) {
+		return nil, errors.New("admin method from remote")
+	}
+	if len(params) != 2 {
+		return nil, errInvalidParamsCount
+	}
+	subsystem := fmt.Sprint(params[0])
+	level, err := logger.ParseLevel(fmt.Sprint(params[1]))
+	if err != nil {
+		return nil, err
+	}
+	logger.SetSubsystemLevel(subsystem, level)
+	rpcLogger.Info("setLogLevel", "subsystem", subsystem, "level", level, "remote", r.RemoteAddr)
+	return logger.Levels(), nil
+}
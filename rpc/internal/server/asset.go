@@ -1,7 +1,6 @@
 package server
 
 import (
-	"errors"
 	"fmt"
 
 	"github.com/MixinNetwork/mixin/crypto"
@@ -10,7 +9,7 @@ import (
 
 func readAsset(store storage.Store, params []any) (map[string]any, error) {
 	if len(params) != 1 {
-		return nil, errors.New("invalid params count")
+		return nil, errInvalidParamsCount
 	}
 	id, err := crypto.HashFromString(fmt.Sprint(params[0]))
 	if err != nil {
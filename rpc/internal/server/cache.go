@@ -0,0 +1,157 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/kernel"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+// listCacheTransactions accepts the offset hash, count, asset, type and the
+// minimum age in seconds, the empty asset or type matches all transactions.
+// It may scan the whole cache, so it's an admin method like the eviction
+func listCacheTransactions(r *http.Request, store storage.Store, params []any) ([]map[string]any, error) {
+	if !isLocalRequest(r) {
+		return nil, errors.New("admin method from remote")
+	}
+	if len(params) != 5 {
+		return nil, errInvalidParamsCount
+	}
+	var offset crypto.Hash
+	if o := fmt.Sprint(params[0]); o != "" {
+		h, err := crypto.HashFromString(o)
+		if err != nil {
+			return nil, err
+		}
+		offset = h
+	}
+	count, err := strconv.ParseUint(fmt.Sprint(params[1]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	var asset crypto.Hash
+	if a := fmt.Sprint(params[2]); a != "" {
+		h, err := crypto.HashFromString(a)
+		if err != nil {
+			return nil, err
+		}
+		asset = h
+	}
+	txType := -1
+	if t := fmt.Sprint(params[3]); t != "" {
+		n, err := strconv.ParseUint(t, 10, 8)
+		if err != nil {
+			return nil, err
+		}
+		txType = int(n)
+	}
+	age, err := strconv.ParseUint(fmt.Sprint(params[4]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	now := uint64(time.Now().UnixNano())
+	txs, err := store.CacheListTransactions(offset, count, func(ct *storage.CacheTransaction) bool {
+		if asset.HasValue() && ct.Transaction.Asset != asset {
+			return false
+		}
+		if txType >= 0 && int(ct.Transaction.TransactionType()) != txType {
+			return false
+		}
+		return ct.Timestamp+age*uint64(time.Second) <= now
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]map[string]any, len(txs))
+	for i, ct := range txs {
+		result[i] = cacheTransactionToMap(ct)
+	}
+	return result, nil
+}
+
+// getCacheStats scans the whole cache, so it's an admin method
+func getCacheStats(r *http.Request, store storage.Store, node *kernel.Node) (map[string]any, error) {
+	if !isLocalRequest(r) {
+		return nil, errors.New("admin method from remote")
+	}
+	stats, err := store.CacheStats()
+	if err != nil {
+		return nil, err
+	}
+	caches, finals, state := node.QueueState()
+	chains := make(map[string]map[string]uint64)
+	for id, sa := range state {
+		chains[id] = map[string]uint64{"caches": sa[0], "finals": sa[1]}
+	}
+	return map[string]any{
+		"transactions": stats.Transactions,
+		"queued":       stats.Queued,
+		"size":         stats.Size,
+		"oldest":       stats.Oldest,
+		"caches":       caches,
+		"finals":       finals,
+		"chains":       chains,
+	}, nil
+}
+
+// evictCacheTransaction removes the transaction from the cache, but it could
+// still be finalized if already sent to the consensus
+func evictCacheTransaction(r *http.Request, store storage.Store, params []any) (map[string]any, error) {
+	if !isLocalRequest(r) {
+		return nil, errors.New("admin method from remote")
+	}
+	if len(params) != 1 {
+		return nil, errInvalidParamsCount
+	}
+	hash, err := crypto.HashFromString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	tx, err := store.CacheGetTransaction(hash)
+	if err != nil || tx == nil {
+		return nil, err
+	}
+	err = store.CacheRemoveTransactions([]crypto.Hash{hash})
+	if err != nil {
+		return nil, err
+	}
+	rpcLogger.Info("evictCacheTransaction", "hash", hash, "remote", r.RemoteAddr)
+	return map[string]any{"hash": hash}, nil
+}
+
+func diagnoseCacheTransaction(node *kernel.Node, params []any) (map[string]any, error) {
+	if len(params) != 1 {
+		return nil, errInvalidParamsCount
+	}
+	hash, err := crypto.HashFromString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	ct, reasons, err := node.DiagnoseCacheTransaction(hash)
+	if err != nil || ct == nil {
+		return nil, err
+	}
+	data := cacheTransactionToMap(ct)
+	data["reasons"] = reasons
+	return data, nil
+}
+
+func cacheTransactionToMap(ct *storage.CacheTransaction) map[string]any {
+	tx := ct.Transaction
+	return map[string]any{
+		"hash":      tx.PayloadHash(),
+		"type":      tx.TransactionType(),
+		"asset":     tx.Asset,
+		"size":      len(tx.Marshal()),
+		"inputs":    len(tx.Inputs),
+		"outputs":   len(tx.Outputs),
+		"timestamp": ct.Timestamp,
+		"queued":    ct.Queued,
+	}
+}
@@ -2,7 +2,6 @@ package server
 
 import (
 	"encoding/hex"
-	"errors"
 	"fmt"
 	"strconv"
 
@@ -13,7 +12,7 @@ import (
 
 func readDeposit(store storage.Store, params []any) (map[string]any, error) {
 	if len(params) != 3 {
-		return nil, errors.New("invalid params count")
+		return nil, errInvalidParamsCount
 	}
 	chain, err := crypto.HashFromString(fmt.Sprint(params[0]))
 	if err != nil {
@@ -35,9 +34,15 @@ func readDeposit(store storage.Store, params []any) (map[string]any, error) {
 		return nil, err
 	}
 	ver, snap, err := store.ReadTransaction(locked)
-	if err != nil || ver == nil {
+	if err != nil {
 		return nil, err
 	}
+	if ver == nil && snap != "" {
+		return nil, fmt.Errorf("transaction %s pruned", locked)
+	}
+	if ver == nil {
+		return nil, nil
+	}
 
 	data := transactionToMap(ver)
 	data["hex"] = hex.EncodeToString(ver.Marshal())
@@ -2,8 +2,8 @@ package server
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 	"time"
@@ -21,9 +21,10 @@ type RPC struct {
 }
 
 type Call struct {
-	Id     string `json:"id"`
-	Method string `json:"method"`
-	Params []any  `json:"params"`
+	JSONRPC string          `json:"jsonrpc,omitempty"`
+	Id      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  []any           `json:"params"`
 }
 
 func handlePanic(w http.ResponseWriter, _ *http.Request) {
@@ -58,6 +59,10 @@ func (r *Render) render(body map[string]any) {
 	if !r.start.IsZero() {
 		body["runtime"] = fmt.Sprint(time.Since(r.start).Seconds())
 	}
+	r.write(body)
+}
+
+func (r *Render) write(body any) {
 	b, err := json.Marshal(body)
 	if err != nil {
 		panic(err)
@@ -91,170 +96,146 @@ func (impl *RPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		impl.handleObject(w, r, rdr)
 		return
 	}
+	if r.URL.Path == "/subscribe" && r.Method == "GET" && impl.custom.RPC.Subscription {
+		impl.handleSubscribe(w, r, rdr)
+		return
+	}
+	if r.URL.Path == "/metrics" && r.Method == "GET" && impl.custom.RPC.Metrics {
+		impl.handleMetrics(w, r, rdr)
+		return
+	}
 	if r.URL.Path != "/" || r.Method != "POST" {
 		rdr.RenderError(fmt.Errorf("bad request %s %s", r.Method, r.URL.Path))
 		return
 	}
 
-	var call Call
-	dec := json.NewDecoder(r.Body)
-	dec.UseNumber()
-	if err := dec.Decode(&call); err != nil {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maximumRequestSize))
+	if err != nil {
 		rdr.RenderError(fmt.Errorf("bad request %s", err.Error()))
 		return
 	}
-	rdr.id = call.Id
+	if isBatchRequest(body) {
+		impl.handleBatch(r, rdr, body)
+		return
+	}
+
+	call, err := decodeCall(body)
+	if err != nil && (call.JSONRPC != "" || isParseError(err)) {
+		rdr.write(jsonrpcError(call.Id, err))
+		return
+	} else if err != nil {
+		rdr.id = call.legacyId()
+		rdr.RenderError(fmt.Errorf("bad request %s", err.Error()))
+		return
+	}
+	if call.JSONRPC != "" {
+		impl.handleJSONRPC(r, rdr, call)
+		return
+	}
+	rdr.id = call.legacyId()
 	if impl.custom.RPC.Runtime {
 		rdr.start = time.Now()
 	}
+	data, err := impl.handleCall(r, call)
+	if err != nil {
+		rdr.RenderError(err)
+	} else {
+		rdr.RenderData(data)
+	}
+}
+
+func (impl *RPC) handleCall(r *http.Request, call *Call) (any, error) {
+	rpcLogger.Debug("handleCall", "method", call.Method, "remote", r.RemoteAddr)
 	switch call.Method {
 	case "getinfo":
-		impl.renderInfo(rdr)
+		return getInfo(impl.Store, impl.Node)
 	case "listpeers":
 		peers := make([]map[string]any, 0)
-		if strings.HasPrefix(r.RemoteAddr, "127.0.0.1:") {
-			peers = peerNeighbors(impl.Node.Peer.Neighbors())
+		if isLocalRequest(r) {
+			peers = peerNeighbors(impl.Node.Peer, impl.Node.Peer.Neighbors())
+			peers = append(peers, bannedPeers(impl.Node.Peer, peers)...)
 		}
-		rdr.RenderData(peers)
+		return peers, nil
 	case "listrelayers":
 		if len(call.Params) != 1 {
-			rdr.RenderError(errors.New("invalid params count"))
-			return
+			return nil, errInvalidParamsCount
 		}
 		peers := make([]map[string]any, 0)
-		if strings.HasPrefix(r.RemoteAddr, "127.0.0.1:") {
+		if isLocalRequest(r) {
 			id, _ := crypto.HashFromString(fmt.Sprint(call.Params[0]))
-			peers = peerNeighbors(impl.Node.Peer.GetRemoteRelayers(id))
+			peers = peerNeighbors(impl.Node.Peer, impl.Node.Peer.GetRemoteRelayers(id))
 		}
-		rdr.RenderData(peers)
+		return peers, nil
 	case "dumpgraphhead":
-		data, err := dumpGraphHead(impl.Node, call.Params)
-		if err != nil {
-			rdr.RenderError(err)
-		} else {
-			rdr.RenderData(data)
-		}
+		return dumpGraphHead(impl.Node, call.Params)
+	case "getloglevels":
+		return getLogLevels(r, call.Params)
+	case "setloglevel":
+		return setLogLevel(r, call.Params)
 	case "sendrawtransaction":
-		id, err := queueTransaction(impl.Node, call.Params)
+		id, err := queueTransaction(r, impl.Node, call.Params)
 		if err != nil {
-			rdr.RenderError(err)
-		} else {
-			rdr.RenderData(map[string]string{"hash": id})
+			return nil, err
 		}
+		return map[string]string{"hash": id}, nil
+	case "simulatetransaction":
+		return simulateTransaction(impl.Node, call.Params)
 	case "gettransaction":
-		tx, err := getTransaction(impl.Store, call.Params)
-		if err != nil {
-			rdr.RenderError(err)
-		} else {
-			rdr.RenderData(tx)
-		}
+		return getTransaction(impl.Store, call.Params)
 	case "getcachetransaction":
-		tx, err := getCacheTransaction(impl.Store, call.Params)
-		if err != nil {
-			rdr.RenderError(err)
-		} else {
-			rdr.RenderData(tx)
-		}
+		return getCacheTransaction(impl.Store, call.Params)
+	case "listcachetransactions":
+		return listCacheTransactions(r, impl.Store, call.Params)
+	case "getcachestats":
+		return getCacheStats(r, impl.Store, impl.Node)
+	case "diagnosecachetransaction":
+		return diagnoseCacheTransaction(impl.Node, call.Params)
+	case "evictcachetransaction":
+		return evictCacheTransaction(r, impl.Store, call.Params)
 	case "getdeposittransaction":
-		tx, err := readDeposit(impl.Store, call.Params)
-		if err != nil {
-			rdr.RenderError(err)
-		} else {
-			rdr.RenderData(tx)
-		}
+		return readDeposit(impl.Store, call.Params)
 	case "getwithdrawalclaim":
-		tx, err := readWithdrawal(impl.Store, call.Params)
-		if err != nil {
-			rdr.RenderError(err)
-		} else {
-			rdr.RenderData(tx)
-		}
+		return readWithdrawal(impl.Store, call.Params)
 	case "getutxo":
-		utxo, err := getUTXO(impl.Store, call.Params)
-		if err != nil {
-			rdr.RenderError(err)
-		} else {
-			rdr.RenderData(utxo)
-		}
+		return getUTXO(impl.Store, call.Params)
 	case "getkey":
-		utxo, err := getGhostKey(impl.Store, call.Params)
-		if err != nil {
-			rdr.RenderError(err)
-		} else {
-			rdr.RenderData(utxo)
-		}
+		return getGhostKey(impl.Store, call.Params)
 	case "getasset":
-		asset, err := readAsset(impl.Store, call.Params)
-		if err != nil {
-			rdr.RenderError(err)
-		} else {
-			rdr.RenderData(asset)
-		}
+		return readAsset(impl.Store, call.Params)
 	case "getsnapshot":
-		snap, err := getSnapshot(impl.Node, impl.Store, call.Params)
-		if err != nil {
-			rdr.RenderError(err)
-		} else {
-			rdr.RenderData(snap)
-		}
+		return getSnapshot(impl.Node, impl.Store, call.Params)
 	case "listsnapshots":
-		snapshots, err := listSnapshots(impl.Node, impl.Store, call.Params)
-		if err != nil {
-			rdr.RenderError(err)
-		} else {
-			rdr.RenderData(snapshots)
-		}
+		return listSnapshots(impl.Node, impl.Store, call.Params)
 	case "listcustodianupdates":
-		curs, err := getCustodianHistory(impl.Store, call.Params)
-		if err != nil {
-			rdr.RenderError(err)
-		} else {
-			rdr.RenderData(curs)
-		}
+		return getCustodianHistory(impl.Store, call.Params)
 	case "listmintworks":
-		works, err := listMintWorks(impl.Node, call.Params)
-		if err != nil {
-			rdr.RenderError(err)
-		} else {
-			rdr.RenderData(works)
-		}
+		return listMintWorks(impl.Node, call.Params)
 	case "listmintdistributions":
-		distributions, err := listMintDistributions(impl.Store, call.Params)
-		if err != nil {
-			rdr.RenderError(err)
-		} else {
-			rdr.RenderData(distributions)
-		}
+		return listMintDistributions(impl.Store, call.Params)
 	case "listallnodes":
-		nodes, err := listAllNodes(impl.Store, impl.Node, call.Params)
-		if err != nil {
-			rdr.RenderError(err)
-		} else {
-			rdr.RenderData(nodes)
-		}
+		return listAllNodes(impl.Store, impl.Node, call.Params)
 	case "getroundbynumber":
-		round, err := getRoundByNumber(impl.Node, impl.Store, call.Params)
-		if err != nil {
-			rdr.RenderError(err)
-		} else {
-			rdr.RenderData(round)
-		}
+		return getRoundByNumber(impl.Node, impl.Store, call.Params)
 	case "getroundbyhash":
-		round, err := getRoundByHash(impl.Node, impl.Store, call.Params)
-		if err != nil {
-			rdr.RenderError(err)
-		} else {
-			rdr.RenderData(round)
-		}
+		return getRoundByHash(impl.Node, impl.Store, call.Params)
 	case "getroundlink":
 		link, err := getRoundLink(impl.Store, call.Params)
 		if err != nil {
-			rdr.RenderError(err)
-		} else {
-			rdr.RenderData(map[string]any{"link": link})
+			return nil, err
 		}
+		return map[string]any{"link": link}, nil
+	case "getcollection":
+		return getCollection(impl.Store, call.Params)
+	case "getinscription":
+		return getInscription(impl.Store, call.Params)
+	case "listinscriptions":
+		return listInscriptions(impl.Store, call.Params)
+	case "listoutputs":
+		return listOutputs(r, impl.custom, impl.Store, call.Params)
+	case "getbalance":
+		return getBalance(r, impl.custom, impl.Store, call.Params)
 	default:
-		rdr.RenderError(fmt.Errorf("invalid method %s", call.Method))
+		return nil, &Error{Code: ErrorCodeMethodNotFound, Message: fmt.Sprintf("invalid method %s", call.Method)}
 	}
 }
 
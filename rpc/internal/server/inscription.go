@@ -0,0 +1,114 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+func getCollection(store storage.Store, params []any) (map[string]any, error) {
+	if len(params) != 1 {
+		return nil, errInvalidParamsCount
+	}
+	hash, err := crypto.HashFromString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	collection, err := store.ReadInscriptionCollection(hash)
+	if err != nil || collection == nil {
+		return nil, err
+	}
+	return collectionToMap(collection), nil
+}
+
+func getInscription(store storage.Store, params []any) (map[string]any, error) {
+	if len(params) != 1 {
+		return nil, errInvalidParamsCount
+	}
+	hash, err := crypto.HashFromString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	item, err := store.ReadInscriptionItem(hash)
+	if err != nil || item == nil {
+		return nil, err
+	}
+	return inscriptionToMap(item), nil
+}
+
+func listInscriptions(store storage.Store, params []any) ([]map[string]any, error) {
+	if len(params) != 3 {
+		return nil, errInvalidParamsCount
+	}
+	collection, err := crypto.HashFromString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	offset, err := strconv.ParseUint(fmt.Sprint(params[1]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.ParseUint(fmt.Sprint(params[2]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	items, err := store.ReadInscriptionItems(collection, offset, count)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]map[string]any, len(items))
+	for i, item := range items {
+		result[i] = inscriptionToMap(item)
+	}
+	return result, nil
+}
+
+func collectionToMap(c *common.InscriptionCollection) map[string]any {
+	d := c.Deployment
+	data := map[string]any{
+		"hash":          c.Hash,
+		"mode":          d.Mode,
+		"unit":          d.Unit,
+		"supply":        d.Supply,
+		"symbol":        d.Symbol,
+		"name":          d.Name,
+		"description":   d.Description,
+		"icon":          d.Icon,
+		"total":         d.Total(),
+		"inscriptions":  c.Inscriptions,
+		"distributions": c.Distributions,
+		"timestamp":     c.Timestamp,
+	}
+	if c.Asset.HasValue() {
+		data["asset"] = c.Asset
+	}
+	if d.Treasury != nil {
+		data["treasury"] = d.Treasury
+	}
+	return data
+}
+
+func inscriptionToMap(i *common.InscriptionItem) map[string]any {
+	data := map[string]any{
+		"collection": i.Collection,
+		"sequence":   i.Sequence,
+		"hash":       i.Hash,
+		"recipient":  i.Recipient,
+		"state":      i.State,
+		"timestamp":  i.Timestamp,
+	}
+	if i.ContentHash.HasValue() {
+		data["content_hash"] = i.ContentHash
+	}
+	switch i.State {
+	case common.InscriptionStateDistributed, common.InscriptionStateOccupied:
+		data["owner"] = map[string]any{
+			"hash":  i.OwnerHash,
+			"index": i.OwnerIndex,
+		}
+	}
+	return data
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+const (
+	JSONRPCVersion = "2.0"
+
+	ErrorCodeParse          = -32700
+	ErrorCodeInvalidRequest = -32600
+	ErrorCodeMethodNotFound = -32601
+	ErrorCodeInvalidParams  = -32602
+	ErrorCodeInternal       = -32603
+	ErrorCodeServer         = -32000
+
+	maximumRequestSize = 64 * 1024 * 1024
+	maximumBatchSize   = 100
+)
+
+var errInvalidParamsCount = errors.New("invalid params count")
+
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func isBatchRequest(body []byte) bool {
+	body = bytes.TrimLeft(body, " \t\r\n")
+	return len(body) > 0 && body[0] == '['
+}
+
+// decodeCall returns the JSON-RPC error with the code, and the call decoded
+// as far as possible, so the error could be rendered with the call id
+func decodeCall(body []byte) (*Call, error) {
+	var raw struct {
+		JSONRPC string          `json:"jsonrpc"`
+		Id      json.RawMessage `json:"id"`
+		Method  string          `json:"method"`
+		Params  json.RawMessage `json:"params"`
+	}
+	err := json.Unmarshal(body, &raw)
+	call := &Call{JSONRPC: raw.JSONRPC, Id: raw.Id, Method: raw.Method}
+	var se *json.SyntaxError
+	if errors.As(err, &se) {
+		return call, &Error{Code: ErrorCodeParse, Message: err.Error()}
+	} else if err != nil {
+		return call, &Error{Code: ErrorCodeInvalidRequest, Message: err.Error()}
+	}
+
+	params := bytes.TrimSpace(raw.Params)
+	if len(params) == 0 || bytes.Equal(params, []byte("null")) {
+		return call, nil
+	}
+	if params[0] == '{' {
+		return call, &Error{Code: ErrorCodeInvalidParams, Message: "by-name params not supported"}
+	}
+	dec := json.NewDecoder(bytes.NewReader(params))
+	dec.UseNumber()
+	err = dec.Decode(&call.Params)
+	if err != nil {
+		return call, &Error{Code: ErrorCodeInvalidRequest, Message: err.Error()}
+	}
+	return call, nil
+}
+
+// isParseError tells whether the body is not JSON at all, then the version
+// is unknown and the error is rendered in the JSON-RPC 2.0 envelope
+func isParseError(err error) bool {
+	var re *Error
+	return errors.As(err, &re) && re.Code == ErrorCodeParse
+}
+
+// legacyId keeps the string id of the legacy envelope as is
+func (call *Call) legacyId() string {
+	if len(call.Id) == 0 {
+		return ""
+	}
+	var id string
+	err := json.Unmarshal(call.Id, &id)
+	if err != nil {
+		return string(call.Id)
+	}
+	return id
+}
+
+func (call *Call) isNotification() bool {
+	return len(call.Id) == 0
+}
+
+func (impl *RPC) handleJSONRPC(r *http.Request, rdr *Render, call *Call) {
+	resp := impl.serveJSONRPC(r, call)
+	if resp == nil {
+		rdr.w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	rdr.write(resp)
+}
+
+func (impl *RPC) handleBatch(r *http.Request, rdr *Render, body []byte) {
+	var raws []json.RawMessage
+	err := json.Unmarshal(body, &raws)
+	if err != nil {
+		rdr.write(jsonrpcError(nil, &Error{Code: ErrorCodeParse, Message: err.Error()}))
+		return
+	}
+	if len(raws) == 0 || len(raws) > maximumBatchSize {
+		err := &Error{Code: ErrorCodeInvalidRequest, Message: fmt.Sprintf("invalid batch size %d", len(raws))}
+		rdr.write(jsonrpcError(nil, err))
+		return
+	}
+
+	var wg sync.WaitGroup
+	responses := make([]map[string]any, len(raws))
+	for i, raw := range raws {
+		wg.Add(1)
+		go func(i int, raw json.RawMessage) {
+			defer wg.Done()
+			call, err := decodeCall(raw)
+			if err != nil {
+				responses[i] = jsonrpcError(call.Id, err)
+				return
+			}
+			responses[i] = impl.serveJSONRPC(r, call)
+		}(i, raw)
+	}
+	wg.Wait()
+
+	result := make([]map[string]any, 0)
+	for _, resp := range responses {
+		if resp != nil {
+			result = append(result, resp)
+		}
+	}
+	if len(result) == 0 {
+		rdr.w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	rdr.write(result)
+}
+
+// serveJSONRPC returns nil for notifications, which need no response
+func (impl *RPC) serveJSONRPC(r *http.Request, call *Call) (resp map[string]any) {
+	if call.JSONRPC != JSONRPCVersion || call.Method == "" {
+		err := &Error{Code: ErrorCodeInvalidRequest, Message: "invalid request"}
+		return jsonrpcError(call.Id, err)
+	}
+
+	defer func() {
+		if rcv := recover(); rcv != nil {
+			resp = jsonrpcError(call.Id, &Error{Code: ErrorCodeInternal, Message: "server error"})
+		}
+		if call.isNotification() {
+			resp = nil
+		}
+	}()
+
+	data, err := impl.handleCall(r, call)
+	if err != nil {
+		return jsonrpcError(call.Id, err)
+	}
+	return map[string]any{
+		"jsonrpc": JSONRPCVersion,
+		"id":      jsonrpcId(call.Id),
+		"result":  data,
+	}
+}
+
+func jsonrpcError(id json.RawMessage, err error) map[string]any {
+	var re *Error
+	if !errors.As(err, &re) {
+		re = &Error{Code: errorCode(err), Message: err.Error()}
+	}
+	return map[string]any{
+		"jsonrpc": JSONRPCVersion,
+		"id":      jsonrpcId(id),
+		"error":   re,
+	}
+}
+
+func jsonrpcId(id json.RawMessage) json.RawMessage {
+	if len(id) == 0 {
+		return json.RawMessage("null")
+	}
+	return id
+}
+
+func errorCode(err error) int {
+	var ne *strconv.NumError
+	var he hex.InvalidByteError
+	switch {
+	case errors.Is(err, errInvalidParamsCount), errors.As(err, &ne), errors.As(err, &he), errors.Is(err, hex.ErrLength):
+		return ErrorCodeInvalidParams
+	}
+	return ErrorCodeServer
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONRPC(t *testing.T) {
+	require := require.New(t)
+
+	custom, err := config.Initialize("../../../config/config.example.toml")
+	require.Nil(err)
+	impl := &RPC{custom: custom}
+
+	body := testServeRPC(impl, `{"id":"legacy","method":"gettransaction","params":[]}`)
+	require.Equal("legacy", body["id"])
+	require.Equal("invalid params count", body["error"])
+
+	body = testServeRPC(impl, `{"jsonrpc":"2.0","id":7,"method":"gettransaction","params":[]}`)
+	require.Equal("2.0", body["jsonrpc"])
+	require.Equal(float64(7), body["id"])
+	rpcErr := body["error"].(map[string]any)
+	require.Equal(float64(ErrorCodeInvalidParams), rpcErr["code"])
+	require.Equal("invalid params count", rpcErr["message"])
+
+	body = testServeRPC(impl, `{"jsonrpc":"2.0","id":"a","method":"gettransaction","params":["xyz"]}`)
+	rpcErr = body["error"].(map[string]any)
+	require.Equal(float64(ErrorCodeInvalidParams), rpcErr["code"])
+
+	body = testServeRPC(impl, `{"jsonrpc":"1.0","id":"a","method":"gettransaction","params":[]}`)
+	rpcErr = body["error"].(map[string]any)
+	require.Equal(float64(ErrorCodeInvalidRequest), rpcErr["code"])
+
+	body = testServeRPC(impl, `{"jsonrpc":"2.0","id":8,"method":`)
+	require.Equal("2.0", body["jsonrpc"])
+	require.Nil(body["id"])
+	require.Equal(float64(ErrorCodeParse), body["error"].(map[string]any)["code"])
+
+	body = testServeRPC(impl, `{"jsonrpc":"2.0","id":9,"method":["gettransaction"],"params":[]}`)
+	require.Equal(float64(9), body["id"])
+	require.Equal(float64(ErrorCodeInvalidRequest), body["error"].(map[string]any)["code"])
+
+	body = testServeRPC(impl, `{"jsonrpc":"2.0","id":10,"method":"gettransaction","params":"xyz"}`)
+	require.Equal(float64(10), body["id"])
+	require.Equal(float64(ErrorCodeInvalidRequest), body["error"].(map[string]any)["code"])
+
+	body = testServeRPC(impl, `{"jsonrpc":"2.0","id":11,"method":"gettransaction","params":{"hash":"xyz"}}`)
+	require.Equal(float64(11), body["id"])
+	rpcErr = body["error"].(map[string]any)
+	require.Equal(float64(ErrorCodeInvalidParams), rpcErr["code"])
+	require.Equal("by-name params not supported", rpcErr["message"])
+
+	body = testServeRPC(impl, `{"id":"legacy","method":["gettransaction"],"params":[]}`)
+	require.Equal("legacy", body["id"])
+	require.Contains(body["error"], "bad request")
+
+	for _, method := range []string{"listcachetransactions", "getcachestats", "listoutputs", "getbalance"} {
+		body = testServeRPC(impl, `{"jsonrpc":"2.0","id":12,"method":"`+method+`","params":[]}`)
+		require.Equal("admin method from remote", body["error"].(map[string]any)["message"])
+	}
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`[
+		{"jsonrpc":"2.0","id":1,"method":"invalid","params":[]},
+		{"jsonrpc":"2.0","method":"invalid","params":[]},
+		{"jsonrpc":"2.0","id":"b","method":"getutxo","params":[]},
+		1
+	]`))
+	rec := httptest.NewRecorder()
+	impl.ServeHTTP(rec, req)
+	require.Equal(http.StatusOK, rec.Code)
+	var batch []map[string]any
+	err = json.Unmarshal(rec.Body.Bytes(), &batch)
+	require.Nil(err)
+	require.Len(batch, 3)
+	require.Equal(float64(1), batch[0]["id"])
+	require.Equal(float64(ErrorCodeMethodNotFound), batch[0]["error"].(map[string]any)["code"])
+	require.Equal("b", batch[1]["id"])
+	require.Equal(float64(ErrorCodeInvalidParams), batch[1]["error"].(map[string]any)["code"])
+	require.Nil(batch[2]["id"])
+	require.Equal(float64(ErrorCodeInvalidRequest), batch[2]["error"].(map[string]any)["code"])
+
+	req = httptest.NewRequest("POST", "/", strings.NewReader(`{"jsonrpc":"2.0","method":"invalid","params":[]}`))
+	rec = httptest.NewRecorder()
+	impl.ServeHTTP(rec, req)
+	require.Equal(http.StatusNoContent, rec.Code)
+	require.Equal(0, rec.Body.Len())
+
+	body = testServeRPC(impl, `[]`)
+	require.Equal(float64(ErrorCodeInvalidRequest), body["error"].(map[string]any)["code"])
+}
+
+func testServeRPC(impl *RPC, call string) map[string]any {
+	req := httptest.NewRequest("POST", "/", strings.NewReader(call))
+	rec := httptest.NewRecorder()
+	impl.ServeHTTP(rec, req)
+	var body map[string]any
+	err := json.Unmarshal(rec.Body.Bytes(), &body)
+	if err != nil {
+		panic(rec.Body.String())
+	}
+	return body
+}
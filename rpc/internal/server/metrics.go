@@ -0,0 +1,31 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/MixinNetwork/mixin/metrics"
+)
+
+// handleMetrics serves the prometheus text exposition, the gauges are read
+// from the node state and storage on each scrape
+func (impl *RPC) handleMetrics(w http.ResponseWriter, _ *http.Request, rdr *Render) {
+	var merr error
+	buf := new(bytes.Buffer)
+	err := metrics.Write(buf, func(mw *metrics.Writer) {
+		merr = impl.Node.WriteMetrics(mw)
+	})
+	if err == nil {
+		err = merr
+	}
+	if err != nil {
+		rdr.RenderError(err)
+		return
+	}
+	w.Header().Set("Content-Type", metrics.ContentType)
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(buf.Bytes())
+	if err != nil {
+		panic(err)
+	}
+}
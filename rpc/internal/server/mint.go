@@ -1,7 +1,6 @@
 package server
 
 import (
-	"errors"
 	"fmt"
 	"strconv"
 
@@ -12,7 +11,7 @@ import (
 
 func listMintWorks(node *kernel.Node, params []any) (map[string]any, error) {
 	if len(params) != 1 {
-		return nil, errors.New("invalid params count")
+		return nil, errInvalidParamsCount
 	}
 	offset, err := strconv.ParseUint(fmt.Sprint(params[0]), 10, 64)
 	if err != nil {
@@ -32,7 +31,7 @@ func listMintWorks(node *kernel.Node, params []any) (map[string]any, error) {
 
 func listMintDistributions(store storage.Store, params []any) ([]map[string]any, error) {
 	if len(params) != 3 {
-		return nil, errors.New("invalid params count")
+		return nil, errInvalidParamsCount
 	}
 	offset, err := strconv.ParseUint(fmt.Sprint(params[0]), 10, 64)
 	if err != nil {
@@ -1,12 +1,12 @@
 package server
 
 import (
-	"errors"
 	"fmt"
 	"sort"
 	"strconv"
 	"time"
 
+	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/kernel"
 	"github.com/MixinNetwork/mixin/p2p"
 	"github.com/MixinNetwork/mixin/storage"
@@ -14,7 +14,7 @@ import (
 
 func listAllNodes(store storage.Store, node *kernel.Node, params []any) ([]map[string]any, error) {
 	if len(params) != 2 {
-		return nil, errors.New("invalid params count")
+		return nil, errInvalidParamsCount
 	}
 	threshold, err := strconv.ParseUint(fmt.Sprint(params[0]), 10, 64)
 	if err != nil {
@@ -43,15 +43,55 @@ func listAllNodes(store storage.Store, node *kernel.Node, params []any) ([]map[s
 	return result, nil
 }
 
-func peerNeighbors(peers []*p2p.Peer) []map[string]any {
+func peerNeighbors(me *p2p.Peer, peers []*p2p.Peer) []map[string]any {
 	sort.Slice(peers, func(i, j int) bool { return peers[i].IdForNetwork.String() < peers[j].IdForNetwork.String() })
 	data := make([]map[string]any, 0)
 	for _, p := range peers {
-		data = append(data, map[string]any{
+		item := map[string]any{
 			"id":      p.IdForNetwork.String(),
 			"address": p.Address,
 			"relayer": p.IsRelayer(),
-		})
+			"metric":  p.Metric(),
+		}
+		peerScore(item, me.Score(p.IdForNetwork))
+		data = append(data, item)
 	}
 	return data
 }
+
+// bannedPeers lists the banned peers not connected
+func bannedPeers(me *p2p.Peer, neighbors []map[string]any) []map[string]any {
+	connected := make(map[string]bool)
+	for _, n := range neighbors {
+		connected[n["id"].(string)] = true
+	}
+	bans := me.BannedPeers()
+	ids := make([]crypto.Hash, 0)
+	for id := range bans {
+		if !connected[id.String()] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+	data := make([]map[string]any, 0)
+	for _, id := range ids {
+		item := map[string]any{
+			"id":      id.String(),
+			"address": "",
+			"relayer": false,
+			"metric":  map[string]*p2p.MetricPool{},
+		}
+		peerScore(item, bans[id])
+		data = append(data, item)
+	}
+	return data
+}
+
+func peerScore(item map[string]any, s *p2p.PeerScore) {
+	item["score"] = s.Score
+	item["penalties"] = s.Penalties
+	item["banned_until"] = uint64(0)
+	if !s.BannedUntil.IsZero() {
+		item["banned_until"] = uint64(s.BannedUntil.UnixNano())
+	}
+}
@@ -13,7 +13,7 @@ import (
 
 func getRoundLink(store storage.Store, params []any) (uint64, error) {
 	if len(params) != 2 {
-		return 0, errors.New("invalid params count")
+		return 0, errInvalidParamsCount
 	}
 	from, err := crypto.HashFromString(fmt.Sprint(params[0]))
 	if err != nil {
@@ -28,7 +28,7 @@ func getRoundLink(store storage.Store, params []any) (uint64, error) {
 
 func getRoundByNumber(kn *kernel.Node, store storage.Store, params []any) (map[string]any, error) {
 	if len(params) != 2 {
-		return nil, errors.New("invalid params count")
+		return nil, errInvalidParamsCount
 	}
 	node, err := crypto.HashFromString(fmt.Sprint(params[0]))
 	if err != nil {
@@ -42,6 +42,13 @@ func getRoundByNumber(kn *kernel.Node, store storage.Store, params []any) (map[s
 	if err != nil {
 		return nil, err
 	}
+	checkpoint, err := store.ReadPruneCheckpoint(node)
+	if err != nil {
+		return nil, err
+	}
+	if number < checkpoint {
+		return nil, fmt.Errorf("round %s:%d pruned", node, number)
+	}
 	hash := node
 	start := head.Timestamp
 	end := head.Timestamp
@@ -85,7 +92,7 @@ func getRoundByNumber(kn *kernel.Node, store storage.Store, params []any) (map[s
 
 func getRoundByHash(kn *kernel.Node, store storage.Store, params []any) (map[string]any, error) {
 	if len(params) != 1 {
-		return nil, errors.New("invalid params count")
+		return nil, errInvalidParamsCount
 	}
 	hash, err := crypto.HashFromString(fmt.Sprint(params[0]))
 	if err != nil {
@@ -98,6 +105,13 @@ func getRoundByHash(kn *kernel.Node, store storage.Store, params []any) (map[str
 	if round == nil {
 		return nil, errors.New("round not found")
 	}
+	checkpoint, err := store.ReadPruneCheckpoint(round.NodeId)
+	if err != nil {
+		return nil, err
+	}
+	if round.Number < checkpoint {
+		return nil, fmt.Errorf("round %s pruned", hash)
+	}
 	start := round.Timestamp
 	end := round.Timestamp
 
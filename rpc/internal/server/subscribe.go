@@ -0,0 +1,256 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/kernel"
+)
+
+const (
+	subscribeEventTransaction = "transaction"
+	subscribeReplayBatch      = 100
+	subscribeBufferSize       = 1024
+	subscribePingInterval     = 15 * time.Second
+)
+
+type subscribeFilter struct {
+	events   map[string]bool
+	asset    crypto.Hash
+	keys     map[crypto.Key]bool
+	topology uint64
+	resume   bool
+	sig      bool
+}
+
+// handleSubscribe streams the events as server-sent events, the event id is
+// the snapshot topology, so clients could resume with the Last-Event-ID header
+// or the topology query, e.g. /subscribe?events=snapshot,transaction&topology=7
+func (impl *RPC) handleSubscribe(w http.ResponseWriter, r *http.Request, rdr *Render) {
+	filter, err := parseSubscribeFilter(r.URL.Query(), r.Header.Get("Last-Event-ID"))
+	if err != nil {
+		rdr.RenderError(fmt.Errorf("bad request %s", err.Error()))
+		return
+	}
+	rc := http.NewResponseController(w)
+	err = rc.SetWriteDeadline(time.Time{})
+	if err != nil {
+		rdr.RenderError(err)
+		return
+	}
+
+	sub := impl.Node.Subscribe(subscribeBufferSize)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	err = rc.Flush()
+	if err != nil {
+		return
+	}
+
+	for filter.resume {
+		snapshots, transactions, err := impl.Store.ReadSnapshotWithTransactionsSinceTopology(filter.topology, subscribeReplayBatch)
+		if err != nil {
+			writeSubscribeError(w, err, filter.topology)
+			return
+		}
+		for i, s := range snapshots {
+			err = impl.writeSnapshotEvent(w, filter, s, transactions[i])
+			if err != nil {
+				return
+			}
+			filter.topology = s.TopologicalOrder + 1
+		}
+		if len(snapshots) < subscribeReplayBatch {
+			break
+		}
+		err = rc.Flush()
+		if err != nil {
+			return
+		}
+	}
+	err = rc.Flush()
+	if err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(subscribePingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			_, err = w.Write([]byte(": ping\n\n"))
+		case e, ok := <-sub.C:
+			if !ok {
+				writeSubscribeError(w, fmt.Errorf("subscription lagged"), filter.topology)
+				return
+			}
+			err = impl.writeEvent(w, filter, e)
+		}
+		if err != nil {
+			return
+		}
+		err = rc.Flush()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (impl *RPC) writeEvent(w http.ResponseWriter, filter *subscribeFilter, e *kernel.Event) error {
+	switch e.Type {
+	case kernel.EventTypeNodeState:
+		if !filter.events[kernel.EventTypeNodeState] {
+			return nil
+		}
+		n := e.Node
+		return writeSubscribeEvent(w, kernel.EventTypeNodeState, "", map[string]any{
+			"id":          n.IdForNetwork,
+			"signer":      n.Signer,
+			"payee":       n.Payee,
+			"transaction": n.Transaction,
+			"timestamp":   n.Timestamp,
+			"state":       n.State,
+		})
+	case kernel.EventTypeSnapshot:
+		s := e.Snapshot
+		if s.TopologicalOrder < filter.topology {
+			return nil
+		}
+		var tx *common.VersionedTransaction
+		if filter.events[subscribeEventTransaction] {
+			ver, _, err := impl.Store.ReadTransaction(s.SoleTransaction())
+			if err != nil {
+				return err
+			}
+			tx = ver
+		}
+		err := impl.writeSnapshotEvent(w, filter, s, tx)
+		filter.topology = s.TopologicalOrder + 1
+		return err
+	}
+	return nil
+}
+
+func (impl *RPC) writeSnapshotEvent(w http.ResponseWriter, filter *subscribeFilter, s *common.SnapshotWithTopologicalOrder, tx *common.VersionedTransaction) error {
+	id := strconv.FormatUint(s.TopologicalOrder, 10)
+	if filter.events[kernel.EventTypeSnapshot] {
+		err := writeSubscribeEvent(w, kernel.EventTypeSnapshot, id, snapshotToMap(impl.Node, s, nil, filter.sig))
+		if err != nil {
+			return err
+		}
+	}
+	if tx == nil || !filter.events[subscribeEventTransaction] || !filter.matchTransaction(tx) {
+		return nil
+	}
+	data := transactionToMap(tx)
+	data["snapshot"] = s.Hash
+	data["topology"] = s.TopologicalOrder
+	return writeSubscribeEvent(w, subscribeEventTransaction, id, data)
+}
+
+func (filter *subscribeFilter) matchTransaction(tx *common.VersionedTransaction) bool {
+	if filter.asset.HasValue() && tx.Asset != filter.asset {
+		return false
+	}
+	if len(filter.keys) == 0 {
+		return true
+	}
+	for _, out := range tx.Outputs {
+		for _, k := range out.Keys {
+			if filter.keys[*k] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func writeSubscribeEvent(w http.ResponseWriter, typ, id string, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		panic(err)
+	}
+	msg := "event: " + typ + "\n"
+	if id != "" {
+		msg = msg + "id: " + id + "\n"
+	}
+	msg = msg + "data: " + string(b) + "\n\n"
+	_, err = w.Write([]byte(msg))
+	return err
+}
+
+func writeSubscribeError(w http.ResponseWriter, err error, topology uint64) {
+	_ = writeSubscribeEvent(w, "error", "", map[string]any{
+		"error":    err.Error(),
+		"topology": topology,
+	})
+}
+
+func parseSubscribeFilter(query url.Values, lastEventId string) (*subscribeFilter, error) {
+	filter := &subscribeFilter{
+		events: make(map[string]bool),
+		keys:   make(map[crypto.Key]bool),
+	}
+
+	events := query.Get("events")
+	if events == "" {
+		events = kernel.EventTypeSnapshot
+	}
+	for _, e := range strings.Split(events, ",") {
+		switch e {
+		case kernel.EventTypeSnapshot, kernel.EventTypeNodeState, subscribeEventTransaction:
+			filter.events[e] = true
+		default:
+			return nil, fmt.Errorf("invalid event %s", e)
+		}
+	}
+
+	if a := query.Get("asset"); a != "" {
+		asset, err := crypto.HashFromString(a)
+		if err != nil {
+			return nil, err
+		}
+		filter.asset = asset
+	}
+	if ks := query.Get("keys"); ks != "" {
+		for _, s := range strings.Split(ks, ",") {
+			k, err := crypto.KeyFromString(s)
+			if err != nil {
+				return nil, err
+			}
+			filter.keys[k] = true
+		}
+	}
+
+	if t := query.Get("topology"); t != "" {
+		topology, err := strconv.ParseUint(t, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		filter.topology, filter.resume = topology, true
+	}
+	if lastEventId != "" {
+		topology, err := strconv.ParseUint(lastEventId, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		filter.topology, filter.resume = topology+1, true
+	}
+
+	sig, _ := strconv.ParseBool(query.Get("sig"))
+	filter.sig = sig
+	return filter, nil
+}
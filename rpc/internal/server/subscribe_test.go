@@ -0,0 +1,159 @@
+package server
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/kernel"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/dgraph-io/ristretto/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribeFilter(t *testing.T) {
+	require := require.New(t)
+
+	filter, err := parseSubscribeFilter(url.Values{}, "")
+	require.Nil(err)
+	require.True(filter.events[kernel.EventTypeSnapshot])
+	require.Len(filter.events, 1)
+	require.False(filter.resume)
+
+	_, err = parseSubscribeFilter(url.Values{"events": {"snapshot,block"}}, "")
+	require.ErrorContains(err, "invalid event block")
+	_, err = parseSubscribeFilter(url.Values{"topology": {"abc"}}, "")
+	require.NotNil(err)
+	filter, err = parseSubscribeFilter(url.Values{"topology": {"7"}}, "")
+	require.Nil(err)
+	require.True(filter.resume)
+	require.Equal(uint64(7), filter.topology)
+	filter, err = parseSubscribeFilter(url.Values{"topology": {"7"}}, "9")
+	require.Nil(err)
+	require.Equal(uint64(10), filter.topology)
+
+	seed := make([]byte, 64)
+	key := crypto.NewKeyFromSeed(seed).Public()
+	other := crypto.NewKeyFromSeed(append(seed[1:], 1)).Public()
+	tx := common.NewTransactionV5(common.XINAssetId).AsVersioned()
+	tx.Outputs = append(tx.Outputs, &common.Output{Keys: []*crypto.Key{&key}})
+
+	filter, err = parseSubscribeFilter(url.Values{"events": {"transaction"}}, "")
+	require.Nil(err)
+	require.True(filter.matchTransaction(tx))
+	filter, err = parseSubscribeFilter(url.Values{"asset": {crypto.Blake3Hash([]byte("asset")).String()}}, "")
+	require.Nil(err)
+	require.False(filter.matchTransaction(tx))
+	filter, err = parseSubscribeFilter(url.Values{"asset": {common.XINAssetId.String()}, "keys": {other.String()}}, "")
+	require.Nil(err)
+	require.False(filter.matchTransaction(tx))
+	filter, err = parseSubscribeFilter(url.Values{"keys": {other.String() + "," + key.String()}}, "")
+	require.Nil(err)
+	require.True(filter.matchTransaction(tx))
+
+	impl := &RPC{}
+	n := &kernel.CNode{IdForNetwork: crypto.Blake3Hash(seed), State: common.NodeStateAccepted}
+	w := httptest.NewRecorder()
+	filter, _ = parseSubscribeFilter(url.Values{"events": {"snapshot"}}, "")
+	err = impl.writeEvent(w, filter, &kernel.Event{Type: kernel.EventTypeNodeState, Node: n})
+	require.Nil(err)
+	require.Equal("", w.Body.String())
+	filter, _ = parseSubscribeFilter(url.Values{"events": {"node"}}, "")
+	err = impl.writeEvent(w, filter, &kernel.Event{Type: kernel.EventTypeNodeState, Node: n})
+	require.Nil(err)
+	require.True(strings.HasPrefix(w.Body.String(), "event: node\ndata: {"))
+	require.Contains(w.Body.String(), `"state":"ACCEPTED"`)
+	w = httptest.NewRecorder()
+	err = impl.writeEvent(w, filter, &kernel.Event{Type: kernel.EventTypeSnapshot, Snapshot: &common.SnapshotWithTopologicalOrder{}})
+	require.Nil(err)
+	require.Equal("", w.Body.String())
+}
+
+func TestSubscribeReplay(t *testing.T) {
+	require := require.New(t)
+
+	custom, err := config.Initialize("../../../config/config.example.toml")
+	require.Nil(err)
+	custom.RPC.Subscription = true
+	gns, err := common.ReadGenesis("../../../config/genesis.json")
+	require.Nil(err)
+	store, err := storage.NewMemoryStore(custom)
+	require.Nil(err)
+	defer store.Close()
+	cache, err := ristretto.NewCache(&ristretto.Config[[]byte, any]{
+		NumCounters: 1e4,
+		MaxCost:     1 << 20,
+		BufferItems: 64,
+	})
+	require.Nil(err)
+	node, err := kernel.SetupNode(custom, store, cache, gns)
+	require.Nil(err)
+	snapshots, err := store.ReadSnapshotsSinceTopology(0, 100)
+	require.Nil(err)
+	require.Greater(len(snapshots), 2)
+
+	server := httptest.NewServer(&RPC{Store: store, Node: node, custom: custom})
+	defer server.Close()
+
+	events := testSubscribe(require, server.URL+"/subscribe?events=snapshot,transaction&topology=0", "", 2*len(snapshots))
+	for i, s := range snapshots {
+		require.Equal("snapshot", events[2*i][0])
+		require.Equal(s.TopologicalOrder, events[2*i][1])
+		require.Equal("transaction", events[2*i+1][0])
+		require.Equal(s.TopologicalOrder, events[2*i+1][1])
+	}
+
+	last := snapshots[len(snapshots)-2].TopologicalOrder
+	events = testSubscribe(require, server.URL+"/subscribe", strconv.FormatUint(last, 10), 1)
+	require.Equal("snapshot", events[0][0])
+	require.Equal(last+1, events[0][1])
+
+	resp, err := http.Get(server.URL + "/subscribe?events=block")
+	require.Nil(err)
+	defer resp.Body.Close()
+	require.Equal(http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.Nil(err)
+	require.Contains(string(body), "invalid event block")
+}
+
+// testSubscribe reads the stream until the count events with id received, and
+// returns the type and id of them
+func testSubscribe(require *require.Assertions, uri string, last string, count int) [][2]any {
+	req, err := http.NewRequest("GET", uri, nil)
+	require.Nil(err)
+	if last != "" {
+		req.Header.Set("Last-Event-ID", last)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.Nil(err)
+	defer resp.Body.Close()
+	require.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+
+	var events [][2]any
+	var typ string
+	r := bufio.NewReader(resp.Body)
+	for len(events) < count {
+		line, err := r.ReadString('\n')
+		require.Nil(err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			typ = strings.TrimPrefix(line, "event: ")
+			require.NotEqual("error", typ)
+		case strings.HasPrefix(line, "id: "):
+			id, err := strconv.ParseUint(strings.TrimPrefix(line, "id: "), 10, 64)
+			require.Nil(err)
+			events = append(events, [2]any{typ, id})
+		}
+	}
+	return events
+}
@@ -4,8 +4,11 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"net"
+	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/crypto"
@@ -15,7 +18,7 @@ import (
 
 func getCacheTransaction(store storage.Store, params []any) (map[string]any, error) {
 	if len(params) != 1 {
-		return nil, errors.New("invalid params count")
+		return nil, errInvalidParamsCount
 	}
 	hash, err := crypto.HashFromString(fmt.Sprint(params[0]))
 	if err != nil {
@@ -30,20 +33,30 @@ func getCacheTransaction(store storage.Store, params []any) (map[string]any, err
 	return data, nil
 }
 
-func queueTransaction(node *kernel.Node, params []any) (string, error) {
+// requestSource identifies the submitting host of the request, so the fair
+// cache policy takes turns among the RPC clients
+func requestSource(r *http.Request) crypto.Hash {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return crypto.Blake3Hash([]byte(host))
+}
+
+func queueTransaction(r *http.Request, node *kernel.Node, params []any) (string, error) {
 	if len(params) != 1 {
-		return "", errors.New("invalid params count")
+		return "", errInvalidParamsCount
 	}
 	raw, err := hex.DecodeString(fmt.Sprint(params[0]))
 	if err != nil {
 		return "", err
 	}
-	ver, err := common.UnmarshalVersionedTransaction(raw)
+	ver, err := common.UnmarshalVersionedTransactionAt(raw, uint64(time.Now().UnixNano()))
 	if err != nil {
 		return "", err
 	}
 	for {
-		hash, err := node.QueueTransaction(ver)
+		hash, err := node.QueueTransaction(ver, requestSource(r))
 		if err == nil {
 			return hash, nil
 		}
@@ -54,18 +67,47 @@ func queueTransaction(node *kernel.Node, params []any) (string, error) {
 	}
 }
 
+func simulateTransaction(node *kernel.Node, params []any) (any, error) {
+	if len(params) != 1 {
+		return nil, errInvalidParamsCount
+	}
+	raw, err := hex.DecodeString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	ver, err := common.UnmarshalVersionedTransaction(raw)
+	if err != nil {
+		return nil, err
+	}
+	report, snap, err := node.SimulateTransaction(ver)
+	if err != nil {
+		return nil, err
+	}
+	return struct {
+		*common.SimulationReport
+		Valid    bool   `json:"valid"`
+		Snapshot string `json:"snapshot,omitempty"`
+	}{report, report.Valid(), snap}, nil
+}
+
 func getTransaction(store storage.Store, params []any) (map[string]any, error) {
 	if len(params) != 1 {
-		return nil, errors.New("invalid params count")
+		return nil, errInvalidParamsCount
 	}
 	hash, err := crypto.HashFromString(fmt.Sprint(params[0]))
 	if err != nil {
 		return nil, err
 	}
 	tx, snap, err := store.ReadTransaction(hash)
-	if err != nil || tx == nil {
+	if err != nil {
 		return nil, err
 	}
+	if tx == nil && snap != "" {
+		return nil, fmt.Errorf("transaction %s pruned", hash)
+	}
+	if tx == nil {
+		return nil, nil
+	}
 	data := transactionToMap(tx)
 	data["hex"] = hex.EncodeToString(tx.Marshal())
 	if len(snap) > 0 {
@@ -76,7 +118,7 @@ func getTransaction(store storage.Store, params []any) (map[string]any, error) {
 
 func getUTXO(store storage.Store, params []any) (map[string]any, error) {
 	if len(params) != 2 {
-		return nil, errors.New("invalid params count")
+		return nil, errInvalidParamsCount
 	}
 	hash, err := crypto.HashFromString(fmt.Sprint(params[0]))
 	if err != nil {
@@ -114,7 +156,7 @@ func getUTXO(store storage.Store, params []any) (map[string]any, error) {
 
 func getGhostKey(store storage.Store, params []any) (map[string]any, error) {
 	if len(params) != 1 {
-		return nil, errors.New("invalid params count")
+		return nil, errInvalidParamsCount
 	}
 	key, err := crypto.KeyFromString(fmt.Sprint(params[0]))
 	if err != nil {
@@ -133,13 +175,16 @@ func getGhostKey(store storage.Store, params []any) (map[string]any, error) {
 
 func getSnapshot(node *kernel.Node, store storage.Store, params []any) (map[string]any, error) {
 	if len(params) != 1 {
-		return nil, errors.New("invalid params count")
+		return nil, errInvalidParamsCount
 	}
 	hash, err := crypto.HashFromString(fmt.Sprint(params[0]))
 	if err != nil {
 		return nil, err
 	}
 	snap, err := store.ReadSnapshot(hash)
+	if errors.Is(err, storage.ErrPruned) {
+		return nil, fmt.Errorf("snapshot %s pruned", hash)
+	}
 	if err != nil || snap == nil {
 		return nil, err
 	}
@@ -152,7 +197,7 @@ func getSnapshot(node *kernel.Node, store storage.Store, params []any) (map[stri
 
 func listSnapshots(node *kernel.Node, store storage.Store, params []any) ([]map[string]any, error) {
 	if len(params) != 4 {
-		return nil, errors.New("invalid params count")
+		return nil, errInvalidParamsCount
 	}
 	offset, err := strconv.ParseUint(fmt.Sprint(params[0]), 10, 64)
 	if err != nil {
@@ -223,10 +268,17 @@ func transactionToMap(tx *common.VersionedTransaction) map[string]any {
 	var inputs []map[string]any
 	for _, in := range tx.Inputs {
 		if in.Hash.HasValue() {
-			inputs = append(inputs, map[string]any{
+			input := map[string]any{
 				"hash":  in.Hash,
 				"index": in.Index,
-			})
+			}
+			if in.Branch > 0 {
+				input["branch"] = in.Branch
+			}
+			if len(in.Data) > 0 {
+				input["data"] = hex.EncodeToString(in.Data)
+			}
+			inputs = append(inputs, input)
 		} else if len(in.Genesis) > 0 {
 			inputs = append(inputs, map[string]any{
 				"genesis": hex.EncodeToString(in.Genesis),
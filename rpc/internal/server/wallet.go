@@ -0,0 +1,111 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+// the wallet methods reveal the outputs and balances of the registered keys,
+// so they are only available to the local requests like the admin methods
+
+func listOutputs(r *http.Request, custom *config.Custom, store storage.Store, params []any) ([]map[string]any, error) {
+	if !isLocalRequest(r) {
+		return nil, errors.New("admin method from remote")
+	}
+	if len(params) != 3 {
+		return nil, errInvalidParamsCount
+	}
+	wallet, err := readWalletParam(custom, params[0])
+	if err != nil {
+		return nil, err
+	}
+	offset, err := strconv.ParseUint(fmt.Sprint(params[1]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.ParseUint(fmt.Sprint(params[2]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	outputs, err := store.ReadWalletOutputs(wallet, offset, count)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]map[string]any, len(outputs))
+	for i, out := range outputs {
+		result[i] = walletOutputToMap(out)
+	}
+	return result, nil
+}
+
+func getBalance(r *http.Request, custom *config.Custom, store storage.Store, params []any) (map[string]any, error) {
+	if !isLocalRequest(r) {
+		return nil, errors.New("admin method from remote")
+	}
+	if len(params) != 2 {
+		return nil, errInvalidParamsCount
+	}
+	wallet, err := readWalletParam(custom, params[0])
+	if err != nil {
+		return nil, err
+	}
+	asset, err := crypto.HashFromString(fmt.Sprint(params[1]))
+	if err != nil {
+		return nil, err
+	}
+	checkpoint, err := store.ReadWalletCheckpoint(wallet)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := store.ReadWalletBalance(wallet, asset)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{
+		"asset":    asset,
+		"balance":  balance,
+		"topology": checkpoint,
+	}, nil
+}
+
+func readWalletParam(custom *config.Custom, param any) (crypto.Hash, error) {
+	addr, err := common.NewAddressFromString(fmt.Sprint(param))
+	if err != nil {
+		return crypto.Hash{}, err
+	}
+	if !custom.Wallet.Scan {
+		return crypto.Hash{}, errors.New("wallet scan disabled")
+	}
+	for _, k := range custom.Wallet.Keys {
+		if k.PublicSpend == addr.PublicSpendKey && k.PrivateView.Public() == addr.PublicViewKey {
+			return addr.Hash(), nil
+		}
+	}
+	return crypto.Hash{}, fmt.Errorf("wallet %s not registered", addr.String())
+}
+
+func walletOutputToMap(out *common.WalletOutput) map[string]any {
+	item := map[string]any{
+		"sequence":  out.Sequence,
+		"asset":     out.Asset,
+		"hash":      out.Hash,
+		"index":     out.Index,
+		"amount":    out.Amount,
+		"keys":      out.Keys,
+		"mask":      out.Mask,
+		"script":    out.Script,
+		"snapshot":  out.Snapshot,
+		"timestamp": out.Timestamp,
+	}
+	if out.Spent.HasValue() {
+		item["spent"] = out.Spent
+	}
+	return item
+}
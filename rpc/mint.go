@@ -1,41 +1,64 @@
 package rpc
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 
 	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
 )
 
-func ListMintDistributions(rpc string, offset, count uint64) ([]*common.VersionedTransaction, error) {
-	raw, err := CallMixinRPC(rpc, "listmintdistributions", []any{offset, count, false})
-	if err != nil || raw == nil {
-		return nil, err
-	}
+type MintDistribution struct {
+	Group  string         `json:"group"`
+	Batch  uint64         `json:"batch"`
+	Amount common.Integer `json:"amount"`
+	// either the transaction hash or the transaction object
+	Transaction json.RawMessage `json:"transaction"`
+}
 
-	var mds []struct {
-		Amount      string `json:"amount"`
-		Batch       uint64 `json:"batch"`
-		Transaction string `json:"transaction"`
-	}
-	err = json.Unmarshal(raw, &mds)
+func ListMintDistributions(rpc string, offset, count uint64) ([]*common.VersionedTransaction, error) {
+	ctx := context.Background()
+	client := NewClient(rpc)
+	mds, err := client.ListMintDistributions(ctx, offset, count, false)
 	if err != nil {
-		panic(string(raw))
+		return nil, err
 	}
 
 	txs := make([]*common.VersionedTransaction, len(mds))
 	for i, md := range mds {
-		tx, _, err := GetTransaction(rpc, md.Transaction)
+		var hash crypto.Hash
+		err := json.Unmarshal(md.Transaction, &hash)
 		if err != nil {
 			return nil, err
 		}
+		tx, _, err := client.GetTransaction(ctx, hash)
+		if err != nil {
+			return nil, err
+		}
+		if tx == nil || tx.Inputs[0].Mint == nil {
+			return nil, fmt.Errorf("malformed mint distribution %s", hash)
+		}
 		m := tx.Inputs[0].Mint
-		if m.Amount.String() != md.Amount {
-			panic(md.Transaction)
+		if m.Amount.Cmp(md.Amount) != 0 {
+			return nil, fmt.Errorf("malformed mint distribution %s", hash)
 		}
 		if m.Batch != md.Batch {
-			panic(md.Transaction)
+			return nil, fmt.Errorf("malformed mint distribution %s", hash)
 		}
 		txs[i] = tx
 	}
 	return txs, nil
 }
+
+func (c *Client) ListMintWorks(ctx context.Context, offset uint64) (map[string][2]uint64, error) {
+	var works map[string][2]uint64
+	_, err := c.callInto(ctx, "listmintworks", []any{offset}, &works)
+	return works, err
+}
+
+func (c *Client) ListMintDistributions(ctx context.Context, offset, count uint64, tx bool) ([]*MintDistribution, error) {
+	var mds []*MintDistribution
+	_, err := c.callInto(ctx, "listmintdistributions", []any{offset, count, tx}, &mds)
+	return mds, err
+}
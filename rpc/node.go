@@ -0,0 +1,23 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+type KernelNode struct {
+	Id          crypto.Hash    `json:"id"`
+	Signer      common.Address `json:"signer"`
+	Payee       common.Address `json:"payee"`
+	Transaction crypto.Hash    `json:"transaction"`
+	Timestamp   uint64         `json:"timestamp"`
+	State       string         `json:"state"`
+}
+
+func (c *Client) ListAllNodes(ctx context.Context, threshold uint64, state bool) ([]*KernelNode, error) {
+	var nodes []*KernelNode
+	_, err := c.callInto(ctx, "listallnodes", []any{threshold, state}, &nodes)
+	return nodes, err
+}
@@ -0,0 +1,48 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+type RoundReferences struct {
+	Self     crypto.Hash `json:"self"`
+	External crypto.Hash `json:"external"`
+}
+
+type Round struct {
+	Node       crypto.Hash      `json:"node"`
+	Hash       crypto.Hash      `json:"hash"`
+	Start      uint64           `json:"start"`
+	End        uint64           `json:"end"`
+	Number     uint64           `json:"number"`
+	References *RoundReferences `json:"references"`
+	Snapshots  []*Snapshot      `json:"snapshots"`
+}
+
+func (c *Client) GetRoundByNumber(ctx context.Context, node crypto.Hash, number uint64) (*Round, error) {
+	var round Round
+	ok, err := c.callInto(ctx, "getroundbynumber", []any{node.String(), number}, &round)
+	if !ok {
+		return nil, err
+	}
+	return &round, nil
+}
+
+func (c *Client) GetRoundByHash(ctx context.Context, hash crypto.Hash) (*Round, error) {
+	var round Round
+	ok, err := c.callInto(ctx, "getroundbyhash", []any{hash.String()}, &round)
+	if !ok {
+		return nil, err
+	}
+	return &round, nil
+}
+
+func (c *Client) GetRoundLink(ctx context.Context, from, to crypto.Hash) (uint64, error) {
+	var res struct {
+		Link uint64 `json:"link"`
+	}
+	_, err := c.callInto(ctx, "getroundlink", []any{from.String(), to.String()}, &res)
+	return res.Link, err
+}
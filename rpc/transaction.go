@@ -1,71 +1,211 @@
 package rpc
 
 import (
+	"context"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/crypto"
 )
 
-func GetSnapshot(rpc, hash string) (*common.SnapshotWithTopologicalOrder, error) {
-	raw, err := CallMixinRPC(rpc, "getsnapshot", []any{hash})
-	if err != nil || raw == nil {
+type Snapshot struct {
+	Version    uint8            `json:"version"`
+	Node       crypto.Hash      `json:"node"`
+	References *RoundReferences `json:"references"`
+	Round      uint64           `json:"round"`
+	Timestamp  uint64           `json:"timestamp"`
+	Hash       crypto.Hash      `json:"hash"`
+	Hex        string           `json:"hex"`
+	Topology   uint64           `json:"topology"`
+	Witness    struct {
+		Signature *crypto.Signature `json:"signature"`
+		Timestamp uint64            `json:"timestamp"`
+	} `json:"witness"`
+	// either the transaction hash or the transaction object
+	Transactions []json.RawMessage     `json:"transactions"`
+	Signature    *crypto.CosiSignature `json:"signature,omitempty"`
+}
+
+func (s *Snapshot) Decode() (*common.SnapshotWithTopologicalOrder, error) {
+	b, err := hex.DecodeString(s.Hex)
+	if err != nil {
 		return nil, err
 	}
-	var signed map[string]any
-	err = json.Unmarshal(raw, &signed)
+	return common.UnmarshalVersionedSnapshot(b)
+}
+
+func GetSnapshot(rpc, hash string) (*common.SnapshotWithTopologicalOrder, error) {
+	h, err := crypto.HashFromString(hash)
 	if err != nil {
-		panic(string(raw))
+		return nil, err
 	}
-	hex, err := hex.DecodeString(signed["hex"].(string))
+	snap, err := NewClient(rpc).GetSnapshot(context.Background(), h)
+	if err != nil || snap == nil {
+		return nil, err
+	}
+	return snap.Decode()
+}
+
+func GetTransaction(rpc, hash string) (*common.VersionedTransaction, string, error) {
+	h, err := crypto.HashFromString(hash)
 	if err != nil {
-		panic(string(raw))
+		return nil, "", err
 	}
-	ver, err := common.UnmarshalVersionedSnapshot(hex)
+	return NewClient(rpc).GetTransaction(context.Background(), h)
+}
+
+func SendRawTransaction(rpc, raw string) (crypto.Hash, error) {
+	return NewClient(rpc).SendRawTransaction(context.Background(), raw)
+}
+
+func (c *Client) GetSnapshot(ctx context.Context, hash crypto.Hash) (*Snapshot, error) {
+	var snap Snapshot
+	ok, err := c.callInto(ctx, "getsnapshot", []any{hash.String()}, &snap)
+	if !ok {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+func (c *Client) ListSnapshots(ctx context.Context, offset, count uint64, sig, tx bool) ([]*Snapshot, error) {
+	var snapshots []*Snapshot
+	_, err := c.callInto(ctx, "listsnapshots", []any{offset, count, sig, tx}, &snapshots)
+	return snapshots, err
+}
+
+func (c *Client) GetTransaction(ctx context.Context, hash crypto.Hash) (*common.VersionedTransaction, string, error) {
+	return c.readTransaction(ctx, "gettransaction", []any{hash.String()})
+}
+
+func (c *Client) GetCacheTransaction(ctx context.Context, hash crypto.Hash) (*common.VersionedTransaction, error) {
+	ver, _, err := c.readTransaction(ctx, "getcachetransaction", []any{hash.String()})
+	return ver, err
+}
+
+func (c *Client) SendRawTransaction(ctx context.Context, raw string) (crypto.Hash, error) {
+	var tx struct {
+		Hash crypto.Hash `json:"hash"`
+	}
+	_, err := c.callInto(ctx, "sendrawtransaction", []any{raw}, &tx)
 	if err != nil {
-		panic(string(raw))
+		return crypto.Hash{}, err
 	}
-	return ver, nil
+	if !tx.Hash.HasValue() {
+		return crypto.Hash{}, fmt.Errorf("sendrawtransaction => invalid hash")
+	}
+	return tx.Hash, nil
 }
 
-func GetTransaction(rpc, hash string) (*common.VersionedTransaction, string, error) {
-	raw, err := CallMixinRPC(rpc, "gettransaction", []any{hash})
-	if err != nil || raw == nil {
+func (c *Client) GetKey(ctx context.Context, key crypto.Key) (*crypto.Hash, error) {
+	var res struct {
+		Transaction *crypto.Hash `json:"transaction"`
+	}
+	_, err := c.callInto(ctx, "getkey", []any{key.String()}, &res)
+	return res.Transaction, err
+}
+
+func (c *Client) readTransaction(ctx context.Context, method string, params []any) (*common.VersionedTransaction, string, error) {
+	var signed struct {
+		Hex      string `json:"hex"`
+		Snapshot string `json:"snapshot"`
+	}
+	ok, err := c.callInto(ctx, method, params, &signed)
+	if !ok {
 		return nil, "", err
 	}
-	var signed map[string]any
-	err = json.Unmarshal(raw, &signed)
+	b, err := hex.DecodeString(signed.Hex)
 	if err != nil {
-		panic(string(raw))
+		return nil, "", fmt.Errorf("%s => malformed hex %s", method, signed.Hex)
 	}
-	hex, err := hex.DecodeString(signed["hex"].(string))
+	ver, err := common.UnmarshalVersionedTransaction(b)
 	if err != nil {
-		panic(string(raw))
+		return nil, "", err
 	}
-	ver, err := common.UnmarshalVersionedTransaction(hex)
-	if err != nil {
-		panic(string(raw))
+	return ver, signed.Snapshot, nil
+}
+
+type Simulation struct {
+	common.SimulationReport
+	Valid    bool   `json:"valid"`
+	Snapshot string `json:"snapshot"`
+}
+
+func (c *Client) SimulateTransaction(ctx context.Context, raw string) (*Simulation, error) {
+	var sim Simulation
+	ok, err := c.callInto(ctx, "simulatetransaction", []any{raw}, &sim)
+	if !ok {
+		return nil, err
 	}
-	if signed["snapshot"] == nil {
-		return ver, "", nil
+	return &sim, nil
+}
+
+type CacheTransaction struct {
+	Hash      crypto.Hash `json:"hash"`
+	Type      uint8       `json:"type"`
+	Asset     crypto.Hash `json:"asset"`
+	Size      int         `json:"size"`
+	Inputs    int         `json:"inputs"`
+	Outputs   int         `json:"outputs"`
+	Timestamp uint64      `json:"timestamp"`
+	Queued    bool        `json:"queued"`
+	Reasons   []string    `json:"reasons,omitempty"`
+}
+
+type CacheStats struct {
+	Transactions uint64 `json:"transactions"`
+	Queued       uint64 `json:"queued"`
+	Size         uint64 `json:"size"`
+	Oldest       uint64 `json:"oldest"`
+	Caches       uint64 `json:"caches"`
+	Finals       uint64 `json:"finals"`
+	Chains       map[string]struct {
+		Caches uint64 `json:"caches"`
+		Finals uint64 `json:"finals"`
+	} `json:"chains"`
+}
+
+// ListCacheTransactions lists the cached transactions after the offset hash,
+// the zero asset, negative type and zero age match all transactions
+func (c *Client) ListCacheTransactions(ctx context.Context, offset crypto.Hash, count uint64, asset crypto.Hash, txType int, age uint64) ([]*CacheTransaction, error) {
+	params := []any{"", count, "", "", age}
+	if offset.HasValue() {
+		params[0] = offset.String()
+	}
+	if asset.HasValue() {
+		params[2] = asset.String()
 	}
-	return ver, signed["snapshot"].(string), nil
+	if txType >= 0 {
+		params[3] = fmt.Sprint(txType)
+	}
+	var txs []*CacheTransaction
+	_, err := c.callInto(ctx, "listcachetransactions", params, &txs)
+	return txs, err
 }
 
-func SendRawTransaction(rpc, raw string) (crypto.Hash, error) {
-	body, err := CallMixinRPC(rpc, "sendrawtransaction", []any{raw})
-	if err != nil {
-		return crypto.Hash{}, err
+func (c *Client) GetCacheStats(ctx context.Context) (*CacheStats, error) {
+	var stats CacheStats
+	ok, err := c.callInto(ctx, "getcachestats", []any{}, &stats)
+	if !ok {
+		return nil, err
 	}
-	var tx map[string]string
-	err = json.Unmarshal(body, &tx)
-	if err != nil {
-		panic(string(body))
+	return &stats, nil
+}
+
+func (c *Client) DiagnoseCacheTransaction(ctx context.Context, hash crypto.Hash) (*CacheTransaction, error) {
+	var tx CacheTransaction
+	ok, err := c.callInto(ctx, "diagnosecachetransaction", []any{hash.String()}, &tx)
+	if !ok {
+		return nil, err
 	}
-	hash, err := crypto.HashFromString(tx["hash"])
-	if err != nil || !hash.HasValue() {
-		panic(string(body))
+	return &tx, nil
+}
+
+// EvictCacheTransaction returns false if the transaction is not in the cache
+func (c *Client) EvictCacheTransaction(ctx context.Context, hash crypto.Hash) (bool, error) {
+	var res struct {
+		Hash crypto.Hash `json:"hash"`
 	}
-	return hash, nil
+	return c.callInto(ctx, "evictcachetransaction", []any{hash.String()}, &res)
 }
@@ -1,30 +1,35 @@
 package rpc
 
 import (
-	"encoding/json"
+	"context"
 
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/crypto"
 )
 
 type utxoKeysRPCReader struct {
-	rpc string
+	client *Client
 }
 
 func NewUTXOKeysRPCReader(rpc string) *utxoKeysRPCReader {
 	return &utxoKeysRPCReader{
-		rpc: rpc,
+		client: NewClient(rpc),
 	}
 }
 
+func (c *Client) UTXOKeysReader() *utxoKeysRPCReader {
+	return &utxoKeysRPCReader{client: c}
+}
+
 func (ur *utxoKeysRPCReader) ReadUTXOKeys(hash crypto.Hash, index uint) (*common.UTXOKeys, error) {
 	utxo := &common.UTXOKeys{}
-	out, err := GetUTXO(ur.rpc, hash.String(), uint64(index))
+	out, err := ur.client.GetUTXO(context.Background(), hash, index)
 	if err != nil || out == nil {
 		return nil, err
 	}
 	utxo.Keys = out.Keys
 	utxo.Mask = out.Mask
+	utxo.Script = out.Script
 	return utxo, nil
 }
 
@@ -33,10 +38,14 @@ func (ur *utxoKeysRPCReader) ReadDepositLock(deposit *common.DepositData) (crypt
 }
 
 func GetUTXO(rpc, hash string, index uint64) (*common.UTXOWithLock, error) {
-	data, err := CallMixinRPC(rpc, "getutxo", []any{hash, index})
+	h, err := crypto.HashFromString(hash)
 	if err != nil {
 		return nil, err
 	}
+	return NewClient(rpc).GetUTXO(context.Background(), h, uint(index))
+}
+
+func (c *Client) GetUTXO(ctx context.Context, hash crypto.Hash, index uint) (*common.UTXOWithLock, error) {
 	var out struct {
 		Type     uint8          `json:"type"`
 		Hash     crypto.Hash    `json:"hash"`
@@ -47,9 +56,9 @@ func GetUTXO(rpc, hash string, index uint64) (*common.UTXOWithLock, error) {
 		Mask     *crypto.Key    `json:"mask"`
 		LockHash crypto.Hash    `json:"lock"`
 	}
-	err = json.Unmarshal(data, &out)
-	if err != nil {
-		panic(string(data))
+	ok, err := c.callInto(ctx, "getutxo", []any{hash.String(), index}, &out)
+	if !ok {
+		return nil, err
 	}
 
 	utxo := &common.UTXOWithLock{LockHash: out.LockHash}
@@ -59,6 +68,8 @@ func GetUTXO(rpc, hash string, index uint64) (*common.UTXOWithLock, error) {
 	utxo.Amount = out.Amount
 	utxo.Keys = out.Keys
 	utxo.Script = out.Script
-	utxo.Mask = *out.Mask
+	if out.Mask != nil {
+		utxo.Mask = *out.Mask
+	}
 	return utxo, nil
 }
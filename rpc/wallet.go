@@ -0,0 +1,29 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+type WalletBalance struct {
+	Asset    crypto.Hash    `json:"asset"`
+	Balance  common.Integer `json:"balance"`
+	Topology uint64         `json:"topology"`
+}
+
+func (c *Client) ListOutputs(ctx context.Context, wallet common.Address, offset, count uint64) ([]*common.WalletOutput, error) {
+	var outputs []*common.WalletOutput
+	_, err := c.callInto(ctx, "listoutputs", []any{wallet.String(), offset, count}, &outputs)
+	return outputs, err
+}
+
+func (c *Client) GetBalance(ctx context.Context, wallet common.Address, asset crypto.Hash) (*WalletBalance, error) {
+	var balance WalletBalance
+	ok, err := c.callInto(ctx, "getbalance", []any{wallet.String(), asset.String()}, &balance)
+	if !ok {
+		return nil, err
+	}
+	return &balance, nil
+}
@@ -0,0 +1,12 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+func (c *Client) GetWithdrawalClaim(ctx context.Context, hash crypto.Hash) (*common.VersionedTransaction, string, error) {
+	return c.readTransaction(ctx, "getwithdrawalclaim", []any{hash.String()})
+}
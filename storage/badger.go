@@ -10,6 +10,8 @@ import (
 	"github.com/dgraph-io/badger/v4/options"
 )
 
+var storageLogger = logger.New("storage")
+
 type BadgerStore struct {
 	custom      *config.Custom
 	snapshotsDB *badger.DB
@@ -75,10 +77,10 @@ func openDB(dir string, sync bool, custom *config.Custom) (*badger.DB, error) {
 		go func() {
 			for {
 				lsm, vlog := db.Size()
-				logger.Printf("Badger LSM %d VLOG %d\n", lsm, vlog)
+				storageLogger.Printf("Badger LSM %d VLOG %d\n", lsm, vlog)
 				if lsm > 1024*1024*8 || vlog > 1024*1024*32 {
 					err := db.RunValueLogGC(0.5)
-					logger.Printf("Badger RunValueLogGC %v\n", err)
+					storageLogger.Printf("Badger RunValueLogGC %v\n", err)
 				}
 				time.Sleep(5 * time.Minute)
 			}
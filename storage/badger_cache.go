@@ -2,6 +2,7 @@ package storage
 
 import (
 	"encoding/binary"
+	"fmt"
 	"time"
 
 	"github.com/MixinNetwork/mixin/common"
@@ -13,50 +14,85 @@ const (
 	cachePrefixTransactionQueue = "CACHETRANSACTIONQUEUE"
 	cachePrefixTransactionOrder = "CACHETRANSACTIONORDER"
 	cachePrefixTransactionCache = "CACHETRANSACTIONPAYLOAD"
+	cachePrefixTransactionInput = "CACHETRANSACTIONINPUT"
 )
 
 func (s *BadgerStore) CacheRetrieveTransactions(limit int) ([]*common.VersionedTransaction, error) {
-	var txs []*common.VersionedTransaction
+	entries, err := s.CacheSelectTransactions(limit, limit, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	txs := make([]*common.VersionedTransaction, len(entries))
+	for i, e := range entries {
+		txs[i] = e.Transaction
+	}
+	return txs, nil
+}
+
+// CacheSelectTransactions scans at most window transactions accepted by the
+// filter in the queue order, the rejected ones are skipped and stay queued, so
+// they never take the window of the accepted ones. Then the first limit
+// transactions returned by the order function are removed from the queue, the
+// order function could also drop transactions
+func (s *BadgerStore) CacheSelectTransactions(window, limit int, filter func(*CacheTransaction) bool, order func([]*CacheTransaction) []*CacheTransaction) ([]*CacheTransaction, error) {
+	var selected []*CacheTransaction
 	err := s.cacheDB.Update(func(txn *badger.Txn) error {
-		prefix := []byte(cachePrefixTransactionQueue)
 		opts := badger.DefaultIteratorOptions
 		opts.PrefetchValues = false
-		opts.Prefix = prefix
+		opts.Prefix = []byte(cachePrefixTransactionQueue)
 		it := txn.NewIterator(opts)
 		defer it.Close()
 
 		var processed [][]byte
-		var hash crypto.Hash
-		filter := make(map[crypto.Hash]bool)
-		it.Seek(cacheTransactionQueueKey(0, hash))
-		for ; len(txs) < limit && it.Valid(); it.Next() {
+		var entries []*CacheTransaction
+		keys := make(map[crypto.Hash][]byte)
+		for it.Rewind(); len(entries) < window && it.Valid(); it.Next() {
+			var hash crypto.Hash
 			key := it.Item().KeyCopy(nil)
 			copy(hash[:], key[len(cachePrefixTransactionQueue)+8:])
-			processed = append(processed, key)
-			processed = append(processed, cacheTransactionOrderKey(hash))
-			if filter[hash] {
+			if keys[hash] != nil {
+				processed = append(processed, key)
+				continue
+			}
+			item, err := txn.Get(cacheTransactionCacheKey(hash))
+			if err == badger.ErrKeyNotFound {
+				processed = append(processed, key, cacheTransactionOrderKey(hash))
 				continue
+			} else if err != nil {
+				return err
 			}
-			filter[hash] = true
-			ver, err := s.cacheReadTransaction(txn, hash)
+			entry, err := s.cacheReadEntry(txn, item, hash)
 			if err != nil {
 				return err
 			}
-			if ver != nil {
-				txs = append(txs, ver)
+			if filter != nil && !filter(entry) {
+				continue
 			}
+			keys[hash] = key
+			entries = append(entries, entry)
 		}
 
+		if order != nil {
+			entries = order(entries)
+		}
+		if len(entries) > limit {
+			entries = entries[:limit]
+		}
+		for _, e := range entries {
+			hash := e.Transaction.PayloadHash()
+			processed = append(processed, keys[hash], cacheTransactionOrderKey(hash))
+			e.Queued = false
+		}
 		for _, k := range processed {
 			err := txn.Delete(k)
 			if err != nil {
 				return err
 			}
 		}
+		selected = entries
 		return nil
 	})
-
-	return txs, err
+	return selected, err
 }
 
 func (s *BadgerStore) CacheRemoveTransactions(hashes []crypto.Hash) error {
@@ -64,13 +100,7 @@ func (s *BadgerStore) CacheRemoveTransactions(hashes []crypto.Hash) error {
 	for {
 		err := s.cacheDB.Update(func(txn *badger.Txn) error {
 			for i := range hashes {
-				key := cacheTransactionCacheKey(hashes[i])
-				err := txn.Delete(key)
-				if err != nil {
-					return err
-				}
-				key = cacheTransactionOrderKey(hashes[i])
-				err = txn.Delete(key)
+				err := s.cacheRemoveTransaction(txn, hashes[i])
 				if err != nil {
 					return err
 				}
@@ -87,7 +117,9 @@ func (s *BadgerStore) CacheRemoveTransactions(hashes []crypto.Hash) error {
 	}
 }
 
-func (s *BadgerStore) CachePutTransaction(tx *common.VersionedTransaction) error {
+// CachePutTransaction queues the transaction if not queued yet, the sender is
+// the peer relaying the transaction, or the node itself
+func (s *BadgerStore) CachePutTransaction(tx *common.VersionedTransaction, sender crypto.Hash) error {
 	txn := s.cacheDB.NewTransaction(true)
 	defer txn.Discard()
 
@@ -97,21 +129,36 @@ func (s *BadgerStore) CachePutTransaction(tx *common.VersionedTransaction) error
 	if err == nil {
 		return nil
 	}
-	etr := badger.NewEntry(key, []byte{}).WithTTL(time.Duration(s.custom.Node.CacheTTL) * time.Second)
+	ts := uint64(time.Now().UnixNano())
+	val := binary.BigEndian.AppendUint64(nil, ts)
+	val = append(val, sender[:]...)
+	etr := badger.NewEntry(key, val).WithTTL(time.Duration(s.custom.Node.CacheTTL) * time.Second)
 	err = txn.SetEntry(etr)
 	if err != nil {
 		return err
 	}
 
 	key = cacheTransactionCacheKey(hash)
-	val := tx.Marshal()
+	val = tx.Marshal()
 	etr = badger.NewEntry(key, val).WithTTL(time.Duration(s.custom.Node.CacheTTL+60) * time.Second)
 	err = txn.SetEntry(etr)
 	if err != nil {
 		return err
 	}
 
-	key = cacheTransactionQueueKey(uint64(time.Now().UnixNano()), hash)
+	for _, in := range tx.Inputs {
+		if !in.Hash.HasValue() {
+			continue
+		}
+		key = cacheTransactionInputKey(in.Hash, in.Index)
+		etr = badger.NewEntry(key, hash[:]).WithTTL(time.Duration(s.custom.Node.CacheTTL+60) * time.Second)
+		err = txn.SetEntry(etr)
+		if err != nil {
+			return err
+		}
+	}
+
+	key = cacheTransactionQueueKey(ts, hash)
 	etr = badger.NewEntry(key, []byte{}).WithTTL(time.Duration(s.custom.Node.CacheTTL) * time.Second)
 	err = txn.SetEntry(etr)
 	if err != nil {
@@ -121,6 +168,48 @@ func (s *BadgerStore) CachePutTransaction(tx *common.VersionedTransaction) error
 	return txn.Commit()
 }
 
+// CacheReadConflicts returns the cached transactions spending any input of
+// the transaction, only the latest cached transaction of an input is found
+func (s *BadgerStore) CacheReadConflicts(tx *common.VersionedTransaction) ([]*CacheTransaction, error) {
+	txn := s.cacheDB.NewTransaction(false)
+	defer txn.Discard()
+
+	var conflicts []*CacheTransaction
+	filter := map[crypto.Hash]bool{tx.PayloadHash(): true}
+	for _, in := range tx.Inputs {
+		if !in.Hash.HasValue() {
+			continue
+		}
+		item, err := txn.Get(cacheTransactionInputKey(in.Hash, in.Index))
+		if err == badger.ErrKeyNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		var hash crypto.Hash
+		_, err = item.ValueCopy(hash[:0])
+		if err != nil {
+			return nil, err
+		}
+		if filter[hash] {
+			continue
+		}
+		filter[hash] = true
+		item, err = txn.Get(cacheTransactionCacheKey(hash))
+		if err == badger.ErrKeyNotFound {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		entry, err := s.cacheReadEntry(txn, item, hash)
+		if err != nil {
+			return nil, err
+		}
+		conflicts = append(conflicts, entry)
+	}
+	return conflicts, nil
+}
+
 func (s *BadgerStore) CacheGetTransaction(hash crypto.Hash) (*common.VersionedTransaction, error) {
 	txn := s.cacheDB.NewTransaction(false)
 	defer txn.Discard()
@@ -128,6 +217,190 @@ func (s *BadgerStore) CacheGetTransaction(hash crypto.Hash) (*common.VersionedTr
 	return s.cacheReadTransaction(txn, hash)
 }
 
+// CacheTransaction is a transaction in the cache, the timestamp is when it was
+// cached, and it is queued with the sender until retrieved by the queue loop
+type CacheTransaction struct {
+	Transaction *common.VersionedTransaction
+	Sender      crypto.Hash
+	Timestamp   uint64
+	Queued      bool
+}
+
+type CacheStats struct {
+	Transactions uint64
+	Queued       uint64
+	Size         uint64
+	Oldest       uint64
+}
+
+func (s *BadgerStore) CacheGetTransactionEntry(hash crypto.Hash) (*CacheTransaction, error) {
+	txn := s.cacheDB.NewTransaction(false)
+	defer txn.Discard()
+
+	item, err := txn.Get(cacheTransactionCacheKey(hash))
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return s.cacheReadEntry(txn, item, hash)
+}
+
+// CacheListTransactions lists the cached transactions ordered by hash after the
+// offset hash, and only the transactions passing the filter are counted
+func (s *BadgerStore) CacheListTransactions(offset crypto.Hash, count uint64, filter func(*CacheTransaction) bool) ([]*CacheTransaction, error) {
+	if count > 500 {
+		return nil, fmt.Errorf("count %d too large, the maximum is 500", count)
+	}
+
+	txn := s.cacheDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte(cachePrefixTransactionCache)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	txs := make([]*CacheTransaction, 0)
+	for it.Seek(cacheTransactionCacheKey(offset)); it.Valid() && uint64(len(txs)) < count; it.Next() {
+		item := it.Item()
+		var hash crypto.Hash
+		copy(hash[:], item.Key()[len(cachePrefixTransactionCache):])
+		if hash == offset {
+			continue
+		}
+		entry, err := s.cacheReadEntry(txn, item, hash)
+		if err != nil {
+			return nil, err
+		}
+		if filter != nil && !filter(entry) {
+			continue
+		}
+		txs = append(txs, entry)
+	}
+	return txs, nil
+}
+
+func (s *BadgerStore) CacheStats() (*CacheStats, error) {
+	txn := s.cacheDB.NewTransaction(false)
+	defer txn.Discard()
+
+	var stats CacheStats
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = []byte(cachePrefixTransactionCache)
+	it := txn.NewIterator(opts)
+	for it.Rewind(); it.Valid(); it.Next() {
+		item := it.Item()
+		ts := s.cacheTimestamp(item)
+		if stats.Oldest == 0 || ts < stats.Oldest {
+			stats.Oldest = ts
+		}
+		stats.Transactions += 1
+		stats.Size += uint64(item.ValueSize())
+	}
+	it.Close()
+
+	opts.Prefix = []byte(cachePrefixTransactionOrder)
+	it = txn.NewIterator(opts)
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		stats.Queued += 1
+	}
+	return &stats, nil
+}
+
+func (s *BadgerStore) cacheReadEntry(txn *badger.Txn, item *badger.Item, hash crypto.Hash) (*CacheTransaction, error) {
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	ver, err := common.UnmarshalVersionedTransaction(val)
+	if err != nil {
+		return nil, err
+	}
+	entry := &CacheTransaction{Transaction: ver, Timestamp: s.cacheTimestamp(item)}
+	order, err := txn.Get(cacheTransactionOrderKey(hash))
+	if err == badger.ErrKeyNotFound {
+		return entry, nil
+	} else if err != nil {
+		return nil, err
+	}
+	entry.Queued = true
+	val, err = order.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(val) == 40 {
+		copy(entry.Sender[:], val[8:])
+	}
+	return entry, nil
+}
+
+func (s *BadgerStore) cacheRemoveTransaction(txn *badger.Txn, hash crypto.Hash) error {
+	key := cacheTransactionOrderKey(hash)
+	item, err := txn.Get(key)
+	if err == nil {
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if len(val) == 40 {
+			ts := binary.BigEndian.Uint64(val[:8])
+			err = txn.Delete(cacheTransactionQueueKey(ts, hash))
+			if err != nil {
+				return err
+			}
+		}
+	} else if err != badger.ErrKeyNotFound {
+		return err
+	}
+	err = txn.Delete(key)
+	if err != nil {
+		return err
+	}
+
+	ver, err := s.cacheReadTransaction(txn, hash)
+	if err != nil || ver == nil {
+		return err
+	}
+	for _, in := range ver.Inputs {
+		if !in.Hash.HasValue() {
+			continue
+		}
+		key := cacheTransactionInputKey(in.Hash, in.Index)
+		item, err := txn.Get(key)
+		if err == badger.ErrKeyNotFound {
+			continue
+		} else if err != nil {
+			return err
+		}
+		var by crypto.Hash
+		_, err = item.ValueCopy(by[:0])
+		if err != nil {
+			return err
+		}
+		if by != hash {
+			continue
+		}
+		err = txn.Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+	return txn.Delete(cacheTransactionCacheKey(hash))
+}
+
+// the payload expires 60 seconds after the cache TTL since it was cached
+func (s *BadgerStore) cacheTimestamp(item *badger.Item) uint64 {
+	ttl := uint64(s.custom.Node.CacheTTL + 60)
+	at := item.ExpiresAt()
+	if at < ttl {
+		return 0
+	}
+	return (at - ttl) * uint64(time.Second)
+}
+
 func (s *BadgerStore) cacheReadTransaction(txn *badger.Txn, tx crypto.Hash) (*common.VersionedTransaction, error) {
 	key := cacheTransactionCacheKey(tx)
 	item, err := txn.Get(key)
@@ -156,3 +429,8 @@ func cacheTransactionQueueKey(ts uint64, hash crypto.Hash) []byte {
 func cacheTransactionOrderKey(hash crypto.Hash) []byte {
 	return append([]byte(cachePrefixTransactionOrder), hash[:]...)
 }
+
+func cacheTransactionInputKey(hash crypto.Hash, index uint) []byte {
+	key := append([]byte(cachePrefixTransactionInput), hash[:]...)
+	return binary.BigEndian.AppendUint64(key, uint64(index))
+}
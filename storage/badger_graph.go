@@ -9,7 +9,6 @@ import (
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
-	"github.com/MixinNetwork/mixin/logger"
 	"github.com/dgraph-io/badger/v4"
 )
 
@@ -37,6 +36,20 @@ const (
 	graphPrefixAssetTotal        = "ASSETTOTAL"
 	graphPrefixCustodianUpdate   = "CUSTODIANUPDATE"
 	graphPrefixConsensusSnapshot = "CONSENSUSSNAPSHOT"
+	graphPrefixPruneCheckpoint   = "PRUNECHECKPOINT" // the next round to prune of each node
+
+	graphPrefixInscriptionCollection = "INSCRIPTIONCOLLECTION"
+	graphPrefixInscriptionItem       = "INSCRIPTIONITEM"
+	graphPrefixInscriptionHash       = "INSCRIPTIONHASH"    // inscription transaction hash to item key
+	graphPrefixInscriptionOwner      = "INSCRIPTIONOWNER"   // NFT UTXO to item key
+	graphPrefixInscriptionContent    = "INSCRIPTIONCONTENT" // content checksum to sequence, the first occurrence is valid
+	graphPrefixInscriptionChecksum   = "INSCRIPTIONCHECKSUM"
+
+	graphPrefixWalletState   = "WALLETSTATE" // topology checkpoint and output sequence
+	graphPrefixWalletOutput  = "WALLETOUTPUT"
+	graphPrefixWalletUTXO    = "WALLETUTXO"  // owned UTXO to output sequence
+	graphPrefixWalletGhost   = "WALLETGHOST" // ghost key of owned output to output sequence
+	graphPrefixWalletBalance = "WALLETBALANCE"
 )
 
 func (s *BadgerStore) WriteConsensusSnapshot(snap *common.Snapshot, tx *common.VersionedTransaction, hack *common.Snapshot) error {
@@ -55,7 +68,7 @@ func writeConsensusSnapshot(txn *badger.Txn, snap *common.Snapshot, tx *common.V
 		panic(snap.PayloadHash())
 	}
 	if len(tx.Inputs) == 1 && tx.Inputs[0].Mint != nil {
-		logger.Printf("writeConsensusSnapshot(%s) => mint", snap.SoleTransaction())
+		storageLogger.Printf("writeConsensusSnapshot(%s) => mint", snap.SoleTransaction())
 	} else {
 		out := tx.Outputs[0]
 		switch out.Type {
@@ -68,7 +81,7 @@ func writeConsensusSnapshot(txn *badger.Txn, snap *common.Snapshot, tx *common.V
 		default:
 			panic(out.Type)
 		}
-		logger.Printf("writeConsensusSnapshot(%s) => %d", snap.SoleTransaction(), out.Type)
+		storageLogger.Printf("writeConsensusSnapshot(%s) => %d", snap.SoleTransaction(), out.Type)
 	}
 
 	isGenesis := len(tx.Inputs) == 1 && tx.Inputs[0].Genesis != nil
@@ -208,7 +221,7 @@ func readSnapshotsForNodeRound(txn *badger.Txn, nodeId crypto.Hash, round uint64
 }
 
 func (s *BadgerStore) WriteSnapshot(snap *common.SnapshotWithTopologicalOrder, signers []crypto.Hash) error {
-	logger.Debugf("BadgerStore.WriteSnapshot(%v)", snap.Snapshot)
+	storageLogger.Debugf("BadgerStore.WriteSnapshot(%v)", snap.Snapshot)
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
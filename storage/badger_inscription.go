@@ -0,0 +1,409 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/dgraph-io/badger/v4"
+)
+
+func (s *BadgerStore) ReadInscriptionCollection(hash crypto.Hash) (*common.InscriptionCollection, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	return readInscriptionCollection(txn, hash)
+}
+
+func (s *BadgerStore) ReadInscriptionItem(hash crypto.Hash) (*common.InscriptionItem, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	return readInscriptionItemByHash(txn, hash)
+}
+
+func (s *BadgerStore) ReadInscriptionItems(collection crypto.Hash, offset, count uint64) ([]*common.InscriptionItem, error) {
+	if count > 500 {
+		return nil, fmt.Errorf("count %d too large, the maximum is 500", count)
+	}
+
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = append([]byte(graphPrefixInscriptionItem), collection[:]...)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	items := make([]*common.InscriptionItem, 0)
+	it.Seek(graphInscriptionItemKey(collection, offset))
+	for ; it.Valid() && uint64(len(items)) < count; it.Next() {
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		var item common.InscriptionItem
+		err = json.Unmarshal(val, &item)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, &item)
+	}
+	return items, nil
+}
+
+// writeInscriptionOperation indexes the inscription protocol operations
+// of a finalized transaction, all invalid operations are ignored
+func writeInscriptionOperation(txn *badger.Txn, ver *common.VersionedTransaction, timestamp uint64) error {
+	err := releaseInscriptionOwners(txn, ver)
+	if err != nil {
+		return err
+	}
+
+	op := common.ParseInscriptionOperation(ver.Extra)
+	switch op {
+	case common.InscriptionOperationDeploy:
+		return writeInscriptionDeployment(txn, ver, timestamp)
+	case common.InscriptionOperationInscribe:
+		return writeInscriptionInscribe(txn, ver, timestamp)
+	case common.InscriptionOperationDistribute:
+		return writeInscriptionDistribution(txn, ver)
+	case common.InscriptionOperationOccupy:
+		return writeInscriptionOccupation(txn, ver)
+	}
+	return nil
+}
+
+func writeInscriptionDeployment(txn *badger.Txn, ver *common.VersionedTransaction, timestamp uint64) error {
+	if ver.Asset != common.XINAssetId {
+		return nil
+	}
+	d, err := common.ParseDeployment(ver.Extra)
+	if err != nil {
+		storageLogger.Verbosef("writeInscriptionDeployment(%s) => %v", ver.PayloadHash(), err)
+		return nil
+	}
+
+	hash := ver.PayloadHash()
+	old, err := readInscriptionCollection(txn, hash)
+	if err != nil || old != nil {
+		return err
+	}
+	checksums, err := d.Checksums()
+	if err != nil {
+		panic(err)
+	}
+	for i, c := range checksums {
+		key := graphInscriptionChecksumKey(hash, c)
+		err = txn.Set(key, binary.BigEndian.AppendUint64(nil, uint64(i)))
+		if err != nil {
+			return err
+		}
+	}
+	return writeInscriptionCollection(txn, &common.InscriptionCollection{
+		Hash:       hash,
+		Deployment: d,
+		Timestamp:  timestamp,
+	})
+}
+
+func writeInscriptionInscribe(txn *badger.Txn, ver *common.VersionedTransaction, timestamp uint64) error {
+	if ver.Asset != common.XINAssetId || len(ver.References) == 0 {
+		return nil
+	}
+	hash := ver.PayloadHash()
+	ins, err := common.ParseInscription(ver.Extra)
+	if err != nil {
+		storageLogger.Verbosef("writeInscriptionInscribe(%s) => %v", hash, err)
+		return nil
+	}
+	collection, err := readInscriptionCollection(txn, ver.References[0])
+	if err != nil || collection == nil {
+		return err
+	}
+	if collection.Inscriptions >= collection.Deployment.Total() {
+		return nil
+	}
+
+	var checksum crypto.Hash
+	if ins.Content != "" {
+		data, mime, err := common.DecodeInscriptionContent(ins.Content)
+		if err != nil {
+			panic(err)
+		}
+		checksum = crypto.Blake3Hash(data)
+		valid, err := validateInscriptionContent(txn, collection, checksum, data, mime)
+		if err != nil || !valid {
+			return err
+		}
+		key := graphInscriptionContentKey(collection.Hash, checksum)
+		err = txn.Set(key, binary.BigEndian.AppendUint64(nil, collection.Inscriptions))
+		if err != nil {
+			return err
+		}
+	} else if collection.Deployment.Validation != "" {
+		return nil
+	}
+
+	item := &common.InscriptionItem{
+		Collection:  collection.Hash,
+		Sequence:    collection.Inscriptions,
+		Hash:        hash,
+		Recipient:   ins.Recipient,
+		ContentHash: checksum,
+		State:       common.InscriptionStateInscribed,
+		Timestamp:   timestamp,
+	}
+	err = writeInscriptionItem(txn, item)
+	if err != nil {
+		return err
+	}
+	key := graphInscriptionHashKey(hash)
+	err = txn.Set(key, graphInscriptionItemKey(item.Collection, item.Sequence))
+	if err != nil {
+		return err
+	}
+	collection.Inscriptions += 1
+	return writeInscriptionCollection(txn, collection)
+}
+
+func validateInscriptionContent(txn *badger.Txn, collection *common.InscriptionCollection, checksum crypto.Hash, data []byte, mime string) (bool, error) {
+	key := graphInscriptionContentKey(collection.Hash, checksum)
+	_, err := txn.Get(key)
+	if err == nil {
+		return false, nil
+	} else if err != badger.ErrKeyNotFound {
+		return false, err
+	}
+
+	d := collection.Deployment
+	if re, _ := d.Regexp(); re != nil {
+		return strings.HasPrefix(mime, "text/") && re.Match(data), nil
+	}
+	if d.Validation == "" {
+		return true, nil
+	}
+	key = graphInscriptionChecksumKey(collection.Hash, checksum)
+	_, err = txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func writeInscriptionDistribution(txn *badger.Txn, ver *common.VersionedTransaction) error {
+	if len(ver.References) == 0 || len(ver.Outputs) == 0 {
+		return nil
+	}
+	dist, err := common.ParseDistribution(ver.Extra)
+	if err != nil {
+		return nil
+	}
+	item, err := readInscriptionItemByHash(txn, ver.References[0])
+	if err != nil || item == nil {
+		return err
+	}
+	collection, err := readInscriptionCollection(txn, item.Collection)
+	if err != nil {
+		return err
+	}
+	d := collection.Deployment
+	switch {
+	case item.State != common.InscriptionStateInscribed:
+		return nil
+	case dist.Sequence != item.Sequence || dist.Sequence != collection.Distributions:
+		return nil
+	case d.Mode == common.InscriptionModeDone && collection.Inscriptions < d.Total():
+		return nil
+	case collection.Asset.HasValue() && collection.Asset != ver.Asset:
+		return nil
+	case ver.Outputs[0].Amount.Cmp(d.InscriptionAmount()) != 0:
+		return nil
+	}
+
+	collection.Asset = ver.Asset
+	collection.Distributions += 1
+	err = writeInscriptionCollection(txn, collection)
+	if err != nil {
+		return err
+	}
+	item.State = common.InscriptionStateDistributed
+	return writeInscriptionOwner(txn, item, ver.PayloadHash(), 0)
+}
+
+func writeInscriptionOccupation(txn *badger.Txn, ver *common.VersionedTransaction) error {
+	if len(ver.References) == 0 || len(ver.Outputs) == 0 {
+		return nil
+	}
+	occ, err := common.ParseOccupation(ver.Extra)
+	if err != nil {
+		return nil
+	}
+	item, err := readInscriptionItemByHash(txn, ver.References[0])
+	if err != nil || item == nil {
+		return err
+	}
+	collection, err := readInscriptionCollection(txn, item.Collection)
+	if err != nil {
+		return err
+	}
+	switch {
+	case item.State != common.InscriptionStateReleased:
+		return nil
+	case occ.Sequence != item.Sequence:
+		return nil
+	case collection.Asset != ver.Asset:
+		return nil
+	case ver.Outputs[0].Amount.Cmp(collection.Deployment.UnitAmount()) != 0:
+		return nil
+	}
+
+	item.State = common.InscriptionStateOccupied
+	return writeInscriptionOwner(txn, item, ver.PayloadHash(), 0)
+}
+
+// releaseInscriptionOwners transfers the NFT if the owner UTXO is spent
+// as a whole to the first output, otherwise the NFT is released
+func releaseInscriptionOwners(txn *badger.Txn, ver *common.VersionedTransaction) error {
+	for _, in := range ver.Inputs {
+		if len(in.Genesis) > 0 || in.Deposit != nil || in.Mint != nil {
+			continue
+		}
+		key := graphInscriptionOwnerKey(in.Hash, in.Index)
+		item, err := readInscriptionItemByIndex(txn, key)
+		if err != nil {
+			return err
+		}
+		if item == nil {
+			continue
+		}
+		err = txn.Delete(key)
+		if err != nil {
+			return err
+		}
+
+		collection, err := readInscriptionCollection(txn, item.Collection)
+		if err != nil {
+			return err
+		}
+		amount := collection.Deployment.InscriptionAmount()
+		if item.State == common.InscriptionStateOccupied {
+			amount = collection.Deployment.UnitAmount()
+		}
+		if len(ver.Inputs) == 1 && len(ver.Outputs) > 0 && ver.Outputs[0].Amount.Cmp(amount) == 0 {
+			err = writeInscriptionOwner(txn, item, ver.PayloadHash(), 0)
+		} else {
+			item.State = common.InscriptionStateReleased
+			item.OwnerHash, item.OwnerIndex = crypto.Hash{}, 0
+			err = writeInscriptionItem(txn, item)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeInscriptionOwner(txn *badger.Txn, item *common.InscriptionItem, hash crypto.Hash, index uint) error {
+	item.OwnerHash, item.OwnerIndex = hash, index
+	err := writeInscriptionItem(txn, item)
+	if err != nil {
+		return err
+	}
+	key := graphInscriptionOwnerKey(hash, index)
+	return txn.Set(key, graphInscriptionItemKey(item.Collection, item.Sequence))
+}
+
+func readInscriptionCollection(txn *badger.Txn, hash crypto.Hash) (*common.InscriptionCollection, error) {
+	key := graphInscriptionCollectionKey(hash)
+	item, err := txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	var c common.InscriptionCollection
+	err = json.Unmarshal(val, &c)
+	return &c, err
+}
+
+func writeInscriptionCollection(txn *badger.Txn, c *common.InscriptionCollection) error {
+	val, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	key := graphInscriptionCollectionKey(c.Hash)
+	return txn.Set(key, val)
+}
+
+func readInscriptionItemByHash(txn *badger.Txn, hash crypto.Hash) (*common.InscriptionItem, error) {
+	return readInscriptionItemByIndex(txn, graphInscriptionHashKey(hash))
+}
+
+func readInscriptionItemByIndex(txn *badger.Txn, index []byte) (*common.InscriptionItem, error) {
+	item, err := txn.Get(index)
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	key, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	item, err = txn.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	var i common.InscriptionItem
+	err = json.Unmarshal(val, &i)
+	return &i, err
+}
+
+func writeInscriptionItem(txn *badger.Txn, i *common.InscriptionItem) error {
+	val, err := json.Marshal(i)
+	if err != nil {
+		panic(err)
+	}
+	key := graphInscriptionItemKey(i.Collection, i.Sequence)
+	return txn.Set(key, val)
+}
+
+func graphInscriptionCollectionKey(hash crypto.Hash) []byte {
+	return append([]byte(graphPrefixInscriptionCollection), hash[:]...)
+}
+
+func graphInscriptionItemKey(collection crypto.Hash, sequence uint64) []byte {
+	key := append([]byte(graphPrefixInscriptionItem), collection[:]...)
+	return binary.BigEndian.AppendUint64(key, sequence)
+}
+
+func graphInscriptionHashKey(hash crypto.Hash) []byte {
+	return append([]byte(graphPrefixInscriptionHash), hash[:]...)
+}
+
+func graphInscriptionOwnerKey(hash crypto.Hash, index uint) []byte {
+	key := append([]byte(graphPrefixInscriptionOwner), hash[:]...)
+	return binary.BigEndian.AppendUint64(key, uint64(index))
+}
+
+func graphInscriptionContentKey(collection, checksum crypto.Hash) []byte {
+	key := append([]byte(graphPrefixInscriptionContent), collection[:]...)
+	return append(key, checksum[:]...)
+}
+
+func graphInscriptionChecksumKey(collection, checksum crypto.Hash) []byte {
+	key := append([]byte(graphPrefixInscriptionChecksum), collection[:]...)
+	return append(key, checksum[:]...)
+}
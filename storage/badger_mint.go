@@ -102,6 +102,22 @@ func (s *BadgerStore) ReadLastMintDistribution(batch uint64) (*common.MintDistri
 	return nil, nil
 }
 
+// ReadMintLock returns the distribution locked for the mint batch, no matter
+// the transaction finalized or not, and nil if not locked
+func (s *BadgerStore) ReadMintLock(mint *common.MintData) (*common.MintDistribution, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	dist, err := readMintInput(txn, mint)
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	return dist, err
+}
+
 func (s *BadgerStore) LockMintInput(mint *common.MintData, tx crypto.Hash, fork bool) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
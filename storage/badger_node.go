@@ -251,17 +251,16 @@ func nodeSignerFromStateKey(key []byte) (common.Address, uint64) {
 	key = key[len(graphPrefixNodeStateQueue):]
 	ts := binary.BigEndian.Uint64(key[:8])
 	copy(publicSpend[:], key[8:])
-	privateView := publicSpend.DeterministicHashDerive()
-	return common.Address{
-		PrivateViewKey: privateView,
-		PublicViewKey:  privateView.Public(),
-		PublicSpendKey: publicSpend,
-	}, ts
+	return nodeAddressFromSpendKey(publicSpend), ts
 }
 
 func nodePayee(ival []byte) common.Address {
 	var publicSpend crypto.Key
 	copy(publicSpend[:], ival[:len(publicSpend)])
+	return nodeAddressFromSpendKey(publicSpend)
+}
+
+func nodeAddressFromSpendKey(publicSpend crypto.Key) common.Address {
 	privateView := publicSpend.DeterministicHashDerive()
 	return common.Address{
 		PrivateViewKey: privateView,
@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"encoding/binary"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/dgraph-io/badger/v4"
+)
+
+const (
+	graphPrefixPeerBan     = "PEERBAN"  // the p2p peer banned until the unix nano
+	graphPrefixPeerAddress = "PEERADDR" // the p2p relayer address book entry
+)
+
+// WritePeerBan bans the peer until the timestamp, or removes the ban if zero
+func (s *BadgerStore) WritePeerBan(id crypto.Hash, until uint64) error {
+	return s.snapshotsDB.Update(func(txn *badger.Txn) error {
+		key := append([]byte(graphPrefixPeerBan), id[:]...)
+		if until == 0 {
+			return txn.Delete(key)
+		}
+		return txn.Set(key, binary.BigEndian.AppendUint64(nil, until))
+	})
+}
+
+func (s *BadgerStore) ReadPeerBans() (map[crypto.Hash]uint64, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte(graphPrefixPeerBan)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	bans := make(map[crypto.Hash]uint64)
+	for it.Seek(opts.Prefix); it.Valid(); it.Next() {
+		item := it.Item()
+		var id crypto.Hash
+		copy(id[:], item.Key()[len(opts.Prefix):])
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		bans[id] = binary.BigEndian.Uint64(val)
+	}
+	return bans, nil
+}
+
+// WritePeerAddress writes the encoded address book entry of the relayer, or
+// removes it if the data is empty
+func (s *BadgerStore) WritePeerAddress(id crypto.Hash, data []byte) error {
+	return s.snapshotsDB.Update(func(txn *badger.Txn) error {
+		key := append([]byte(graphPrefixPeerAddress), id[:]...)
+		if len(data) == 0 {
+			return txn.Delete(key)
+		}
+		return txn.Set(key, data)
+	})
+}
+
+func (s *BadgerStore) ReadPeerAddresses() (map[crypto.Hash][]byte, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte(graphPrefixPeerAddress)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	addresses := make(map[crypto.Hash][]byte)
+	for it.Seek(opts.Prefix); it.Valid(); it.Next() {
+		item := it.Item()
+		var id crypto.Hash
+		copy(id[:], item.Key()[len(opts.Prefix):])
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		addresses[id] = val
+	}
+	return addresses, nil
+}
@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"errors"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// ErrPruned is returned when the requested history has been removed by the
+// ledger pruning, only the finalization and unique markers are kept
+var ErrPruned = errors.New("history pruned")
+
+func (s *BadgerStore) ReadPruneCheckpoint(nodeId crypto.Hash) (uint64, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	return graphReadUint64(txn, graphPruneCheckpointKey(nodeId))
+}
+
+// PruneNodeRounds removes the spent history of the node rounds from the prune
+// checkpoint until the round before, at most limit rounds each call, and
+// returns the new checkpoint. A round is pruned only when the transactions of
+// all its snapshots are scripts or deposits and all their outputs have been
+// consumed by finalized transactions of the same kinds, so the node, custodian,
+// mint and withdrawal history needed by the validation is always kept.
+func (s *BadgerStore) PruneNodeRounds(nodeId crypto.Hash, before uint64, limit int) (uint64, error) {
+	checkpoint, err := s.ReadPruneCheckpoint(nodeId)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < limit && checkpoint < before; i++ {
+		err = s.pruneNodeRound(nodeId, checkpoint)
+		if err != nil {
+			return checkpoint, err
+		}
+		checkpoint = checkpoint + 1
+	}
+	return checkpoint, nil
+}
+
+func (s *BadgerStore) pruneNodeRound(nodeId crypto.Hash, number uint64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	txn := s.snapshotsDB.NewTransaction(true)
+	defer txn.Discard()
+
+	old, err := graphReadUint64(txn, graphPruneCheckpointKey(nodeId))
+	if err != nil {
+		return err
+	}
+	if old != number {
+		return nil
+	}
+
+	snapshots, err := readSnapshotsForNodeRound(txn, nodeId, number)
+	if err != nil {
+		return err
+	}
+	prunable, err := roundPrunable(txn, snapshots)
+	if err != nil {
+		return err
+	}
+	if prunable {
+		for _, snap := range snapshots {
+			err := pruneSnapshot(txn, snap)
+			if err != nil {
+				return err
+			}
+		}
+		_, _, hash := computeRoundHash(nodeId, number, snapshots)
+		err = txn.Delete(graphRoundKey(hash))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = graphWriteUint64(txn, graphPruneCheckpointKey(nodeId), number+1)
+	if err != nil {
+		return err
+	}
+	return txn.Commit()
+}
+
+// roundPrunable tells whether all snapshots of the round could be pruned,
+// a round is pruned as a whole or kept as a whole, so the snapshots left
+// always match the round hash
+func roundPrunable(txn *badger.Txn, snapshots []*common.SnapshotWithTopologicalOrder) (bool, error) {
+	if len(snapshots) == 0 {
+		return false, nil
+	}
+	for _, snap := range snapshots {
+		ver, final, err := readTransactionAndFinalization(txn, snap.SoleTransaction())
+		if err != nil || final == "" {
+			return false, err
+		}
+		if ver == nil {
+			continue
+		}
+		ok, err := transactionPrunable(txn, ver)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func pruneSnapshot(txn *badger.Txn, snap *common.SnapshotWithTopologicalOrder) error {
+	hash := snap.SoleTransaction()
+	ver, err := readTransaction(txn, hash)
+	if err != nil {
+		return err
+	}
+	if ver != nil {
+		for _, utxo := range ver.UnspentOutputs() {
+			err = txn.Delete(graphUtxoKey(utxo.Hash, utxo.Index))
+			if err != nil {
+				return err
+			}
+		}
+		err = txn.Delete(graphTransactionKey(hash))
+		if err != nil {
+			return err
+		}
+	}
+
+	item, err := txn.Get(graphSnapTopologyKey(snap.Hash))
+	if err != nil {
+		return err
+	}
+	topo, err := item.ValueCopy(nil)
+	if err != nil {
+		return err
+	}
+	err = txn.Delete(topo)
+	if err != nil {
+		return err
+	}
+	key := graphSnapshotKey(snap.NodeId, snap.RoundNumber, hash)
+	return txn.Delete(key)
+}
+
+func transactionPrunable(txn *badger.Txn, ver *common.VersionedTransaction) (bool, error) {
+	switch ver.TransactionType() {
+	case common.TransactionTypeScript:
+	case common.TransactionTypeDeposit:
+	default:
+		return false, nil
+	}
+	for _, utxo := range ver.UnspentOutputs() {
+		item, err := txn.Get(graphUtxoKey(utxo.Hash, utxo.Index))
+		if err == badger.ErrKeyNotFound {
+			return false, nil
+		} else if err != nil {
+			return false, err
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return false, err
+		}
+		out, err := common.UnmarshalUTXO(val)
+		if err != nil || !out.LockHash.HasValue() {
+			return false, err
+		}
+		lock, final, err := readTransactionAndFinalization(txn, out.LockHash)
+		if err != nil || final == "" {
+			return false, err
+		}
+		if lock == nil {
+			continue
+		}
+		switch lock.TransactionType() {
+		case common.TransactionTypeScript:
+		case common.TransactionTypeDeposit:
+		default:
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func graphPruneCheckpointKey(nodeId crypto.Hash) []byte {
+	return append([]byte(graphPrefixPruneCheckpoint), nodeId[:]...)
+}
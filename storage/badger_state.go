@@ -0,0 +1,584 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/dgraph-io/badger/v4"
+	"github.com/zeebo/blake3"
+)
+
+const (
+	StateFileMagic   = "MIXINSTATE"
+	StateFileVersion = 2
+)
+
+// the entries of these prefixes are the ledger state and exported fully,
+// the snapshots, transactions and rounds are exported per node
+var stateFullPrefixes = []string{
+	graphPrefixGhost,
+	graphPrefixUTXO,
+	graphPrefixDeposit,
+	graphPrefixWithdrawal,
+	graphPrefixMint,
+	graphPrefixFinalization,
+	graphPrefixLink,
+	graphPrefixWorkLead,
+	graphPrefixWorkSign,
+	graphPrefixWorkOffset,
+	graphPrefixWorkSnapshot,
+	graphPrefixSpaceCheckpoint,
+	graphPrefixSpaceQueue,
+	graphPrefixAssetInfo,
+	graphPrefixAssetTotal,
+	graphPrefixCustodianUpdate,
+	graphPrefixConsensusSnapshot,
+	graphPrefixNodeStateQueue,
+	graphPrefixNodeOperation,
+	graphPrefixInscriptionCollection,
+	graphPrefixInscriptionItem,
+	graphPrefixInscriptionHash,
+	graphPrefixInscriptionOwner,
+	graphPrefixInscriptionContent,
+	graphPrefixInscriptionChecksum,
+}
+
+var stateRoundPrefixes = []string{
+	graphPrefixRound,
+	graphPrefixSnapshot,
+	graphPrefixSnapTopology,
+	graphPrefixTopology,
+	graphPrefixTransaction,
+	graphPrefixUnique,
+	graphPrefixPruneCheckpoint,
+}
+
+// StateHeader describes a state file, all entries are read from the same
+// database snapshot, and the snapshot is the last one at the topology. The
+// UTXOs is the hash of all UTXO entries, and each of them should be checked
+// against the finalized snapshot of its transaction
+type StateHeader struct {
+	Version   uint8
+	NetworkId crypto.Hash
+	Topology  uint64
+	Snapshot  crypto.Hash
+	Rounds    uint64
+	UTXOs     crypto.Hash
+}
+
+type stateWriter struct {
+	file    io.Writer
+	w       *bufio.Writer
+	hasher  hash.Hash
+	entries int
+}
+
+// ExportState writes the ledger state to the file, with the full history of
+// the latest rounds of each node and the history needed by the validation
+// before them, like a node pruned with the rounds as the window
+func (s *BadgerStore) ExportState(path string, networkId crypto.Hash, rounds uint64) (*StateHeader, int, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	topo := readLastTopology(txn)
+	snaps, err := readSnapshotsSinceTopology(txn, topo, 1)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(snaps) != 1 {
+		return nil, 0, fmt.Errorf("no snapshot at topology %d", topo)
+	}
+	utxos, err := readStateUTXOs(txn, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	header := &StateHeader{
+		Version:   StateFileVersion,
+		NetworkId: networkId,
+		Topology:  topo,
+		Snapshot:  snaps[0].PayloadHash(),
+		Rounds:    rounds,
+		UTXOs:     utxos,
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	hasher := blake3.New()
+	sw := &stateWriter{
+		file:   f,
+		w:      bufio.NewWriter(io.MultiWriter(f, hasher)),
+		hasher: hasher,
+	}
+	err = sw.writeHeader(header)
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, prefix := range stateFullPrefixes {
+		err = exportStatePrefix(txn, sw, []byte(prefix))
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	for _, n := range readAllNodes(txn, ^uint64(0), false) {
+		err = exportStateNodeRounds(txn, sw, n.IdForNetwork(networkId), rounds)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	err = sw.finish()
+	if err != nil {
+		return nil, 0, err
+	}
+	return header, sw.entries, f.Sync()
+}
+
+// ImportState verifies the checksum of the state file and imports it to a
+// new store in the staging directory, then the verify is called with the
+// staging store, and the entries are copied to this store, which must be
+// empty, only if the verify passes. The staging directory is always removed.
+func (s *BadgerStore) ImportState(path string, networkId crypto.Hash, staging string, verify func(*BadgerStore, *StateHeader) error) (*StateHeader, int, error) {
+	header, _, err := readStateFile(path, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if header.NetworkId != networkId {
+		return nil, 0, fmt.Errorf("state network %s not match %s", header.NetworkId, networkId)
+	}
+	if !s.stateEmpty() {
+		return nil, 0, fmt.Errorf("import state to a non-empty store")
+	}
+
+	err = os.RemoveAll(staging)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer os.RemoveAll(staging)
+	stage, err := NewBadgerStore(s.custom, staging)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer stage.Close()
+
+	wb := stage.snapshotsDB.NewWriteBatch()
+	defer wb.Cancel()
+	header, entries, err := readStateFile(path, wb.Set)
+	if err != nil {
+		return nil, 0, err
+	}
+	err = wb.Flush()
+	if err != nil {
+		return nil, 0, err
+	}
+	utxos, err := stage.ReadStateUTXOs(nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if utxos != header.UTXOs {
+		return nil, 0, fmt.Errorf("invalid state utxos hash %s %s", utxos, header.UTXOs)
+	}
+	err = verify(stage, header)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.stateEmpty() {
+		return nil, 0, fmt.Errorf("import state to a non-empty store")
+	}
+	return header, entries, stage.copyState(s)
+}
+
+func (s *BadgerStore) stateEmpty() bool {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	it := txn.NewIterator(badger.IteratorOptions{Prefix: []byte(graphPrefixTopology)})
+	defer it.Close()
+	it.Rewind()
+	return !it.Valid()
+}
+
+func (s *BadgerStore) copyState(dst *BadgerStore) error {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	wb := dst.snapshotsDB.NewWriteBatch()
+	defer wb.Cancel()
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		item := it.Item()
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		err = wb.Set(item.KeyCopy(nil), val)
+		if err != nil {
+			return err
+		}
+	}
+	return wb.Flush()
+}
+
+// ReadStateUTXOs calls the fn with all UTXO entries in the key order, and
+// returns the hash of them as the UTXOs of the state header
+func (s *BadgerStore) ReadStateUTXOs(fn func(*common.UTXOWithLock) error) (crypto.Hash, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	return readStateUTXOs(txn, fn)
+}
+
+func readStateUTXOs(txn *badger.Txn, fn func(*common.UTXOWithLock) error) (crypto.Hash, error) {
+	prefix := []byte(graphPrefixUTXO)
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var hash crypto.Hash
+	hasher := blake3.New()
+	for it.Seek(prefix); it.Valid(); it.Next() {
+		item := it.Item()
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return hash, err
+		}
+		buf := binary.BigEndian.AppendUint16(nil, uint16(len(item.Key())))
+		buf = append(buf, item.Key()...)
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(val)))
+		hasher.Write(append(buf, val...))
+		if fn == nil {
+			continue
+		}
+		utxo, err := common.UnmarshalUTXO(val)
+		if err != nil {
+			return hash, err
+		}
+		err = fn(utxo)
+		if err != nil {
+			return hash, err
+		}
+	}
+	copy(hash[:], hasher.Sum(nil))
+	return hash, nil
+}
+
+func readStateFile(path string, fn func(key, val []byte) error) (*StateHeader, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	hasher := blake3.New()
+	br := bufio.NewReader(f)
+	r := io.TeeReader(br, hasher)
+
+	magic := make([]byte, len(StateFileMagic))
+	_, err = io.ReadFull(r, magic)
+	if err != nil {
+		return nil, 0, err
+	}
+	if string(magic) != StateFileMagic {
+		return nil, 0, fmt.Errorf("invalid state file magic %x", magic)
+	}
+	buf := make([]byte, 1+32+8+32+8+32)
+	_, err = io.ReadFull(r, buf)
+	if err != nil {
+		return nil, 0, err
+	}
+	header := &StateHeader{Version: buf[0]}
+	if header.Version != StateFileVersion {
+		return nil, 0, fmt.Errorf("invalid state file version %d", header.Version)
+	}
+	copy(header.NetworkId[:], buf[1:33])
+	header.Topology = binary.BigEndian.Uint64(buf[33:41])
+	copy(header.Snapshot[:], buf[41:73])
+	header.Rounds = binary.BigEndian.Uint64(buf[73:81])
+	copy(header.UTXOs[:], buf[81:113])
+
+	var entries int
+	for {
+		key, val, err := readStateEntry(r)
+		if err != nil {
+			return nil, 0, err
+		}
+		if key == nil {
+			break
+		}
+		if !stateKeyAllowed(key) {
+			return nil, 0, fmt.Errorf("invalid state entry %x", key)
+		}
+		if fn != nil {
+			err = fn(key, val)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		entries = entries + 1
+	}
+
+	var checksum crypto.Hash
+	copy(checksum[:], hasher.Sum(nil))
+	var expected crypto.Hash
+	_, err = io.ReadFull(br, expected[:])
+	if err != nil {
+		return nil, 0, err
+	}
+	if checksum != expected {
+		return nil, 0, fmt.Errorf("invalid state file checksum %s %s", checksum, expected)
+	}
+	_, err = br.ReadByte()
+	if err != io.EOF {
+		return nil, 0, fmt.Errorf("invalid state file trailing data")
+	}
+	return header, entries, nil
+}
+
+func readStateEntry(r io.Reader) ([]byte, []byte, error) {
+	var size [4]byte
+	_, err := io.ReadFull(r, size[:2])
+	if err != nil {
+		return nil, nil, err
+	}
+	kl := binary.BigEndian.Uint16(size[:2])
+	if kl == 0 {
+		return nil, nil, nil
+	}
+	key := make([]byte, kl)
+	_, err = io.ReadFull(r, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, err = io.ReadFull(r, size[:])
+	if err != nil {
+		return nil, nil, err
+	}
+	val := make([]byte, binary.BigEndian.Uint32(size[:]))
+	_, err = io.ReadFull(r, val)
+	return key, val, err
+}
+
+func stateKeyAllowed(key []byte) bool {
+	for _, prefix := range stateFullPrefixes {
+		if bytes.HasPrefix(key, []byte(prefix)) {
+			return true
+		}
+	}
+	for _, prefix := range stateRoundPrefixes {
+		if bytes.HasPrefix(key, []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+func exportStatePrefix(txn *badger.Txn, sw *stateWriter, prefix []byte) error {
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Seek(prefix); it.Valid(); it.Next() {
+		item := it.Item()
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		err = sw.writeEntry(item.Key(), val)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func exportStateNodeRounds(txn *badger.Txn, sw *stateWriter, nodeId crypto.Hash, rounds uint64) error {
+	head, err := readRound(txn, nodeId)
+	if err != nil || head == nil {
+		return err
+	}
+	err = sw.copyEntry(txn, graphRoundKey(nodeId))
+	if err != nil {
+		return err
+	}
+
+	offset, err := graphReadUint64(txn, graphWorkOffsetKey(nodeId))
+	if err != nil {
+		return err
+	}
+	_, space, err := readRoundSpaceCheckpoint(txn, nodeId)
+	if err != nil {
+		return err
+	}
+	checkpoint, err := graphReadUint64(txn, graphPruneCheckpointKey(nodeId))
+	if err != nil {
+		return err
+	}
+	var before uint64
+	if head.Number > rounds {
+		before = min(head.Number-rounds, offset, space)
+	}
+	before = max(before, checkpoint)
+	if before > 0 {
+		val := binary.BigEndian.AppendUint64(nil, before)
+		err = sw.writeEntry(graphPruneCheckpointKey(nodeId), val)
+		if err != nil {
+			return err
+		}
+	}
+
+	prefix := append([]byte(graphPrefixSnapshot), nodeId[:]...)
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var number uint64
+	var snapshots []*common.SnapshotWithTopologicalOrder
+	for it.Seek(prefix); it.Valid(); it.Next() {
+		item := it.Item()
+		key := item.KeyCopy(nil)
+		round := binary.BigEndian.Uint64(key[len(prefix):])
+		if round != number {
+			err = exportStateRound(txn, sw, nodeId, number, head.Number, snapshots)
+			if err != nil {
+				return err
+			}
+			number, snapshots = round, nil
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		snap, err := common.UnmarshalVersionedSnapshot(val)
+		if err != nil {
+			return err
+		}
+		snap.Hash = snap.PayloadHash()
+
+		hash := snap.SoleTransaction()
+		ver, _, err := readTransactionAndFinalization(txn, hash)
+		if err != nil {
+			return err
+		}
+		if round < before {
+			if ver == nil {
+				continue
+			}
+			prunable, err := transactionPrunable(txn, ver)
+			if err != nil {
+				return err
+			}
+			if prunable {
+				continue
+			}
+		} else {
+			snapshots = append(snapshots, snap)
+		}
+
+		err = sw.writeEntry(key, val)
+		if err != nil {
+			return err
+		}
+		item, err = txn.Get(graphSnapTopologyKey(snap.Hash))
+		if err != nil {
+			return err
+		}
+		topo, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		err = sw.writeEntry(graphSnapTopologyKey(snap.Hash), topo)
+		if err != nil {
+			return err
+		}
+		err = sw.copyEntry(txn, topo)
+		if err != nil {
+			return err
+		}
+		err = sw.writeEntry(graphUniqueKey(nodeId, hash), []byte{})
+		if err != nil {
+			return err
+		}
+		if ver != nil {
+			err = sw.copyEntry(txn, graphTransactionKey(hash))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return exportStateRound(txn, sw, nodeId, number, head.Number, snapshots)
+}
+
+func exportStateRound(txn *badger.Txn, sw *stateWriter, nodeId crypto.Hash, number, head uint64, snapshots []*common.SnapshotWithTopologicalOrder) error {
+	if len(snapshots) == 0 || number >= head {
+		return nil
+	}
+	_, _, hash := computeRoundHash(nodeId, number, snapshots)
+	return sw.copyEntry(txn, graphRoundKey(hash))
+}
+
+func (sw *stateWriter) writeHeader(header *StateHeader) error {
+	buf := []byte(StateFileMagic)
+	buf = append(buf, header.Version)
+	buf = append(buf, header.NetworkId[:]...)
+	buf = binary.BigEndian.AppendUint64(buf, header.Topology)
+	buf = append(buf, header.Snapshot[:]...)
+	buf = binary.BigEndian.AppendUint64(buf, header.Rounds)
+	buf = append(buf, header.UTXOs[:]...)
+	_, err := sw.w.Write(buf)
+	return err
+}
+
+func (sw *stateWriter) writeEntry(key, val []byte) error {
+	if len(key) == 0 || len(key) > 65535 {
+		panic(len(key))
+	}
+	buf := binary.BigEndian.AppendUint16(nil, uint16(len(key)))
+	buf = append(buf, key...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(val)))
+	buf = append(buf, val...)
+	_, err := sw.w.Write(buf)
+	sw.entries = sw.entries + 1
+	return err
+}
+
+func (sw *stateWriter) copyEntry(txn *badger.Txn, key []byte) error {
+	item, err := txn.Get(key)
+	if err != nil {
+		return fmt.Errorf("state entry %x %v", key, err)
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return err
+	}
+	return sw.writeEntry(key, val)
+}
+
+func (sw *stateWriter) finish() error {
+	_, err := sw.w.Write([]byte{0, 0})
+	if err != nil {
+		return err
+	}
+	err = sw.w.Flush()
+	if err != nil {
+		return err
+	}
+	_, err = sw.file.Write(sw.hasher.Sum(nil))
+	return err
+}
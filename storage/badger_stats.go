@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"github.com/dgraph-io/badger/v4"
+)
+
+type DBStats struct {
+	Name     string
+	LSMSize  int64
+	VlogSize int64
+	Levels   []*DBLevelStats
+}
+
+type DBLevelStats struct {
+	Level  int
+	Tables int
+	Size   int64
+}
+
+func (s *BadgerStore) ReadDBStats() []*DBStats {
+	return []*DBStats{
+		readDBStats("snapshots", s.snapshotsDB),
+		readDBStats("cache", s.cacheDB),
+	}
+}
+
+func readDBStats(name string, db *badger.DB) *DBStats {
+	lsm, vlog := db.Size()
+	stats := &DBStats{Name: name, LSMSize: lsm, VlogSize: vlog}
+	for _, l := range db.Levels() {
+		stats.Levels = append(stats.Levels, &DBLevelStats{
+			Level:  l.Level,
+			Tables: l.NumTables,
+			Size:   l.Size,
+		})
+	}
+	return stats
+}
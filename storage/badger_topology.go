@@ -30,7 +30,9 @@ func readSnapshotWithTopo(txn *badger.Txn, hash crypto.Hash) (*common.SnapshotWi
 	}
 
 	item, err = txn.Get(topo)
-	if err != nil {
+	if err == badger.ErrKeyNotFound {
+		return nil, ErrPruned
+	} else if err != nil {
 		return nil, err
 	}
 	key, err := item.ValueCopy(nil)
@@ -19,9 +19,11 @@ func (s *BadgerStore) ReadTransaction(hash crypto.Hash) (*common.VersionedTransa
 	return readTransactionAndFinalization(txn, hash)
 }
 
+// readTransactionAndFinalization returns a nil transaction with the finalized
+// snapshot if the transaction has been pruned
 func readTransactionAndFinalization(txn *badger.Txn, hash crypto.Hash) (*common.VersionedTransaction, string, error) {
 	tx, err := readTransaction(txn, hash)
-	if err != nil || tx == nil {
+	if err != nil {
 		return tx, "", err
 	}
 	key := graphFinalizationKey(hash)
@@ -179,6 +181,11 @@ func finalizeTransaction(txn *badger.Txn, ver *common.VersionedTransaction, snap
 		}
 	}
 
+	err = writeInscriptionOperation(txn, ver, snap.Timestamp)
+	if err != nil {
+		return err
+	}
+
 	return writeTotalInAsset(txn, ver)
 }
 
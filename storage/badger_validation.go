@@ -11,7 +11,6 @@ import (
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
-	"github.com/MixinNetwork/mixin/logger"
 )
 
 func (s *BadgerStore) ValidateGraphEntries(networkId crypto.Hash, depth uint64) (int, int, error) {
@@ -22,7 +21,7 @@ func (s *BadgerStore) ValidateGraphEntries(networkId crypto.Hash, depth uint64)
 		go func(nodeId crypto.Hash) {
 			total, invalid, err := s.validateSnapshotEntriesForNode(nodeId, depth)
 			if err != nil {
-				logger.Printf("SNAPSHOT VALIDATION ERROR FOR NODE %s %s\n", nodeId, err.Error())
+				storageLogger.Printf("SNAPSHOT VALIDATION ERROR FOR NODE %s %s\n", nodeId, err.Error())
 				errchan <- err
 			}
 			stats <- [2]int{total, invalid}
@@ -42,11 +41,11 @@ func (s *BadgerStore) ValidateGraphEntries(networkId crypto.Hash, depth uint64)
 }
 
 func (s *BadgerStore) validateSnapshotEntriesForNode(nodeId crypto.Hash, depth uint64) (int, int, error) {
-	logger.Printf("SNAPSHOT VALIDATE NODE %s BEGIN\n", nodeId)
+	storageLogger.Printf("SNAPSHOT VALIDATE NODE %s BEGIN\n", nodeId)
 	txn := s.snapshotsDB.NewTransaction(false)
 	defer func() {
 		txn.Discard()
-		logger.Printf("SNAPSHOT VALIDATE NODE %s DONE\n", nodeId)
+		storageLogger.Printf("SNAPSHOT VALIDATE NODE %s DONE\n", nodeId)
 	}()
 
 	head, err := readRound(txn, nodeId)
@@ -54,15 +53,23 @@ func (s *BadgerStore) validateSnapshotEntriesForNode(nodeId crypto.Hash, depth u
 		return 0, 0, err
 	}
 	if head == nil {
-		logger.Printf("SNAPSHOT VALIDATE NODE %s 0 ROUND\n", nodeId)
+		storageLogger.Printf("SNAPSHOT VALIDATE NODE %s 0 ROUND\n", nodeId)
 		return 0, 0, nil
 	}
 
-	logger.Printf("SNAPSHOT VALIDATE NODE %s %d ROUNDS\n", nodeId, head.Number)
+	storageLogger.Printf("SNAPSHOT VALIDATE NODE %s %d ROUNDS\n", nodeId, head.Number)
 	start := head.Number - depth
 	if head.Number < depth {
 		start = 0
 	}
+	checkpoint, err := graphReadUint64(txn, graphPruneCheckpointKey(nodeId))
+	if err != nil {
+		return 0, 0, err
+	}
+	if start < checkpoint {
+		storageLogger.Printf("SNAPSHOT VALIDATE NODE %s PRUNED BEFORE %d\n", nodeId, checkpoint)
+		start = checkpoint
+	}
 	invalid, total := 0, 0
 	for i := start; i < head.Number; i++ {
 		snapshots, err := readSnapshotsForNodeRound(txn, nodeId, i)
@@ -71,7 +78,7 @@ func (s *BadgerStore) validateSnapshotEntriesForNode(nodeId crypto.Hash, depth u
 		}
 		for _, s := range snapshots {
 			total += 1
-			item, err := txn.Get(graphTransactionKey(s.SoleTransaction()))
+			item, err := txn.Get(graphFinalizationKey(s.SoleTransaction()))
 			if err != nil {
 				return total, invalid, err
 			}
@@ -79,32 +86,30 @@ func (s *BadgerStore) validateSnapshotEntriesForNode(nodeId crypto.Hash, depth u
 			if err != nil {
 				return total, invalid, err
 			}
-			ver, err := common.UnmarshalVersionedTransaction(val)
-			if err != nil {
-				return total, invalid, err
-			}
-			if s.SoleTransaction().String() != ver.PayloadHash().String() {
-				logger.Printf("MALFORMED TRANSACTION %s %s %#v\n", s.SoleTransaction(), ver.PayloadHash(), ver)
-				invalid += 1
+			if s.Hash.String() != hex.EncodeToString(val) {
+				storageLogger.Printf("DUPLICATED FINALIZATION %s %s\n", s.Hash, hex.EncodeToString(val))
 			}
-			item, err = txn.Get(graphFinalizationKey(s.SoleTransaction()))
+			ver, err := readTransaction(txn, s.SoleTransaction())
 			if err != nil {
 				return total, invalid, err
 			}
-			val, err = item.ValueCopy(nil)
-			if err != nil {
-				return total, invalid, err
+			if ver == nil {
+				// the duplicated snapshot transaction pruned in an older round
+				continue
 			}
-			if s.Hash.String() != hex.EncodeToString(val) {
-				logger.Printf("DUPLICATED FINALIZATION %s %s\n", s.Hash, hex.EncodeToString(val))
+			if s.SoleTransaction().String() != ver.PayloadHash().String() {
+				storageLogger.Printf("MALFORMED TRANSACTION %s %s %#v\n", s.SoleTransaction(), ver.PayloadHash(), ver)
+				invalid += 1
 			}
 			dup, _ := crypto.HashFromString(hex.EncodeToString(val))
 			topo, err := readSnapshotWithTopo(txn, dup)
-			if err != nil {
+			if err == ErrPruned {
+				continue
+			} else if err != nil {
 				return total, invalid, err
 			}
 			if topo.SoleTransaction().String() != s.SoleTransaction().String() {
-				logger.Printf("MALFORMED FINALIZATION %s %s\n", s.Hash, topo.Hash)
+				storageLogger.Printf("MALFORMED FINALIZATION %s %s\n", s.Hash, topo.Hash)
 				invalid += 1
 			}
 		}
@@ -114,10 +119,10 @@ func (s *BadgerStore) validateSnapshotEntriesForNode(nodeId crypto.Hash, depth u
 			return total, invalid, err
 		}
 		if round == nil {
-			logger.Printf("MISSING ROUND %s %d %s\n", nodeId, i, hash)
+			storageLogger.Printf("MISSING ROUND %s %d %s\n", nodeId, i, hash)
 			invalid += 1
 		} else if round.NodeId != nodeId || round.Number != i {
-			logger.Printf("MALFORMED ROUND %s %d %s %s %d\n", nodeId, i, hash, round.NodeId, round.Number)
+			storageLogger.Printf("MALFORMED ROUND %s %d %s %s %d\n", nodeId, i, hash, round.NodeId, round.Number)
 			invalid += 1
 		}
 	}
@@ -0,0 +1,249 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/dgraph-io/badger/v4"
+)
+
+func (s *BadgerStore) ReadWalletCheckpoint(wallet crypto.Hash) (uint64, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	checkpoint, _, err := readWalletState(txn, wallet)
+	return checkpoint, err
+}
+
+func (s *BadgerStore) ReadWalletBalance(wallet, asset crypto.Hash) (common.Integer, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	return readWalletBalance(txn, wallet, asset)
+}
+
+func (s *BadgerStore) ReadWalletOutputs(wallet crypto.Hash, offset, count uint64) ([]*common.WalletOutput, error) {
+	if count > 500 {
+		return nil, fmt.Errorf("count %d too large, the maximum is 500", count)
+	}
+
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = append([]byte(graphPrefixWalletOutput), wallet[:]...)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	outputs := make([]*common.WalletOutput, 0)
+	it.Seek(graphWalletOutputKey(wallet, offset))
+	for ; it.Valid() && uint64(len(outputs)) < count; it.Next() {
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		var out common.WalletOutput
+		err = json.Unmarshal(val, &out)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, &out)
+	}
+	return outputs, nil
+}
+
+// ReadWalletOutputByGhostKey returns the owned output with the ghost key, and
+// nil if no output of the wallet has the key
+func (s *BadgerStore) ReadWalletOutputByGhostKey(wallet crypto.Hash, key crypto.Key) (*common.WalletOutput, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	return readWalletOutputByIndex(txn, wallet, graphWalletGhostKey(wallet, key))
+}
+
+// WriteWalletScan records the owned outputs and the spends found in the
+// transactions before the checkpoint, spends of unknown outputs are ignored
+func (s *BadgerStore) WriteWalletScan(wallet crypto.Hash, checkpoint uint64, outputs []*common.WalletOutput, spends []*common.WalletSpend) error {
+	txn := s.snapshotsDB.NewTransaction(true)
+	defer txn.Discard()
+
+	old, sequence, err := readWalletState(txn, wallet)
+	if err != nil {
+		return err
+	}
+	if checkpoint < old {
+		panic(fmt.Errorf("malformed wallet checkpoint %s %d %d", wallet, old, checkpoint))
+	}
+
+	for _, out := range outputs {
+		key := graphWalletUTXOKey(wallet, out.Hash, out.Index)
+		_, err := txn.Get(key)
+		if err == nil {
+			continue
+		} else if err != badger.ErrKeyNotFound {
+			return err
+		}
+		out.Sequence = sequence
+		err = writeWalletOutput(txn, wallet, out)
+		if err != nil {
+			return err
+		}
+		val := binary.BigEndian.AppendUint64(nil, sequence)
+		err = txn.Set(key, val)
+		if err != nil {
+			return err
+		}
+		for _, k := range out.Keys {
+			err = txn.Set(graphWalletGhostKey(wallet, *k), val)
+			if err != nil {
+				return err
+			}
+		}
+		err = writeWalletBalance(txn, wallet, out.Asset, out.Amount, true)
+		if err != nil {
+			return err
+		}
+		sequence = sequence + 1
+	}
+
+	for _, sp := range spends {
+		out, err := readWalletOutputByUTXO(txn, wallet, sp.Hash, sp.Index)
+		if err != nil {
+			return err
+		}
+		if out == nil || out.Spent.HasValue() {
+			continue
+		}
+		out.Spent = sp.Transaction
+		err = writeWalletOutput(txn, wallet, out)
+		if err != nil {
+			return err
+		}
+		err = writeWalletBalance(txn, wallet, out.Asset, out.Amount, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = writeWalletState(txn, wallet, checkpoint, sequence)
+	if err != nil {
+		return err
+	}
+	return txn.Commit()
+}
+
+func readWalletState(txn *badger.Txn, wallet crypto.Hash) (uint64, uint64, error) {
+	item, err := txn.Get(graphWalletStateKey(wallet))
+	if err == badger.ErrKeyNotFound {
+		return 0, 0, nil
+	} else if err != nil {
+		return 0, 0, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	checkpoint := binary.BigEndian.Uint64(val[:8])
+	sequence := binary.BigEndian.Uint64(val[8:])
+	return checkpoint, sequence, nil
+}
+
+func writeWalletState(txn *badger.Txn, wallet crypto.Hash, checkpoint, sequence uint64) error {
+	val := binary.BigEndian.AppendUint64(nil, checkpoint)
+	val = binary.BigEndian.AppendUint64(val, sequence)
+	return txn.Set(graphWalletStateKey(wallet), val)
+}
+
+func readWalletOutputByUTXO(txn *badger.Txn, wallet, hash crypto.Hash, index uint) (*common.WalletOutput, error) {
+	return readWalletOutputByIndex(txn, wallet, graphWalletUTXOKey(wallet, hash, index))
+}
+
+func readWalletOutputByIndex(txn *badger.Txn, wallet crypto.Hash, index []byte) (*common.WalletOutput, error) {
+	item, err := txn.Get(index)
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	key := graphWalletOutputKey(wallet, binary.BigEndian.Uint64(val))
+	item, err = txn.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	val, err = item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	var out common.WalletOutput
+	err = json.Unmarshal(val, &out)
+	return &out, err
+}
+
+func writeWalletOutput(txn *badger.Txn, wallet crypto.Hash, out *common.WalletOutput) error {
+	val, err := json.Marshal(out)
+	if err != nil {
+		panic(err)
+	}
+	key := graphWalletOutputKey(wallet, out.Sequence)
+	return txn.Set(key, val)
+}
+
+func readWalletBalance(txn *badger.Txn, wallet, asset crypto.Hash) (common.Integer, error) {
+	item, err := txn.Get(graphWalletBalanceKey(wallet, asset))
+	if err == badger.ErrKeyNotFound {
+		return common.Zero, nil
+	} else if err != nil {
+		return common.Zero, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return common.Zero, err
+	}
+	return common.NewIntegerFromString(string(val)), nil
+}
+
+func writeWalletBalance(txn *badger.Txn, wallet, asset crypto.Hash, amount common.Integer, receive bool) error {
+	balance, err := readWalletBalance(txn, wallet, asset)
+	if err != nil {
+		return err
+	}
+	if receive {
+		balance = balance.Add(amount)
+	} else {
+		balance = balance.Sub(amount)
+	}
+	key := graphWalletBalanceKey(wallet, asset)
+	return txn.Set(key, []byte(balance.String()))
+}
+
+func graphWalletStateKey(wallet crypto.Hash) []byte {
+	return append([]byte(graphPrefixWalletState), wallet[:]...)
+}
+
+func graphWalletOutputKey(wallet crypto.Hash, sequence uint64) []byte {
+	key := append([]byte(graphPrefixWalletOutput), wallet[:]...)
+	return binary.BigEndian.AppendUint64(key, sequence)
+}
+
+func graphWalletUTXOKey(wallet, hash crypto.Hash, index uint) []byte {
+	key := append([]byte(graphPrefixWalletUTXO), wallet[:]...)
+	key = append(key, hash[:]...)
+	return binary.BigEndian.AppendUint64(key, uint64(index))
+}
+
+func graphWalletGhostKey(wallet crypto.Hash, ghost crypto.Key) []byte {
+	key := append([]byte(graphPrefixWalletGhost), wallet[:]...)
+	return append(key, ghost[:]...)
+}
+
+func graphWalletBalanceKey(wallet, asset crypto.Hash) []byte {
+	key := append([]byte(graphPrefixWalletBalance), wallet[:]...)
+	return append(key, asset[:]...)
+}
@@ -0,0 +1,269 @@
+package storage
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/util/base58"
+	"github.com/stretchr/testify/require"
+)
+
+// testInscriptionWriter finalizes the transactions through WriteSnapshot in
+// the head round of the node, so the inscription operations are indexed the
+// same way as the kernel does
+type testInscriptionWriter struct {
+	require  *require.Assertions
+	store    Store
+	round    *common.Round
+	asset    *common.Asset
+	topology uint64
+	deposits int
+}
+
+func testStoreInscriptions(require *require.Assertions, store Store) {
+	rounds, snapshots, _ := testStoreLoadGenesis(require, store)
+	round, err := store.ReadRound(rounds[0].NodeId)
+	require.Nil(err)
+	asset, _, err := store.ReadAssetWithBalance(common.XINAssetId)
+	require.Nil(err)
+	w := &testInscriptionWriter{require: require, store: store, round: round, asset: asset, topology: uint64(len(snapshots))}
+
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	recipient := testInscriptionMixAddress(common.NewAddressFromSeed(seed))
+	one, two, three := crypto.Blake3Hash([]byte("one")), crypto.Blake3Hash([]byte("two")), crypto.Blake3Hash([]byte("three"))
+	checksums := append(append(one[:], two[:]...), three[:]...)
+	deployment := &common.Deployment{
+		Version:    common.InscriptionDeploymentVersion,
+		Operation:  common.InscriptionOperationDeploy,
+		Mode:       common.InscriptionModeInstant,
+		Unit:       "10",
+		Supply:     "30",
+		Symbol:     "MAO",
+		Name:       "Mixin Autonomous Organization",
+		Icon:       "data:image/webp;base64,iVBO",
+		Validation: common.InscriptionValidationChecksum + base64.StdEncoding.EncodeToString(checksums),
+	}
+
+	// the invalid deployment is ignored
+	invalid := *deployment
+	invalid.Version = 2
+	bad := w.deposit(10, testInscriptionExtra(&invalid))
+	collection, err := store.ReadInscriptionCollection(bad)
+	require.Nil(err)
+	require.Nil(collection)
+
+	deploy := w.deposit(10, testInscriptionExtra(deployment))
+	collection, err = store.ReadInscriptionCollection(deploy)
+	require.Nil(err)
+	require.Equal(deploy, collection.Hash)
+	require.Equal(uint64(3), collection.Deployment.Total())
+	require.Equal(uint64(0), collection.Inscriptions)
+	require.False(collection.Asset.HasValue())
+
+	// only the contents of the deployment checksums are inscribed, once each
+	i0 := w.deposit(10, testInscriptionInscribe(recipient, "one"), deploy)
+	require.Nil(w.read(w.deposit(10, testInscriptionInscribe(recipient, "one"), deploy)))
+	require.Nil(w.read(w.deposit(10, testInscriptionInscribe(recipient, "four"), deploy)))
+	require.Nil(w.read(w.deposit(10, testInscriptionInscribe(recipient, ""), deploy)))
+	require.Nil(w.read(w.deposit(10, testInscriptionInscribe(recipient, "two"), bad)))
+	require.Nil(w.read(w.deposit(10, []byte(`{"operation":"inscribe","recipient":"MIX","content":"text/plain,two"}`), deploy)))
+	i1 := w.deposit(10, testInscriptionInscribe(recipient, "two"), deploy)
+
+	item, err := store.ReadInscriptionItem(i0)
+	require.Nil(err)
+	require.Equal(deploy, item.Collection)
+	require.Equal(uint64(0), item.Sequence)
+	require.Equal(recipient, item.Recipient)
+	require.Equal(one, item.ContentHash)
+	require.Equal(common.InscriptionStateInscribed, item.State)
+	require.False(item.OwnerHash.HasValue())
+	item, err = store.ReadInscriptionItem(i1)
+	require.Nil(err)
+	require.Equal(uint64(1), item.Sequence)
+	require.Equal(two, item.ContentHash)
+	collection, err = store.ReadInscriptionCollection(deploy)
+	require.Nil(err)
+	require.Equal(uint64(2), collection.Inscriptions)
+	items, err := store.ReadInscriptionItems(deploy, 0, 10)
+	require.Nil(err)
+	require.Len(items, 2)
+	require.Equal(i0, items[0].Hash)
+	require.Equal(i1, items[1].Hash)
+	items, err = store.ReadInscriptionItems(deploy, 1, 10)
+	require.Nil(err)
+	require.Len(items, 1)
+	require.Equal(i1, items[0].Hash)
+	_, err = store.ReadInscriptionItems(deploy, 0, 501)
+	require.NotNil(err)
+
+	// the distributions must follow the sequence with the inscription amount
+	w.deposit(10, testInscriptionSequence(common.InscriptionOperationDistribute, 1), i1)
+	w.deposit(5, testInscriptionSequence(common.InscriptionOperationDistribute, 0), i0)
+	w.deposit(10, testInscriptionSequence(common.InscriptionOperationDistribute, 1), i0)
+	w.deposit(10, testInscriptionSequence(common.InscriptionOperationOccupy, 0), i0)
+	item = w.item(i0)
+	require.Equal(common.InscriptionStateInscribed, item.State)
+	item = w.item(i1)
+	require.Equal(common.InscriptionStateInscribed, item.State)
+
+	d0 := w.deposit(10, testInscriptionSequence(common.InscriptionOperationDistribute, 0), i0)
+	item = w.item(i0)
+	require.Equal(common.InscriptionStateDistributed, item.State)
+	require.Equal(d0, item.OwnerHash)
+	require.Equal(uint(0), item.OwnerIndex)
+	collection, err = store.ReadInscriptionCollection(deploy)
+	require.Nil(err)
+	require.Equal(uint64(1), collection.Distributions)
+	require.Equal(common.XINAssetId, collection.Asset)
+	w.deposit(10, testInscriptionSequence(common.InscriptionOperationDistribute, 0), i0)
+	collection, err = store.ReadInscriptionCollection(deploy)
+	require.Nil(err)
+	require.Equal(uint64(1), collection.Distributions)
+
+	// the owner moves if the UTXO is spent as a whole, or it's released
+	t0 := w.transfer(d0, 10)
+	item = w.item(i0)
+	require.Equal(common.InscriptionStateDistributed, item.State)
+	require.Equal(t0, item.OwnerHash)
+	t1 := w.transfer(t0, 5, 5)
+	item = w.item(i0)
+	require.Equal(common.InscriptionStateReleased, item.State)
+	require.False(item.OwnerHash.HasValue())
+	w.transfer(t1, 5)
+	item = w.item(i0)
+	require.Equal(common.InscriptionStateReleased, item.State)
+
+	// only the released inscription is occupied with the unit amount
+	w.deposit(10, testInscriptionSequence(common.InscriptionOperationOccupy, 1), i0)
+	w.deposit(5, testInscriptionSequence(common.InscriptionOperationOccupy, 0), i0)
+	w.deposit(10, testInscriptionSequence(common.InscriptionOperationOccupy, 1), i1)
+	require.Equal(common.InscriptionStateReleased, w.item(i0).State)
+	require.Equal(common.InscriptionStateInscribed, w.item(i1).State)
+	o0 := w.deposit(10, testInscriptionSequence(common.InscriptionOperationOccupy, 0), i0)
+	item = w.item(i0)
+	require.Equal(common.InscriptionStateOccupied, item.State)
+	require.Equal(o0, item.OwnerHash)
+	t2 := w.transfer(o0, 10)
+	item = w.item(i0)
+	require.Equal(common.InscriptionStateOccupied, item.State)
+	require.Equal(t2, item.OwnerHash)
+
+	// the regex validation only accepts the matched text contents
+	deployment.Validation = common.InscriptionValidationRegex + "^[a-z]+\\.mao$"
+	regex := w.deposit(10, testInscriptionExtra(deployment))
+	r0 := w.deposit(10, testInscriptionInscribe(recipient, "cedric.mao"), regex)
+	require.Nil(w.read(w.deposit(10, testInscriptionInscribe(recipient, "Cedric.mao"), regex)))
+	require.Nil(w.read(w.deposit(10, testInscriptionInscribe(recipient, "cedric.mao"), regex)))
+	image := []byte(`{"operation":"inscribe","recipient":"` + recipient + `","content":"image/png,mixin.mao"}`)
+	require.Nil(w.read(w.deposit(10, image, regex)))
+	item = w.item(r0)
+	require.Equal(regex, item.Collection)
+	require.Equal(crypto.Blake3Hash([]byte("cedric.mao")), item.ContentHash)
+
+	// the collection without validation is closed after the total
+	deployment.Validation = ""
+	free := w.deposit(10, testInscriptionExtra(deployment))
+	for _, content := range []string{"", "a", "b"} {
+		require.NotNil(w.read(w.deposit(10, testInscriptionInscribe(recipient, content), free)))
+	}
+	require.Nil(w.read(w.deposit(10, testInscriptionInscribe(recipient, "c"), free)))
+	collection, err = store.ReadInscriptionCollection(free)
+	require.Nil(err)
+	require.Equal(uint64(3), collection.Inscriptions)
+}
+
+func (w *testInscriptionWriter) read(hash crypto.Hash) *common.InscriptionItem {
+	item, err := w.store.ReadInscriptionItem(hash)
+	w.require.Nil(err)
+	return item
+}
+
+func (w *testInscriptionWriter) item(hash crypto.Hash) *common.InscriptionItem {
+	item := w.read(hash)
+	w.require.NotNil(item)
+	return item
+}
+
+func (w *testInscriptionWriter) deposit(amount uint64, extra []byte, references ...crypto.Hash) crypto.Hash {
+	w.deposits += 1
+	tx := common.NewTransactionV5(common.XINAssetId)
+	tx.AddDepositInput(&common.DepositData{
+		Chain:       common.EthereumAssetId,
+		AssetKey:    w.asset.AssetKey,
+		Transaction: fmt.Sprintf("0xMIXININSCRIPTION%d", w.deposits),
+		Index:       0,
+		Amount:      common.NewInteger(amount),
+	})
+	w.output(tx, amount)
+	tx.References = references
+	tx.Extra = extra
+	ver := tx.AsVersioned()
+	err := w.store.LockDepositInput(tx.Inputs[0].Deposit, ver.PayloadHash(), false)
+	w.require.Nil(err)
+	return w.write(ver)
+}
+
+func (w *testInscriptionWriter) transfer(input crypto.Hash, amounts ...uint64) crypto.Hash {
+	tx := common.NewTransactionV5(common.XINAssetId)
+	tx.AddInput(input, 0)
+	for _, a := range amounts {
+		w.output(tx, a)
+	}
+	ver := tx.AsVersioned()
+	err := w.store.LockUTXOs(tx.Inputs, ver.PayloadHash(), false)
+	w.require.Nil(err)
+	return w.write(ver)
+}
+
+func (w *testInscriptionWriter) output(tx *common.Transaction, amount uint64) {
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	mixin := common.NewAddressFromSeed(seed)
+	tx.AddRandomScriptOutput([]*common.Address{&mixin}, common.NewThresholdScript(1), common.NewInteger(amount))
+}
+
+func (w *testInscriptionWriter) write(ver *common.VersionedTransaction) crypto.Hash {
+	err := w.store.WriteTransaction(ver)
+	w.require.Nil(err)
+	testStoreWriteSnapshot(w.require, w.store, w.round, ver.PayloadHash(), w.topology)
+	w.topology += 1
+	return ver.PayloadHash()
+}
+
+func testInscriptionExtra(v any) []byte {
+	extra, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return extra
+}
+
+func testInscriptionInscribe(recipient, content string) []byte {
+	ins := &common.Inscription{
+		Operation: common.InscriptionOperationInscribe,
+		Recipient: recipient,
+	}
+	if content != "" {
+		ins.Content = "text/plain;charset=UTF-8;base64," + base64.StdEncoding.EncodeToString([]byte(content))
+	}
+	return testInscriptionExtra(ins)
+}
+
+func testInscriptionSequence(operation string, sequence uint64) []byte {
+	return testInscriptionExtra(map[string]any{"operation": operation, "sequence": sequence})
+}
+
+func testInscriptionMixAddress(members ...common.Address) string {
+	payload := []byte{common.MixAddressVersion, 1, byte(len(members))}
+	for _, a := range members {
+		payload = append(payload, a.PublicSpendKey[:]...)
+		payload = append(payload, a.PublicViewKey[:]...)
+	}
+	checksum := crypto.Sha256Hash(append([]byte(common.MixAddressPrefix), payload...))
+	payload = append(payload, checksum[:4]...)
+	return common.MixAddressPrefix + base58.Encode(payload)
+}
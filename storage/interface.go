@@ -39,12 +39,18 @@ type Store interface {
 	ReadCustodian(ts uint64) (*common.CustodianUpdateRequest, error)
 	ListCustodianUpdates() ([]*common.CustodianUpdateRequest, error)
 
-	CachePutTransaction(tx *common.VersionedTransaction) error
+	CachePutTransaction(tx *common.VersionedTransaction, sender crypto.Hash) error
 	CacheGetTransaction(hash crypto.Hash) (*common.VersionedTransaction, error)
 	CacheRetrieveTransactions(limit int) ([]*common.VersionedTransaction, error)
 	CacheRemoveTransactions([]crypto.Hash) error
+	CacheGetTransactionEntry(hash crypto.Hash) (*CacheTransaction, error)
+	CacheListTransactions(offset crypto.Hash, count uint64, filter func(*CacheTransaction) bool) ([]*CacheTransaction, error)
+	CacheStats() (*CacheStats, error)
+	CacheSelectTransactions(window, limit int, filter func(*CacheTransaction) bool, order func([]*CacheTransaction) []*CacheTransaction) ([]*CacheTransaction, error)
+	CacheReadConflicts(tx *common.VersionedTransaction) ([]*CacheTransaction, error)
 
 	ReadLastMintDistribution(batch uint64) (*common.MintDistribution, error)
+	ReadMintLock(mint *common.MintData) (*common.MintDistribution, error)
 	LockMintInput(mint *common.MintData, tx crypto.Hash, fork bool) error
 	ReadMintDistributions(offset, count uint64) ([]*common.MintDistribution, []*common.VersionedTransaction, error)
 	ReadSnapshotWorksForNodeRound(nodeId crypto.Hash, round uint64) ([]*common.SnapshotWork, error)
@@ -58,6 +64,25 @@ type Store interface {
 	ListAggregatedRoundSpaceCheckpoints(cids []crypto.Hash) (map[crypto.Hash]*common.RoundSpace, error)
 	ReadNodeRoundSpacesForBatch(nodeId crypto.Hash, batch uint64) ([]*common.RoundSpace, error)
 
+	ReadInscriptionCollection(hash crypto.Hash) (*common.InscriptionCollection, error)
+	ReadInscriptionItem(hash crypto.Hash) (*common.InscriptionItem, error)
+	ReadInscriptionItems(collection crypto.Hash, offset, count uint64) ([]*common.InscriptionItem, error)
+
+	ReadWalletCheckpoint(wallet crypto.Hash) (uint64, error)
+	ReadWalletBalance(wallet, asset crypto.Hash) (common.Integer, error)
+	ReadWalletOutputs(wallet crypto.Hash, offset, count uint64) ([]*common.WalletOutput, error)
+	ReadWalletOutputByGhostKey(wallet crypto.Hash, key crypto.Key) (*common.WalletOutput, error)
+	WriteWalletScan(wallet crypto.Hash, checkpoint uint64, outputs []*common.WalletOutput, spends []*common.WalletSpend) error
+
+	WritePeerBan(id crypto.Hash, until uint64) error
+	ReadPeerBans() (map[crypto.Hash]uint64, error)
+	WritePeerAddress(id crypto.Hash, data []byte) error
+	ReadPeerAddresses() (map[crypto.Hash][]byte, error)
+
+	ReadPruneCheckpoint(nodeId crypto.Hash) (uint64, error)
+	PruneNodeRounds(nodeId crypto.Hash, before uint64, limit int) (uint64, error)
+
 	RemoveGraphEntries(prefix string) (int, error)
 	ValidateGraphEntries(networkId crypto.Hash, depth uint64) (int, int, error)
+	ReadDBStats() []*DBStats
 }
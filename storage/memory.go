@@ -0,0 +1,359 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+// MemoryStore is the Store backed by Go maps, nothing is persisted after the
+// process exits. All writes of a call are made in a transaction, which is
+// rolled back on error or panic, so a failed call leaves nothing behind just
+// like the badger store.
+type MemoryStore struct {
+	custom     *config.Custom
+	mutex      *sync.RWMutex
+	cacheMutex *sync.RWMutex
+
+	transactions   map[crypto.Hash][]byte
+	finalizations  map[crypto.Hash]crypto.Hash
+	uniques        map[memoryPair]bool
+	utxos          map[memoryUTXOKey][]byte
+	ghosts         map[crypto.Key]crypto.Hash
+	deposits       map[crypto.Hash]crypto.Hash
+	mints          map[uint64][]byte
+	withdrawals    map[crypto.Hash]crypto.Hash
+	assetInfos     map[crypto.Hash]common.Asset
+	assetTotals    map[crypto.Hash]string
+	rounds         map[crypto.Hash][]byte
+	links          map[memoryPair]uint64
+	snapshots      map[memoryRoundKey]map[crypto.Hash][]byte
+	topologies     map[uint64]memorySnapshotKey
+	snapTopologies map[crypto.Hash]uint64
+	consensus      map[memoryConsensusKey]crypto.Hash
+	nodeStates     map[memoryNodeKey]memoryNodeEntry
+	nodeOperations map[uint64]memoryNodeOperation
+	custodians     map[uint64]crypto.Hash
+
+	workOffsets      map[crypto.Hash]memoryWorkOffset
+	workSigns        map[memoryWorkDayKey]uint64
+	workLeads        map[memoryWorkDayKey]uint64
+	workSnapshots    map[memoryRoundKey]map[uint64][]crypto.Hash
+	spaceCheckpoints map[crypto.Hash][2]uint64
+	spaceQueue       map[memorySpaceKey]map[uint64]uint64
+
+	inscriptionCollections map[crypto.Hash][]byte
+	inscriptionItems       map[memoryInscriptionKey][]byte
+	inscriptionHashes      map[crypto.Hash]memoryInscriptionKey
+	inscriptionOwners      map[memoryUTXOKey]memoryInscriptionKey
+	inscriptionContents    map[memoryPair]uint64
+	inscriptionChecksums   map[memoryPair]uint64
+
+	walletStates   map[crypto.Hash][2]uint64
+	walletOutputs  map[memoryWalletKey][]byte
+	walletUTXOs    map[memoryWalletUTXOKey]uint64
+	walletGhosts   map[memoryWalletGhostKey]uint64
+	walletBalances map[memoryPair]string
+
+	peerBans         map[crypto.Hash]uint64
+	peerAddresses    map[crypto.Hash][]byte
+	pruneCheckpoints map[crypto.Hash]uint64
+
+	cacheTransactions map[crypto.Hash]memoryCacheEntry
+	cacheOrders       map[crypto.Hash]memoryCacheOrder
+	cacheQueue        map[memoryQueueKey]uint64
+	cacheInputs       map[memoryUTXOKey]memoryCacheInput
+}
+
+type memoryPair [2]crypto.Hash
+
+type memoryUTXOKey struct {
+	hash  crypto.Hash
+	index uint
+}
+
+type memoryRoundKey struct {
+	node  crypto.Hash
+	round uint64
+}
+
+type memorySnapshotKey struct {
+	memoryRoundKey
+	tx crypto.Hash
+}
+
+type memoryConsensusKey struct {
+	timestamp uint64
+	snapshot  crypto.Hash
+}
+
+type memoryNodeKey struct {
+	timestamp uint64
+	signer    crypto.Key
+}
+
+type memoryNodeEntry struct {
+	payee crypto.Key
+	tx    crypto.Hash
+	state string
+}
+
+type memoryNodeOperation struct {
+	tx crypto.Hash
+	op string
+}
+
+type memoryWorkOffset struct {
+	round     uint64
+	snapshots []crypto.Hash
+}
+
+type memoryWorkDayKey struct {
+	node crypto.Hash
+	day  uint32
+}
+
+type memorySpaceKey struct {
+	node  crypto.Hash
+	batch uint64
+}
+
+type memoryInscriptionKey struct {
+	collection crypto.Hash
+	sequence   uint64
+}
+
+type memoryWalletKey struct {
+	wallet   crypto.Hash
+	sequence uint64
+}
+
+type memoryWalletUTXOKey struct {
+	wallet crypto.Hash
+	memoryUTXOKey
+}
+
+type memoryWalletGhostKey struct {
+	wallet crypto.Hash
+	ghost  crypto.Key
+}
+
+func NewMemoryStore(custom *config.Custom) (*MemoryStore, error) {
+	return &MemoryStore{
+		custom:     custom,
+		mutex:      new(sync.RWMutex),
+		cacheMutex: new(sync.RWMutex),
+
+		transactions:   make(map[crypto.Hash][]byte),
+		finalizations:  make(map[crypto.Hash]crypto.Hash),
+		uniques:        make(map[memoryPair]bool),
+		utxos:          make(map[memoryUTXOKey][]byte),
+		ghosts:         make(map[crypto.Key]crypto.Hash),
+		deposits:       make(map[crypto.Hash]crypto.Hash),
+		mints:          make(map[uint64][]byte),
+		withdrawals:    make(map[crypto.Hash]crypto.Hash),
+		assetInfos:     make(map[crypto.Hash]common.Asset),
+		assetTotals:    make(map[crypto.Hash]string),
+		rounds:         make(map[crypto.Hash][]byte),
+		links:          make(map[memoryPair]uint64),
+		snapshots:      make(map[memoryRoundKey]map[crypto.Hash][]byte),
+		topologies:     make(map[uint64]memorySnapshotKey),
+		snapTopologies: make(map[crypto.Hash]uint64),
+		consensus:      make(map[memoryConsensusKey]crypto.Hash),
+		nodeStates:     make(map[memoryNodeKey]memoryNodeEntry),
+		nodeOperations: make(map[uint64]memoryNodeOperation),
+		custodians:     make(map[uint64]crypto.Hash),
+
+		workOffsets:      make(map[crypto.Hash]memoryWorkOffset),
+		workSigns:        make(map[memoryWorkDayKey]uint64),
+		workLeads:        make(map[memoryWorkDayKey]uint64),
+		workSnapshots:    make(map[memoryRoundKey]map[uint64][]crypto.Hash),
+		spaceCheckpoints: make(map[crypto.Hash][2]uint64),
+		spaceQueue:       make(map[memorySpaceKey]map[uint64]uint64),
+
+		inscriptionCollections: make(map[crypto.Hash][]byte),
+		inscriptionItems:       make(map[memoryInscriptionKey][]byte),
+		inscriptionHashes:      make(map[crypto.Hash]memoryInscriptionKey),
+		inscriptionOwners:      make(map[memoryUTXOKey]memoryInscriptionKey),
+		inscriptionContents:    make(map[memoryPair]uint64),
+		inscriptionChecksums:   make(map[memoryPair]uint64),
+
+		walletStates:   make(map[crypto.Hash][2]uint64),
+		walletOutputs:  make(map[memoryWalletKey][]byte),
+		walletUTXOs:    make(map[memoryWalletUTXOKey]uint64),
+		walletGhosts:   make(map[memoryWalletGhostKey]uint64),
+		walletBalances: make(map[memoryPair]string),
+
+		peerBans:         make(map[crypto.Hash]uint64),
+		peerAddresses:    make(map[crypto.Hash][]byte),
+		pruneCheckpoints: make(map[crypto.Hash]uint64),
+
+		cacheTransactions: make(map[crypto.Hash]memoryCacheEntry),
+		cacheOrders:       make(map[crypto.Hash]memoryCacheOrder),
+		cacheQueue:        make(map[memoryQueueKey]uint64),
+		cacheInputs:       make(map[memoryUTXOKey]memoryCacheInput),
+	}, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// memoryTxn records how to undo each write, the writes are applied to the
+// maps directly, so the reads in the same transaction see them
+type memoryTxn struct {
+	undo []func()
+}
+
+// update runs the function in a write transaction, the caller must hold the
+// lock of the maps written
+func (s *MemoryStore) update(fn func(txn *memoryTxn) error) error {
+	txn := &memoryTxn{}
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		for i := len(txn.undo) - 1; i >= 0; i-- {
+			txn.undo[i]()
+		}
+	}()
+	err := fn(txn)
+	if err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+func memorySet[K comparable, V any](txn *memoryTxn, m map[K]V, k K, v V) {
+	old, ok := m[k]
+	txn.undo = append(txn.undo, func() {
+		if ok {
+			m[k] = old
+		} else {
+			delete(m, k)
+		}
+	})
+	m[k] = v
+}
+
+func memoryDelete[K comparable, V any](txn *memoryTxn, m map[K]V, k K) {
+	old, ok := m[k]
+	if !ok {
+		return
+	}
+	txn.undo = append(txn.undo, func() { m[k] = old })
+	delete(m, k)
+}
+
+func memoryClear[K comparable, V any](txn *memoryTxn, m map[K]V) int {
+	removed := len(m)
+	for k := range m {
+		memoryDelete(txn, m, k)
+	}
+	return removed
+}
+
+func memorySetNested[K1, K2 comparable, V any](txn *memoryTxn, m map[K1]map[K2]V, k1 K1, k2 K2, v V) {
+	inner := m[k1]
+	if inner == nil {
+		inner = make(map[K2]V)
+		memorySet(txn, m, k1, inner)
+	}
+	memorySet(txn, inner, k2, v)
+}
+
+func memoryDeleteNested[K1, K2 comparable, V any](txn *memoryTxn, m map[K1]map[K2]V, k1 K1, k2 K2) {
+	inner := m[k1]
+	memoryDelete(txn, inner, k2)
+	if inner != nil && len(inner) == 0 {
+		memoryDelete(txn, m, k1)
+	}
+}
+
+// memoryClearNested clears the table grouped by the outer key, and returns
+// the number of the inner entries removed
+func memoryClearNested[K1, K2 comparable, V any](txn *memoryTxn, m map[K1]map[K2]V) int {
+	var removed int
+	for _, inner := range m {
+		removed += len(inner)
+	}
+	memoryClear(txn, m)
+	return removed
+}
+
+func (s *MemoryStore) ReadDBStats() []*DBStats {
+	return []*DBStats{{Name: "snapshots"}, {Name: "cache"}}
+}
+
+// RemoveGraphEntries removes all entries of the graph tables whose prefix
+// starts with the prefix, the prefixes inside a table are not supported
+func (s *MemoryStore) RemoveGraphEntries(prefix string) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tables := map[string]func(txn *memoryTxn) int{
+		graphPrefixGhost:             func(txn *memoryTxn) int { return memoryClear(txn, s.ghosts) },
+		graphPrefixUTXO:              func(txn *memoryTxn) int { return memoryClear(txn, s.utxos) },
+		graphPrefixDeposit:           func(txn *memoryTxn) int { return memoryClear(txn, s.deposits) },
+		graphPrefixWithdrawal:        func(txn *memoryTxn) int { return memoryClear(txn, s.withdrawals) },
+		graphPrefixMint:              func(txn *memoryTxn) int { return memoryClear(txn, s.mints) },
+		graphPrefixTransaction:       func(txn *memoryTxn) int { return memoryClear(txn, s.transactions) },
+		graphPrefixFinalization:      func(txn *memoryTxn) int { return memoryClear(txn, s.finalizations) },
+		graphPrefixUnique:            func(txn *memoryTxn) int { return memoryClear(txn, s.uniques) },
+		graphPrefixRound:             func(txn *memoryTxn) int { return memoryClear(txn, s.rounds) },
+		graphPrefixSnapshot:          func(txn *memoryTxn) int { return memoryClearNested(txn, s.snapshots) },
+		graphPrefixLink:              func(txn *memoryTxn) int { return memoryClear(txn, s.links) },
+		graphPrefixTopology:          func(txn *memoryTxn) int { return memoryClear(txn, s.topologies) },
+		graphPrefixSnapTopology:      func(txn *memoryTxn) int { return memoryClear(txn, s.snapTopologies) },
+		graphPrefixWorkLead:          func(txn *memoryTxn) int { return memoryClear(txn, s.workLeads) },
+		graphPrefixWorkSign:          func(txn *memoryTxn) int { return memoryClear(txn, s.workSigns) },
+		graphPrefixWorkOffset:        func(txn *memoryTxn) int { return memoryClear(txn, s.workOffsets) },
+		graphPrefixWorkSnapshot:      func(txn *memoryTxn) int { return memoryClearNested(txn, s.workSnapshots) },
+		graphPrefixSpaceCheckpoint:   func(txn *memoryTxn) int { return memoryClear(txn, s.spaceCheckpoints) },
+		graphPrefixSpaceQueue:        func(txn *memoryTxn) int { return memoryClearNested(txn, s.spaceQueue) },
+		graphPrefixAssetInfo:         func(txn *memoryTxn) int { return memoryClear(txn, s.assetInfos) },
+		graphPrefixAssetTotal:        func(txn *memoryTxn) int { return memoryClear(txn, s.assetTotals) },
+		graphPrefixCustodianUpdate:   func(txn *memoryTxn) int { return memoryClear(txn, s.custodians) },
+		graphPrefixConsensusSnapshot: func(txn *memoryTxn) int { return memoryClear(txn, s.consensus) },
+		graphPrefixPruneCheckpoint:   func(txn *memoryTxn) int { return memoryClear(txn, s.pruneCheckpoints) },
+		graphPrefixNodeStateQueue:    func(txn *memoryTxn) int { return memoryClear(txn, s.nodeStates) },
+		graphPrefixNodeOperation:     func(txn *memoryTxn) int { return memoryClear(txn, s.nodeOperations) },
+		graphPrefixPeerBan:           func(txn *memoryTxn) int { return memoryClear(txn, s.peerBans) },
+		graphPrefixPeerAddress:       func(txn *memoryTxn) int { return memoryClear(txn, s.peerAddresses) },
+
+		graphPrefixInscriptionCollection: func(txn *memoryTxn) int { return memoryClear(txn, s.inscriptionCollections) },
+		graphPrefixInscriptionItem:       func(txn *memoryTxn) int { return memoryClear(txn, s.inscriptionItems) },
+		graphPrefixInscriptionHash:       func(txn *memoryTxn) int { return memoryClear(txn, s.inscriptionHashes) },
+		graphPrefixInscriptionOwner:      func(txn *memoryTxn) int { return memoryClear(txn, s.inscriptionOwners) },
+		graphPrefixInscriptionContent:    func(txn *memoryTxn) int { return memoryClear(txn, s.inscriptionContents) },
+		graphPrefixInscriptionChecksum:   func(txn *memoryTxn) int { return memoryClear(txn, s.inscriptionChecksums) },
+
+		graphPrefixWalletState:   func(txn *memoryTxn) int { return memoryClear(txn, s.walletStates) },
+		graphPrefixWalletOutput:  func(txn *memoryTxn) int { return memoryClear(txn, s.walletOutputs) },
+		graphPrefixWalletUTXO:    func(txn *memoryTxn) int { return memoryClear(txn, s.walletUTXOs) },
+		graphPrefixWalletGhost:   func(txn *memoryTxn) int { return memoryClear(txn, s.walletGhosts) },
+		graphPrefixWalletBalance: func(txn *memoryTxn) int { return memoryClear(txn, s.walletBalances) },
+	}
+	for name := range tables {
+		if len(prefix) > len(name) && strings.HasPrefix(prefix, name) {
+			return 0, fmt.Errorf("memory store can't remove the entries inside %s", name)
+		}
+	}
+
+	var removed int
+	err := s.update(func(txn *memoryTxn) error {
+		for name, remove := range tables {
+			if strings.HasPrefix(name, prefix) {
+				removed += remove(txn)
+			}
+		}
+		return nil
+	})
+	return removed, err
+}
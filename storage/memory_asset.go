@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+func (s *MemoryStore) ReadAssetWithBalance(id crypto.Hash) (*common.Asset, common.Integer, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	asset, err := s.readAssetInfo(id)
+	if err != nil || asset == nil {
+		return nil, common.Zero, err
+	}
+	return asset, s.readTotalInAsset(id), nil
+}
+
+func (s *MemoryStore) readTotalInAsset(id crypto.Hash) common.Integer {
+	total, found := s.assetTotals[id]
+	if !found {
+		return common.Zero
+	}
+	return common.NewIntegerFromString(total)
+}
+
+func (s *MemoryStore) writeTotalInAsset(txn *memoryTxn, ver *common.VersionedTransaction) error {
+	asset, err := s.readAssetInfo(ver.Asset)
+	if err != nil {
+		return err
+	} else if asset == nil {
+		panic(ver.Asset.String())
+	}
+	total := s.readTotalInAsset(ver.Asset)
+
+	typ := ver.TransactionType()
+	switch {
+	case typ == common.TransactionTypeWithdrawalSubmit:
+		for _, o := range ver.Outputs {
+			if o.Type == common.OutputTypeWithdrawalSubmit {
+				total = total.Sub(o.Amount)
+			}
+		}
+	case typ == common.TransactionTypeDeposit:
+		total = total.Add(ver.DepositData().Amount)
+	case typ == common.TransactionTypeMint:
+		total = total.Add(ver.Inputs[0].Mint.Amount)
+	case len(ver.Inputs[0].Genesis) > 0:
+		for _, out := range ver.Outputs {
+			total = total.Add(out.Amount)
+		}
+	default:
+		return nil
+	}
+
+	max := common.GetAssetCapacity(ver.Asset)
+	if total.Cmp(max) > 0 {
+		panic(total.String())
+	}
+	memorySet(txn, s.assetTotals, ver.Asset, total.String())
+	return nil
+}
+
+func (s *MemoryStore) readAssetInfo(id crypto.Hash) (*common.Asset, error) {
+	a, found := s.assetInfos[id]
+	if !found {
+		return nil, nil
+	}
+	return &a, a.Verify()
+}
+
+func (s *MemoryStore) verifyAssetInfo(id crypto.Hash, a *common.Asset) error {
+	old, err := s.readAssetInfo(id)
+	if err != nil || old == nil {
+		return err
+	}
+	if old.Chain == a.Chain && old.AssetKey == a.AssetKey {
+		return nil
+	}
+	return fmt.Errorf("invalid asset info %s %v %v", id.String(), *old, *a)
+}
+
+func (s *MemoryStore) writeAssetInfo(txn *memoryTxn, id crypto.Hash, a *common.Asset) error {
+	old, err := s.readAssetInfo(id)
+	if err != nil {
+		return err
+	}
+	if old == nil {
+		memorySet(txn, s.assetInfos, id, *a)
+		return nil
+	}
+	if old.Chain == a.Chain && old.AssetKey == a.AssetKey {
+		return nil
+	}
+	return fmt.Errorf("invalid asset info %s %v %v", id.String(), *old, *a)
+}
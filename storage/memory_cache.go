@@ -0,0 +1,314 @@
+package storage
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+// the cache entries expire at the unix second, the same as the badger TTL
+type memoryCacheEntry struct {
+	payload   []byte
+	expiresAt uint64
+}
+
+type memoryCacheOrder struct {
+	timestamp uint64
+	sender    crypto.Hash
+	expiresAt uint64
+}
+
+type memoryCacheInput struct {
+	hash      crypto.Hash
+	expiresAt uint64
+}
+
+type memoryQueueKey struct {
+	timestamp uint64
+	hash      crypto.Hash
+}
+
+func (s *MemoryStore) CacheRetrieveTransactions(limit int) ([]*common.VersionedTransaction, error) {
+	entries, err := s.CacheSelectTransactions(limit, limit, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	txs := make([]*common.VersionedTransaction, len(entries))
+	for i, e := range entries {
+		txs[i] = e.Transaction
+	}
+	return txs, nil
+}
+
+func (s *MemoryStore) CacheSelectTransactions(window, limit int, filter func(*CacheTransaction) bool, order func([]*CacheTransaction) []*CacheTransaction) ([]*CacheTransaction, error) {
+	s.cacheMutex.Lock()
+	defer s.cacheMutex.Unlock()
+
+	s.cacheExpire()
+	queue := slices.SortedFunc(maps.Keys(s.cacheQueue), func(a, b memoryQueueKey) int {
+		if a.timestamp != b.timestamp {
+			return cmp.Compare(a.timestamp, b.timestamp)
+		}
+		return slices.Compare(a.hash[:], b.hash[:])
+	})
+
+	var entries []*CacheTransaction
+	keys := make(map[crypto.Hash]memoryQueueKey)
+	for _, key := range queue {
+		if len(entries) >= window {
+			break
+		}
+		if _, found := keys[key.hash]; found {
+			delete(s.cacheQueue, key)
+			continue
+		}
+		if _, found := s.cacheTransactions[key.hash]; !found {
+			delete(s.cacheQueue, key)
+			delete(s.cacheOrders, key.hash)
+			continue
+		}
+		entry, err := s.cacheReadEntry(key.hash)
+		if err != nil {
+			return nil, err
+		}
+		if filter != nil && !filter(entry) {
+			continue
+		}
+		keys[key.hash] = key
+		entries = append(entries, entry)
+	}
+
+	if order != nil {
+		entries = order(entries)
+	}
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	for _, e := range entries {
+		hash := e.Transaction.PayloadHash()
+		delete(s.cacheQueue, keys[hash])
+		delete(s.cacheOrders, hash)
+		e.Queued = false
+	}
+	return entries, nil
+}
+
+func (s *MemoryStore) CacheRemoveTransactions(hashes []crypto.Hash) error {
+	s.cacheMutex.Lock()
+	defer s.cacheMutex.Unlock()
+
+	for _, h := range hashes {
+		err := s.cacheRemoveTransaction(h)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) CachePutTransaction(tx *common.VersionedTransaction, sender crypto.Hash) error {
+	s.cacheMutex.Lock()
+	defer s.cacheMutex.Unlock()
+
+	s.cacheExpire()
+	hash := tx.PayloadHash()
+	if _, found := s.cacheOrders[hash]; found {
+		return nil
+	}
+	now := time.Now()
+	ts := uint64(now.UnixNano())
+	ttl := time.Duration(s.custom.Node.CacheTTL) * time.Second
+	queueExpiry := uint64(now.Add(ttl).Unix())
+	cacheExpiry := uint64(now.Add(ttl + time.Minute).Unix())
+
+	s.cacheOrders[hash] = memoryCacheOrder{ts, sender, queueExpiry}
+	s.cacheTransactions[hash] = memoryCacheEntry{tx.Marshal(), cacheExpiry}
+	for _, in := range tx.Inputs {
+		if !in.Hash.HasValue() {
+			continue
+		}
+		s.cacheInputs[memoryUTXOKey{in.Hash, in.Index}] = memoryCacheInput{hash, cacheExpiry}
+	}
+	s.cacheQueue[memoryQueueKey{ts, hash}] = queueExpiry
+	return nil
+}
+
+func (s *MemoryStore) CacheReadConflicts(tx *common.VersionedTransaction) ([]*CacheTransaction, error) {
+	s.cacheMutex.RLock()
+	defer s.cacheMutex.RUnlock()
+
+	var conflicts []*CacheTransaction
+	filter := map[crypto.Hash]bool{tx.PayloadHash(): true}
+	for _, in := range tx.Inputs {
+		if !in.Hash.HasValue() {
+			continue
+		}
+		input, found := s.cacheInputs[memoryUTXOKey{in.Hash, in.Index}]
+		if !found || cacheExpired(input.expiresAt) {
+			continue
+		}
+		if filter[input.hash] {
+			continue
+		}
+		filter[input.hash] = true
+		entry, err := s.cacheReadEntry(input.hash)
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			conflicts = append(conflicts, entry)
+		}
+	}
+	return conflicts, nil
+}
+
+func (s *MemoryStore) CacheGetTransaction(hash crypto.Hash) (*common.VersionedTransaction, error) {
+	s.cacheMutex.RLock()
+	defer s.cacheMutex.RUnlock()
+
+	return s.cacheReadTransaction(hash)
+}
+
+func (s *MemoryStore) CacheGetTransactionEntry(hash crypto.Hash) (*CacheTransaction, error) {
+	s.cacheMutex.RLock()
+	defer s.cacheMutex.RUnlock()
+
+	return s.cacheReadEntry(hash)
+}
+
+func (s *MemoryStore) CacheListTransactions(offset crypto.Hash, count uint64, filter func(*CacheTransaction) bool) ([]*CacheTransaction, error) {
+	if count > 500 {
+		return nil, fmt.Errorf("count %d too large, the maximum is 500", count)
+	}
+	s.cacheMutex.RLock()
+	defer s.cacheMutex.RUnlock()
+
+	hashes := slices.SortedFunc(maps.Keys(s.cacheTransactions), func(a, b crypto.Hash) int {
+		return slices.Compare(a[:], b[:])
+	})
+	txs := make([]*CacheTransaction, 0)
+	for _, hash := range hashes {
+		if uint64(len(txs)) >= count {
+			break
+		}
+		if slices.Compare(hash[:], offset[:]) <= 0 {
+			continue
+		}
+		entry, err := s.cacheReadEntry(hash)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil || (filter != nil && !filter(entry)) {
+			continue
+		}
+		txs = append(txs, entry)
+	}
+	return txs, nil
+}
+
+func (s *MemoryStore) CacheStats() (*CacheStats, error) {
+	s.cacheMutex.RLock()
+	defer s.cacheMutex.RUnlock()
+
+	var stats CacheStats
+	for _, e := range s.cacheTransactions {
+		if cacheExpired(e.expiresAt) {
+			continue
+		}
+		ts := s.cacheTimestamp(e.expiresAt)
+		if stats.Oldest == 0 || ts < stats.Oldest {
+			stats.Oldest = ts
+		}
+		stats.Transactions += 1
+		stats.Size += uint64(len(e.payload))
+	}
+	for _, o := range s.cacheOrders {
+		if !cacheExpired(o.expiresAt) {
+			stats.Queued += 1
+		}
+	}
+	return &stats, nil
+}
+
+func (s *MemoryStore) cacheReadEntry(hash crypto.Hash) (*CacheTransaction, error) {
+	e, found := s.cacheTransactions[hash]
+	if !found || cacheExpired(e.expiresAt) {
+		return nil, nil
+	}
+	ver, err := common.UnmarshalVersionedTransaction(e.payload)
+	if err != nil {
+		return nil, err
+	}
+	entry := &CacheTransaction{Transaction: ver, Timestamp: s.cacheTimestamp(e.expiresAt)}
+	o, found := s.cacheOrders[hash]
+	if found && !cacheExpired(o.expiresAt) {
+		entry.Queued = true
+		entry.Sender = o.sender
+	}
+	return entry, nil
+}
+
+func (s *MemoryStore) cacheReadTransaction(hash crypto.Hash) (*common.VersionedTransaction, error) {
+	e, found := s.cacheTransactions[hash]
+	if !found || cacheExpired(e.expiresAt) {
+		return nil, nil
+	}
+	return common.UnmarshalVersionedTransaction(e.payload)
+}
+
+func (s *MemoryStore) cacheRemoveTransaction(hash crypto.Hash) error {
+	if o, found := s.cacheOrders[hash]; found {
+		delete(s.cacheQueue, memoryQueueKey{o.timestamp, hash})
+		delete(s.cacheOrders, hash)
+	}
+	ver, err := s.cacheReadTransaction(hash)
+	if err != nil || ver == nil {
+		return err
+	}
+	for _, in := range ver.Inputs {
+		if !in.Hash.HasValue() {
+			continue
+		}
+		key := memoryUTXOKey{in.Hash, in.Index}
+		if input, found := s.cacheInputs[key]; found && input.hash == hash {
+			delete(s.cacheInputs, key)
+		}
+	}
+	delete(s.cacheTransactions, hash)
+	return nil
+}
+
+// cacheExpire removes all expired entries, the caller must hold the cache
+// write lock
+func (s *MemoryStore) cacheExpire() {
+	maps.DeleteFunc(s.cacheTransactions, func(_ crypto.Hash, e memoryCacheEntry) bool {
+		return cacheExpired(e.expiresAt)
+	})
+	maps.DeleteFunc(s.cacheOrders, func(_ crypto.Hash, o memoryCacheOrder) bool {
+		return cacheExpired(o.expiresAt)
+	})
+	maps.DeleteFunc(s.cacheQueue, func(_ memoryQueueKey, at uint64) bool {
+		return cacheExpired(at)
+	})
+	maps.DeleteFunc(s.cacheInputs, func(_ memoryUTXOKey, in memoryCacheInput) bool {
+		return cacheExpired(in.expiresAt)
+	})
+}
+
+// the payload expires 60 seconds after the cache TTL since it was cached
+func (s *MemoryStore) cacheTimestamp(expiresAt uint64) uint64 {
+	ttl := uint64(s.custom.Node.CacheTTL + 60)
+	if expiresAt < ttl {
+		return 0
+	}
+	return (expiresAt - ttl) * uint64(time.Second)
+}
+
+func cacheExpired(expiresAt uint64) bool {
+	return expiresAt <= uint64(time.Now().Unix())
+}
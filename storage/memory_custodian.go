@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/MixinNetwork/mixin/common"
+)
+
+func (s *MemoryStore) ListCustodianUpdates() ([]*common.CustodianUpdateRequest, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var curs []*common.CustodianUpdateRequest
+	for i, ts := range slices.Sorted(maps.Keys(s.custodians)) {
+		cur, err := s.parseCustodianUpdate(ts, i == 0)
+		if err != nil {
+			return nil, err
+		}
+		curs = append(curs, cur)
+	}
+	return curs, nil
+}
+
+func (s *MemoryStore) ReadCustodian(ts uint64) (*common.CustodianUpdateRequest, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.readCustodianAccount(ts)
+}
+
+func (s *MemoryStore) readCustodianAccount(ts uint64) (*common.CustodianUpdateRequest, error) {
+	var found *common.CustodianUpdateRequest
+	for i, cts := range slices.Sorted(maps.Keys(s.custodians)) {
+		if cts > ts {
+			break
+		}
+		cur, err := s.parseCustodianUpdate(cts, i == 0)
+		if err != nil {
+			return nil, err
+		}
+		found = cur
+	}
+	return found, nil
+}
+
+func (s *MemoryStore) parseCustodianUpdate(ts uint64, genesis bool) (*common.CustodianUpdateRequest, error) {
+	hash := s.custodians[ts]
+	tx, err := s.readTransaction(hash)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := common.ParseCustodianUpdateNodesExtra(tx.Extra, genesis)
+	if err != nil {
+		return nil, err
+	}
+	cur.Transaction = hash
+	cur.Timestamp = ts
+	return cur, nil
+}
+
+func (s *MemoryStore) writeCustodianNodes(txn *memoryTxn, snapTime uint64, utxo *common.UTXOWithLock, extra []byte, genesis bool) error {
+	now, err := common.ParseCustodianUpdateNodesExtra(extra, genesis)
+	if err != nil {
+		panic(fmt.Errorf("common.ParseCustodianUpdateNodesExtra(%x, %t) => %v", extra, genesis, err))
+	}
+	if len(now.Nodes) > 50 {
+		panic(len(now.Nodes))
+	}
+	prev, err := s.readCustodianAccount(snapTime)
+	if err != nil {
+		return err
+	}
+	switch {
+	case prev == nil:
+	case prev.Timestamp > snapTime:
+		panic(utxo.Hash.String())
+	case prev.Timestamp == snapTime && now.Custodian.String() == prev.Custodian.String():
+		return nil
+	case prev.Timestamp == snapTime && now.Custodian.String() != prev.Custodian.String():
+		panic(utxo.Hash.String())
+	case prev.Timestamp < snapTime:
+	}
+
+	memorySet(txn, s.custodians, snapTime, utxo.Hash)
+	return nil
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/MixinNetwork/mixin/common"
+)
+
+func (s *MemoryStore) LoadGenesis(rounds []*common.Round, snapshots []*common.SnapshotWithTopologicalOrder, transactions []*common.VersionedTransaction) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	loaded, err := s.checkGenesisLoad(snapshots)
+	if loaded || err != nil {
+		return err
+	}
+
+	return s.update(func(txn *memoryTxn) error {
+		err := s.writeAssetInfo(txn, common.XINAssetId, common.XINAsset)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range rounds {
+			s.writeRound(txn, r.Hash, r)
+		}
+		for i, snap := range snapshots {
+			err := s.writeTransaction(txn, transactions[i])
+			if err != nil {
+				return err
+			}
+			err = s.writeSnapshot(txn, snap, transactions[i])
+			if err != nil {
+				return err
+			}
+			err = s.writeSnapshotWork(txn, snap, nil)
+			if err != nil {
+				return err
+			}
+		}
+
+		cs := snapshots[len(snapshots)-1]
+		ct := transactions[len(snapshots)-1]
+		if cs.TopologicalOrder+1 != uint64(len(snapshots)) {
+			panic(cs.TopologicalOrder)
+		}
+		return s.writeConsensusSnapshot(txn, cs.Snapshot, ct, nil)
+	})
+}
+
+func (s *MemoryStore) CheckGenesisLoad(snapshots []*common.SnapshotWithTopologicalOrder) (bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.checkGenesisLoad(snapshots)
+}
+
+func (s *MemoryStore) checkGenesisLoad(snapshots []*common.SnapshotWithTopologicalOrder) (bool, error) {
+	loaded := len(s.topologies) > 0
+	for index, topo := range slices.Sorted(maps.Keys(s.topologies)) {
+		if index >= len(snapshots) {
+			break
+		}
+		snap, err := common.UnmarshalVersionedSnapshot(s.readSnapshot(s.topologies[topo]))
+		if err != nil {
+			return loaded, err
+		}
+		hash := snap.PayloadHash()
+		if hash != snapshots[index].Hash {
+			return loaded, fmt.Errorf("malformed genesis snapshot %s %s", snapshots[index].Hash, hash)
+		}
+	}
+	return loaded, nil
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+func (s *MemoryStore) WriteConsensusSnapshot(snap *common.Snapshot, tx *common.VersionedTransaction, hack *common.Snapshot) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.update(func(txn *memoryTxn) error {
+		return s.writeConsensusSnapshot(txn, snap, tx, hack)
+	})
+}
+
+func (s *MemoryStore) writeConsensusSnapshot(txn *memoryTxn, snap *common.Snapshot, tx *common.VersionedTransaction, hack *common.Snapshot) error {
+	if snap.SoleTransaction() != tx.PayloadHash() {
+		panic(snap.PayloadHash())
+	}
+	if len(tx.Inputs) == 1 && tx.Inputs[0].Mint != nil {
+		storageLogger.Printf("writeConsensusSnapshot(%s) => mint", snap.SoleTransaction())
+	} else {
+		out := tx.Outputs[0]
+		switch out.Type {
+		case common.OutputTypeNodePledge:
+		case common.OutputTypeNodeCancel:
+		case common.OutputTypeNodeAccept:
+		case common.OutputTypeNodeRemove:
+		case common.OutputTypeCustodianUpdateNodes:
+		case common.OutputTypeCustodianSlashNodes:
+		default:
+			panic(out.Type)
+		}
+		storageLogger.Printf("writeConsensusSnapshot(%s) => %d", snap.SoleTransaction(), out.Type)
+	}
+
+	isGenesis := len(tx.Inputs) == 1 && tx.Inputs[0].Genesis != nil
+	last, err := s.readLastConsensusSnapshot()
+	if err != nil {
+		return err
+	}
+	if hack != nil {
+		if last != nil {
+			panic(snap.PayloadHash())
+		}
+		last = hack
+	}
+
+	if !isGenesis {
+		if last.SoleTransaction() == tx.PayloadHash() {
+			return nil
+		}
+		if last.SoleTransaction() != tx.References[0] {
+			panic(snap.PayloadHash())
+		}
+		if last.Timestamp >= snap.Timestamp {
+			panic(snap.PayloadHash())
+		}
+		key := memoryConsensusKey{last.Timestamp, last.PayloadHash()}
+		memorySet(txn, s.consensus, key, tx.PayloadHash())
+	}
+
+	key := memoryConsensusKey{snap.Timestamp, snap.PayloadHash()}
+	memorySet(txn, s.consensus, key, crypto.Hash{})
+	return nil
+}
+
+func (s *MemoryStore) ReadLastConsensusSnapshot() (*common.Snapshot, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.readLastConsensusSnapshot()
+}
+
+func (s *MemoryStore) readLastConsensusSnapshot() (*common.Snapshot, error) {
+	if len(s.consensus) == 0 {
+		return nil, nil
+	}
+	key := slices.MaxFunc(slices.Collect(maps.Keys(s.consensus)), func(a, b memoryConsensusKey) int {
+		if a.timestamp != b.timestamp {
+			return cmp.Compare(a.timestamp, b.timestamp)
+		}
+		return slices.Compare(a.snapshot[:], b.snapshot[:])
+	})
+	snap, err := s.readSnapshotWithTopo(key.snapshot)
+	if err != nil {
+		return nil, err
+	}
+	if snap.Timestamp != key.timestamp {
+		panic(snap.PayloadHash())
+	}
+	if next := s.consensus[key]; next.HasValue() {
+		panic(next.String())
+	}
+	return snap.Snapshot, nil
+}
+
+func (s *MemoryStore) ReadSnapshotsForNodeRound(nodeId crypto.Hash, round uint64) ([]*common.SnapshotWithTopologicalOrder, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.readSnapshotsForNodeRound(nodeId, round)
+}
+
+func (s *MemoryStore) readSnapshotsForNodeRound(nodeId crypto.Hash, round uint64) ([]*common.SnapshotWithTopologicalOrder, error) {
+	snapshots := make([]*common.SnapshotWithTopologicalOrder, 0)
+	for _, v := range s.snapshots[memoryRoundKey{nodeId, round}] {
+		s, err := common.UnmarshalVersionedSnapshot(v)
+		if err != nil {
+			return snapshots, err
+		}
+		s.Hash = s.PayloadHash()
+		snapshots = append(snapshots, s)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		if snapshots[i].Timestamp == snapshots[j].Timestamp {
+			a, b := snapshots[i].SoleTransaction(), snapshots[j].SoleTransaction()
+			return slices.Compare(a[:], b[:]) < 0
+		}
+		return snapshots[i].Timestamp < snapshots[j].Timestamp
+	})
+	return snapshots, nil
+}
+
+func (s *MemoryStore) WriteSnapshot(snap *common.SnapshotWithTopologicalOrder, signers []crypto.Hash) error {
+	storageLogger.Debugf("MemoryStore.WriteSnapshot(%v)", snap.Snapshot)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// FIXME assert only, remove in future
+	if config.Debug {
+		cache, err := s.readRound(snap.NodeId)
+		if err != nil {
+			return err
+		}
+		if cache == nil || snap.RoundNumber != cache.Number {
+			panic(fmt.Errorf("snapshot round number assert error %v %d", cache, snap.RoundNumber))
+		}
+		if snap.RoundNumber > 0 && !snap.References.Equal(cache.References) {
+			panic("snapshot references assert error")
+		}
+		if _, found := s.transactions[snap.SoleTransaction()]; !found {
+			panic("snapshot transaction not exist")
+		}
+		if s.readSnapshot(memorySnapshotKey{memoryRoundKey{snap.NodeId, snap.RoundNumber}, snap.SoleTransaction()}) != nil {
+			panic("snapshot duplication")
+		}
+		if s.uniques[memoryPair{snap.NodeId, snap.SoleTransaction()}] {
+			panic("snapshot duplication")
+		}
+	}
+	// end assert
+
+	return s.update(func(txn *memoryTxn) error {
+		ver, err := s.readTransaction(snap.SoleTransaction())
+		if err != nil {
+			return err
+		}
+		err = s.writeSnapshot(txn, snap, ver)
+		if err != nil {
+			return err
+		}
+		return s.writeSnapshotWork(txn, snap, signers)
+	})
+}
+
+func (s *MemoryStore) writeSnapshot(txn *memoryTxn, snap *common.SnapshotWithTopologicalOrder, ver *common.VersionedTransaction) error {
+	err := s.finalizeTransaction(txn, ver, snap)
+	if err != nil {
+		return err
+	}
+
+	key := memorySnapshotKey{memoryRoundKey{snap.NodeId, snap.RoundNumber}, snap.SoleTransaction()}
+	memorySetNested(txn, s.snapshots, key.memoryRoundKey, key.tx, snap.VersionedMarshal())
+	memorySet(txn, s.uniques, memoryPair{snap.NodeId, snap.SoleTransaction()}, true)
+	return s.writeTopology(txn, snap)
+}
+
+func (s *MemoryStore) readSnapshot(key memorySnapshotKey) []byte {
+	return s.snapshots[key.memoryRoundKey][key.tx]
+}
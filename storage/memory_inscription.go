@@ -0,0 +1,317 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+func (s *MemoryStore) ReadInscriptionCollection(hash crypto.Hash) (*common.InscriptionCollection, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.readInscriptionCollection(hash)
+}
+
+func (s *MemoryStore) ReadInscriptionItem(hash crypto.Hash) (*common.InscriptionItem, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	key, found := s.inscriptionHashes[hash]
+	if !found {
+		return nil, nil
+	}
+	return s.readInscriptionItem(key)
+}
+
+func (s *MemoryStore) ReadInscriptionItems(collection crypto.Hash, offset, count uint64) ([]*common.InscriptionItem, error) {
+	if count > 500 {
+		return nil, fmt.Errorf("count %d too large, the maximum is 500", count)
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	items := make([]*common.InscriptionItem, 0)
+	for seq := offset; uint64(len(items)) < count; seq++ {
+		key := memoryInscriptionKey{collection, seq}
+		if _, found := s.inscriptionItems[key]; !found {
+			break
+		}
+		item, err := s.readInscriptionItem(key)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (s *MemoryStore) writeInscriptionOperation(txn *memoryTxn, ver *common.VersionedTransaction, timestamp uint64) error {
+	err := s.releaseInscriptionOwners(txn, ver)
+	if err != nil {
+		return err
+	}
+
+	op := common.ParseInscriptionOperation(ver.Extra)
+	switch op {
+	case common.InscriptionOperationDeploy:
+		return s.writeInscriptionDeployment(txn, ver, timestamp)
+	case common.InscriptionOperationInscribe:
+		return s.writeInscriptionInscribe(txn, ver, timestamp)
+	case common.InscriptionOperationDistribute:
+		return s.writeInscriptionDistribution(txn, ver)
+	case common.InscriptionOperationOccupy:
+		return s.writeInscriptionOccupation(txn, ver)
+	}
+	return nil
+}
+
+func (s *MemoryStore) writeInscriptionDeployment(txn *memoryTxn, ver *common.VersionedTransaction, timestamp uint64) error {
+	if ver.Asset != common.XINAssetId {
+		return nil
+	}
+	d, err := common.ParseDeployment(ver.Extra)
+	if err != nil {
+		storageLogger.Verbosef("writeInscriptionDeployment(%s) => %v", ver.PayloadHash(), err)
+		return nil
+	}
+
+	hash := ver.PayloadHash()
+	old, err := s.readInscriptionCollection(hash)
+	if err != nil || old != nil {
+		return err
+	}
+	checksums, err := d.Checksums()
+	if err != nil {
+		panic(err)
+	}
+	for i, c := range checksums {
+		memorySet(txn, s.inscriptionChecksums, memoryPair{hash, c}, uint64(i))
+	}
+	s.writeInscriptionCollection(txn, &common.InscriptionCollection{
+		Hash:       hash,
+		Deployment: d,
+		Timestamp:  timestamp,
+	})
+	return nil
+}
+
+func (s *MemoryStore) writeInscriptionInscribe(txn *memoryTxn, ver *common.VersionedTransaction, timestamp uint64) error {
+	if ver.Asset != common.XINAssetId || len(ver.References) == 0 {
+		return nil
+	}
+	hash := ver.PayloadHash()
+	ins, err := common.ParseInscription(ver.Extra)
+	if err != nil {
+		storageLogger.Verbosef("writeInscriptionInscribe(%s) => %v", hash, err)
+		return nil
+	}
+	collection, err := s.readInscriptionCollection(ver.References[0])
+	if err != nil || collection == nil {
+		return err
+	}
+	if collection.Inscriptions >= collection.Deployment.Total() {
+		return nil
+	}
+
+	var checksum crypto.Hash
+	if ins.Content != "" {
+		data, mime, err := common.DecodeInscriptionContent(ins.Content)
+		if err != nil {
+			panic(err)
+		}
+		checksum = crypto.Blake3Hash(data)
+		if !s.validateInscriptionContent(collection, checksum, data, mime) {
+			return nil
+		}
+		memorySet(txn, s.inscriptionContents, memoryPair{collection.Hash, checksum}, collection.Inscriptions)
+	} else if collection.Deployment.Validation != "" {
+		return nil
+	}
+
+	item := &common.InscriptionItem{
+		Collection:  collection.Hash,
+		Sequence:    collection.Inscriptions,
+		Hash:        hash,
+		Recipient:   ins.Recipient,
+		ContentHash: checksum,
+		State:       common.InscriptionStateInscribed,
+		Timestamp:   timestamp,
+	}
+	s.writeInscriptionItem(txn, item)
+	memorySet(txn, s.inscriptionHashes, hash, memoryInscriptionKey{item.Collection, item.Sequence})
+	collection.Inscriptions += 1
+	s.writeInscriptionCollection(txn, collection)
+	return nil
+}
+
+func (s *MemoryStore) validateInscriptionContent(collection *common.InscriptionCollection, checksum crypto.Hash, data []byte, mime string) bool {
+	if _, found := s.inscriptionContents[memoryPair{collection.Hash, checksum}]; found {
+		return false
+	}
+
+	d := collection.Deployment
+	if re, _ := d.Regexp(); re != nil {
+		return strings.HasPrefix(mime, "text/") && re.Match(data)
+	}
+	if d.Validation == "" {
+		return true
+	}
+	_, found := s.inscriptionChecksums[memoryPair{collection.Hash, checksum}]
+	return found
+}
+
+func (s *MemoryStore) writeInscriptionDistribution(txn *memoryTxn, ver *common.VersionedTransaction) error {
+	if len(ver.References) == 0 || len(ver.Outputs) == 0 {
+		return nil
+	}
+	dist, err := common.ParseDistribution(ver.Extra)
+	if err != nil {
+		return nil
+	}
+	key, found := s.inscriptionHashes[ver.References[0]]
+	if !found {
+		return nil
+	}
+	item, err := s.readInscriptionItem(key)
+	if err != nil {
+		return err
+	}
+	collection, err := s.readInscriptionCollection(item.Collection)
+	if err != nil {
+		return err
+	}
+	d := collection.Deployment
+	switch {
+	case item.State != common.InscriptionStateInscribed:
+		return nil
+	case dist.Sequence != item.Sequence || dist.Sequence != collection.Distributions:
+		return nil
+	case d.Mode == common.InscriptionModeDone && collection.Inscriptions < d.Total():
+		return nil
+	case collection.Asset.HasValue() && collection.Asset != ver.Asset:
+		return nil
+	case ver.Outputs[0].Amount.Cmp(d.InscriptionAmount()) != 0:
+		return nil
+	}
+
+	collection.Asset = ver.Asset
+	collection.Distributions += 1
+	s.writeInscriptionCollection(txn, collection)
+	item.State = common.InscriptionStateDistributed
+	s.writeInscriptionOwner(txn, item, ver.PayloadHash(), 0)
+	return nil
+}
+
+func (s *MemoryStore) writeInscriptionOccupation(txn *memoryTxn, ver *common.VersionedTransaction) error {
+	if len(ver.References) == 0 || len(ver.Outputs) == 0 {
+		return nil
+	}
+	occ, err := common.ParseOccupation(ver.Extra)
+	if err != nil {
+		return nil
+	}
+	key, found := s.inscriptionHashes[ver.References[0]]
+	if !found {
+		return nil
+	}
+	item, err := s.readInscriptionItem(key)
+	if err != nil {
+		return err
+	}
+	collection, err := s.readInscriptionCollection(item.Collection)
+	if err != nil {
+		return err
+	}
+	switch {
+	case item.State != common.InscriptionStateReleased:
+		return nil
+	case occ.Sequence != item.Sequence:
+		return nil
+	case collection.Asset != ver.Asset:
+		return nil
+	case ver.Outputs[0].Amount.Cmp(collection.Deployment.UnitAmount()) != 0:
+		return nil
+	}
+
+	item.State = common.InscriptionStateOccupied
+	s.writeInscriptionOwner(txn, item, ver.PayloadHash(), 0)
+	return nil
+}
+
+func (s *MemoryStore) releaseInscriptionOwners(txn *memoryTxn, ver *common.VersionedTransaction) error {
+	for _, in := range ver.Inputs {
+		if len(in.Genesis) > 0 || in.Deposit != nil || in.Mint != nil {
+			continue
+		}
+		owner := memoryUTXOKey{in.Hash, in.Index}
+		key, found := s.inscriptionOwners[owner]
+		if !found {
+			continue
+		}
+		item, err := s.readInscriptionItem(key)
+		if err != nil {
+			return err
+		}
+		memoryDelete(txn, s.inscriptionOwners, owner)
+
+		collection, err := s.readInscriptionCollection(item.Collection)
+		if err != nil {
+			return err
+		}
+		amount := collection.Deployment.InscriptionAmount()
+		if item.State == common.InscriptionStateOccupied {
+			amount = collection.Deployment.UnitAmount()
+		}
+		if len(ver.Inputs) == 1 && len(ver.Outputs) > 0 && ver.Outputs[0].Amount.Cmp(amount) == 0 {
+			s.writeInscriptionOwner(txn, item, ver.PayloadHash(), 0)
+		} else {
+			item.State = common.InscriptionStateReleased
+			item.OwnerHash, item.OwnerIndex = crypto.Hash{}, 0
+			s.writeInscriptionItem(txn, item)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) writeInscriptionOwner(txn *memoryTxn, item *common.InscriptionItem, hash crypto.Hash, index uint) {
+	item.OwnerHash, item.OwnerIndex = hash, index
+	s.writeInscriptionItem(txn, item)
+	key := memoryInscriptionKey{item.Collection, item.Sequence}
+	memorySet(txn, s.inscriptionOwners, memoryUTXOKey{hash, index}, key)
+}
+
+func (s *MemoryStore) readInscriptionCollection(hash crypto.Hash) (*common.InscriptionCollection, error) {
+	val, found := s.inscriptionCollections[hash]
+	if !found {
+		return nil, nil
+	}
+	var c common.InscriptionCollection
+	err := json.Unmarshal(val, &c)
+	return &c, err
+}
+
+func (s *MemoryStore) writeInscriptionCollection(txn *memoryTxn, c *common.InscriptionCollection) {
+	val, err := json.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	memorySet(txn, s.inscriptionCollections, c.Hash, val)
+}
+
+func (s *MemoryStore) readInscriptionItem(key memoryInscriptionKey) (*common.InscriptionItem, error) {
+	var i common.InscriptionItem
+	err := json.Unmarshal(s.inscriptionItems[key], &i)
+	return &i, err
+}
+
+func (s *MemoryStore) writeInscriptionItem(txn *memoryTxn, i *common.InscriptionItem) {
+	val, err := json.Marshal(i)
+	if err != nil {
+		panic(err)
+	}
+	memorySet(txn, s.inscriptionItems, memoryInscriptionKey{i.Collection, i.Sequence}, val)
+}
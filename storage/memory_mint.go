@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+func (s *MemoryStore) ReadMintDistributions(offset, count uint64) ([]*common.MintDistribution, []*common.VersionedTransaction, error) {
+	if count > 500 {
+		return nil, nil, fmt.Errorf("count %d too large, the maximum is 500", count)
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	mints := make([]*common.MintDistribution, 0)
+	transactions := make([]*common.VersionedTransaction, 0)
+	for _, batch := range slices.Sorted(maps.Keys(s.mints)) {
+		if batch < offset {
+			continue
+		}
+		if uint64(len(mints)) >= count {
+			break
+		}
+		data, err := common.UnmarshalMintDistribution(s.mints[batch])
+		if err != nil {
+			return nil, nil, err
+		}
+		tx, err := s.readTransaction(data.Transaction)
+		if err != nil {
+			return nil, nil, err
+		}
+		if tx == nil {
+			continue
+		}
+		if _, found := s.finalizations[data.Transaction]; !found {
+			continue
+		}
+		transactions = append(transactions, tx)
+		mints = append(mints, data)
+	}
+	return mints, transactions, nil
+}
+
+func (s *MemoryStore) ReadLastMintDistribution(batch uint64) (*common.MintDistribution, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	batches := slices.Sorted(maps.Keys(s.mints))
+	for i := len(batches) - 1; i >= 0; i-- {
+		if batches[i] > batch {
+			continue
+		}
+		data, err := common.UnmarshalMintDistribution(s.mints[batches[i]])
+		if err != nil {
+			return nil, err
+		}
+		if _, found := s.finalizations[data.Transaction]; !found {
+			continue
+		}
+		return data, nil
+	}
+	return nil, nil
+}
+
+func (s *MemoryStore) ReadMintLock(mint *common.MintData) (*common.MintDistribution, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.readMintInput(mint)
+}
+
+func (s *MemoryStore) LockMintInput(mint *common.MintData, tx crypto.Hash, fork bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.update(func(txn *memoryTxn) error {
+		dist, err := s.readMintInput(mint)
+		if err != nil {
+			return err
+		}
+		if dist == nil {
+			memorySet(txn, s.mints, mint.Batch, mint.Distribute(tx).Marshal())
+			return nil
+		}
+
+		if dist.Transaction == tx && dist.Amount.Cmp(mint.Amount) == 0 {
+			return nil
+		}
+
+		if !fork {
+			return fmt.Errorf("mint locked for transaction %s amount %s", dist.Transaction.String(), dist.Amount.String())
+		}
+		err = s.pruneTransaction(txn, dist.Transaction)
+		if err != nil {
+			return err
+		}
+		memorySet(txn, s.mints, mint.Batch, mint.Distribute(tx).Marshal())
+		return nil
+	})
+}
+
+func (s *MemoryStore) readMintInput(mint *common.MintData) (*common.MintDistribution, error) {
+	val, found := s.mints[mint.Batch]
+	if !found {
+		return nil, nil
+	}
+	return common.UnmarshalMintDistribution(val)
+}
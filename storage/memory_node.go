@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+func (s *MemoryStore) readAllNodes(threshold uint64, withState bool) []*common.Node {
+	keys := slices.SortedFunc(maps.Keys(s.nodeStates), func(a, b memoryNodeKey) int {
+		if a.timestamp != b.timestamp {
+			return cmp.Compare(a.timestamp, b.timestamp)
+		}
+		return slices.Compare(a.signer[:], b.signer[:])
+	})
+
+	nodes := make([]*common.Node, 0)
+	for _, k := range keys {
+		if k.timestamp == 0 {
+			panic(fmt.Errorf("invalid node timestamp %s", k.signer.String()))
+		}
+		if k.timestamp > threshold {
+			continue
+		}
+		entry := s.nodeStates[k]
+		nodes = append(nodes, &common.Node{
+			Signer:      nodeAddressFromSpendKey(k.signer),
+			Payee:       nodeAddressFromSpendKey(entry.payee),
+			Transaction: entry.tx,
+			State:       entry.state,
+			Timestamp:   k.timestamp,
+		})
+	}
+
+	if withState {
+		return nodes
+	}
+	filter := make(map[crypto.Hash]*common.Node)
+	for _, n := range nodes {
+		filter[n.Signer.Hash()] = n
+	}
+	nodes = make([]*common.Node, 0)
+	for _, n := range filter {
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Timestamp < nodes[j].Timestamp
+	})
+	return nodes
+}
+
+func (s *MemoryStore) ReadAllNodes(threshold uint64, withState bool) []*common.Node {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.readAllNodes(threshold, withState)
+}
+
+func (s *MemoryStore) AddNodeOperation(tx *common.VersionedTransaction, timestamp, threshold uint64, finalized bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var op string
+	switch tx.TransactionType() {
+	case common.TransactionTypeNodePledge:
+		op = "PLEDGE"
+	case common.TransactionTypeNodeCancel:
+		op = "CANCEL"
+	}
+	if op == "" {
+		return fmt.Errorf("invalid operation %d %s", tx.TransactionType(), op)
+	}
+	hash := tx.PayloadHash()
+
+	var last memoryNodeOperation
+	var lastTs uint64
+	if len(s.nodeOperations) > 0 {
+		lastTs = slices.Max(slices.Collect(maps.Keys(s.nodeOperations)))
+		last = s.nodeOperations[lastTs]
+	}
+
+	if lastTs+threshold >= timestamp {
+		if last.op == op && last.tx == hash {
+			return nil
+		}
+		if !finalized {
+			return fmt.Errorf("invalid operation lock %s %s %d", last.tx, last.op, lastTs)
+		}
+	}
+
+	return s.update(func(txn *memoryTxn) error {
+		memorySet(txn, s.nodeOperations, timestamp, memoryNodeOperation{hash, op})
+		return nil
+	})
+}
+
+func (s *MemoryStore) writeNodeCancel(txn *memoryTxn, signer, payee crypto.Key, tx crypto.Hash, timestamp uint64) error {
+	offset := timestamp + uint64(config.KernelNodeAcceptPeriodMinimum)
+	nodes := s.readAllNodes(offset, true)
+	last := nodes[len(nodes)-1]
+	if last.State != common.NodeStatePledging {
+		return fmt.Errorf("node %s is %s@%d while tx %s", last.Signer, last.State, last.Timestamp, tx.String())
+	}
+	if last.Signer.PublicSpendKey != signer || last.Payee.PublicSpendKey != payee {
+		return fmt.Errorf("node %s %s not match at pledging", last.Signer.PublicSpendKey, signer)
+	}
+
+	s.writeNodeState(txn, signer, payee, tx, timestamp, common.NodeStateCancelled)
+	return nil
+}
+
+func (s *MemoryStore) writeNodeRemove(txn *memoryTxn, signer, payee crypto.Key, tx crypto.Hash, timestamp uint64) error {
+	offset := timestamp + uint64(config.KernelNodeAcceptPeriodMinimum)
+	nodes := s.readAllNodes(offset, true)
+	last := nodes[len(nodes)-1]
+	switch last.State {
+	case common.NodeStateAccepted:
+	case common.NodeStateRemoved:
+	case common.NodeStateCancelled:
+	default:
+		return fmt.Errorf("node %s is %s@%d while tx %s", last.Signer, last.State, last.Timestamp, tx.String())
+	}
+
+	var node *common.Node
+	for _, n := range nodes {
+		if n.Signer.PublicSpendKey == signer {
+			node = n
+		}
+	}
+	if node == nil {
+		return fmt.Errorf("node not available to remove %s", signer)
+	}
+	if node.Payee.PublicSpendKey != payee {
+		return fmt.Errorf("node %s %s not match at %s", last.Payee.PublicSpendKey, payee, node.State)
+	}
+	if node.State != common.NodeStateAccepted {
+		return fmt.Errorf("node %s %s not match at %s", last.Payee.PublicSpendKey, payee, node.State)
+	}
+
+	s.writeNodeState(txn, signer, payee, tx, timestamp, common.NodeStateRemoved)
+	return nil
+}
+
+func (s *MemoryStore) writeNodeAccept(txn *memoryTxn, signer, payee crypto.Key, tx crypto.Hash, timestamp uint64, genesis bool) error {
+	if !genesis {
+		offset := timestamp + uint64(config.KernelNodeAcceptPeriodMinimum)
+		nodes := s.readAllNodes(offset, true)
+		last := nodes[len(nodes)-1]
+		if last.State != common.NodeStatePledging {
+			return fmt.Errorf("node %s is %s@%d while tx %s", last.Signer, last.State, last.Timestamp, tx.String())
+		}
+		if last.Signer.PublicSpendKey != signer || last.Payee.PublicSpendKey != payee {
+			return fmt.Errorf("node %s %s not match at pledging", last.Signer.PublicSpendKey, signer)
+		}
+	}
+
+	s.writeNodeState(txn, signer, payee, tx, timestamp, common.NodeStateAccepted)
+	return nil
+}
+
+func (s *MemoryStore) writeNodePledge(txn *memoryTxn, signer, payee crypto.Key, tx crypto.Hash, timestamp uint64) error {
+	offset := timestamp + uint64(config.KernelNodePledgePeriodMinimum)
+	nodes := s.readAllNodes(offset, false)
+	for _, n := range nodes {
+		switch n.State {
+		case common.NodeStateAccepted:
+		case common.NodeStateRemoved:
+		case common.NodeStateCancelled:
+		default:
+			return fmt.Errorf("node %s is %s@%d while tx %s", n.Signer, n.State, n.Timestamp, tx.String())
+		}
+	}
+
+	for _, n := range nodes {
+		if n.Signer.PublicSpendKey == signer || n.Transaction == tx {
+			return fmt.Errorf("node %s is already %s@%d", n.Signer, n.State, n.Timestamp)
+		}
+	}
+
+	s.writeNodeState(txn, signer, payee, tx, timestamp, common.NodeStatePledging)
+	return nil
+}
+
+func (s *MemoryStore) writeNodeState(txn *memoryTxn, signer, payee crypto.Key, tx crypto.Hash, timestamp uint64, state string) {
+	key := memoryNodeKey{timestamp, signer}
+	memorySet(txn, s.nodeStates, key, memoryNodeEntry{payee, tx, state})
+}
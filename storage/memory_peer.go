@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"maps"
+	"slices"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+func (s *MemoryStore) WritePeerBan(id crypto.Hash, until uint64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.update(func(txn *memoryTxn) error {
+		if until == 0 {
+			memoryDelete(txn, s.peerBans, id)
+		} else {
+			memorySet(txn, s.peerBans, id, until)
+		}
+		return nil
+	})
+}
+
+func (s *MemoryStore) ReadPeerBans() (map[crypto.Hash]uint64, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return maps.Clone(s.peerBans), nil
+}
+
+func (s *MemoryStore) WritePeerAddress(id crypto.Hash, data []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.update(func(txn *memoryTxn) error {
+		if len(data) == 0 {
+			memoryDelete(txn, s.peerAddresses, id)
+		} else {
+			memorySet(txn, s.peerAddresses, id, slices.Clone(data))
+		}
+		return nil
+	})
+}
+
+func (s *MemoryStore) ReadPeerAddresses() (map[crypto.Hash][]byte, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	addresses := make(map[crypto.Hash][]byte)
+	for id, data := range s.peerAddresses {
+		addresses[id] = slices.Clone(data)
+	}
+	return addresses, nil
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+func (s *MemoryStore) ReadPruneCheckpoint(nodeId crypto.Hash) (uint64, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.pruneCheckpoints[nodeId], nil
+}
+
+func (s *MemoryStore) PruneNodeRounds(nodeId crypto.Hash, before uint64, limit int) (uint64, error) {
+	checkpoint, err := s.ReadPruneCheckpoint(nodeId)
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < limit && checkpoint < before; i++ {
+		err = s.pruneNodeRound(nodeId, checkpoint)
+		if err != nil {
+			return checkpoint, err
+		}
+		checkpoint = checkpoint + 1
+	}
+	return checkpoint, nil
+}
+
+func (s *MemoryStore) pruneNodeRound(nodeId crypto.Hash, number uint64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.pruneCheckpoints[nodeId] != number {
+		return nil
+	}
+
+	return s.update(func(txn *memoryTxn) error {
+		snapshots, err := s.readSnapshotsForNodeRound(nodeId, number)
+		if err != nil {
+			return err
+		}
+		prunable, err := s.roundPrunable(snapshots)
+		if err != nil {
+			return err
+		}
+		if prunable {
+			for _, snap := range snapshots {
+				s.pruneSnapshot(txn, snap)
+			}
+			_, _, hash := computeRoundHash(nodeId, number, snapshots)
+			memoryDelete(txn, s.rounds, hash)
+		}
+
+		memorySet(txn, s.pruneCheckpoints, nodeId, number+1)
+		return nil
+	})
+}
+
+func (s *MemoryStore) roundPrunable(snapshots []*common.SnapshotWithTopologicalOrder) (bool, error) {
+	if len(snapshots) == 0 {
+		return false, nil
+	}
+	for _, snap := range snapshots {
+		ver, final, err := s.readTransactionAndFinalization(snap.SoleTransaction())
+		if err != nil || final == "" {
+			return false, err
+		}
+		if ver == nil {
+			continue
+		}
+		ok, err := s.transactionPrunable(ver)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (s *MemoryStore) pruneSnapshot(txn *memoryTxn, snap *common.SnapshotWithTopologicalOrder) {
+	hash := snap.SoleTransaction()
+	if val, found := s.transactions[hash]; found {
+		ver, err := common.UnmarshalVersionedTransaction(val)
+		if err != nil {
+			panic(err)
+		}
+		for _, utxo := range ver.UnspentOutputs() {
+			memoryDelete(txn, s.utxos, memoryUTXOKey{utxo.Hash, utxo.Index})
+		}
+		memoryDelete(txn, s.transactions, hash)
+	}
+
+	memoryDelete(txn, s.topologies, s.snapTopologies[snap.Hash])
+	memoryDeleteNested(txn, s.snapshots, memoryRoundKey{snap.NodeId, snap.RoundNumber}, hash)
+}
+
+func (s *MemoryStore) transactionPrunable(ver *common.VersionedTransaction) (bool, error) {
+	switch ver.TransactionType() {
+	case common.TransactionTypeScript:
+	case common.TransactionTypeDeposit:
+	default:
+		return false, nil
+	}
+	for _, utxo := range ver.UnspentOutputs() {
+		out, err := s.readUTXOLock(utxo.Hash, utxo.Index)
+		if err != nil || out == nil || !out.LockHash.HasValue() {
+			return false, err
+		}
+		lock, final, err := s.readTransactionAndFinalization(out.LockHash)
+		if err != nil || final == "" {
+			return false, err
+		}
+		if lock == nil {
+			continue
+		}
+		switch lock.TransactionType() {
+		case common.TransactionTypeScript:
+		case common.TransactionTypeDeposit:
+		default:
+			return false, nil
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+func (s *MemoryStore) ReadLink(from, to crypto.Hash) (uint64, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.links[memoryPair{from, to}], nil
+}
+
+func (s *MemoryStore) ReadRound(hash crypto.Hash) (*common.Round, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.readRound(hash)
+}
+
+func (s *MemoryStore) UpdateEmptyHeadRound(node crypto.Hash, number uint64, references *common.RoundLink) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	self, err := s.readRound(node)
+	if err != nil {
+		return err
+	}
+	if self.Number != number {
+		panic("round number assert error")
+	}
+	if self.References.Self != references.Self {
+		panic("self reference assert error")
+	}
+	external, err := s.readRound(references.External)
+	if err != nil {
+		return err
+	}
+	if external == nil {
+		panic("external final not exist")
+	}
+	if external.NodeId == self.NodeId {
+		panic("self references loop")
+	}
+	if len(s.snapshots[memoryRoundKey{node, number}]) != 0 {
+		panic("round not empty")
+	}
+
+	return s.update(func(txn *memoryTxn) error {
+		memorySet(txn, s.links, memoryPair{node, external.NodeId}, external.Number)
+		s.writeRound(txn, node, &common.Round{
+			Hash:       node,
+			NodeId:     node,
+			Number:     number,
+			References: references,
+		})
+		return nil
+	})
+}
+
+func (s *MemoryStore) StartNewRound(node crypto.Hash, number uint64, references *common.RoundLink, finalStart uint64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// FIXME assert only, remove in future
+	if config.Debug && number != 0 {
+		self, err := s.readRound(node)
+		if err != nil {
+			return err
+		}
+		external, err := s.readRound(references.External)
+		if err != nil {
+			return err
+		}
+		if self == nil {
+			panic("self final assert error")
+		}
+		if self.Number != number-1 {
+			panic(fmt.Errorf("self round number mismatch %d %d", self.Number, number))
+		}
+		if external == nil {
+			panic("external final not exist")
+		}
+		if external.NodeId == self.NodeId {
+			panic("self references loop")
+		}
+		old, err := s.readRound(references.Self)
+		if err != nil {
+			return err
+		}
+		if old != nil {
+			panic("self final already exist")
+		}
+		link := s.links[memoryPair{node, external.NodeId}]
+		if link > external.Number {
+			panic(fmt.Sprintf("external link backward %s=>%s %d=>%d", self.NodeId, external.NodeId, link, external.Number))
+		}
+	}
+	// assert end
+
+	return s.update(func(txn *memoryTxn) error {
+		return s.startNewRound(txn, node, number, references, finalStart)
+	})
+}
+
+func (s *MemoryStore) startNewRound(txn *memoryTxn, node crypto.Hash, number uint64, references *common.RoundLink, selfPreviousStart uint64) error {
+	if number != 0 {
+		self, err := s.readRound(node)
+		if err != nil {
+			return err
+		}
+		external, err := s.readRound(references.External)
+		if err != nil {
+			return err
+		}
+
+		memorySet(txn, s.links, memoryPair{node, external.NodeId}, external.Number)
+		self.Timestamp = selfPreviousStart
+		self.Hash = references.Self
+		s.writeRound(txn, references.Self, self)
+	}
+
+	s.writeRound(txn, node, &common.Round{
+		Hash:       node,
+		NodeId:     node,
+		Number:     number,
+		References: references,
+	})
+	return nil
+}
+
+func (s *MemoryStore) readRound(hash crypto.Hash) (*common.Round, error) {
+	val, found := s.rounds[hash]
+	if !found {
+		return nil, nil
+	}
+	round, err := common.UnmarshalRound(val)
+	if err != nil {
+		return nil, err
+	}
+	if !round.Hash.HasValue() {
+		panic(hash)
+	}
+	return round, nil
+}
+
+func (s *MemoryStore) writeRound(txn *memoryTxn, hash crypto.Hash, round *common.Round) {
+	memorySet(txn, s.rounds, hash, round.Marshal())
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+func (s *MemoryStore) ListAggregatedRoundSpaceCheckpoints(cids []crypto.Hash) (map[crypto.Hash]*common.RoundSpace, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	spaces := make(map[crypto.Hash]*common.RoundSpace)
+	for _, id := range cids {
+		checkpoint := s.spaceCheckpoints[id]
+		spaces[id] = &common.RoundSpace{
+			NodeId: id,
+			Batch:  checkpoint[0],
+			Round:  checkpoint[1],
+		}
+	}
+	return spaces, nil
+}
+
+func (s *MemoryStore) ReadNodeRoundSpacesForBatch(nodeId crypto.Hash, batch uint64) ([]*common.RoundSpace, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var spaces []*common.RoundSpace
+	queue := s.spaceQueue[memorySpaceKey{nodeId, batch}]
+	for _, round := range slices.Sorted(maps.Keys(queue)) {
+		spaces = append(spaces, &common.RoundSpace{
+			NodeId:   nodeId,
+			Batch:    batch,
+			Round:    round,
+			Duration: queue[round],
+		})
+	}
+	return spaces, nil
+}
+
+func (s *MemoryStore) ReadRoundSpaceCheckpoint(nodeId crypto.Hash) (uint64, uint64, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	checkpoint := s.spaceCheckpoints[nodeId]
+	return checkpoint[0], checkpoint[1], nil
+}
+
+func (s *MemoryStore) WriteRoundSpaceAndState(space *common.RoundSpace) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.update(func(txn *memoryTxn) error {
+		old := s.spaceCheckpoints[space.NodeId]
+		if old[0] > space.Batch || old[1] > space.Round {
+			panic(fmt.Errorf("WriteRoundSpaceAndState(%v) => invalid round %d:%d", space, old[0], old[1]))
+		}
+
+		memorySet(txn, s.spaceCheckpoints, space.NodeId, [2]uint64{space.Batch, space.Round})
+		if space.Duration == 0 {
+			return nil
+		}
+		if space.Round == 0 {
+			panic(fmt.Errorf("WriteRoundSpaceAndState(%v) => first accepted round", space))
+		}
+
+		if space.Duration < uint64(config.CheckpointDuration) {
+			panic(fmt.Errorf("WriteRoundSpaceAndState(%v) => invalid space", space))
+		}
+		memorySetNested(txn, s.spaceQueue, memorySpaceKey{space.NodeId, space.Batch}, space.Round, space.Duration)
+		return nil
+	})
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+func (s *MemoryStore) ReadSnapshot(hash crypto.Hash) (*common.SnapshotWithTopologicalOrder, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.readSnapshotWithTopo(hash)
+}
+
+func (s *MemoryStore) readSnapshotWithTopo(hash crypto.Hash) (*common.SnapshotWithTopologicalOrder, error) {
+	topo, found := s.snapTopologies[hash]
+	if !found {
+		return nil, nil
+	}
+	key, found := s.topologies[topo]
+	if !found {
+		return nil, ErrPruned
+	}
+	snap, err := common.UnmarshalVersionedSnapshot(s.readSnapshot(key))
+	if err != nil {
+		return nil, err
+	}
+	snap.Hash = hash
+	snap.TopologicalOrder = topo
+	return snap, nil
+}
+
+func (s *MemoryStore) ReadSnapshotWithTransactionsSinceTopology(topologyOffset, count uint64) ([]*common.SnapshotWithTopologicalOrder, []*common.VersionedTransaction, error) {
+	if count > 500 {
+		return nil, nil, fmt.Errorf("count %d too large, the maximum is 500", count)
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	snapshots, err := s.readSnapshotsSinceTopology(topologyOffset, count)
+	if err != nil {
+		return nil, nil, err
+	}
+	transactions := make([]*common.VersionedTransaction, len(snapshots))
+	for i, snap := range snapshots {
+		tx, err := s.readTransaction(snap.SoleTransaction())
+		if err != nil {
+			return nil, nil, err
+		}
+		transactions[i] = tx
+	}
+	return snapshots, transactions, nil
+}
+
+func (s *MemoryStore) ReadSnapshotsSinceTopology(topologyOffset, count uint64) ([]*common.SnapshotWithTopologicalOrder, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.readSnapshotsSinceTopology(topologyOffset, count)
+}
+
+// readSnapshotsSinceTopology walks the orders one by one, the topology is
+// contiguous except the pruned snapshots
+func (s *MemoryStore) readSnapshotsSinceTopology(topologyOffset, count uint64) ([]*common.SnapshotWithTopologicalOrder, error) {
+	snapshots := make([]*common.SnapshotWithTopologicalOrder, 0)
+	if len(s.topologies) == 0 {
+		return snapshots, nil
+	}
+	last := s.readLastTopology()
+	for topo := topologyOffset; topo <= last && uint64(len(snapshots)) < count; topo++ {
+		key, found := s.topologies[topo]
+		if !found {
+			continue
+		}
+		snap, err := common.UnmarshalVersionedSnapshot(s.readSnapshot(key))
+		if err != nil {
+			return snapshots, err
+		}
+		snap.Hash = snap.PayloadHash()
+		snap.TopologicalOrder = topo
+		snapshots = append(snapshots, snap)
+	}
+	return snapshots, nil
+}
+
+func (s *MemoryStore) readLastTopology() uint64 {
+	if len(s.topologies) == 0 {
+		return 0
+	}
+	return slices.Max(slices.Collect(maps.Keys(s.topologies)))
+}
+
+func (s *MemoryStore) LastSnapshot() (*common.SnapshotWithTopologicalOrder, *common.VersionedTransaction) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	topo := s.readLastTopology()
+	snaps, err := s.readSnapshotsSinceTopology(topo, 10)
+	if err != nil {
+		panic(err)
+	}
+	if len(snaps) != 1 {
+		panic(topo)
+	}
+	tx, err := s.readTransaction(snaps[0].SoleTransaction())
+	if err != nil {
+		panic(err)
+	}
+	return snaps[0], tx
+}
+
+func (s *MemoryStore) writeTopology(txn *memoryTxn, snap *common.SnapshotWithTopologicalOrder) error {
+	if _, found := s.topologies[snap.TopologicalOrder]; found {
+		panic(snap.TopologicalOrder)
+	}
+	key := memorySnapshotKey{memoryRoundKey{snap.NodeId, snap.RoundNumber}, snap.SoleTransaction()}
+	memorySet(txn, s.topologies, snap.TopologicalOrder, key)
+	memorySet(txn, s.snapTopologies, snap.PayloadHash(), snap.TopologicalOrder)
+	return nil
+}
@@ -0,0 +1,336 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+func (s *MemoryStore) ReadTransaction(hash crypto.Hash) (*common.VersionedTransaction, string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.readTransactionAndFinalization(hash)
+}
+
+// readTransactionAndFinalization returns a nil transaction with the finalized
+// snapshot if the transaction has been pruned
+func (s *MemoryStore) readTransactionAndFinalization(hash crypto.Hash) (*common.VersionedTransaction, string, error) {
+	tx, err := s.readTransaction(hash)
+	if err != nil {
+		return tx, "", err
+	}
+	final, found := s.finalizations[hash]
+	if !found {
+		return tx, "", nil
+	}
+	return tx, final.String(), nil
+}
+
+func (s *MemoryStore) readTransaction(hash crypto.Hash) (*common.VersionedTransaction, error) {
+	val, found := s.transactions[hash]
+	if !found {
+		return nil, nil
+	}
+	return common.UnmarshalVersionedTransaction(val)
+}
+
+func (s *MemoryStore) WriteTransaction(ver *common.VersionedTransaction) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// FIXME assert kind checks, not needed at all
+	if config.Debug {
+		txHash := ver.PayloadHash()
+		for _, in := range ver.Inputs {
+			switch {
+			case len(in.Genesis) > 0:
+			case in.Deposit != nil:
+				lock, found := s.deposits[in.Deposit.UniqueKey()]
+				if !found || lock != txHash {
+					panic(fmt.Errorf("deposit locked for transaction %s", lock))
+				}
+			case in.Mint != nil:
+				dist, err := s.readMintInput(in.Mint)
+				if err != nil || dist == nil {
+					panic(fmt.Errorf("mint check error %v", err))
+				}
+				if dist.Transaction != txHash || dist.Amount.Cmp(in.Mint.Amount) != 0 {
+					panic(fmt.Errorf("mint locked for transaction %s", dist.Transaction.String()))
+				}
+			default:
+				out, err := s.readUTXOLock(in.Hash, in.Index)
+				if err != nil || out == nil {
+					panic(fmt.Errorf("UTXO check error %v %s:%d=>%s", err, in.Hash.String(), in.Index, txHash.String()))
+				}
+				if out.LockHash != txHash {
+					panic(fmt.Errorf("utxo %s:%d locked for transaction %s instead of %s", out.Hash, out.Index, out.LockHash, txHash))
+				}
+			}
+		}
+	}
+	// assert end
+
+	return s.update(func(txn *memoryTxn) error {
+		return s.writeTransaction(txn, ver)
+	})
+}
+
+func (s *MemoryStore) writeTransaction(txn *memoryTxn, ver *common.VersionedTransaction) error {
+	hash := ver.PayloadHash()
+	if _, found := s.transactions[hash]; found {
+		return nil
+	}
+
+	if d := ver.Inputs[0].Deposit; d != nil {
+		err := s.verifyAssetInfo(ver.Asset, d.Asset())
+		if err != nil {
+			return err
+		}
+	}
+
+	memorySet(txn, s.transactions, hash, ver.Marshal())
+	return nil
+}
+
+func (s *MemoryStore) pruneTransaction(txn *memoryTxn, hash crypto.Hash) error {
+	if _, found := s.finalizations[hash]; found {
+		return fmt.Errorf("prune finalized transaction %s", hash.String())
+	}
+	memoryDelete(txn, s.transactions, hash)
+	return nil
+}
+
+func (s *MemoryStore) finalizeTransaction(txn *memoryTxn, ver *common.VersionedTransaction, snap *common.SnapshotWithTopologicalOrder) error {
+	hash := ver.PayloadHash()
+	if _, found := s.finalizations[hash]; found {
+		return nil
+	}
+	memorySet(txn, s.finalizations, hash, snap.PayloadHash())
+
+	if d := ver.Inputs[0].Deposit; d != nil {
+		err := s.writeAssetInfo(txn, ver.Asset, d.Asset())
+		if err != nil {
+			return err
+		}
+	}
+
+	genesis := len(ver.Inputs[0].Genesis) > 0
+	for _, utxo := range ver.UnspentOutputs() {
+		err := s.writeUTXO(txn, utxo, ver, snap.Timestamp, genesis)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := s.writeInscriptionOperation(txn, ver, snap.Timestamp)
+	if err != nil {
+		return err
+	}
+
+	return s.writeTotalInAsset(txn, ver)
+}
+
+func (s *MemoryStore) writeUTXO(txn *memoryTxn, utxo *common.UTXOWithLock, ver *common.VersionedTransaction, timestamp uint64, genesis bool) error {
+	for _, k := range utxo.Keys {
+		err := s.lockGhostKey(txn, k, utxo.Hash, true)
+		if err != nil {
+			return err
+		}
+	}
+	memorySet(txn, s.utxos, memoryUTXOKey{utxo.Hash, utxo.Index}, utxo.Marshal())
+
+	var signer, payee crypto.Key
+	if len(ver.Extra) >= len(signer) {
+		copy(signer[:], ver.Extra)
+		copy(payee[:], ver.Extra[len(signer):])
+	}
+	switch utxo.Type {
+	case common.OutputTypeNodePledge:
+		return s.writeNodePledge(txn, signer, payee, utxo.Hash, timestamp)
+	case common.OutputTypeNodeCancel:
+		return s.writeNodeCancel(txn, signer, payee, utxo.Hash, timestamp)
+	case common.OutputTypeNodeAccept:
+		return s.writeNodeAccept(txn, signer, payee, utxo.Hash, timestamp, genesis)
+	case common.OutputTypeNodeRemove:
+		return s.writeNodeRemove(txn, signer, payee, utxo.Hash, timestamp)
+	case common.OutputTypeCustodianUpdateNodes:
+		return s.writeCustodianNodes(txn, timestamp, utxo, ver.Extra, genesis)
+	case common.OutputTypeWithdrawalClaim:
+		return s.writeWithdrawalClaim(txn, ver.References[0], ver.PayloadHash())
+	}
+
+	return nil
+}
+
+func (s *MemoryStore) ReadUTXOKeys(hash crypto.Hash, index uint) (*common.UTXOKeys, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	utxo, err := s.readUTXOLock(hash, index)
+	if err != nil || utxo == nil {
+		return nil, err
+	}
+	return &common.UTXOKeys{
+		Mask:   utxo.Mask,
+		Keys:   utxo.Keys,
+		Script: utxo.Script,
+	}, nil
+}
+
+func (s *MemoryStore) ReadUTXOLock(hash crypto.Hash, index uint) (*common.UTXOWithLock, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.readUTXOLock(hash, index)
+}
+
+func (s *MemoryStore) readUTXOLock(hash crypto.Hash, index uint) (*common.UTXOWithLock, error) {
+	val, found := s.utxos[memoryUTXOKey{hash, index}]
+	if !found {
+		return nil, nil
+	}
+	return common.UnmarshalUTXO(val)
+}
+
+func (s *MemoryStore) LockUTXOs(inputs []*common.Input, tx crypto.Hash, fork bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.update(func(txn *memoryTxn) error {
+		for _, in := range inputs {
+			err := s.lockUTXO(txn, in.Hash, in.Index, tx, fork)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *MemoryStore) lockUTXO(txn *memoryTxn, hash crypto.Hash, index uint, tx crypto.Hash, fork bool) error {
+	out, err := s.readUTXOLock(hash, index)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return fmt.Errorf("utxo %s:%d not found", hash, index)
+	}
+
+	if out.LockHash.HasValue() && out.LockHash != tx {
+		if !fork {
+			return fmt.Errorf("utxo locked for transaction %s", out.LockHash)
+		}
+		err := s.pruneTransaction(txn, out.LockHash)
+		if err != nil {
+			return err
+		}
+	}
+	out.LockHash = tx
+	memorySet(txn, s.utxos, memoryUTXOKey{hash, index}, out.Marshal())
+	return nil
+}
+
+func (s *MemoryStore) ReadGhostKeyLock(key crypto.Key) (*crypto.Hash, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	by, found := s.ghosts[key]
+	if !found {
+		return nil, nil
+	}
+	return &by, nil
+}
+
+func (s *MemoryStore) LockGhostKeys(keys []*crypto.Key, tx crypto.Hash, fork bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.update(func(txn *memoryTxn) error {
+		filter := make(map[crypto.Key]bool)
+		for _, ghost := range keys {
+			if filter[*ghost] {
+				return fmt.Errorf("duplicated ghost key %s", ghost.String())
+			}
+			filter[*ghost] = true
+			err := s.lockGhostKey(txn, ghost, tx, fork)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *MemoryStore) lockGhostKey(txn *memoryTxn, ghost *crypto.Key, tx crypto.Hash, fork bool) error {
+	by, found := s.ghosts[*ghost]
+	if !found {
+		memorySet(txn, s.ghosts, *ghost, tx)
+		return nil
+	}
+	if !by.HasValue() {
+		return fmt.Errorf("ghost key %s malformed lock %x", ghost.String(), by[:])
+	}
+	if fork && ghostKeyForkAllowed(tx) {
+		return nil
+	}
+	if by != tx {
+		return fmt.Errorf("ghost key %s locked for transaction %s", ghost.String(), by.String())
+	}
+	return nil
+}
+
+func (s *MemoryStore) ReadDepositLock(deposit *common.DepositData) (crypto.Hash, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.deposits[deposit.UniqueKey()], nil
+}
+
+func (s *MemoryStore) LockDepositInput(deposit *common.DepositData, tx crypto.Hash, fork bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.update(func(txn *memoryTxn) error {
+		key := deposit.UniqueKey()
+		lock, found := s.deposits[key]
+		if !found || lock == tx {
+			memorySet(txn, s.deposits, key, tx)
+			return nil
+		}
+		if !fork {
+			return fmt.Errorf("deposit locked for transaction %s", lock)
+		}
+		err := s.pruneTransaction(txn, lock)
+		if err != nil {
+			return err
+		}
+		memorySet(txn, s.deposits, key, tx)
+		return nil
+	})
+}
+
+func (s *MemoryStore) ReadWithdrawalClaim(hash crypto.Hash) (*common.VersionedTransaction, string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	claim, found := s.withdrawals[hash]
+	if !found {
+		return nil, "", nil
+	}
+	return s.readTransactionAndFinalization(claim)
+}
+
+func (s *MemoryStore) writeWithdrawalClaim(txn *memoryTxn, hash, claim crypto.Hash) error {
+	tx, snap, err := s.readTransactionAndFinalization(hash)
+	if err != nil {
+		return err
+	}
+	if tx == nil || len(snap) != 64 {
+		panic(claim.String())
+	}
+	memorySet(txn, s.withdrawals, hash, claim)
+	return nil
+}
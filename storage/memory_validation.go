@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+func (s *MemoryStore) ValidateGraphEntries(networkId crypto.Hash, depth uint64) (int, int, error) {
+	var total, invalid int
+	for _, n := range s.ReadAllNodes(uint64(time.Now().UnixNano()), false) {
+		nt, ni, err := s.validateSnapshotEntriesForNode(n.IdForNetwork(networkId), depth)
+		total, invalid = total+nt, invalid+ni
+		if err != nil {
+			return total, invalid, err
+		}
+	}
+	return total, invalid, nil
+}
+
+func (s *MemoryStore) validateSnapshotEntriesForNode(nodeId crypto.Hash, depth uint64) (int, int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	head, err := s.readRound(nodeId)
+	if err != nil || head == nil {
+		return 0, 0, err
+	}
+
+	start := head.Number - depth
+	if head.Number < depth {
+		start = 0
+	}
+	start = max(start, s.pruneCheckpoints[nodeId])
+	invalid, total := 0, 0
+	for i := start; i < head.Number; i++ {
+		snapshots, err := s.readSnapshotsForNodeRound(nodeId, i)
+		if err != nil {
+			return total, invalid, err
+		}
+		for _, snap := range snapshots {
+			total += 1
+			final := s.finalizations[snap.SoleTransaction()]
+			if final != snap.Hash {
+				storageLogger.Printf("DUPLICATED FINALIZATION %s %s\n", snap.Hash, final)
+			}
+			ver, err := s.readTransaction(snap.SoleTransaction())
+			if err != nil {
+				return total, invalid, err
+			}
+			if ver == nil {
+				continue
+			}
+			if snap.SoleTransaction() != ver.PayloadHash() {
+				storageLogger.Printf("MALFORMED TRANSACTION %s %s %#v\n", snap.SoleTransaction(), ver.PayloadHash(), ver)
+				invalid += 1
+			}
+			topo, err := s.readSnapshotWithTopo(final)
+			if err == ErrPruned {
+				continue
+			} else if err != nil {
+				return total, invalid, err
+			}
+			if topo.SoleTransaction() != snap.SoleTransaction() {
+				storageLogger.Printf("MALFORMED FINALIZATION %s %s\n", snap.Hash, topo.Hash)
+				invalid += 1
+			}
+		}
+		_, _, hash := computeRoundHash(nodeId, i, snapshots)
+		round, err := s.readRound(hash)
+		if err != nil {
+			return total, invalid, err
+		}
+		if round == nil {
+			storageLogger.Printf("MISSING ROUND %s %d %s\n", nodeId, i, hash)
+			invalid += 1
+		} else if round.NodeId != nodeId || round.Number != i {
+			storageLogger.Printf("MALFORMED ROUND %s %d %s %s %d\n", nodeId, i, hash, round.NodeId, round.Number)
+			invalid += 1
+		}
+	}
+	return total, invalid, nil
+}
@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+func (s *MemoryStore) ReadWalletCheckpoint(wallet crypto.Hash) (uint64, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.walletStates[wallet][0], nil
+}
+
+func (s *MemoryStore) ReadWalletBalance(wallet, asset crypto.Hash) (common.Integer, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.readWalletBalance(wallet, asset), nil
+}
+
+func (s *MemoryStore) ReadWalletOutputs(wallet crypto.Hash, offset, count uint64) ([]*common.WalletOutput, error) {
+	if count > 500 {
+		return nil, fmt.Errorf("count %d too large, the maximum is 500", count)
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	outputs := make([]*common.WalletOutput, 0)
+	for seq := offset; uint64(len(outputs)) < count; seq++ {
+		key := memoryWalletKey{wallet, seq}
+		if _, found := s.walletOutputs[key]; !found {
+			break
+		}
+		out, err := s.readWalletOutput(key)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, out)
+	}
+	return outputs, nil
+}
+
+func (s *MemoryStore) ReadWalletOutputByGhostKey(wallet crypto.Hash, key crypto.Key) (*common.WalletOutput, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	seq, found := s.walletGhosts[memoryWalletGhostKey{wallet, key}]
+	if !found {
+		return nil, nil
+	}
+	return s.readWalletOutput(memoryWalletKey{wallet, seq})
+}
+
+func (s *MemoryStore) WriteWalletScan(wallet crypto.Hash, checkpoint uint64, outputs []*common.WalletOutput, spends []*common.WalletSpend) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	state := s.walletStates[wallet]
+	old, sequence := state[0], state[1]
+	if checkpoint < old {
+		panic(fmt.Errorf("malformed wallet checkpoint %s %d %d", wallet, old, checkpoint))
+	}
+
+	return s.update(func(txn *memoryTxn) error {
+		for _, out := range outputs {
+			key := memoryWalletUTXOKey{wallet, memoryUTXOKey{out.Hash, out.Index}}
+			if _, found := s.walletUTXOs[key]; found {
+				continue
+			}
+			out.Sequence = sequence
+			s.writeWalletOutput(txn, wallet, out)
+			memorySet(txn, s.walletUTXOs, key, sequence)
+			for _, k := range out.Keys {
+				memorySet(txn, s.walletGhosts, memoryWalletGhostKey{wallet, *k}, sequence)
+			}
+			s.writeWalletBalance(txn, wallet, out.Asset, out.Amount, true)
+			sequence = sequence + 1
+		}
+
+		for _, sp := range spends {
+			seq, found := s.walletUTXOs[memoryWalletUTXOKey{wallet, memoryUTXOKey{sp.Hash, sp.Index}}]
+			if !found {
+				continue
+			}
+			out, err := s.readWalletOutput(memoryWalletKey{wallet, seq})
+			if err != nil {
+				return err
+			}
+			if out.Spent.HasValue() {
+				continue
+			}
+			out.Spent = sp.Transaction
+			s.writeWalletOutput(txn, wallet, out)
+			s.writeWalletBalance(txn, wallet, out.Asset, out.Amount, false)
+		}
+
+		memorySet(txn, s.walletStates, wallet, [2]uint64{checkpoint, sequence})
+		return nil
+	})
+}
+
+func (s *MemoryStore) readWalletOutput(key memoryWalletKey) (*common.WalletOutput, error) {
+	var out common.WalletOutput
+	err := json.Unmarshal(s.walletOutputs[key], &out)
+	return &out, err
+}
+
+func (s *MemoryStore) writeWalletOutput(txn *memoryTxn, wallet crypto.Hash, out *common.WalletOutput) {
+	val, err := json.Marshal(out)
+	if err != nil {
+		panic(err)
+	}
+	memorySet(txn, s.walletOutputs, memoryWalletKey{wallet, out.Sequence}, val)
+}
+
+func (s *MemoryStore) readWalletBalance(wallet, asset crypto.Hash) common.Integer {
+	balance, found := s.walletBalances[memoryPair{wallet, asset}]
+	if !found {
+		return common.Zero
+	}
+	return common.NewIntegerFromString(balance)
+}
+
+func (s *MemoryStore) writeWalletBalance(txn *memoryTxn, wallet, asset crypto.Hash, amount common.Integer, receive bool) {
+	balance := s.readWalletBalance(wallet, asset)
+	if receive {
+		balance = balance.Add(amount)
+	} else {
+		balance = balance.Sub(amount)
+	}
+	memorySet(txn, s.walletBalances, memoryPair{wallet, asset}, balance.String())
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+func (s *MemoryStore) ReadWorkOffset(nodeId crypto.Hash) (uint64, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.workOffsets[nodeId].round, nil
+}
+
+func (s *MemoryStore) ReadSnapshotWorksForNodeRound(nodeId crypto.Hash, round uint64) ([]*common.SnapshotWork, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	works := s.workSnapshots[memoryRoundKey{nodeId, round}]
+	snapshots := make([]*common.SnapshotWork, 0)
+	for _, ts := range slices.Sorted(maps.Keys(works)) {
+		val := works[ts]
+		snapshots = append(snapshots, &common.SnapshotWork{
+			Hash:      val[0],
+			Timestamp: ts,
+			Signers:   slices.Clone(val[1:]),
+		})
+	}
+	return snapshots, nil
+}
+
+func (s *MemoryStore) ListWorkOffsets(cids []crypto.Hash) (map[crypto.Hash]uint64, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	works := make(map[crypto.Hash]uint64)
+	for _, id := range cids {
+		works[id] = s.workOffsets[id].round
+	}
+	return works, nil
+}
+
+func (s *MemoryStore) ListNodeWorks(cids []crypto.Hash, day uint32) (map[crypto.Hash][2]uint64, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	works := make(map[crypto.Hash][2]uint64)
+	for _, id := range cids {
+		key := memoryWorkDayKey{id, day}
+		works[id] = [2]uint64{s.workLeads[key], s.workSigns[key]}
+	}
+	return works, nil
+}
+
+func (s *MemoryStore) WriteRoundWork(nodeId crypto.Hash, round uint64, snapshots []*common.SnapshotWork, credit bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.update(func(txn *memoryTxn) error {
+		offset := s.workOffsets[nodeId]
+		if offset.round > round {
+			return nil
+		}
+		if round > offset.round+1 {
+			panic(fmt.Errorf("WriteRoundWork invalid offset %s %d %d", nodeId, offset.round, round))
+		}
+
+		fresh := snapshots
+		if round == offset.round {
+			osm := make(map[crypto.Hash]bool)
+			for _, h := range offset.snapshots {
+				osm[h] = true
+			}
+			fresh = make([]*common.SnapshotWork, 0)
+			filter := make(map[crypto.Hash]bool)
+			for _, ss := range snapshots {
+				if !osm[ss.Hash] {
+					fresh = append(fresh, ss)
+				}
+				filter[ss.Hash] = true
+			}
+			for id := range osm {
+				if !filter[id] {
+					panic(fmt.Errorf("WriteRoundWork missing snapshot %s %d %d %d %d %s", nodeId, round, len(snapshots), len(fresh), len(osm), id))
+				}
+			}
+		} else {
+			memoryDelete(txn, s.workSnapshots, memoryRoundKey{nodeId, offset.round})
+		}
+
+		hashes := make([]crypto.Hash, len(snapshots))
+		for i, ss := range snapshots {
+			hashes[i] = ss.Hash
+		}
+		memorySet(txn, s.workOffsets, nodeId, memoryWorkOffset{round, hashes})
+		if len(fresh) == 0 {
+			return nil
+		}
+		if len(fresh[0].Signers) == 0 || !credit {
+			return nil
+		}
+
+		day := uint32(fresh[0].Timestamp / DAY_U64)
+		wm := make(map[crypto.Hash]uint64)
+		for _, w := range fresh {
+			if w.Timestamp == 0 {
+				panic(w)
+			}
+			if uint32(w.Timestamp/DAY_U64) != day {
+				panic(w)
+			}
+			if !w.Hash.HasValue() {
+				panic(w)
+			}
+			for _, si := range w.Signers {
+				wm[si] += 1
+			}
+		}
+		if wm[nodeId] != uint64(len(fresh)) {
+			panic(nodeId)
+		}
+
+		for ni, wn := range wm {
+			if ni == nodeId {
+				continue
+			}
+			key := memoryWorkDayKey{ni, day}
+			memorySet(txn, s.workSigns, key, s.workSigns[key]+wn)
+		}
+		key := memoryWorkDayKey{nodeId, day}
+		memorySet(txn, s.workLeads, key, s.workLeads[key]+wm[nodeId])
+		return nil
+	})
+}
+
+func (s *MemoryStore) writeSnapshotWork(txn *memoryTxn, snap *common.SnapshotWithTopologicalOrder, signers []crypto.Hash) error {
+	val := append([]crypto.Hash{snap.Hash}, signers...)
+	memorySetNested(txn, s.workSnapshots, memoryRoundKey{snap.NodeId, snap.RoundNumber}, snap.Timestamp, val)
+	return nil
+}
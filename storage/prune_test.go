@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrune(t *testing.T) {
+	custom, err := config.Initialize("../config/config.example.toml")
+	require.Nil(t, err)
+
+	for name, open := range storeBackends {
+		t.Run(name, func(t *testing.T) {
+			require := require.New(t)
+			store, err := open(custom, t.TempDir())
+			require.Nil(err)
+			defer store.Close()
+			testPruneRounds(require, store)
+		})
+	}
+}
+
+func testPruneRounds(require *require.Assertions, store Store) {
+	gns, err := common.ReadGenesis("../config/genesis.json")
+	require.Nil(err)
+	rounds, snapshots, transactions, err := gns.BuildSnapshots()
+	require.Nil(err)
+	err = store.LoadGenesis(rounds, snapshots, transactions)
+	require.Nil(err)
+	nodeId := rounds[0].NodeId
+	signers := []crypto.Hash{nodeId}
+	var external crypto.Hash
+	for _, r := range rounds {
+		if r.NodeId != nodeId && r.Number == 0 {
+			external = r.Hash
+		}
+	}
+	asset, _, err := store.ReadAssetWithBalance(common.XINAssetId)
+	require.Nil(err)
+	topology := uint64(len(snapshots))
+
+	// round 1 has the deposit and its transfer, the transfer output is
+	// unspent so the whole round is kept
+	round, err := store.ReadRound(nodeId)
+	require.Nil(err)
+	d1, ds1 := writePruneTestDeposit(require, store, round, asset, "0xMIXINTODAMOON1", topology, signers)
+	t1, ts1 := writePruneTestTransfer(require, store, round, d1, topology+1, signers)
+
+	// round 2 has only a deposit spent by the transfer in round 3, so the
+	// whole round is pruned
+	round = writePruneTestRound(require, store, round, external)
+	d2, ds2 := writePruneTestDeposit(require, store, round, asset, "0xMIXINTODAMOON2", topology+2, signers)
+	round = writePruneTestRound(require, store, round, external)
+	t2, ts2 := writePruneTestTransfer(require, store, round, d2, topology+3, signers)
+	prunedRound, err := store.ReadRound(round.References.Self)
+	require.Nil(err)
+	require.Equal(uint64(2), prunedRound.Number)
+
+	checkpoint, err := store.PruneNodeRounds(nodeId, 3, 10)
+	require.Nil(err)
+	require.Equal(uint64(3), checkpoint)
+	checkpoint, err = store.ReadPruneCheckpoint(nodeId)
+	require.Nil(err)
+	require.Equal(uint64(3), checkpoint)
+
+	for _, h := range []crypto.Hash{d1, t1} {
+		ver, _, err := store.ReadTransaction(h)
+		require.Nil(err)
+		require.Equal(h, ver.PayloadHash())
+	}
+	for _, s := range []*common.SnapshotWithTopologicalOrder{ds1, ts1, ts2} {
+		snap, err := store.ReadSnapshot(s.PayloadHash())
+		require.Nil(err)
+		require.Equal(s.PayloadHash(), snap.PayloadHash())
+	}
+	utxo, err := store.ReadUTXOLock(d1, 0)
+	require.Nil(err)
+	require.Equal(t1, utxo.LockHash)
+	snaps, err := store.ReadSnapshotsForNodeRound(nodeId, 1)
+	require.Nil(err)
+	require.Len(snaps, 2)
+
+	ver, final, err := store.ReadTransaction(d2)
+	require.Nil(err)
+	require.Nil(ver)
+	require.Equal(ds2.PayloadHash().String(), final)
+	snap, err := store.ReadSnapshot(ds2.PayloadHash())
+	require.ErrorIs(err, ErrPruned)
+	require.Nil(snap)
+	utxo, err = store.ReadUTXOLock(d2, 0)
+	require.Nil(err)
+	require.Nil(utxo)
+	snaps, err = store.ReadSnapshotsForNodeRound(nodeId, 2)
+	require.Nil(err)
+	require.Len(snaps, 0)
+	prunedRound, err = store.ReadRound(round.References.Self)
+	require.Nil(err)
+	require.Nil(prunedRound)
+
+	ver, final, err = store.ReadTransaction(t2)
+	require.Nil(err)
+	require.Equal(t2, ver.PayloadHash())
+	require.Equal(ts2.PayloadHash().String(), final)
+	utxo, err = store.ReadUTXOLock(t2, 0)
+	require.Nil(err)
+	require.NotNil(utxo)
+
+	for _, s := range snapshots {
+		snap, err = store.ReadSnapshot(s.PayloadHash())
+		require.Nil(err)
+		require.NotNil(snap)
+	}
+	topos, err := store.ReadSnapshotsSinceTopology(0, 100)
+	require.Nil(err)
+	require.Len(topos, len(snapshots)+3)
+}
+
+func writePruneTestDeposit(require *require.Assertions, store Store, round *common.Round, asset *common.Asset, hash string, topology uint64, signers []crypto.Hash) (crypto.Hash, *common.SnapshotWithTopologicalOrder) {
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	mixin := common.NewAddressFromSeed(seed)
+
+	deposit := common.NewTransactionV5(common.XINAssetId)
+	deposit.AddDepositInput(&common.DepositData{
+		Chain:       common.EthereumAssetId,
+		AssetKey:    asset.AssetKey,
+		Transaction: hash,
+		Index:       0,
+		Amount:      common.NewInteger(10),
+	})
+	deposit.AddScriptOutput([]*common.Address{&mixin}, common.NewThresholdScript(1), common.NewInteger(10), seed)
+	dh := deposit.AsVersioned().PayloadHash()
+	err := store.LockDepositInput(deposit.Inputs[0].Deposit, dh, false)
+	require.Nil(err)
+	err = store.WriteTransaction(deposit.AsVersioned())
+	require.Nil(err)
+	return dh, writePruneTestSnapshot(store, round, dh, topology, signers)
+}
+
+func writePruneTestTransfer(require *require.Assertions, store Store, round *common.Round, input crypto.Hash, topology uint64, signers []crypto.Hash) (crypto.Hash, *common.SnapshotWithTopologicalOrder) {
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	mixin := common.NewAddressFromSeed(seed)
+
+	transfer := common.NewTransactionV5(common.XINAssetId)
+	transfer.AddInput(input, 0)
+	transfer.AddScriptOutput([]*common.Address{&mixin}, common.NewThresholdScript(1), common.NewInteger(10), seed)
+	th := transfer.AsVersioned().PayloadHash()
+	err := store.LockUTXOs(transfer.Inputs, th, false)
+	require.Nil(err)
+	err = store.WriteTransaction(transfer.AsVersioned())
+	require.Nil(err)
+	return th, writePruneTestSnapshot(store, round, th, topology, signers)
+}
+
+func writePruneTestRound(require *require.Assertions, store Store, round *common.Round, external crypto.Hash) *common.Round {
+	snapshots, err := store.ReadSnapshotsForNodeRound(round.NodeId, round.Number)
+	require.Nil(err)
+	start, _, hash := computeRoundHash(round.NodeId, round.Number, snapshots)
+	references := &common.RoundLink{Self: hash, External: external}
+	err = store.StartNewRound(round.NodeId, round.Number+1, references, start)
+	require.Nil(err)
+	round, err = store.ReadRound(round.NodeId)
+	require.Nil(err)
+	require.Equal(references.Self, round.References.Self)
+	return round
+}
+
+func writePruneTestSnapshot(store Store, round *common.Round, tx crypto.Hash, topology uint64, signers []crypto.Hash) *common.SnapshotWithTopologicalOrder {
+	snap := &common.Snapshot{
+		Version:      common.SnapshotVersionCommonEncoding,
+		NodeId:       round.NodeId,
+		RoundNumber:  round.Number,
+		Timestamp:    uint64(time.Now().UnixNano()),
+		Transactions: []crypto.Hash{tx},
+		References:   round.References,
+	}
+	topo := &common.SnapshotWithTopologicalOrder{
+		Snapshot:         snap,
+		TopologicalOrder: topology,
+	}
+	err := store.WriteSnapshot(topo, signers)
+	if err != nil {
+		panic(err)
+	}
+	return topo
+}
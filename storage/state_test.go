@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestState(t *testing.T) {
+	require := require.New(t)
+
+	custom, err := config.Initialize("../config/config.example.toml")
+	require.Nil(err)
+
+	store, _ := NewBadgerStore(custom, t.TempDir())
+	defer store.Close()
+
+	gns, err := common.ReadGenesis("../config/genesis.json")
+	require.Nil(err)
+	rounds, snapshots, transactions, err := gns.BuildSnapshots()
+	require.Nil(err)
+	err = store.LoadGenesis(rounds, snapshots, transactions)
+	require.Nil(err)
+	nodeId := rounds[0].NodeId
+	round, err := store.ReadRound(nodeId)
+	require.Nil(err)
+	asset, _, err := store.ReadAssetWithBalance(common.XINAssetId)
+	require.Nil(err)
+
+	seed := make([]byte, 64)
+	crypto.ReadRand(seed)
+	mixin := common.NewAddressFromSeed(seed)
+	deposit := common.NewTransactionV5(common.XINAssetId)
+	deposit.AddDepositInput(&common.DepositData{
+		Chain:       common.EthereumAssetId,
+		AssetKey:    asset.AssetKey,
+		Transaction: "0xMIXINTODAMOONTRANSACTION",
+		Index:       0,
+		Amount:      common.NewInteger(10),
+	})
+	deposit.AddScriptOutput([]*common.Address{&mixin}, common.NewThresholdScript(1), common.NewInteger(10), seed)
+	dh := deposit.AsVersioned().PayloadHash()
+	err = store.LockDepositInput(deposit.Inputs[0].Deposit, dh, false)
+	require.Nil(err)
+	err = store.WriteTransaction(deposit.AsVersioned())
+	require.Nil(err)
+	ds := writePruneTestSnapshot(store, round, dh, uint64(len(snapshots)), []crypto.Hash{nodeId})
+
+	file := t.TempDir() + "/state"
+	header, entries, err := store.ExportState(file, gns.NetworkId(), 1000)
+	require.Nil(err)
+	require.Equal(uint8(StateFileVersion), header.Version)
+	require.Equal(uint64(len(snapshots)), header.Topology)
+	require.Equal(ds.PayloadHash(), header.Snapshot)
+	require.Greater(entries, len(snapshots))
+
+	imported, _ := NewBadgerStore(custom, t.TempDir())
+	defer imported.Close()
+	staging := t.TempDir() + "/staging"
+	verified := func(stage *BadgerStore, h *StateHeader) error {
+		utxos, err := stage.ReadStateUTXOs(nil)
+		require.Nil(err)
+		require.Equal(h.UTXOs, utxos)
+		return nil
+	}
+	_, _, err = imported.ImportState(file, crypto.Blake3Hash([]byte("network")), staging, verified)
+	require.ErrorContains(err, "state network")
+	_, _, err = imported.ImportState(file, gns.NetworkId(), staging, func(*BadgerStore, *StateHeader) error {
+		return fmt.Errorf("bad state")
+	})
+	require.ErrorContains(err, "bad state")
+	snaps, err := imported.ReadSnapshotsSinceTopology(0, 10)
+	require.Nil(err)
+	require.Len(snaps, 0)
+	_, err = os.Stat(staging)
+	require.True(os.IsNotExist(err))
+	header, count, err := imported.ImportState(file, gns.NetworkId(), staging, verified)
+	require.Nil(err)
+	require.Equal(entries, count)
+	require.Equal(ds.PayloadHash(), header.Snapshot)
+	_, _, err = imported.ImportState(file, gns.NetworkId(), staging, verified)
+	require.ErrorContains(err, "non-empty store")
+
+	loaded, err := imported.CheckGenesisLoad(snapshots)
+	require.Nil(err)
+	require.True(loaded)
+	last, _ := imported.LastSnapshot()
+	require.Equal(ds.PayloadHash(), last.PayloadHash())
+	utxo, err := imported.ReadUTXOLock(dh, 0)
+	require.Nil(err)
+	require.Equal(common.NewInteger(10), utxo.Amount)
+	lock, err := imported.ReadDepositLock(deposit.Inputs[0].Deposit)
+	require.Nil(err)
+	require.Equal(dh, lock)
+	_, balance, err := imported.ReadAssetWithBalance(common.XINAssetId)
+	require.Nil(err)
+	require.Equal("365563.00000000", balance.String())
+	head, err := imported.ReadRound(nodeId)
+	require.Nil(err)
+	require.Equal(round.Number, head.Number)
+	require.Len(imported.ReadAllNodes(^uint64(0), true), len(store.ReadAllNodes(^uint64(0), true)))
+	total, invalid, err := imported.ValidateGraphEntries(gns.NetworkId(), 1000)
+	require.Nil(err)
+	require.Equal(0, invalid)
+	require.Greater(total, 0)
+
+	data, err := os.ReadFile(file)
+	require.Nil(err)
+	data[len(data)-40] = data[len(data)-40] ^ 0xff
+	err = os.WriteFile(file, data, 0644)
+	require.Nil(err)
+	corrupted, _ := NewBadgerStore(custom, t.TempDir())
+	defer corrupted.Close()
+	_, _, err = corrupted.ImportState(file, gns.NetworkId(), staging, verified)
+	require.ErrorContains(err, "checksum")
+}
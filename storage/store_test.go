@@ -0,0 +1,502 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+// every Store implementation must pass the conformance suite, add the new
+// backend constructor here
+var storeBackends = map[string]func(custom *config.Custom, dir string) (Store, error){
+	"badger": func(custom *config.Custom, dir string) (Store, error) {
+		return NewBadgerStore(custom, dir)
+	},
+	"memory": func(custom *config.Custom, dir string) (Store, error) {
+		return NewMemoryStore(custom)
+	},
+}
+
+var storeConformance = map[string]func(*require.Assertions, Store){
+	"genesis":     testStoreGenesis,
+	"utxo":        testStoreUTXOLocks,
+	"ghost":       testStoreGhostKeyLocks,
+	"deposit":     testStoreDepositLocks,
+	"cachequeue":  testStoreCacheQueue,
+	"peerbans":    testStorePeerBans,
+	"peeraddrs":   testStorePeerAddresses,
+	"wallet":      testStoreWalletScan,
+	"inscription": testStoreInscriptions,
+}
+
+func TestStoreConformance(t *testing.T) {
+	custom, err := config.Initialize("../config/config.example.toml")
+	require.Nil(t, err)
+
+	for name, open := range storeBackends {
+		for test, fn := range storeConformance {
+			t.Run(name+"/"+test, func(t *testing.T) {
+				require := require.New(t)
+				store, err := open(custom, t.TempDir())
+				require.Nil(err)
+				defer store.Close()
+				fn(require, store)
+			})
+		}
+	}
+}
+
+func testStoreGenesis(require *require.Assertions, store Store) {
+	gns, err := common.ReadGenesis("../config/genesis.json")
+	require.Nil(err)
+	rounds, snapshots, transactions := testStoreLoadGenesis(require, store)
+
+	loaded, err := store.CheckGenesisLoad(snapshots)
+	require.Nil(err)
+	require.True(loaded)
+	err = store.LoadGenesis(rounds, snapshots, transactions)
+	require.Nil(err)
+
+	nodes := store.ReadAllNodes(uint64(time.Now().UnixNano()), false)
+	require.Len(nodes, len(gns.Nodes))
+	last, tx := store.LastSnapshot()
+	require.Equal(snapshots[len(snapshots)-1].PayloadHash(), last.PayloadHash())
+	require.Equal(last.SoleTransaction(), tx.PayloadHash())
+	cs, err := store.ReadLastConsensusSnapshot()
+	require.Nil(err)
+	require.Equal(last.PayloadHash(), cs.PayloadHash())
+
+	topos, err := store.ReadSnapshotsSinceTopology(0, 100)
+	require.Nil(err)
+	require.Len(topos, len(snapshots))
+	for i, s := range snapshots {
+		require.Equal(uint64(i), topos[i].TopologicalOrder)
+		snap, err := store.ReadSnapshot(s.PayloadHash())
+		require.Nil(err)
+		require.Equal(uint64(i), snap.TopologicalOrder)
+		ver, final, err := store.ReadTransaction(s.SoleTransaction())
+		require.Nil(err)
+		require.Equal(s.SoleTransaction(), ver.PayloadHash())
+		require.Equal(s.PayloadHash().String(), final)
+	}
+
+	for _, r := range rounds {
+		head, err := store.ReadRound(r.NodeId)
+		require.Nil(err)
+		require.Equal(uint64(1), head.Number)
+		ss, err := store.ReadSnapshotsForNodeRound(r.NodeId, 0)
+		require.Nil(err)
+		require.NotEmpty(ss)
+	}
+	snap, err := store.ReadSnapshot(crypto.Blake3Hash([]byte("snapshot")))
+	require.Nil(err)
+	require.Nil(snap)
+}
+
+func testStoreUTXOLocks(require *require.Assertions, store Store) {
+	rounds, snapshots, _ := testStoreLoadGenesis(require, store)
+	round, err := store.ReadRound(rounds[0].NodeId)
+	require.Nil(err)
+
+	seed := make([]byte, 64)
+	deposit, mixin := testStoreDeposit(require, store, seed)
+	dh := deposit.PayloadHash()
+	err = store.LockDepositInput(deposit.Inputs[0].Deposit, dh, false)
+	require.Nil(err)
+	err = store.WriteTransaction(deposit)
+	require.Nil(err)
+	testStoreWriteSnapshot(require, store, round, dh, uint64(len(snapshots)))
+	utxo, err := store.ReadUTXOLock(dh, 0)
+	require.Nil(err)
+	require.False(utxo.LockHash.HasValue())
+	keys, err := store.ReadUTXOKeys(dh, 0)
+	require.Nil(err)
+	require.Equal(utxo.Keys, keys.Keys)
+
+	a := testStoreTransfer(dh, mixin, seed, 1)
+	b := testStoreTransfer(dh, mixin, seed, 2)
+	c := testStoreTransfer(dh, mixin, seed, 3)
+	err = store.LockUTXOs(a.Inputs, a.PayloadHash(), false)
+	require.Nil(err)
+	err = store.LockUTXOs(a.Inputs, a.PayloadHash(), false)
+	require.Nil(err)
+	err = store.LockUTXOs(b.Inputs, b.PayloadHash(), false)
+	require.ErrorContains(err, "utxo locked for transaction "+a.PayloadHash().String())
+	err = store.WriteTransaction(a)
+	require.Nil(err)
+
+	err = store.LockUTXOs(b.Inputs, b.PayloadHash(), true)
+	require.Nil(err)
+	utxo, err = store.ReadUTXOLock(dh, 0)
+	require.Nil(err)
+	require.Equal(b.PayloadHash(), utxo.LockHash)
+	ver, _, err := store.ReadTransaction(a.PayloadHash())
+	require.Nil(err)
+	require.Nil(ver)
+
+	err = store.WriteTransaction(b)
+	require.Nil(err)
+	testStoreWriteSnapshot(require, store, round, b.PayloadHash(), uint64(len(snapshots))+1)
+	err = store.LockUTXOs(c.Inputs, c.PayloadHash(), true)
+	require.ErrorContains(err, "prune finalized transaction "+b.PayloadHash().String())
+	utxo, err = store.ReadUTXOLock(dh, 0)
+	require.Nil(err)
+	require.Equal(b.PayloadHash(), utxo.LockHash)
+}
+
+func testStoreGhostKeyLocks(require *require.Assertions, store Store) {
+	a := crypto.Blake3Hash([]byte("a"))
+	b := crypto.Blake3Hash([]byte("b"))
+	k1 := crypto.NewKeyFromSeed(make([]byte, 64)).Public()
+	k2 := k1.DeterministicHashDerive().Public()
+
+	err := store.LockGhostKeys([]*crypto.Key{&k1, &k1}, a, false)
+	require.ErrorContains(err, "duplicated ghost key")
+	lock, err := store.ReadGhostKeyLock(k1)
+	require.Nil(err)
+	require.Nil(lock)
+
+	err = store.LockGhostKeys([]*crypto.Key{&k1, &k2}, a, false)
+	require.Nil(err)
+	err = store.LockGhostKeys([]*crypto.Key{&k1, &k2}, a, false)
+	require.Nil(err)
+	lock, err = store.ReadGhostKeyLock(k2)
+	require.Nil(err)
+	require.Equal(a, *lock)
+
+	err = store.LockGhostKeys([]*crypto.Key{&k2}, b, false)
+	require.ErrorContains(err, "locked for transaction "+a.String())
+	err = store.LockGhostKeys([]*crypto.Key{&k2}, b, true)
+	require.ErrorContains(err, "locked for transaction "+a.String())
+	lock, err = store.ReadGhostKeyLock(k2)
+	require.Nil(err)
+	require.Equal(a, *lock)
+}
+
+func testStoreWalletScan(require *require.Assertions, store Store) {
+	wallet := crypto.Blake3Hash([]byte("wallet"))
+	asset := crypto.Blake3Hash([]byte("asset"))
+	a, b := crypto.Blake3Hash([]byte("a")), crypto.Blake3Hash([]byte("b"))
+	k1 := crypto.NewKeyFromSeed(make([]byte, 64)).Public()
+	k2 := k1.DeterministicHashDerive().Public()
+	k3 := k2.DeterministicHashDerive().Public()
+
+	outputs := []*common.WalletOutput{
+		{Asset: asset, Hash: a, Index: 0, Amount: common.NewInteger(3), Keys: []*crypto.Key{&k1}},
+		{Asset: asset, Hash: a, Index: 1, Amount: common.NewInteger(5), Keys: []*crypto.Key{&k2}},
+	}
+	spends := []*common.WalletSpend{{Hash: b, Index: 0, Transaction: a}}
+	err := store.WriteWalletScan(wallet, 10, outputs, spends)
+	require.Nil(err)
+	checkpoint, err := store.ReadWalletCheckpoint(wallet)
+	require.Nil(err)
+	require.Equal(uint64(10), checkpoint)
+	balance, err := store.ReadWalletBalance(wallet, asset)
+	require.Nil(err)
+	require.Equal("8.00000000", balance.String())
+
+	outputs = []*common.WalletOutput{
+		{Asset: asset, Hash: a, Index: 1, Amount: common.NewInteger(5), Keys: []*crypto.Key{&k2}},
+		{Asset: asset, Hash: b, Index: 0, Amount: common.NewInteger(7), Keys: []*crypto.Key{&k3}},
+	}
+	spends = []*common.WalletSpend{
+		{Hash: a, Index: 0, Transaction: b},
+		{Hash: a, Index: 0, Transaction: b},
+	}
+	err = store.WriteWalletScan(wallet, 20, outputs, spends)
+	require.Nil(err)
+	balance, err = store.ReadWalletBalance(wallet, asset)
+	require.Nil(err)
+	require.Equal("12.00000000", balance.String())
+	balance, err = store.ReadWalletBalance(crypto.Blake3Hash([]byte("other")), asset)
+	require.Nil(err)
+	require.Equal("0.00000000", balance.String())
+
+	list, err := store.ReadWalletOutputs(wallet, 0, 10)
+	require.Nil(err)
+	require.Len(list, 3)
+	require.Equal(uint64(2), list[2].Sequence)
+	require.Equal(b, list[2].Hash)
+	require.Equal(b, list[0].Spent)
+	require.False(list[1].Spent.HasValue())
+	list, err = store.ReadWalletOutputs(wallet, 1, 1)
+	require.Nil(err)
+	require.Len(list, 1)
+	require.Equal(uint64(1), list[0].Sequence)
+
+	out, err := store.ReadWalletOutputByGhostKey(wallet, k1)
+	require.Nil(err)
+	require.Equal(uint64(0), out.Sequence)
+	require.Equal(b, out.Spent)
+	out, err = store.ReadWalletOutputByGhostKey(wallet, k3)
+	require.Nil(err)
+	require.Equal(b, out.Hash)
+	out, err = store.ReadWalletOutputByGhostKey(crypto.Blake3Hash([]byte("other")), k3)
+	require.Nil(err)
+	require.Nil(out)
+
+	require.Panics(func() { store.WriteWalletScan(wallet, 19, nil, nil) })
+}
+
+func testStoreDepositLocks(require *require.Assertions, store Store) {
+	testStoreLoadGenesis(require, store)
+	seed := make([]byte, 64)
+	a, _ := testStoreDeposit(require, store, seed)
+	seed[0] = 1
+	b, _ := testStoreDeposit(require, store, seed)
+	deposit := a.Inputs[0].Deposit
+
+	lock, err := store.ReadDepositLock(deposit)
+	require.Nil(err)
+	require.False(lock.HasValue())
+	err = store.LockDepositInput(deposit, a.PayloadHash(), false)
+	require.Nil(err)
+	err = store.LockDepositInput(deposit, a.PayloadHash(), false)
+	require.Nil(err)
+	err = store.LockDepositInput(deposit, b.PayloadHash(), false)
+	require.ErrorContains(err, "deposit locked for transaction "+a.PayloadHash().String())
+	err = store.WriteTransaction(a)
+	require.Nil(err)
+	err = store.LockDepositInput(deposit, b.PayloadHash(), true)
+	require.Nil(err)
+	lock, err = store.ReadDepositLock(deposit)
+	require.Nil(err)
+	require.Equal(b.PayloadHash(), lock)
+	ver, _, err := store.ReadTransaction(a.PayloadHash())
+	require.Nil(err)
+	require.Nil(ver)
+}
+
+func testStoreCacheQueue(require *require.Assertions, store Store) {
+	seed := make([]byte, 64)
+	mixin := common.NewAddressFromSeed(seed)
+	a := testStoreTransfer(crypto.Blake3Hash([]byte("a")), mixin, seed, 1)
+	b := testStoreTransfer(crypto.Blake3Hash([]byte("b")), mixin, seed, 2)
+	peer := crypto.Blake3Hash([]byte("peer"))
+
+	ver, err := store.CacheGetTransaction(a.PayloadHash())
+	require.Nil(err)
+	require.Nil(ver)
+	err = store.CachePutTransaction(a, peer)
+	require.Nil(err)
+	err = store.CachePutTransaction(b, peer)
+	require.Nil(err)
+	err = store.CachePutTransaction(a, peer)
+	require.Nil(err)
+	ver, err = store.CacheGetTransaction(a.PayloadHash())
+	require.Nil(err)
+	require.Equal(a.PayloadHash(), ver.PayloadHash())
+
+	txs, err := store.CacheRetrieveTransactions(1)
+	require.Nil(err)
+	require.Len(txs, 1)
+	require.Equal(a.PayloadHash(), txs[0].PayloadHash())
+	txs, err = store.CacheRetrieveTransactions(10)
+	require.Nil(err)
+	require.Len(txs, 1)
+	require.Equal(b.PayloadHash(), txs[0].PayloadHash())
+	txs, err = store.CacheRetrieveTransactions(10)
+	require.Nil(err)
+	require.Len(txs, 0)
+
+	ver, err = store.CacheGetTransaction(b.PayloadHash())
+	require.Nil(err)
+	require.Equal(b.PayloadHash(), ver.PayloadHash())
+	err = store.CacheRemoveTransactions([]crypto.Hash{a.PayloadHash(), b.PayloadHash()})
+	require.Nil(err)
+	ver, err = store.CacheGetTransaction(b.PayloadHash())
+	require.Nil(err)
+	require.Nil(ver)
+
+	err = store.CachePutTransaction(a, peer)
+	require.Nil(err)
+	txs, err = store.CacheRetrieveTransactions(10)
+	require.Nil(err)
+	require.Len(txs, 1)
+	require.Equal(a.PayloadHash(), txs[0].PayloadHash())
+
+	err = store.CachePutTransaction(b, peer)
+	require.Nil(err)
+	stats, err := store.CacheStats()
+	require.Nil(err)
+	require.Equal(uint64(2), stats.Transactions)
+	require.Equal(uint64(1), stats.Queued)
+	require.Equal(uint64(len(a.Marshal())+len(b.Marshal())), stats.Size)
+	require.InDelta(uint64(time.Now().UnixNano()), stats.Oldest, float64(5*time.Second))
+
+	entries, err := store.CacheListTransactions(crypto.Hash{}, 10, nil)
+	require.Nil(err)
+	require.Len(entries, 2)
+	entry, err := store.CacheGetTransactionEntry(b.PayloadHash())
+	require.Nil(err)
+	require.True(entry.Queued)
+	entry, err = store.CacheGetTransactionEntry(a.PayloadHash())
+	require.Nil(err)
+	require.False(entry.Queued)
+	entries, err = store.CacheListTransactions(entries[0].Transaction.PayloadHash(), 10, nil)
+	require.Nil(err)
+	require.Len(entries, 1)
+	entries, err = store.CacheListTransactions(crypto.Hash{}, 10, func(ct *CacheTransaction) bool {
+		return ct.Queued
+	})
+	require.Nil(err)
+	require.Len(entries, 1)
+	require.Equal(b.PayloadHash(), entries[0].Transaction.PayloadHash())
+	_, err = store.CacheListTransactions(crypto.Hash{}, 501, nil)
+	require.ErrorContains(err, "count 501 too large")
+
+	c := testStoreTransfer(crypto.Blake3Hash([]byte("b")), mixin, seed, 3)
+	conflicts, err := store.CacheReadConflicts(c)
+	require.Nil(err)
+	require.Len(conflicts, 1)
+	require.Equal(b.PayloadHash(), conflicts[0].Transaction.PayloadHash())
+	require.Equal(peer, conflicts[0].Sender)
+	err = store.CachePutTransaction(c, crypto.Hash{})
+	require.Nil(err)
+	conflicts, err = store.CacheReadConflicts(b)
+	require.Nil(err)
+	require.Len(conflicts, 1)
+	require.Equal(c.PayloadHash(), conflicts[0].Transaction.PayloadHash())
+
+	selected, err := store.CacheSelectTransactions(10, 1, nil, func(entries []*CacheTransaction) []*CacheTransaction {
+		require.Len(entries, 2)
+		return []*CacheTransaction{entries[1]}
+	})
+	require.Nil(err)
+	require.Len(selected, 1)
+	require.Equal(c.PayloadHash(), selected[0].Transaction.PayloadHash())
+	require.False(selected[0].Queued)
+	err = store.CacheRemoveTransactions([]crypto.Hash{b.PayloadHash(), c.PayloadHash()})
+	require.Nil(err)
+	conflicts, err = store.CacheReadConflicts(b)
+	require.Nil(err)
+	require.Len(conflicts, 0)
+	stats, err = store.CacheStats()
+	require.Nil(err)
+	require.Equal(uint64(1), stats.Transactions)
+	require.Equal(uint64(0), stats.Queued)
+	txs, err = store.CacheRetrieveTransactions(10)
+	require.Nil(err)
+	require.Len(txs, 0)
+
+	var skipped []crypto.Hash
+	for i := range 5 {
+		d := testStoreTransfer(crypto.Blake3Hash([]byte{byte(i)}), mixin, seed, 4)
+		err = store.CachePutTransaction(d, crypto.Hash{})
+		require.Nil(err)
+		skipped = append(skipped, d.PayloadHash())
+	}
+	err = store.CachePutTransaction(c, peer)
+	require.Nil(err)
+	selected, err = store.CacheSelectTransactions(1, 10, func(e *CacheTransaction) bool {
+		return e.Sender == peer
+	}, nil)
+	require.Nil(err)
+	require.Len(selected, 1)
+	require.Equal(c.PayloadHash(), selected[0].Transaction.PayloadHash())
+	txs, err = store.CacheRetrieveTransactions(10)
+	require.Nil(err)
+	require.Len(txs, 5)
+	for i, tx := range txs {
+		require.Equal(skipped[i], tx.PayloadHash())
+	}
+}
+
+func testStorePeerBans(require *require.Assertions, store Store) {
+	bans, err := store.ReadPeerBans()
+	require.Nil(err)
+	require.Len(bans, 0)
+
+	a, b := crypto.Blake3Hash([]byte("a")), crypto.Blake3Hash([]byte("b"))
+	require.Nil(store.WritePeerBan(a, 100))
+	require.Nil(store.WritePeerBan(b, 200))
+	require.Nil(store.WritePeerBan(a, 300))
+	bans, err = store.ReadPeerBans()
+	require.Nil(err)
+	require.Equal(map[crypto.Hash]uint64{a: 300, b: 200}, bans)
+
+	require.Nil(store.WritePeerBan(b, 0))
+	bans, err = store.ReadPeerBans()
+	require.Nil(err)
+	require.Equal(map[crypto.Hash]uint64{a: 300}, bans)
+}
+
+func testStoreLoadGenesis(require *require.Assertions, store Store) ([]*common.Round, []*common.SnapshotWithTopologicalOrder, []*common.VersionedTransaction) {
+	gns, err := common.ReadGenesis("../config/genesis.json")
+	require.Nil(err)
+	rounds, snapshots, transactions, err := gns.BuildSnapshots()
+	require.Nil(err)
+	loaded, err := store.CheckGenesisLoad(snapshots)
+	require.Nil(err)
+	require.False(loaded)
+	err = store.LoadGenesis(rounds, snapshots, transactions)
+	require.Nil(err)
+	return rounds, snapshots, transactions
+}
+
+func testStoreDeposit(require *require.Assertions, store Store, seed []byte) (*common.VersionedTransaction, common.Address) {
+	asset, _, err := store.ReadAssetWithBalance(common.XINAssetId)
+	require.Nil(err)
+	mixin := common.NewAddressFromSeed(seed)
+	tx := common.NewTransactionV5(common.XINAssetId)
+	tx.AddDepositInput(&common.DepositData{
+		Chain:       common.EthereumAssetId,
+		AssetKey:    asset.AssetKey,
+		Transaction: "0xMIXINTODAMOONTRANSACTION",
+		Index:       0,
+		Amount:      common.NewInteger(10),
+	})
+	tx.AddScriptOutput([]*common.Address{&mixin}, common.NewThresholdScript(1), common.NewInteger(10), seed)
+	tx.Extra = seed[:1]
+	return tx.AsVersioned(), mixin
+}
+
+func testStoreTransfer(input crypto.Hash, mixin common.Address, seed []byte, nonce byte) *common.VersionedTransaction {
+	mask := make([]byte, len(seed))
+	copy(mask, seed)
+	mask[len(mask)-1] = nonce
+	tx := common.NewTransactionV5(common.XINAssetId)
+	tx.AddInput(input, 0)
+	tx.AddScriptOutput([]*common.Address{&mixin}, common.NewThresholdScript(1), common.NewInteger(10), mask)
+	return tx.AsVersioned()
+}
+
+func testStoreWriteSnapshot(require *require.Assertions, store Store, round *common.Round, tx crypto.Hash, topology uint64) {
+	snap := &common.Snapshot{
+		Version:      common.SnapshotVersionCommonEncoding,
+		NodeId:       round.NodeId,
+		RoundNumber:  round.Number,
+		Timestamp:    uint64(time.Now().UnixNano()),
+		Transactions: []crypto.Hash{tx},
+		References:   round.References,
+	}
+	topo := &common.SnapshotWithTopologicalOrder{
+		Snapshot:         snap,
+		TopologicalOrder: topology,
+	}
+	err := store.WriteSnapshot(topo, []crypto.Hash{round.NodeId})
+	require.Nil(err)
+}
+
+func testStorePeerAddresses(require *require.Assertions, store Store) {
+	addresses, err := store.ReadPeerAddresses()
+	require.Nil(err)
+	require.Len(addresses, 0)
+
+	a, b := crypto.Blake3Hash([]byte("a")), crypto.Blake3Hash([]byte("b"))
+	require.Nil(store.WritePeerAddress(a, []byte("a1")))
+	require.Nil(store.WritePeerAddress(b, []byte("b1")))
+	require.Nil(store.WritePeerAddress(a, []byte("a2")))
+	addresses, err = store.ReadPeerAddresses()
+	require.Nil(err)
+	require.Equal(map[crypto.Hash][]byte{a: []byte("a2"), b: []byte("b1")}, addresses)
+
+	require.Nil(store.WritePeerAddress(a, nil))
+	addresses, err = store.ReadPeerAddresses()
+	require.Nil(err)
+	require.Equal(map[crypto.Hash][]byte{b: []byte("b1")}, addresses)
+}